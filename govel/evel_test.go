@@ -152,6 +152,40 @@ func (s *EvelTestSuite) TestPostFault() {
 	s.Equal((&event.Event).ReportingEntityID, "reportingEntityID")
 }
 
+func (s *EvelTestSuite) TestPostSyslog() {
+	type request struct {
+		Event EventSyslog `json:"event"`
+	}
+	var event *request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		event = new(request)
+		s.Equal("application/json", req.Header.Get("Content-Type"))
+		err := json.NewDecoder(req.Body).Decode(event)
+		s.NoError(err)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	s.conf2.FQDN = u.Hostname()
+	s.conf2.Port, _ = strconv.Atoi(u.Port())
+
+	evel, err := NewEvel(s.conf2, s.event, "")
+	s.NoError(err)
+
+	s.Nil(event)
+	syslog := NewSyslog("mysyslog", "myid", "Disk is full", "DISK", SourceVirtualMachine, "mysource")
+	syslog.SyslogFacility = 4
+	syslog.SyslogPri = 34
+	syslog.SyslogSev = SyslogCritical
+	syslog.SyslogProc = "su"
+	syslog.SyslogProcID = 1234
+	syslog.SyslogVer = 1
+	err = evel.PostEvent(syslog)
+	s.NoError(err)
+	s.NotNil(event)
+	s.Equal(syslog, &event.Event)
+	s.Equal((&event.Event).ReportingEntityID, "reportingEntityID")
+}
+
 func (s *EvelTestSuite) TestPostMeasurements() {
 	type request struct {
 		Event EventMeasurements `json:"event"`
@@ -66,16 +66,30 @@ func NewSchemaFromBytes(bytes []byte) (*JSONSchema, error) {
 
 // Validate the provided data with the schema
 func (schema *JSONSchema) Validate(data interface{}) error {
-	res, err := schema.inner.Validate(gojsonschema.NewGoLoader(data))
+	errs, err := schema.Errors(data)
 	if err != nil {
 		return err
 	}
-	if !res.Valid() {
+	if len(errs) > 0 {
 		log.Error("JSON is not valid:")
-		for _, err := range res.Errors() {
-			log.Error(" -- ", err.String())
+		for _, err := range errs {
+			log.Error(" -- ", err)
 		}
 		return ErrSchemaInvalid
 	}
 	return nil
 }
+
+// Errors returns the description of the schema violations of the provided data.
+// It's empty if data are valid
+func (schema *JSONSchema) Errors(data interface{}) ([]string, error) {
+	res, err := schema.inner.Validate(gojsonschema.NewGoLoader(data))
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]string, len(res.Errors()))
+	for i, err := range res.Errors() {
+		errs[i] = err.String()
+	}
+	return errs, nil
+}
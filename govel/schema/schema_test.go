@@ -85,6 +85,14 @@ func (s *JSONSchemaTestSuite) TestInvalid() {
 	data := map[string]interface{}{"root": map[string]interface{}{"A": "abc", "B": "12"}}
 	err = schema.Validate(data)
 	s.Error(err)
+	errs, err := schema.Errors(data)
+	s.NoError(err)
+	s.Len(errs, 1)
+	s.Contains(errs[0], "root.B")
+
+	errs, err = schema.Errors(map[string]interface{}{"root": map[string]interface{}{"A": "abc"}})
+	s.NoError(err)
+	s.Empty(errs)
 }
 
 func (s *JSONSchemaTestSuite) TestLoadFromFile() {
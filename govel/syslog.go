@@ -0,0 +1,81 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package govel
+
+import (
+	"time"
+)
+
+// SyslogSeverity is the severity of a syslog message
+type SyslogSeverity string
+
+// Possible values for SyslogSeverity, ordered by syslog numerical code
+const (
+	SyslogEmergency SyslogSeverity = "Emergency"
+	SyslogAlert     SyslogSeverity = "Alert"
+	SyslogCritical  SyslogSeverity = "Critical"
+	SyslogError     SyslogSeverity = "Error"
+	SyslogWarning   SyslogSeverity = "Warning"
+	SyslogNotice    SyslogSeverity = "Notice"
+	SyslogInfo      SyslogSeverity = "Info"
+	SyslogDebug     SyslogSeverity = "Debug"
+)
+
+type syslogFields struct {
+	AdditionalFields    string         `json:"additionalFields,omitempty"`
+	EventSourceHost     string         `json:"eventSourceHost,omitempty"`
+	EventSourceType     SourceType     `json:"eventSourceType"`
+	SyslogFacility      int            `json:"syslogFacility"`
+	SyslogFieldsVersion float32        `json:"syslogFieldsVersion"`
+	SyslogMsg           string         `json:"syslogMsg"`
+	SyslogPri           int            `json:"syslogPri"`
+	SyslogProc          string         `json:"syslogProc,omitempty"`
+	SyslogProcID        int64          `json:"syslogProcId,omitempty"`
+	SyslogSData         string         `json:"syslogSData,omitempty"`
+	SyslogSdID          string         `json:"syslogSdId,omitempty"`
+	SyslogSev           SyslogSeverity `json:"syslogSev,omitempty"`
+	SyslogTag           string         `json:"syslogTag"`
+	SyslogVer           int            `json:"syslogVer,omitempty"`
+}
+
+// EventSyslog is a syslog event
+type EventSyslog struct {
+	EventHeader  `json:"commonEventHeader"`
+	syslogFields `json:"syslogFields"`
+}
+
+// NewSyslog creates a new syslog event, for the message `msg` with tag `tag`
+func NewSyslog(name, id, msg, tag string, sourceType SourceType, sourceName string) *EventSyslog {
+	syslog := new(EventSyslog)
+
+	syslog.SyslogMsg = msg
+	syslog.SyslogTag = tag
+	syslog.EventSourceType = sourceType
+	syslog.SyslogFieldsVersion = 3.0
+
+	syslog.Domain = DomainSyslog
+	syslog.SourceName = sourceName
+	syslog.EventName = name
+	syslog.EventID = id
+	syslog.Version = 3.0
+	syslog.Priority = PriorityNormal
+
+	syslog.StartEpochMicrosec = time.Now().UnixNano() / 1000
+	syslog.LastEpochMicrosec = syslog.StartEpochMicrosec
+
+	return syslog
+}
@@ -17,9 +17,12 @@
 package agent
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 	"github.com/nokia/onap-vespa/ves-agent/config"
 	"github.com/nokia/onap-vespa/ves-agent/convert"
@@ -29,7 +32,11 @@ import (
 	"github.com/nokia/onap-vespa/ves-agent/metrics"
 	"github.com/nokia/onap-vespa/ves-agent/rest"
 	"github.com/nokia/onap-vespa/ves-agent/scheduler"
+	"github.com/nokia/onap-vespa/ves-agent/snmp"
+	"github.com/nokia/onap-vespa/ves-agent/syslog"
+	"github.com/nokia/onap-vespa/ves-agent/tail"
 
+	"github.com/prometheus/alertmanager/template"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -39,11 +46,31 @@ import (
 // It initializes the AlertReceiver server to receive and handle Alert event from prometheus.
 type Agent struct {
 	measSched, hbSched           *scheduler.Scheduler
+	collector                    *metrics.Collector
 	measTimer, hbTimer           *time.Timer
+	reconcileSched               *scheduler.Scheduler // Nil if reconciliation is disabled
+	reconcileTimer               *time.Timer
+	expireSched                  *scheduler.Scheduler // Nil if faults never expire
+	expireTimer                  *time.Timer
 	measIntervalCh, hbIntervalCh <-chan time.Duration
-	alertCh                      chan rest.MessageFault
+	queueCh                      chan struct{} // Notified when alerts are queued
+	queueTimer                   *time.Timer   // Retry timer of the oldest pending alert. Nil if not retrying
+	adminCh                      chan rest.MessageAdmin
+	admin                        config.AdminConfiguration
 	fm                           *convert.FaultManager
+	historySize                  int // Number of records kept in the fault history. 0 disables the history
 	alertRoute                   rest.Route
+	alertConf                    config.AlertManagerConfiguration
+	trapReceiver                 *snmp.Receiver // Nil if the SNMP trap receiver is disabled
+	logTailer                    *tail.Tailer   // Nil if no log file is tailed
+	alertForwarder               *rest.AlertForwarder
+	syslogListener               *syslog.Listener // Nil if the syslog listener is disabled
+	syslogFwd                    *syslog.Forwarder
+	syslogConf                   config.SyslogConfiguration
+	syslogCh                     chan *syslog.Message // Syslog messages received while leader
+	syslogBatch                  []*syslog.Message    // Syslog messages waiting to be posted
+	syslogTimer                  *time.Timer          // Timer posting the pending syslog messages. Nil if there is none
+	tlsConfig                    *tls.Config
 	state                        *ha.Cluster
 	namingCodes                  map[string]string
 }
@@ -59,14 +86,27 @@ func NewAgent(conf *config.VESAgentConfiguration) *Agent {
 
 	log.Info("Create measurement scheduler")
 	// Create a new Scheduler used to trigger the measurements collector
-	measSched := initMeasScheduler(conf, namingCodes, state)
+	measSched, collector := initMeasScheduler(conf, namingCodes, state)
 
 	log.Info("Create heartbeat scheduler")
 	// Create a new Scheduler used to trigger the heartbeat events
 	hbSched := initHbScheduler(&conf.Event, conf.Heartbeat.DefaultInterval, namingCodes, state)
 
 	// create a FaultManager
-	fm := convert.NewFaultManagerWithState(&conf.Event, state)
+	fm, err := convert.NewFaultManagerWithConfig(&conf.Event, &conf.Fault, state)
+	if err != nil {
+		log.Panic(err)
+	}
+	var reconcileSched *scheduler.Scheduler
+	if conf.AlertManager.Reconcile.Enabled() {
+		log.Info("Create faults reconciliation scheduler")
+		reconcileSched = initReconcileScheduler(&conf.AlertManager.Reconcile, fm, state)
+	}
+	var expireSched *scheduler.Scheduler
+	if conf.Fault.ExpirationEnabled() || conf.Logs.TimeoutsEnabled() {
+		log.Info("Create faults expiration scheduler")
+		expireSched = scheduler.NewSchedulerWithState("expiration", convert.NewSweeper(fm), conf.Fault.SweepInterval, state)
+	}
 	// declare the AlertReceiver route where the server will be listening
 	alertRoute := rest.Route{
 		Name:        "AlertReceiver",
@@ -74,14 +114,54 @@ func NewAgent(conf *config.VESAgentConfiguration) *Agent {
 		Pattern:     conf.AlertManager.Path,
 		HandlerFunc: nil,
 	}
+	var tlsConfig *tls.Config
+	if conf.AlertManager.TLS.Enabled() {
+		tlsConfig, err = rest.NewTLSConfig(conf.AlertManager.TLS.Cert, conf.AlertManager.TLS.Key, conf.AlertManager.TLS.ClientCA, conf.AlertManager.TLS.PeerCA)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	var trapReceiver *snmp.Receiver
+	if conf.SNMP.Enabled() {
+		log.Info("Create SNMP trap receiver")
+		if trapReceiver, err = snmp.NewReceiver(&conf.SNMP); err != nil {
+			log.Panic(err)
+		}
+	}
+	var logTailer *tail.Tailer
+	if conf.Logs.Enabled() {
+		log.Info("Create log files tailer")
+		if logTailer, err = tail.NewTailer(&conf.Logs); err != nil {
+			log.Panic(err)
+		}
+	}
+	var syslogListener *syslog.Listener
+	if conf.Syslog.Enabled() {
+		log.Info("Create syslog listener")
+		if syslogListener, err = syslog.NewListener(&conf.Syslog); err != nil {
+			log.Panic(err)
+		}
+	}
 
 	return &Agent{
-		measSched:   measSched,
-		hbSched:     hbSched,
-		fm:          fm,
-		alertRoute:  alertRoute,
-		state:       state,
-		namingCodes: namingCodes,
+		measSched:      measSched,
+		collector:      collector,
+		hbSched:        hbSched,
+		reconcileSched: reconcileSched,
+		expireSched:    expireSched,
+		fm:             fm,
+		historySize:    conf.Fault.HistorySize,
+		alertRoute:     alertRoute,
+		alertConf:      conf.AlertManager,
+		trapReceiver:   trapReceiver,
+		logTailer:      logTailer,
+		syslogListener: syslogListener,
+		syslogFwd:      syslog.NewForwarderWithState(&conf.Syslog, &conf.Event, namingCodes, state),
+		syslogConf:     conf.Syslog,
+		tlsConfig:      tlsConfig,
+		state:          state,
+		namingCodes:    namingCodes,
+		admin:          conf.Admin,
 	}
 }
 
@@ -93,14 +173,19 @@ func (agent *Agent) Stats() map[string]interface{} {
 	}
 }
 
-func initMeasScheduler(conf *config.VESAgentConfiguration, namingCodes map[string]string, state ha.AgentState) *scheduler.Scheduler {
+func initMeasScheduler(conf *config.VESAgentConfiguration, namingCodes map[string]string, state ha.AgentState) (*scheduler.Scheduler, *metrics.Collector) {
 	// Creates a new measurements collector
 	prom, err := metrics.NewCollectorWithState(&conf.Measurement, &conf.Event, namingCodes, state)
 	if err != nil {
 		log.Panic(err)
 	}
 	measSched := scheduler.NewSchedulerWithState("measurements", prom, conf.Measurement.DefaultInterval, state)
-	return measSched
+	measSched.SetBackfill(scheduler.Backfill{
+		Chunk:  conf.Measurement.Backfill.Chunk,
+		MaxAge: conf.Measurement.MaxBufferingDuration,
+		Delay:  conf.Measurement.Backfill.Delay,
+	})
+	return measSched, prom
 }
 
 func initHbScheduler(conf *govel.EventConfiguration, defaultInterval time.Duration, namingCodes map[string]string, state ha.AgentState) *scheduler.Scheduler {
@@ -113,6 +198,15 @@ func initHbScheduler(conf *govel.EventConfiguration, defaultInterval time.Durati
 	return hbSched
 }
 
+func initReconcileScheduler(conf *config.ReconcileConfiguration, fm *convert.FaultManager, state ha.AgentState) *scheduler.Scheduler {
+	// Creates a new reconciler with Alertmanager's active alerts
+	am, err := convert.NewAlertmanagerClient(conf)
+	if err != nil {
+		log.Panic(err)
+	}
+	return scheduler.NewSchedulerWithState("reconciliation", convert.NewReconciler(am, fm), conf.Interval, state)
+}
+
 // initNfcNamingCode extract the vnfcNamingCode from vnfcName
 func initNfcNamingCode(nfcNamingCodes []govel.NfcNamingCode) map[string]string {
 	namingCodes := make(map[string]string)
@@ -124,6 +218,16 @@ func initNfcNamingCode(nfcNamingCodes []govel.NfcNamingCode) map[string]string {
 	return namingCodes
 }
 
+// DryRunRules evaluates the metric rules of `conf` on the window from `from` to `to`, without sending
+// the measurement events. If `index` is not negative, only the rule with this index is evaluated
+func DryRunRules(conf *config.VESAgentConfiguration, index int, from, to time.Time) (*metrics.DryRunReport, error) {
+	col, err := metrics.NewDryRunCollector(&conf.Measurement, &conf.Event, initNfcNamingCode(conf.Event.NfcNamingCodes))
+	if err != nil {
+		return nil, err
+	}
+	return col.DryRun(index, from, to, conf.Measurement.DefaultInterval)
+}
+
 // StartAgent registers to heartbeat and measurement interval changed events, and triggers the events.
 // It initializes the AlertReceiver server to receive and handle Alert event from prometheus.
 func (agent *Agent) StartAgent(bind string, ves govel.VESCollectorIf) {
@@ -143,12 +247,79 @@ func (agent *Agent) listen(bind string, ves govel.VESCollectorIf) {
 	log.Info("Setup alert receiver server")
 	// Setup the AlertReceiver and subscribe to alert events
 	agent.notifyAlertEventReceived(bind)
+
+	if agent.trapReceiver != nil || agent.logTailer != nil {
+		agent.alertForwarder = rest.NewAlertForwarder(agent.state, agent.tlsConfig, agent.alertConf.Path,
+			agent.alertConf.User, agent.alertConf.Password, agent.alertConf.BearerToken)
+	}
+
+	if agent.trapReceiver != nil {
+		log.Infof("Setup SNMP trap receiver on %s", agent.trapReceiver.Addr())
+		go func() {
+			if err := agent.trapReceiver.Serve(agent.receiveAlerts(convert.OriginSNMP)); err != nil {
+				log.Errorf("SNMP trap receiver stopped: %s", err.Error())
+			}
+		}()
+	}
+
+	if agent.logTailer != nil {
+		log.Info("Setup log files tailer")
+		go func() {
+			if err := agent.logTailer.Serve(agent.receiveAlerts(convert.OriginLogs)); err != nil {
+				log.Errorf("Log files tailer stopped: %s", err.Error())
+			}
+		}()
+	}
+
+	if agent.syslogListener != nil {
+		log.Infof("Setup syslog listener on UDP %v, TCP %v", agent.syslogListener.UDPAddr(), agent.syslogListener.TCPAddr())
+		agent.syslogCh = make(chan *syslog.Message, 1024)
+		go func() {
+			if err := agent.syslogListener.Serve(agent.receiveSyslog); err != nil {
+				log.Errorf("Syslog listener stopped: %s", err.Error())
+			}
+		}()
+	}
+}
+
+// receiveSyslog hands the syslog message `msg` over to the leadership loop, for it to be
+// posted in the next batch. Only the cluster's leader forwards messages, others drop them
+func (agent *Agent) receiveSyslog(msg *syslog.Message) {
+	if !agent.state.IsLeader() {
+		return
+	}
+	select {
+	case agent.syslogCh <- msg:
+	default:
+		log.Warnf("Dropping syslog message from %s: too many messages waiting to be posted", msg.Source)
+	}
+}
+
+// receiveAlerts returns a function queuing alerts received from `origin`, other than the Alertmanager webhook,
+// for them to be processed the same way. If the local node is not the cluster's leader, alerts are forwarded
+// to the leader's webhook
+func (agent *Agent) receiveAlerts(origin convert.AlertOrigin) func([]template.Alert) error {
+	return func(alerts []template.Alert) error {
+		if !agent.state.IsLeader() {
+			return agent.alertForwarder.Forward(alerts, origin)
+		}
+		if _, err := rest.EnqueueAlerts(agent.state, agent.alertConf.Queue.MaxPending, alerts, origin); err != nil {
+			return err
+		}
+		agent.notifyQueue()
+		return nil
+	}
 }
 
 func (agent *Agent) notifyAlertEventReceived(bind string) {
 	// attach the AlertReceiver handler to the alert route managed by server
-	agent.alertCh = make(chan rest.MessageFault, 1024)
-	agent.alertRoute.HandlerFunc = rest.AlertReceiver(agent.alertCh)
+	agent.queueCh = make(chan struct{}, 1)
+	agent.alertRoute.HandlerFunc = rest.AlertReceiver(agent.state, agent.alertConf.Queue.MaxPending, agent.notifyQueue)
+	if agent.alertConf.AuthEnabled() {
+		agent.alertRoute.HandlerFunc = rest.Auth(agent.alertConf.User, agent.alertConf.Password, agent.alertConf.BearerToken, agent.alertRoute.HandlerFunc)
+	} else {
+		log.Warn("Alert receiver authentication is disabled")
+	}
 
 	routes := []rest.Route{
 		agent.alertRoute,
@@ -161,11 +332,21 @@ func (agent *Agent) notifyAlertEventReceived(bind string) {
 			}
 		})},
 	}
+	if agent.admin.Enabled() {
+		// Administration commands are executed by the leader only
+		agent.adminCh = make(chan rest.MessageAdmin, 16)
+		for _, route := range rest.AdminRoutes(agent.adminCh) {
+			route.HandlerFunc = rest.BasicAuth(agent.admin.User, agent.admin.Password, rest.ForwardToLeader(agent.state, agent.tlsConfig, route.HandlerFunc))
+			routes = append(routes, route)
+		}
+	} else {
+		log.Info("Administration API is disabled")
+	}
 
 	// create an unstarted new server to receive http POST from prometheus
 	alertHandler := rest.NewServer(routes)
 	// start server
-	go rest.StartServer(bind, alertHandler)
+	go rest.StartServer(bind, alertHandler, agent.tlsConfig)
 }
 
 func (agent *Agent) serve(ves govel.VESCollectorIf) {
@@ -179,20 +360,52 @@ func (agent *Agent) serve(ves govel.VESCollectorIf) {
 		// Setup schedulers timers
 		agent.measTimer = agent.measSched.WaitChan()
 		agent.hbTimer = agent.hbSched.WaitChan()
+		if agent.reconcileSched != nil {
+			agent.reconcileTimer = agent.reconcileSched.WaitChan()
+		}
+		if agent.expireSched != nil {
+			agent.expireTimer = agent.expireSched.WaitChan()
+		}
+		// Resume processing of the alerts queued before
+		agent.notifyQueue()
+		// Cleanup faults partially stored by a previous leader
+		if n, err := agent.state.PruneFaults(); err != nil {
+			log.Errorf("Cannot prune faults: %s", err.Error())
+		} else if n > 0 {
+			log.Warnf("Pruned %d partially stored faults", n)
+		}
 		// Run leadership steps until we loose leader state
 		for agent.leaderStep(ves) {
 		}
 		log.Info("Lost cluster leadership")
 		agent.measTimer.Stop()
 		agent.hbTimer.Stop()
+		if agent.reconcileTimer != nil {
+			agent.reconcileTimer.Stop()
+		}
+		if agent.expireTimer != nil {
+			agent.expireTimer.Stop()
+		}
+		if agent.queueTimer != nil {
+			agent.queueTimer.Stop()
+			agent.queueTimer = nil
+		}
+		if agent.syslogTimer != nil {
+			agent.syslogTimer.Stop()
+			agent.syslogTimer = nil
+		}
+		if len(agent.syslogBatch) > 0 {
+			log.Warnf("Dropping %d syslog messages", len(agent.syslogBatch))
+			agent.syslogBatch = nil
+		}
 	}
 }
 
 func (agent *Agent) followerStep() bool {
 	select {
-	case fault := <-agent.alertCh:
-		fault.Response <- errors.New("Not the leader")
-		close(fault.Response)
+	case cmd := <-agent.adminCh:
+		cmd.Response <- rest.AdminResult{Err: rest.ErrNotLeader}
+		close(cmd.Response)
 	case leader := <-agent.state.LeaderCh():
 		return leader
 	}
@@ -208,15 +421,37 @@ func (agent *Agent) leaderStep(ves govel.VESCollectorIf) bool {
 	case hbInterval := <-agent.hbIntervalCh:
 		// Heartbeat interval changed event
 		agent.handleHeartbeatIntervalChanged(hbInterval)
-	case fault := <-agent.alertCh:
-		//alert received event
-		agent.handleAlertReceived(ves, fault)
+	case <-agent.queueCh:
+		// Alerts received event. Wait for the retry timer, if any
+		if agent.queueTimer == nil {
+			agent.processQueue(ves)
+		}
+	case <-timerChan(agent.queueTimer):
+		// It's time to retry processing of the oldest pending alert
+		agent.queueTimer = nil
+		agent.processQueue(ves)
+	case msg := <-agent.syslogCh:
+		// Syslog message received
+		agent.bufferSyslog(ves, msg)
+	case <-timerChan(agent.syslogTimer):
+		// It's time to post the pending syslog messages
+		agent.syslogTimer = nil
+		agent.postSyslog(ves)
+	case cmd := <-agent.adminCh:
+		// Administration command received
+		agent.handleAdminCommand(ves, cmd)
 	case <-agent.measTimer.C:
 		// It's time to collect and send some measurements
 		agent.triggerMeasurementEvent(ves)
 	case <-agent.hbTimer.C:
 		// It's time to send the heartbeat
 		agent.triggerHeatbeatEvent(ves)
+	case <-timerChan(agent.reconcileTimer):
+		// It's time to reconcile faults with active alerts
+		agent.triggerReconciliation(ves)
+	case <-timerChan(agent.expireTimer):
+		// It's time to clear expired faults
+		agent.triggerExpiration(ves)
 	case leader := <-agent.state.LeaderCh():
 		return leader
 	}
@@ -224,76 +459,551 @@ func (agent *Agent) leaderStep(ves govel.VESCollectorIf) bool {
 }
 
 func (agent *Agent) handleMeasurementIntervalChanged(interval time.Duration) {
-	if err := agent.measSched.SetInterval(interval); err != nil {
+	if err := updateInterval(agent.measSched, &agent.measTimer, interval); err != nil {
 		log.Errorf("Cannot update measurement interval : %s", err.Error())
-		return
 	}
-	agent.measTimer.Stop()
-	agent.measTimer = agent.measSched.WaitChan()
 }
 
 func (agent *Agent) handleHeartbeatIntervalChanged(interval time.Duration) {
-	if err := agent.hbSched.SetInterval(interval); err != nil {
+	if err := updateInterval(agent.hbSched, &agent.hbTimer, interval); err != nil {
 		log.Errorf("Cannot update heartbeat interval : %s", err.Error())
+	}
+}
+
+func updateInterval(sched *scheduler.Scheduler, timer **time.Timer, interval time.Duration) error {
+	if err := sched.SetInterval(interval); err != nil {
+		return err
+	}
+	(*timer).Stop()
+	*timer = sched.WaitChan()
+	return nil
+}
+
+func (agent *Agent) handleAdminCommand(ves govel.VESCollectorIf, cmd rest.MessageAdmin) {
+	res := rest.AdminResult{}
+	switch cmd.Action {
+	case rest.AdminSchedulerStatus:
+		res.Data, res.Err = agent.schedulersStatus(cmd.Target)
+	case rest.AdminSchedulerTrigger:
+		res.Err = agent.triggerNow(ves, cmd.Target)
+	case rest.AdminSchedulerSetInterval:
+		switch cmd.Target {
+		case agent.measSched.Name():
+			res.Err = updateInterval(agent.measSched, &agent.measTimer, cmd.Interval)
+		case agent.hbSched.Name():
+			res.Err = updateInterval(agent.hbSched, &agent.hbTimer, cmd.Interval)
+		case schedName(agent.reconcileSched):
+			res.Err = updateInterval(agent.reconcileSched, &agent.reconcileTimer, cmd.Interval)
+		case schedName(agent.expireSched):
+			res.Err = updateInterval(agent.expireSched, &agent.expireTimer, cmd.Interval)
+		default:
+			res.Err = rest.ErrNotFound
+		}
+	case rest.AdminAlertStatus:
+		res.Data, res.Err = agent.alertsStatus(cmd.Target)
+	case rest.AdminFaultStatus:
+		res.Data, res.Err = agent.faultsStatus(cmd.Target)
+	case rest.AdminFaultClear:
+		res.Err = agent.clearFault(ves, cmd.Target)
+	case rest.AdminFaultResend:
+		res.Data, res.Err = agent.resendFault(ves, cmd.Target)
+	case rest.AdminFaultHistory:
+		res.Data = agent.faultHistory(cmd.Filter)
+	case rest.AdminRuleStatus:
+		res.Data = agent.rulesStatus()
+	case rest.AdminSourceStatus:
+		res.Data = agent.sourcesStatus()
+	default:
+		res.Err = fmt.Errorf("Unsupported admin action %d", cmd.Action)
+	}
+	cmd.Response <- res
+	close(cmd.Response)
+}
+
+// schedulersStatus returns the status of the scheduler named `name`,
+// or of all the schedulers if `name` is empty
+func (agent *Agent) schedulersStatus(name string) (interface{}, error) {
+	status := []rest.SchedulerStatus{}
+	scheds := []*scheduler.Scheduler{agent.measSched, agent.hbSched}
+	for _, sched := range []*scheduler.Scheduler{agent.reconcileSched, agent.expireSched} {
+		if sched != nil {
+			scheds = append(scheds, sched)
+		}
+	}
+	for _, sched := range scheds {
+		if name != "" && name != sched.Name() {
+			continue
+		}
+		st := rest.SchedulerStatus{
+			Name:            sched.Name(),
+			Interval:        sched.GetInterval().String(),
+			DefaultInterval: sched.DefaultInterval().String(),
+			NextRun:         sched.NextRun(),
+			Backfilling:     sched.Backfilling(),
+		}
+		if from, to := sched.LastAck(); !to.IsZero() {
+			st.LastAck = &rest.TimeWindow{From: from, To: to}
+		}
+		if name != "" {
+			return st, nil
+		}
+		status = append(status, st)
+	}
+	if name != "" {
+		return nil, rest.ErrNotFound
+	}
+	return status, nil
+}
+
+// triggerNow immediately executes the scheduler named `name`. If the scheduler
+// is ready, this is a regular execution. Otherwise the current state is sent,
+// without changing the scheduler's next run time
+func (agent *Agent) triggerNow(ves govel.VESCollectorIf, name string) error {
+	switch name {
+	case agent.measSched.Name():
+		return triggerScheduler(agent.measSched, agent.measSched.StepNow, &agent.measTimer, postMeasurements(ves))
+	case agent.hbSched.Name():
+		return triggerScheduler(agent.hbSched, agent.hbSched.StepNow, &agent.hbTimer, postHeartbeat(ves))
+	case schedName(agent.reconcileSched):
+		return triggerScheduler(agent.reconcileSched, agent.reconcileSched.StepNow, &agent.reconcileTimer, agent.postAlerts(ves, convert.FaultReconcile))
+	case schedName(agent.expireSched):
+		return triggerScheduler(agent.expireSched, agent.expireSched.StepNow, &agent.expireTimer, agent.postAlerts(ves, convert.FaultExpire))
+	}
+	return rest.ErrNotFound
+}
+
+// schedName returns the name of an optional scheduler, or an empty string if it is disabled (nil)
+func schedName(sched *scheduler.Scheduler) string {
+	if sched == nil {
+		return ""
+	}
+	return sched.Name()
+}
+
+// timerChan returns the channel of `timer`, or nil if `timer` is nil
+func timerChan(timer *time.Timer) <-chan time.Time {
+	if timer == nil {
+		return nil
+	}
+	return timer.C
+}
+
+// alertsStatus returns the processing status of the queued alert with ID `id`,
+// or of all the known queued alerts if `id` is empty
+func (agent *Agent) alertsStatus(id string) (interface{}, error) {
+	if id == "" {
+		alerts := agent.state.GetQueuedAlerts()
+		status := make([]rest.QueuedAlertStatus, 0, len(alerts))
+		for _, queued := range alerts {
+			status = append(status, queuedAlertStatus(queued))
+		}
+		return status, nil
+	}
+	idx, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, rest.ErrNotFound
+	}
+	queued, ok := agent.state.GetQueuedAlert(idx)
+	if !ok {
+		return nil, rest.ErrNotFound
+	}
+	return queuedAlertStatus(queued), nil
+}
+
+func queuedAlertStatus(queued convert.QueuedAlert) rest.QueuedAlertStatus {
+	status := rest.QueuedAlertStatus{
+		ID:          queued.ID,
+		Status:      string(queued.Status),
+		AlertStatus: queued.Alert.Status,
+		Labels:      queued.Alert.Labels,
+		Received:    time.Unix(queued.Received, 0),
+		Attempts:    queued.Attempts,
+		Error:       queued.Error,
+	}
+	if queued.LastAttempt != 0 {
+		last := time.Unix(queued.LastAttempt, 0)
+		status.LastAttempt = &last
+	}
+	return status
+}
+
+// faultIndex returns the index of the active fault `id`, which is either the index or the VES eventId of the fault
+func (agent *Agent) faultIndex(id string) (int32, error) {
+	if idx, err := strconv.ParseInt(id, 10, 32); err == nil {
+		return int32(idx), nil
+	}
+	if idx := agent.fm.GetFaultID(id); idx != 0 {
+		return idx, nil
+	}
+	return 0, rest.ErrNotFound
+}
+
+// faultsStatus returns the active fault with index or eventId `id`,
+// or all the active faults if `id` is empty
+func (agent *Agent) faultsStatus(id string) (interface{}, error) {
+	if id == "" {
+		faults := agent.fm.Faults()
+		status := make([]rest.FaultStatus, 0, len(faults))
+		for _, fault := range faults {
+			status = append(status, faultStatus(fault))
+		}
+		return status, nil
+	}
+	idx, err := agent.faultIndex(id)
+	if err != nil {
+		return nil, err
+	}
+	fault, ok := agent.fm.Fault(idx)
+	if !ok {
+		return nil, rest.ErrNotFound
+	}
+	return faultStatus(fault), nil
+}
+
+func faultStatus(fault convert.Fault) rest.FaultStatus {
+	status := rest.FaultStatus{
+		ID:          fault.ID,
+		Name:        fault.Name,
+		EventID:     fault.EventID,
+		Sequence:    fault.Sequence,
+		StartEpoch:  fault.StartEpoch,
+		SourceName:  fault.SourceName,
+		Labels:      fault.Labels,
+		Annotations: fault.Annotations,
+		LastEvent:   fault.Event,
+	}
+	if fault.LastSeen != 0 {
+		seen := time.Unix(fault.LastSeen, 0)
+		status.LastSeen = &seen
+	}
+	if fault.LastSent != 0 {
+		sent := time.Unix(fault.LastSent, 0)
+		status.LastSent = &sent
+	}
+	return status
+}
+
+// clearFault manually clears the active fault with index or eventId `id`
+func (agent *Agent) clearFault(ves govel.VESCollectorIf, id string) error {
+	idx, err := agent.faultIndex(id)
+	if err != nil {
+		return err
+	}
+	return agent.postManualFault(ves, convert.FaultManualClear, idx)
+}
+
+// resendFault manually re-sends the last event of the active fault with index or eventId `id`,
+// and returns the updated fault
+func (agent *Agent) resendFault(ves govel.VESCollectorIf, id string) (interface{}, error) {
+	idx, err := agent.faultIndex(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := agent.postManualFault(ves, convert.FaultManualResend, idx); err != nil {
+		return nil, err
+	}
+	return agent.faultsStatus(id)
+}
+
+// postManualFault builds the event of the manual `action` on fault `id`,
+// sends it to VES collector and commits it
+func (agent *Agent) postManualFault(ves govel.VESCollectorIf, action convert.FaultAction, id int32) error {
+	build := convert.ResendFault
+	if action == convert.FaultManualClear {
+		build = convert.ClearFault
+	}
+	eventFault, commitFunc, err := build(id, agent.fm)
+	switch err {
+	case nil:
+	case convert.ErrFaultNotFound:
+		return rest.ErrNotFound
+	case convert.ErrNoFaultEvent:
+		log.Warnf("Manual operation on fault %d: %s", id, err.Error())
+		return rest.ErrConflict
+	default:
+		return err
+	}
+	record := agent.fm.ManualRecord(action, id, eventFault)
+	if err := ves.PostEvent(eventFault); err != nil {
+		log.Error("Cannot post fault: ", err.Error())
+		agent.recordFault(record, err)
+		return err
+	}
+	agent.recordFault(record, nil)
+	return commitFunc()
+}
+
+// recordFault appends `record` to the fault history, with the outcome `err` of sending its event
+func (agent *Agent) recordFault(record convert.FaultRecord, err error) {
+	if agent.historySize <= 0 {
+		return
+	}
+	record.Time = time.Now().Unix()
+	record.Outcome = convert.FaultSent
+	if err != nil {
+		record.Outcome = convert.FaultFailed
+		record.Error = err.Error()
+	}
+	if err := agent.state.AppendFaultRecord(record, agent.historySize); err != nil {
+		log.Errorf("Cannot record %s of fault %s in history: %s", record.Action, record.EventID, err.Error())
+	}
+}
+
+// faultHistory returns the fault history records matching `filter`
+func (agent *Agent) faultHistory(filter rest.HistoryFilter) []rest.FaultRecordStatus {
+	match := convert.FaultHistoryFilter{SourceName: filter.SourceName, AlertName: filter.AlertName, From: filter.From, To: filter.To}
+	history := []rest.FaultRecordStatus{}
+	for _, record := range agent.state.GetFaultHistory() {
+		if !match.Match(record) {
+			continue
+		}
+		history = append(history, rest.FaultRecordStatus{
+			ID:               record.ID,
+			Time:             time.Unix(record.Time, 0),
+			Action:           string(record.Action),
+			EventID:          record.EventID,
+			AlertName:        record.AlertName,
+			SourceName:       record.SourceName,
+			Severity:         string(record.Severity),
+			PreviousSeverity: string(record.PreviousSeverity),
+			Sequence:         record.Sequence,
+			Outcome:          string(record.Outcome),
+			Error:            record.Error,
+		})
+	}
+	return history
+}
+
+// rulesStatus returns the collection status of the metric rules
+func (agent *Agent) rulesStatus() []rest.RuleStatus {
+	status := []rest.RuleStatus{}
+	for _, rule := range agent.collector.RuleStatuses() {
+		st := rest.RuleStatus{
+			Index:         rule.Index,
+			Expr:          rule.Expr,
+			Target:        rule.Target,
+			Failures:      rule.Failures,
+			TotalFailures: rule.TotalFailures,
+			LastError:     rule.LastError,
+		}
+		if !rule.LastFailure.IsZero() {
+			lastFailure := rule.LastFailure
+			st.LastFailure = &lastFailure
+		}
+		if !rule.QuarantinedUntil.IsZero() {
+			until := rule.QuarantinedUntil
+			st.QuarantinedUntil = &until
+		}
+		status = append(status, st)
+	}
+	return status
+}
+
+// sourcesStatus returns the health of the Prometheus sources
+func (agent *Agent) sourcesStatus() []rest.SourceStatus {
+	status := []rest.SourceStatus{}
+	for _, src := range agent.collector.SourcesHealth() {
+		st := rest.SourceStatus{
+			Name:          src.Name,
+			Address:       src.Address,
+			Healthy:       src.Healthy,
+			Failures:      src.Failures,
+			TotalFailures: src.TotalFailures,
+			LastError:     src.LastError,
+		}
+		if !src.LastFailure.IsZero() {
+			lastFailure := src.LastFailure
+			st.LastFailure = &lastFailure
+		}
+		if !src.LastSuccess.IsZero() {
+			lastSuccess := src.LastSuccess
+			st.LastSuccess = &lastSuccess
+		}
+		status = append(status, st)
+	}
+	return status
+}
+
+// notifyQueue signals that queued alerts are waiting to be processed
+func (agent *Agent) notifyQueue() {
+	// Non blocking write. A pending notification is enough
+	select {
+	case agent.queueCh <- struct{}{}:
+	default:
+	}
+}
+
+// processQueue processes the oldest pending alert of the queue. Alerts are processed in order:
+// an alert which cannot be sent is retried later, before the next ones
+func (agent *Agent) processQueue(ves govel.VESCollectorIf) {
+	queued, ok := agent.state.NextQueuedAlert()
+	if !ok {
 		return
 	}
-	agent.hbTimer.Stop()
-	agent.hbTimer = agent.hbSched.WaitChan()
+	status, err := agent.processAlert(ves, queued.Alert, queued.Origin, convert.FaultClear)
+	errMsg := ""
+	if err != nil {
+		log.Errorf("Cannot process alert %d (%s): %s", queued.ID, queued.Alert.Labels["alertname"], err.Error())
+		errMsg = err.Error()
+	}
+	if err := agent.state.SetQueuedAlertResult(queued.ID, status, errMsg, time.Now().Unix(), agent.alertConf.Queue.Retain); err != nil {
+		log.Errorf("Cannot record processing result of alert %d: %s", queued.ID, err.Error())
+		status = convert.AlertPending
+	}
+	if status == convert.AlertPending {
+		// Setup a retry timer
+		agent.queueTimer = time.NewTimer(10 * time.Second)
+		return
+	}
+	if _, ok := agent.state.NextQueuedAlert(); ok {
+		// Process the next alert
+		agent.notifyQueue()
+	}
 }
 
-func (agent *Agent) handleAlertReceived(ves govel.VESCollectorIf, messageFault rest.MessageFault) {
-	status, eventFault, commitFunc := convert.AlertToFault(messageFault.Alert, agent.fm, agent.namingCodes)
+// processAlert converts the alert received from `origin` to a fault, and sends it to VES collector. A cleared fault
+// is recorded in the fault history with action `clearAction`. The returned status is AlertPending if the alert
+// should be processed again later
+func (agent *Agent) processAlert(ves govel.VESCollectorIf, alert template.Alert, origin convert.AlertOrigin, clearAction convert.FaultAction) (convert.AlertStatus, error) {
+	status, eventFault, commitFunc := convert.AlertToFaultFrom(alert, origin, agent.fm, agent.namingCodes)
+	if status == convert.Ignored {
+		log.Debugf("Alert %s ignored", alert.Labels["alertname"])
+		return convert.AlertIgnored, nil
+	}
+	if status == convert.Suppressed {
+		return convert.AlertSuppressed, nil
+	}
+	if status == convert.StateError {
+		// Likely transient, like a lost leadership: the alert is kept to be processed again
+		return convert.AlertPending, errors.New("Cannot update the fault state")
+	}
 	if status == convert.InError || status == convert.NotExist {
 		log.Warningln("!!!error in ConvertToFault process")
 		if status == convert.InError {
-			messageFault.Response <- errors.New("Cannot convert Fault to VES event")
+			return convert.AlertFailed, errors.New("Cannot convert Fault to VES event")
 		}
-	} else {
+		return convert.AlertIgnored, nil
+	}
+	record := agent.fm.AlertRecord(status, alert, eventFault, clearAction)
+	if err := ves.PostEvent(eventFault); err != nil {
+		log.Error("Cannot post fault: ", err.Error())
+		agent.recordFault(record, err)
+		return convert.AlertPending, err
+	}
+	agent.recordFault(record, nil)
+	// Commit the alert if successfully sent
+	if err := commitFunc(); err != nil {
+		return convert.AlertPending, err
+	}
+	return convert.AlertSent, nil
+}
 
-		if err := ves.PostEvent(eventFault); err != nil {
-			log.Error("Cannot post fault: ", err.Error())
-			// Send result to fault handler.
-			messageFault.Response <- err
-		} else {
-			// Commit the alert if successfully sent
-			if err := commitFunc(); err != nil {
-				messageFault.Response <- err
+func (agent *Agent) triggerReconciliation(ves govel.VESCollectorIf) {
+	_ = triggerScheduler(agent.reconcileSched, agent.reconcileSched.Step, &agent.reconcileTimer, agent.postAlerts(ves, convert.FaultReconcile))
+}
+
+func (agent *Agent) triggerExpiration(ves govel.VESCollectorIf) {
+	_ = triggerScheduler(agent.expireSched, agent.expireSched.Step, &agent.expireTimer, agent.postAlerts(ves, convert.FaultExpire))
+}
+
+// postAlerts processes the alerts returned by faults reconciliation or expiration, recording the faults
+// they clear with action `clearAction` in the fault history.
+// All the alerts are processed, and the last error, if any, is returned
+func (agent *Agent) postAlerts(ves govel.VESCollectorIf, clearAction convert.FaultAction) func(interface{}) error {
+	return func(res interface{}) error {
+		var lastErr error
+		for _, alert := range res.([]template.Alert) {
+			if _, err := agent.processAlert(ves, alert, convert.OriginAlertmanager, clearAction); err != nil {
+				log.Errorf("Cannot process alert %s: %s", alert.Labels["alertname"], err.Error())
+				lastErr = err
 			}
 		}
+		return lastErr
 	}
-	close(messageFault.Response)
 }
 
 func (agent *Agent) triggerMeasurementEvent(ves govel.VESCollectorIf) {
-	triggerScheduler(agent.measSched, &agent.measTimer, func(res interface{}) error {
-		return ves.PostBatch(res.(metrics.EventMeasurementSet).Batch())
-	})
+	_ = triggerScheduler(agent.measSched, agent.measSched.Step, &agent.measTimer, postMeasurements(ves))
 }
 
 func (agent *Agent) triggerHeatbeatEvent(ves govel.VESCollectorIf) {
-	triggerScheduler(agent.hbSched, &agent.hbTimer, func(res interface{}) error {
+	_ = triggerScheduler(agent.hbSched, agent.hbSched.Step, &agent.hbTimer, postHeartbeat(ves))
+}
+
+// bufferSyslog appends `msg` to the pending syslog messages, and posts them if the batch is full.
+// Otherwise, they are posted when the batch interval elapses
+func (agent *Agent) bufferSyslog(ves govel.VESCollectorIf, msg *syslog.Message) {
+	agent.syslogBatch = append(agent.syslogBatch, msg)
+	if agent.syslogConf.BatchSize <= 0 || len(agent.syslogBatch) >= agent.syslogConf.BatchSize {
+		if agent.syslogTimer != nil {
+			agent.syslogTimer.Stop()
+			agent.syslogTimer = nil
+		}
+		agent.postSyslog(ves)
+	} else if agent.syslogTimer == nil {
+		agent.syslogTimer = time.NewTimer(agent.syslogConf.BatchInterval)
+	}
+}
+
+// postSyslog posts the pending syslog messages. Messages are dropped if they cannot be posted
+func (agent *Agent) postSyslog(ves govel.VESCollectorIf) {
+	msgs := agent.syslogBatch
+	agent.syslogBatch = nil
+	batch, err := agent.syslogFwd.Events(msgs)
+	if err == nil {
+		if len(batch) == 1 {
+			err = ves.PostEvent(batch[0])
+		} else {
+			err = ves.PostBatch(batch)
+		}
+	}
+	if err != nil {
+		log.Errorf("Cannot post %d syslog events: %s", len(msgs), err.Error())
+	}
+}
+
+func postMeasurements(ves govel.VESCollectorIf) func(interface{}) error {
+	return func(res interface{}) error {
+		return ves.PostBatch(res.(metrics.EventMeasurementSet).Batch())
+	}
+}
+
+func postHeartbeat(ves govel.VESCollectorIf) func(interface{}) error {
+	return func(res interface{}) error {
 		return ves.PostEvent(res.(govel.Event))
-	})
+	}
 }
 
-func triggerScheduler(sched *scheduler.Scheduler, timer **time.Timer, f func(interface{}) error) {
-	res, err := sched.Step()
+// triggerScheduler executes a scheduler round using `step`, and sends the result with `f`.
+// The scheduler's timer is then set to the next execution, or to a retry delay on failure.
+// A round which is not due (forced run) does not touch the timer
+func triggerScheduler(sched *scheduler.Scheduler, step func() (interface{}, error), timer **time.Timer, f func(interface{}) error) error {
+	due := sched.Ready()
+	res, err := step()
 	if err != nil {
 		log.Errorf("Cannot trigger scheduler %s: %s", sched.Name(), err.Error())
-		// Setup a retry timer
-		*timer = time.NewTimer(10 * time.Second)
-		return
+		if due {
+			// Setup a retry timer
+			(*timer).Stop()
+			*timer = time.NewTimer(10 * time.Second)
+		}
+		return err
 	}
 	if err = f(res); err == nil {
 		// Acknowledge the scheduler interval(s) if send is successful
 		if err := sched.Ack(); err != nil {
 			log.Errorf("Cannot acknowledge scheduler execution: %s", err.Error())
-			return
+			return err
 		}
-		// Set timer to the next interval
-		*timer = sched.WaitChan()
-	} else {
+		if due {
+			// Set timer to the next interval
+			(*timer).Stop()
+			*timer = sched.WaitChan()
+		}
+	} else if due {
 		// If Post to active ves collector failed: setup a retry timer before trying to second ves collector
+		(*timer).Stop()
 		*timer = time.NewTimer(10 * time.Second)
 	}
+	return err
 }
@@ -18,12 +18,21 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 	"github.com/nokia/onap-vespa/ves-agent/config"
 	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/convert"
 	"github.com/nokia/onap-vespa/ves-agent/ha"
+	"github.com/nokia/onap-vespa/ves-agent/metrics"
 	"github.com/nokia/onap-vespa/ves-agent/rest"
+	"github.com/nokia/onap-vespa/ves-agent/syslog"
 
 	"github.com/prometheus/alertmanager/template"
 
@@ -135,11 +144,12 @@ func (suite *AgentTestSuite) SetupSuite() {
 func (suite *AgentTestSuite) TestInitMeasScheduler() {
 	state := ha.NewInMemState()
 	//Without required interval
-	measSched := initMeasScheduler(suite.vesConf, suite.namingCodes, state)
+	measSched, collector := initMeasScheduler(suite.vesConf, suite.namingCodes, state)
+	suite.NotNil(collector)
 	suite.Equal(measSched.GetInterval(), 2*time.Second)
 	//Withrequired interval
 	state.UpdateInterval("measurements", 5*time.Second)
-	measSched = initMeasScheduler(suite.vesConf, suite.namingCodes, state)
+	measSched, _ = initMeasScheduler(suite.vesConf, suite.namingCodes, state)
 	suite.Equal(measSched.GetInterval(), 5*time.Second)
 }
 
@@ -205,7 +215,7 @@ func (suite *AgentTestSuite) TestAgent() {
 	suite.Nil(agent.hbTimer)
 	suite.NotNil(agent.measIntervalCh)
 	suite.NotNil(agent.hbIntervalCh)
-	suite.NotNil(agent.alertCh)
+	suite.NotNil(agent.queueCh)
 
 	suite.cluster.On("PostEvent", mock.AnythingOfType("*govel.EventFault")).Once().Return(nil)
 	// PostEvent with heartbeat MUST be called at least once
@@ -217,7 +227,9 @@ func (suite *AgentTestSuite) TestAgent() {
 	if err != nil {
 		suite.Fail("Error in unmarshall function for alert")
 	}
-	agent.alertCh <- rest.MessageFault{Alert: alert, Response: make(chan error)}
+	_, err = agent.state.EnqueueAlerts([]template.Alert{alert}, convert.OriginAlertmanager, time.Now().Unix(), 0)
+	suite.NoError(err)
+	agent.notifyQueue()
 	agent.measTimer = agent.measSched.WaitChan()
 	agent.hbTimer = agent.hbSched.WaitChan()
 	agent.leaderStep(&suite.cluster)
@@ -226,6 +238,446 @@ func (suite *AgentTestSuite) TestAgent() {
 	suite.cluster.AssertExpectations(suite.T())
 }
 
+func (suite *AgentTestSuite) TestAdminCommands() {
+	agent := NewAgent(suite.vesConf)
+	suite.NotNil(agent)
+	<-agent.state.LeaderCh()
+	ves := &ClusterMock{}
+	agent.adminCh = make(chan rest.MessageAdmin, 1)
+	// Keep schedulers timers out of the way, so that leader steps only handle admin commands
+	agent.measTimer = time.NewTimer(time.Hour)
+	agent.hbTimer = time.NewTimer(time.Hour)
+
+	send := func(cmd rest.MessageAdmin) rest.AdminResult {
+		cmd.Response = make(chan rest.AdminResult, 1)
+		agent.adminCh <- cmd
+		suite.True(agent.leaderStep(ves))
+		agent.measTimer.Stop()
+		agent.hbTimer.Stop()
+		return <-cmd.Response
+	}
+
+	// Status of all schedulers
+	res := send(rest.MessageAdmin{Action: rest.AdminSchedulerStatus})
+	suite.NoError(res.Err)
+	suite.Len(res.Data, 2)
+	// Status of a single scheduler
+	res = send(rest.MessageAdmin{Action: rest.AdminSchedulerStatus, Target: "heartbeats"})
+	suite.NoError(res.Err)
+	status := res.Data.(rest.SchedulerStatus)
+	suite.Equal("heartbeats", status.Name)
+	suite.Equal("1s", status.Interval)
+	suite.Nil(status.LastAck)
+	// Unknown scheduler
+	res = send(rest.MessageAdmin{Action: rest.AdminSchedulerStatus, Target: "foobar"})
+	suite.Equal(rest.ErrNotFound, res.Err)
+	res = send(rest.MessageAdmin{Action: rest.AdminSchedulerTrigger, Target: "foobar"})
+	suite.Equal(rest.ErrNotFound, res.Err)
+	res = send(rest.MessageAdmin{Action: rest.AdminSchedulerSetInterval, Target: "foobar", Interval: time.Minute})
+	suite.Equal(rest.ErrNotFound, res.Err)
+
+	// Override then reset interval
+	res = send(rest.MessageAdmin{Action: rest.AdminSchedulerSetInterval, Target: "heartbeats", Interval: time.Hour})
+	suite.NoError(res.Err)
+	suite.Equal(time.Hour, agent.hbSched.GetInterval())
+	res = send(rest.MessageAdmin{Action: rest.AdminSchedulerSetInterval, Target: "heartbeats"})
+	suite.NoError(res.Err)
+	suite.Equal(time.Second, agent.hbSched.GetInterval())
+
+	// Trigger a run
+	ves.On("PostEvent", mock.AnythingOfType("*govel.HeartbeatEvent")).Once().Return(nil)
+	res = send(rest.MessageAdmin{Action: rest.AdminSchedulerTrigger, Target: "heartbeats"})
+	suite.NoError(res.Err)
+	ves.AssertExpectations(suite.T())
+
+	// Status of metric rules
+	res = send(rest.MessageAdmin{Action: rest.AdminRuleStatus})
+	suite.NoError(res.Err)
+	suite.Empty(res.Data)
+	measConf := suite.vesConf.Measurement
+	measConf.Prometheus.Rules.Metrics = []config.MetricRule{{Expr: "sessions", Target: "ConcurrentSessions"}}
+	collector, err := metrics.NewCollector(&measConf, suite.eventConf, suite.namingCodes)
+	suite.Require().NoError(err)
+	agent.collector = collector
+	res = send(rest.MessageAdmin{Action: rest.AdminRuleStatus})
+	suite.NoError(res.Err)
+	suite.Equal([]rest.RuleStatus{{Index: 0, Expr: "sessions", Target: "ConcurrentSessions"}}, res.Data)
+
+	// Health of Prometheus sources
+	res = send(rest.MessageAdmin{Action: rest.AdminSourceStatus})
+	suite.NoError(res.Err)
+	suite.Equal([]rest.SourceStatus{{Name: "default", Address: measConf.Prometheus.Address, Healthy: true}}, res.Data)
+}
+
+func (suite *AgentTestSuite) TestReconciliation() {
+	alertmanager := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write([]byte(`[{"labels": {"alertname": "NodeFailure", "severity": "critical", "id": "201", "VNFC": "ope-1"},
+			"annotations": {"service": "NodeSupervision", "description": "Node is down"}, "status": {"state": "active"}}]`))
+	}))
+	defer alertmanager.Close()
+	conf := *suite.vesConf
+	conf.AlertManager.Reconcile = config.ReconcileConfiguration{URL: alertmanager.URL, Interval: time.Hour, Timeout: time.Second}
+	agent := NewAgent(&conf)
+	suite.Require().NotNil(agent.reconcileSched)
+	<-agent.state.LeaderCh()
+	ves := &ClusterMock{}
+	agent.adminCh = make(chan rest.MessageAdmin, 1)
+	agent.measTimer = time.NewTimer(time.Hour)
+	agent.hbTimer = time.NewTimer(time.Hour)
+	agent.reconcileTimer = time.NewTimer(time.Hour)
+	defer agent.measTimer.Stop()
+	defer agent.hbTimer.Stop()
+	defer agent.reconcileTimer.Stop()
+
+	// Reconciliation scheduler is managed with the administration API
+	cmd := rest.MessageAdmin{Action: rest.AdminSchedulerStatus, Target: "reconciliation", Response: make(chan rest.AdminResult, 1)}
+	agent.adminCh <- cmd
+	suite.True(agent.leaderStep(ves))
+	res := <-cmd.Response
+	suite.NoError(res.Err)
+	suite.Equal("1h0m0s", res.Data.(rest.SchedulerStatus).Interval)
+
+	// Missed fault is raised
+	ves.On("PostEvent", mock.MatchedBy(func(evt *govel.EventFault) bool {
+		return evt.AlarmCondition == "NodeFailure" && evt.EventSeverity == govel.SeverityCritical
+	})).Once().Return(nil)
+	cmd = rest.MessageAdmin{Action: rest.AdminSchedulerTrigger, Target: "reconciliation", Response: make(chan rest.AdminResult, 1)}
+	agent.adminCh <- cmd
+	suite.True(agent.leaderStep(ves))
+	suite.NoError((<-cmd.Response).Err)
+	ves.AssertExpectations(suite.T())
+	suite.NotZero(agent.state.GetFaultInStorage("201_NodeSupervision_ope-1"))
+}
+
+func (suite *AgentTestSuite) TestAlertQueue() {
+	alerts := []template.Alert{
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeFailure", "severity": "critical", "id": "201", "VNFC": "ope-1"},
+			Annotations: map[string]string{"service": "NodeSupervision", "description": "Node is down"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeFailure", "severity": "debug", "id": "201", "VNFC": "ope-2"},
+			Annotations: map[string]string{"service": "NodeSupervision", "description": "Node is down"}},
+	}
+	conf := *suite.vesConf
+	conf.AlertManager.Queue.Retain = 10
+	agent := NewAgent(&conf)
+	suite.NotNil(agent)
+	<-agent.state.LeaderCh()
+	ves := &ClusterMock{}
+	agent.queueCh = make(chan struct{}, 1)
+	agent.adminCh = make(chan rest.MessageAdmin, 1)
+	agent.measTimer = time.NewTimer(time.Hour)
+	agent.hbTimer = time.NewTimer(time.Hour)
+	defer agent.measTimer.Stop()
+	defer agent.hbTimer.Stop()
+
+	ids, err := agent.state.EnqueueAlerts(alerts, convert.OriginAlertmanager, time.Now().Unix(), 0)
+	suite.Require().NoError(err)
+	suite.Equal([]int64{1, 2}, ids)
+
+	// VES collector is unavailable: first alert is retried later, before the second one
+	ves.On("PostEvent", mock.AnythingOfType("*govel.EventFault")).Once().Return(errors.New("Unavailable"))
+	agent.notifyQueue()
+	suite.True(agent.leaderStep(ves))
+	suite.Require().NotNil(agent.queueTimer)
+	queued, ok := agent.state.GetQueuedAlert(1)
+	suite.True(ok)
+	suite.Equal(convert.AlertPending, queued.Status)
+	suite.Equal(1, queued.Attempts)
+	suite.Equal("Unavailable", queued.Error)
+	ves.AssertExpectations(suite.T())
+
+	// New alerts do not shortcut the retry timer
+	agent.notifyQueue()
+	suite.True(agent.leaderStep(ves))
+	suite.Equal(2, agent.state.PendingAlerts())
+
+	// Retry succeeds, then second alert fails without blocking the queue
+	ves.On("PostEvent", mock.AnythingOfType("*govel.EventFault")).Once().Return(nil)
+	agent.queueTimer.Stop()
+	agent.queueTimer = time.NewTimer(0)
+	suite.True(agent.leaderStep(ves))
+	suite.Nil(agent.queueTimer)
+	suite.True(agent.leaderStep(ves))
+	suite.Nil(agent.queueTimer)
+	suite.Zero(agent.state.PendingAlerts())
+	ves.AssertExpectations(suite.T())
+
+	send := func(target string) rest.AdminResult {
+		cmd := rest.MessageAdmin{Action: rest.AdminAlertStatus, Target: target, Response: make(chan rest.AdminResult, 1)}
+		agent.adminCh <- cmd
+		suite.True(agent.leaderStep(ves))
+		return <-cmd.Response
+	}
+	res := send("")
+	suite.NoError(res.Err)
+	status := res.Data.([]rest.QueuedAlertStatus)
+	suite.Require().Len(status, 2)
+	suite.Equal("sent", status[0].Status)
+	suite.Equal(2, status[0].Attempts)
+	suite.Empty(status[0].Error)
+	suite.Equal("failed", status[1].Status)
+	suite.NotEmpty(status[1].Error)
+	suite.NotNil(status[1].LastAttempt)
+	res = send("2")
+	suite.NoError(res.Err)
+	suite.Equal(status[1], res.Data.(rest.QueuedAlertStatus))
+	suite.Equal(rest.ErrNotFound, send("3").Err)
+}
+
+// failingFaultState is a fault state which cannot store faults, like on a node losing its leadership
+type failingFaultState struct {
+	convert.FaultManagerState
+}
+
+func (state failingFaultState) StoreFaultInStorage(faultName string, faultID int32) error {
+	return errors.New("node is not the leader")
+}
+
+func (suite *AgentTestSuite) TestAlertStateError() {
+	alert := template.Alert{Status: "firing", Labels: map[string]string{"alertname": "NodeFailure", "severity": "critical", "id": "201", "VNFC": "ope-1"},
+		Annotations: map[string]string{"service": "NodeSupervision", "description": "Node is down"}}
+	agent := NewAgent(suite.vesConf)
+	suite.NotNil(agent)
+	<-agent.state.LeaderCh()
+	ves := &ClusterMock{}
+	fm := agent.fm
+	agent.fm = convert.NewFaultManagerWithState(fm.GetEventConf(), failingFaultState{fm.GetFaultState()})
+
+	// Alert is kept to be processed again, rather than failed
+	status, err := agent.processAlert(ves, alert, convert.OriginAlertmanager, convert.FaultClear)
+	suite.Error(err)
+	suite.Equal(convert.AlertPending, status)
+
+	agent.fm = fm
+	ves.On("PostEvent", mock.AnythingOfType("*govel.EventFault")).Once().Return(nil)
+	status, err = agent.processAlert(ves, alert, convert.OriginAlertmanager, convert.FaultClear)
+	suite.NoError(err)
+	suite.Equal(convert.AlertSent, status)
+	ves.AssertExpectations(suite.T())
+}
+
+func (suite *AgentTestSuite) TestReceiveAlerts() {
+	alert := template.Alert{Status: "firing", Labels: map[string]string{"alertname": "LinkDown", "severity": "major", "id": "3", "VNFC": "ope-1"},
+		Annotations: map[string]string{"service": "Network", "description": "Interface eth0 is down"}}
+	conf := *suite.vesConf
+	conf.SNMP = config.SNMPConfiguration{
+		Bind:  "127.0.0.1:0",
+		Traps: []config.TrapRule{{Name: "linkDown", RaiseOID: "1.3.6.1.6.3.1.1.5.3", Labels: map[string]string{"alertname": "LinkDown"}}},
+	}
+	conf.AlertManager.Queue.MaxPending = 1
+	conf.AlertManager.Queue.Retain = 10
+	agent := NewAgent(&conf)
+	suite.Require().NotNil(agent.trapReceiver)
+	defer agent.trapReceiver.Close()
+	<-agent.state.LeaderCh()
+	ves := &ClusterMock{}
+	agent.queueCh = make(chan struct{}, 1)
+	agent.adminCh = make(chan rest.MessageAdmin, 1)
+	agent.measTimer = time.NewTimer(time.Hour)
+	agent.hbTimer = time.NewTimer(time.Hour)
+	defer agent.measTimer.Stop()
+	defer agent.hbTimer.Stop()
+
+	// Alerts are queued and processed like the ones received from Alertmanager
+	suite.NoError(agent.receiveAlerts(convert.OriginSNMP)([]template.Alert{alert}))
+	suite.Equal(rest.ErrQueueFull, agent.receiveAlerts(convert.OriginSNMP)([]template.Alert{alert}))
+	ves.On("PostEvent", mock.AnythingOfType("*govel.EventFault")).Once().Return(nil)
+	suite.True(agent.leaderStep(ves))
+	queued := agent.state.GetQueuedAlerts()
+	suite.Require().NotEmpty(queued)
+	suite.Equal(convert.AlertSent, queued[len(queued)-1].Status)
+	suite.Equal("LinkDown", queued[len(queued)-1].Alert.Labels["alertname"])
+	suite.Equal(convert.OriginSNMP, queued[len(queued)-1].Origin)
+	ves.AssertExpectations(suite.T())
+
+	// The origin is kept with the raised fault
+	state := agent.fm.GetFaultState()
+	suite.NotEmpty(state.GetFaultsInStorage())
+	for _, id := range state.GetFaultsInStorage() {
+		suite.Equal(convert.OriginSNMP, state.GetFaultOrigin(id))
+	}
+
+	// Alerts from log files are marked as well
+	suite.NoError(agent.receiveAlerts(convert.OriginLogs)([]template.Alert{alert}))
+	queued = agent.state.GetQueuedAlerts()
+	suite.Equal(convert.OriginLogs, queued[len(queued)-1].Origin)
+}
+
+func (suite *AgentTestSuite) TestLogTailer() {
+	conf := *suite.vesConf
+	rule := config.LogRule{Name: "nodeFailure", Raise: "Node (?P<node>\\w+) is down", Labels: map[string]string{"alertname": "NodeFailure"}}
+	conf.Logs = config.LogConfiguration{Files: []config.LogFile{{Path: filepath.Join(os.TempDir(), "ves-agent-missing.log"), Rules: []config.LogRule{rule}}}}
+	agent := NewAgent(&conf)
+	suite.Require().NotNil(agent.logTailer)
+	suite.NoError(agent.logTailer.Close())
+	suite.Nil(agent.expireSched)
+
+	// Faults cleared on timeout are expired by the leader
+	rule.Timeout = time.Minute
+	conf.Logs.Files[0].Rules = []config.LogRule{rule}
+	agent = NewAgent(&conf)
+	suite.Require().NotNil(agent.logTailer)
+	suite.NoError(agent.logTailer.Close())
+	suite.NotNil(agent.expireSched)
+}
+
+func (suite *AgentTestSuite) TestSyslog() {
+	conf := *suite.vesConf
+	conf.Syslog = config.SyslogConfiguration{UDP: "127.0.0.1:0", BatchSize: 2, BatchInterval: time.Hour}
+	agent := NewAgent(&conf)
+	suite.Require().NotNil(agent.syslogListener)
+	defer agent.syslogListener.Close()
+	<-agent.state.LeaderCh()
+	ves := &ClusterMock{}
+	agent.syslogCh = make(chan *syslog.Message, 10)
+	agent.measTimer = time.NewTimer(time.Hour)
+	agent.hbTimer = time.NewTimer(time.Hour)
+	defer agent.measTimer.Stop()
+	defer agent.hbTimer.Stop()
+
+	msg, err := syslog.Parse([]byte("<11>1 - ope-1 app - - - disk is full"))
+	suite.Require().NoError(err)
+
+	// Messages are posted once the batch is full
+	agent.receiveSyslog(msg)
+	suite.True(agent.leaderStep(ves))
+	suite.Len(agent.syslogBatch, 1)
+	suite.NotNil(agent.syslogTimer)
+	ves.On("PostBatch", mock.MatchedBy(func(batch govel.Batch) bool { return len(batch) == 2 })).Once().Return(nil)
+	agent.receiveSyslog(msg)
+	suite.True(agent.leaderStep(ves))
+	suite.Empty(agent.syslogBatch)
+	suite.Nil(agent.syslogTimer)
+	ves.AssertExpectations(suite.T())
+
+	// Or when the batch interval elapses
+	ves.On("PostEvent", mock.AnythingOfType("*govel.EventSyslog")).Once().Return(errors.New("Unavailable"))
+	agent.receiveSyslog(msg)
+	suite.True(agent.leaderStep(ves))
+	agent.syslogTimer.Stop()
+	agent.syslogTimer = time.NewTimer(0)
+	suite.True(agent.leaderStep(ves))
+	suite.Empty(agent.syslogBatch)
+	suite.Nil(agent.syslogTimer)
+	ves.AssertExpectations(suite.T())
+}
+
+func (suite *AgentTestSuite) TestFaultAdmin() {
+	alert := template.Alert{Status: "firing", Labels: map[string]string{"alertname": "NodeFailure", "severity": "critical", "id": "201", "VNFC": "ope-1"},
+		Annotations: map[string]string{"service": "NodeSupervision", "description": "Node is down"}}
+	conf := *suite.vesConf
+	conf.Fault.HistorySize = 10
+	agent := NewAgent(&conf)
+	suite.NotNil(agent)
+	<-agent.state.LeaderCh()
+	ves := &ClusterMock{}
+	agent.adminCh = make(chan rest.MessageAdmin, 1)
+	agent.measTimer = time.NewTimer(time.Hour)
+	agent.hbTimer = time.NewTimer(time.Hour)
+	defer agent.measTimer.Stop()
+	defer agent.hbTimer.Stop()
+
+	ves.On("PostEvent", mock.AnythingOfType("*govel.EventFault")).Once().Return(nil)
+	status, err := agent.processAlert(ves, alert, convert.OriginAlertmanager, convert.FaultClear)
+	suite.Require().NoError(err)
+	suite.Equal(convert.AlertSent, status)
+	id := agent.state.GetFaultInStorage("201_NodeSupervision_ope-1")
+	suite.Require().NotZero(id)
+	eventID := fmt.Sprintf("fault%010d", id)
+
+	send := func(action rest.AdminAction, target string) rest.AdminResult {
+		cmd := rest.MessageAdmin{Action: action, Target: target, Response: make(chan rest.AdminResult, 1)}
+		agent.adminCh <- cmd
+		suite.True(agent.leaderStep(ves))
+		return <-cmd.Response
+	}
+	res := send(rest.AdminFaultStatus, "")
+	suite.NoError(res.Err)
+	faults := res.Data.([]rest.FaultStatus)
+	suite.Require().Len(faults, 1)
+	suite.Equal(eventID, faults[0].EventID)
+	suite.Equal("ope-1", faults[0].SourceName)
+	suite.EqualValues(2, faults[0].Sequence)
+	suite.NotNil(faults[0].LastSent)
+	suite.NotEmpty(faults[0].LastEvent)
+	res = send(rest.AdminFaultStatus, eventID)
+	suite.NoError(res.Err)
+	suite.Equal(faults[0], res.Data.(rest.FaultStatus))
+	suite.Equal(rest.ErrNotFound, send(rest.AdminFaultStatus, "0").Err)
+
+	// Resend last event, with a new sequence number
+	ves.On("PostEvent", mock.MatchedBy(func(evt *govel.EventFault) bool {
+		return evt.EventID == eventID && evt.Sequence == 2 && evt.EventSeverity == govel.SeverityCritical
+	})).Once().Return(nil)
+	res = send(rest.AdminFaultResend, eventID)
+	suite.NoError(res.Err)
+	suite.EqualValues(3, res.Data.(rest.FaultStatus).Sequence)
+	ves.AssertExpectations(suite.T())
+
+	// Failed clear keeps the fault
+	ves.On("PostEvent", mock.AnythingOfType("*govel.EventFault")).Once().Return(errors.New("Unavailable"))
+	suite.Error(send(rest.AdminFaultClear, eventID).Err)
+	suite.Equal(id, agent.state.GetFaultInStorage("201_NodeSupervision_ope-1"))
+
+	ves.On("PostEvent", mock.MatchedBy(func(evt *govel.EventFault) bool {
+		return evt.EventID == eventID && evt.Sequence == 3 && evt.EventSeverity == govel.SeverityNormal
+	})).Once().Return(nil)
+	res = send(rest.AdminFaultClear, fmt.Sprint(id))
+	suite.NoError(res.Err)
+	suite.Nil(res.Data)
+	ves.AssertExpectations(suite.T())
+	suite.Zero(agent.state.GetFaultInStorage("201_NodeSupervision_ope-1"))
+	suite.Equal(rest.ErrNotFound, send(rest.AdminFaultClear, eventID).Err)
+
+	// All the events sent for the fault are recorded in history
+	res = send(rest.AdminFaultHistory, "")
+	suite.NoError(res.Err)
+	history := res.Data.([]rest.FaultRecordStatus)
+	suite.Require().Len(history, 4)
+	for i, action := range []string{"raise", "manualResend", "manualClear", "manualClear"} {
+		suite.Equal(action, history[i].Action)
+		suite.Equal(eventID, history[i].EventID)
+		suite.Equal("NodeFailure", history[i].AlertName)
+	}
+	suite.Equal("sent", history[0].Outcome)
+	suite.Empty(history[0].PreviousSeverity)
+	suite.Equal("failed", history[2].Outcome)
+	suite.Equal("Unavailable", history[2].Error)
+	suite.Equal("NORMAL", history[3].Severity)
+	suite.Equal("CRITICAL", history[3].PreviousSeverity)
+
+	cmd := rest.MessageAdmin{Action: rest.AdminFaultHistory, Filter: rest.HistoryFilter{SourceName: "ope-2"}, Response: make(chan rest.AdminResult, 1)}
+	agent.adminCh <- cmd
+	suite.True(agent.leaderStep(ves))
+	suite.Empty((<-cmd.Response).Data)
+}
+
+func (suite *AgentTestSuite) TestFaultSweepHistory() {
+	alert := template.Alert{Status: "firing", Labels: map[string]string{"alertname": "NodeFailure", "severity": "critical", "id": "201", "VNFC": "ope-1"},
+		Annotations: map[string]string{"service": "NodeSupervision", "description": "Node is down"}}
+	conf := *suite.vesConf
+	conf.Fault.HistorySize = 10
+	agent := NewAgent(&conf)
+	suite.NotNil(agent)
+	<-agent.state.LeaderCh()
+	ves := &ClusterMock{}
+	ves.On("PostEvent", mock.AnythingOfType("*govel.EventFault")).Times(4).Return(nil)
+
+	// Faults cleared by expiration and reconciliation are recorded with their own action
+	resolved := alert
+	resolved.Status = "resolved"
+	for _, action := range []convert.FaultAction{convert.FaultExpire, convert.FaultReconcile} {
+		_, err := agent.processAlert(ves, alert, convert.OriginAlertmanager, convert.FaultClear)
+		suite.Require().NoError(err)
+		suite.NoError(agent.postAlerts(ves, action)([]template.Alert{resolved}))
+		history := agent.state.GetFaultHistory()
+		suite.Require().NotEmpty(history)
+		suite.Equal(action, history[len(history)-1].Action)
+		suite.Equal(govel.SeverityNormal, history[len(history)-1].Severity)
+	}
+	ves.AssertExpectations(suite.T())
+}
+
 func (suite *AgentTestSuite) TestStats() {
 	agent := NewAgent(suite.vesConf)
 	suite.NotNil(agent)
@@ -0,0 +1,30 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package config
+
+// AdminConfiguration parameters of the administration REST API.
+// The API is disabled if no credentials are configured
+type AdminConfiguration struct {
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+}
+
+// Enabled returns true if the administration API has credentials
+// configured, and can then be exposed
+func (cfg AdminConfiguration) Enabled() bool {
+	return cfg.User != "" && cfg.Password != ""
+}
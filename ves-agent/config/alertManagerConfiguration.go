@@ -16,10 +16,56 @@
 
 package config
 
+import "time"
+
 // AlertManagerConfiguration parameters
 type AlertManagerConfiguration struct {
-	Bind     string `yaml:"bind,omitempty"`
-	Path     string `yaml:"path,omitempty"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
+	Bind        string                 `yaml:"bind,omitempty"`
+	Path        string                 `yaml:"path,omitempty"`
+	User        string                 `yaml:"user"`
+	Password    string                 `yaml:"password"`
+	BearerToken string                 `yaml:"bearerToken,omitempty"` // Token expected in bearer authorization header
+	TLS         TLSConfiguration       `yaml:"tls,omitempty"`         // HTTPS parameters. Plain HTTP is used if not configured
+	Reconcile   ReconcileConfiguration `yaml:"reconcile,omitempty"`   // Reconciliation of faults with Alertmanager's active alerts
+	Queue       QueueConfiguration     `yaml:"queue,omitempty"`       // Queue of received alerts, waiting to be processed
+}
+
+// AuthEnabled returns true if credentials are configured,
+// and requests must then be authenticated
+func (cfg AlertManagerConfiguration) AuthEnabled() bool {
+	return (cfg.User != "" && cfg.Password != "") || cfg.BearerToken != ""
+}
+
+// ReconcileConfiguration parameters of the periodic reconciliation of faults
+// against the alerts active in Alertmanager
+type ReconcileConfiguration struct {
+	URL      string        `yaml:"url"`                // Base URL of Alertmanager, eg: http://localhost:9093. Reconciliation is disabled if empty
+	Interval time.Duration `yaml:"interval"`           // Interval between each reconciliation
+	Timeout  time.Duration `yaml:"timeout"`            // Timeout of requests to Alertmanager
+	Receiver string        `yaml:"receiver,omitempty"` // Only consider alerts routed to receivers matching this regex
+	Filters  []string      `yaml:"filters,omitempty"`  // Only consider alerts matching these Alertmanager matchers, eg: team="platform"
+}
+
+// Enabled returns true if an Alertmanager URL is configured
+func (cfg ReconcileConfiguration) Enabled() bool {
+	return cfg.URL != ""
+}
+
+// QueueConfiguration parameters of the replicated queue of received alerts
+type QueueConfiguration struct {
+	MaxPending int `yaml:"maxPending"` // Maximum number of alerts waiting to be processed. Alerts beyond are rejected. 0 means no limit
+	Retain     int `yaml:"retain"`     // Number of processed alerts whose status is kept
+}
+
+// TLSConfiguration parameters of an HTTPS server
+type TLSConfiguration struct {
+	Cert     string `yaml:"cert"`               // Path to server certificate file (PEM)
+	Key      string `yaml:"key"`                // Path to server private key file (PEM)
+	ClientCA string `yaml:"clientCA,omitempty"` // Path to CA certificates file (PEM). If set, clients must present a certificate signed by one of them
+	PeerCA   string `yaml:"peerCA,omitempty"`   // Path to CA certificates file (PEM) verifying the leader's certificate when forwarding requests. Defaults to ClientCA
+}
+
+// Enabled returns true if a server certificate and key are configured
+func (cfg TLSConfiguration) Enabled() bool {
+	return cfg.Cert != "" && cfg.Key != ""
 }
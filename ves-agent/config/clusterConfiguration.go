@@ -26,6 +26,7 @@ import (
 type Peer struct {
 	ID      string `mapstructure:"id"`      // Peer ID
 	Address string `mapstructure:"address"` // Peer address and port
+	API     string `mapstructure:"api"`     // Peer REST API address and port, used to forward requests to the leader
 }
 
 // Server converts the peer configuration into a raft server
@@ -62,6 +63,17 @@ func (cfg Peers) GetPeer(id string) (Peer, bool) {
 	return Peer{}, false
 }
 
+// GetPeerByAddress find and returns the peer configuration
+// for a given raft address
+func (cfg Peers) GetPeerByAddress(address string) (Peer, bool) {
+	for _, p := range cfg {
+		if p.Address == address {
+			return p, true
+		}
+	}
+	return Peer{}, false
+}
+
 // ClusterConfiguration is the configuration of the
 // raft cluster
 type ClusterConfiguration struct {
@@ -51,6 +51,15 @@ func (s *ClusterConfigTestSuite) TestGetPeer() {
 	s.False(ok)
 }
 
+func (s *ClusterConfigTestSuite) TestGetPeerByAddress() {
+	p, ok := s.cfg.Peers.GetPeerByAddress("127.0.0.2:6565")
+	s.True(ok)
+	s.Equal("2", p.ID)
+
+	p, ok = s.cfg.Peers.GetPeerByAddress("127.0.0.4:6565")
+	s.False(ok)
+}
+
 func (s *ClusterConfigTestSuite) TestServersConvert() {
 	servers := s.cfg.Peers.Servers()
 	s.NotNil(servers)
@@ -58,7 +58,25 @@ func setFlags(flagSet *pflag.FlagSet) {
 	flagSet.StringP("Measurement.DomainAbbreviation", "d", "Measurement", "Domain Abbreviation")
 	flagSet.DurationP("Measurement.DefaultInterval", "m", 300*time.Second, "Measurement interval")
 	flagSet.String("Measurement.Prometheus.Address", "http://localhost:9090", "Base url to of Prometheus server's API")
+	flagSet.String("Measurement.Prometheus.TLS.CA", "", "Path to CA certificates verifying Prometheus certificate")
+	flagSet.String("Measurement.Prometheus.TLS.Cert", "", "Path to client certificate presented to Prometheus")
+	flagSet.String("Measurement.Prometheus.TLS.Key", "", "Path to private key of the client certificate presented to Prometheus")
+	flagSet.String("Measurement.Prometheus.TLS.ServerName", "", "Name verified in Prometheus certificate")
+	flagSet.Bool("Measurement.Prometheus.TLS.InsecureSkipVerify", false, "Don't verify Prometheus certificate")
+	flagSet.String("Measurement.Prometheus.User", "", "Prometheus Username")
+	flagSet.String("Measurement.Prometheus.Password", "", "Prometheus Password")
+	flagSet.String("Measurement.Prometheus.PasswordFile", "", "Path to file holding Prometheus Password")
+	flagSet.String("Measurement.Prometheus.BearerToken", "", "Prometheus Bearer Token")
+	flagSet.String("Measurement.Prometheus.BearerTokenFile", "", "Path to file holding Prometheus Bearer Token")
+	flagSet.Int("Measurement.Prometheus.Workers", 4, "Number of Prometheus queries run in parallel")
+	flagSet.Duration("Measurement.Prometheus.QueryTimeout", 0, "Timeout of each Prometheus query. 0 for none")
+	flagSet.Int("Measurement.Prometheus.QuarantineAfter", 5, "Number of consecutive collections a rule fails in before being quarantined. 0 disables quarantine")
+	flagSet.Duration("Measurement.Prometheus.QuarantineDuration", time.Hour, "Duration rules are not evaluated for, once quarantined")
 	flagSet.Duration("Measurement.MaxBufferingDuration", time.Hour, "Maximum timeframe size of buffering")
+	flagSet.Duration("Measurement.Backfill.Chunk", 0, "Maximum timeframe size of a catch-up collection. 0 to catch up in one collection")
+	flagSet.Duration("Measurement.Backfill.Delay", 10*time.Second, "Delay between 2 catch-up collections")
+	flagSet.Bool("Measurement.Host.Enabled", false, "Collect host metrics from procfs and statfs")
+	flagSet.String("Measurement.Host.ProcRoot", "/proc", "Root of procfs, for host metrics")
 	flagSet.IntP("Event.MaxSize", "s", 200, "Max Event Size")
 	retrieveReportingEntityName(flagSet)
 	flagSet.DurationP("Event.RetryInterval", "r", 10*time.Second, "VES heartbeat retry interval")
@@ -67,6 +85,36 @@ func setFlags(flagSet *pflag.FlagSet) {
 	flagSet.String("AlertManager.Path", "/alerts", "Alert Manager Path")
 	flagSet.String("AlertManager.User", "", "Alert Manager Username")
 	flagSet.String("AlertManager.Password", "", "Alert Manager Password")
+	flagSet.String("AlertManager.BearerToken", "", "Alert Manager Bearer Token")
+	flagSet.String("AlertManager.TLS.Cert", "", "Path to Alert Manager receiver's TLS certificate")
+	flagSet.String("AlertManager.TLS.Key", "", "Path to Alert Manager receiver's TLS private key")
+	flagSet.String("AlertManager.TLS.ClientCA", "", "Path to CA certificates used to verify Alert Manager client certificates")
+	flagSet.String("AlertManager.TLS.PeerCA", "", "Path to CA certificates used to verify the cluster leader certificate")
+	flagSet.String("AlertManager.Reconcile.URL", "", "Base url of Alertmanager's API, for faults reconciliation")
+	flagSet.Duration("AlertManager.Reconcile.Interval", 5*time.Minute, "Interval between faults reconciliations")
+	flagSet.Duration("AlertManager.Reconcile.Timeout", 30*time.Second, "Timeout of requests to Alertmanager's API")
+	flagSet.String("AlertManager.Reconcile.Receiver", "", "Only reconcile alerts routed to matching receivers")
+	flagSet.Int("AlertManager.Queue.MaxPending", 10000, "Maximum number of received alerts waiting to be processed")
+	flagSet.Int("AlertManager.Queue.Retain", 1000, "Number of processed alerts whose status is kept")
+	flagSet.Duration("Fault.SweepInterval", time.Minute, "Interval between each check for expired faults")
+	flagSet.Int("Fault.HistorySize", 1000, "Number of records kept in the fault history")
+	flagSet.String("Fault.IDs.Format", "sequential", "Format of fault event IDs: sequential or uuid")
+	flagSet.String("Fault.IDs.Prefix", "fault", "Prefix of sequential fault event IDs")
+	flagSet.Int("Fault.IDs.Width", 10, "Number of digits of sequential fault event IDs")
+	flagSet.String("SNMP.Bind", "", "SNMP trap receiver UDP bind address. The receiver is disabled if empty")
+	flagSet.String("SNMP.Community", "", "Community of accepted SNMP traps. Any community is accepted if empty")
+	flagSet.String("Syslog.UDP", "", "Syslog listener UDP bind address")
+	flagSet.String("Syslog.TCP", "", "Syslog listener TCP bind address")
+	flagSet.Int("Syslog.MaxConnections", 100, "Maximum number of concurrent syslog TCP connections. 0 means no limit")
+	flagSet.Duration("Syslog.IdleTimeout", 5*time.Minute, "Syslog TCP connections are closed when no message is received for this duration. 0 means no timeout")
+	flagSet.Float64("Syslog.RateLimit.Rate", 0, "Maximum rate of syslog messages forwarded per source, per second. 0 means no limit")
+	flagSet.Int("Syslog.RateLimit.Burst", 100, "Maximum burst of syslog messages forwarded per source")
+	flagSet.Int("Syslog.BatchSize", 100, "Maximum number of syslog events posted in a batch")
+	flagSet.Duration("Syslog.BatchInterval", time.Second, "Maximum time a syslog message waits for its batch to be posted")
+	flagSet.String("Syslog.SourceType", "virtualMachine", "VES event source type of syslog events")
+	flagSet.Duration("Logs.PollInterval", time.Second, "Interval between each check of tailed log files for new lines")
+	flagSet.String("Admin.User", "", "Administration API Username")
+	flagSet.String("Admin.Password", "", "Administration API Password")
 	flagSet.String("Cluster.ID", "", "Override the cluster's node ID")
 	flagSet.StringP("DataDir", "D", "/var/lib/ves-agent/data", "Path to directory where to store data")
 	flagSet.Bool("Debug", false, "Activate debug traces")
@@ -74,6 +122,12 @@ func setFlags(flagSet *pflag.FlagSet) {
 
 // InitConf initilize the config store from config file, env and cli variables.
 func InitConf(conf *VESAgentConfiguration) error {
+	return InitConfWithFlags(conf, nil)
+}
+
+// InitConfWithFlags initilize the config store like InitConf. The command line flags of `flags`,
+// which aren't configuration parameters, are parsed along with the configuration ones
+func InitConfWithFlags(conf *VESAgentConfiguration, flags *pflag.FlagSet) error {
 
 	//bind env variable
 	viper.SetEnvPrefix("ves")
@@ -99,10 +153,13 @@ func InitConf(conf *VESAgentConfiguration) error {
 	//bind arguments variable
 	flagSet := pflag.NewFlagSet("conf", pflag.ExitOnError)
 	setFlags(flagSet)
-	if err := flagSet.Parse(os.Args); err != nil {
+	if err := viper.BindPFlags(flagSet); err != nil {
 		log.Panic(err)
 	}
-	if err := viper.BindPFlags(flagSet); err != nil {
+	if flags != nil {
+		flagSet.AddFlagSet(flags)
+	}
+	if err := flagSet.Parse(os.Args); err != nil {
 		log.Panic(err)
 	}
 
@@ -121,6 +178,16 @@ func InitConf(conf *VESAgentConfiguration) error {
 		}
 	}
 
+	if (viper.GetString("AlertManager.TLS.Cert") == "") != (viper.GetString("AlertManager.TLS.Key") == "") {
+		return errors.New("Both Cert and Key are required for AlertManager TLS")
+	}
+	if viper.GetString("AlertManager.TLS.ClientCA") != "" && viper.GetString("AlertManager.TLS.Cert") == "" {
+		return errors.New("AlertManager TLS ClientCA requires TLS Cert and Key")
+	}
+	if viper.GetString("AlertManager.TLS.PeerCA") != "" && viper.GetString("AlertManager.TLS.Cert") == "" {
+		return errors.New("AlertManager TLS PeerCA requires TLS Cert and Key")
+	}
+
 	// Viper will check in the following order: override, flag, env, config file, key/value store, default
 	return viper.Unmarshal(conf)
 }
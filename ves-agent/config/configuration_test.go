@@ -21,6 +21,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -94,6 +96,8 @@ func (s *ConfigurationTestSuite) TestDefaultsParameters() {
 	s.Equal(10*time.Second, conf.Event.RetryInterval)
 	s.Equal(3, conf.Event.MaxMissed)
 	s.Equal("localhost:9095", conf.AlertManager.Bind)
+	s.Equal(10000, conf.AlertManager.Queue.MaxPending)
+	s.Equal(1000, conf.AlertManager.Queue.Retain)
 	s.Equal(false, conf.Debug)
 }
 
@@ -197,6 +201,458 @@ func (s *ConfigurationTestSuite) TestOverwriteParameters() {
 	s.Equal(30002, conf.PrimaryCollector.Port)
 }
 
+func (s *ConfigurationTestSuite) TestExtraFlags() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+	os.Args = append(os.Args, "rules", "test", "--rule=2", "-p=30002")
+
+	flags := pflag.NewFlagSet("rules test", pflag.ExitOnError)
+	rule := flags.Int("rule", -1, "Index of the rule")
+	var conf VESAgentConfiguration
+	s.NoError(InitConfWithFlags(&conf, flags))
+	s.Equal(2, *rule)
+	s.Equal(30002, conf.PrimaryCollector.Port)
+	// Extra flags aren't configuration parameters
+	s.False(viper.IsSet("rule"))
+}
+
+func (s *ConfigurationTestSuite) TestAlertManagerSecurity() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+	s.file.WriteString("alertManager: " + LineBreak)
+	s.file.WriteString("  bearerToken: mytoken" + LineBreak)
+	s.file.WriteString("  tls: " + LineBreak)
+	s.file.WriteString("    cert: /etc/ves-agent/cert.pem" + LineBreak)
+	s.file.WriteString("    key: /etc/ves-agent/key.pem" + LineBreak)
+	s.file.WriteString("    clientCA: /etc/ves-agent/ca.pem" + LineBreak)
+	s.file.WriteString("    peerCA: /etc/ves-agent/peer-ca.pem" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.Equal("mytoken", conf.AlertManager.BearerToken)
+	s.True(conf.AlertManager.AuthEnabled())
+	s.True(conf.AlertManager.TLS.Enabled())
+	s.Equal("/etc/ves-agent/cert.pem", conf.AlertManager.TLS.Cert)
+	s.Equal("/etc/ves-agent/key.pem", conf.AlertManager.TLS.Key)
+	s.Equal("/etc/ves-agent/ca.pem", conf.AlertManager.TLS.ClientCA)
+	s.Equal("/etc/ves-agent/peer-ca.pem", conf.AlertManager.TLS.PeerCA)
+}
+
+func (s *ConfigurationTestSuite) TestAlertManagerInvalidTLS() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+	defer os.Unsetenv("VES_ALERTMANAGER_TLS_CERT")
+	defer os.Unsetenv("VES_ALERTMANAGER_TLS_CLIENTCA")
+	defer os.Unsetenv("VES_ALERTMANAGER_TLS_KEY")
+	defer os.Unsetenv("VES_ALERTMANAGER_TLS_PEERCA")
+	os.Setenv("VES_ALERTMANAGER_TLS_CERT", "/etc/ves-agent/cert.pem")
+	var conf VESAgentConfiguration
+	s.Error(InitConf(&conf))
+	os.Setenv("VES_ALERTMANAGER_TLS_CERT", "")
+	os.Setenv("VES_ALERTMANAGER_TLS_CLIENTCA", "/etc/ves-agent/ca.pem")
+	s.Error(InitConf(&conf))
+	os.Setenv("VES_ALERTMANAGER_TLS_CLIENTCA", "")
+	os.Setenv("VES_ALERTMANAGER_TLS_PEERCA", "/etc/ves-agent/ca.pem")
+	s.Error(InitConf(&conf))
+	os.Setenv("VES_ALERTMANAGER_TLS_CERT", "/etc/ves-agent/cert.pem")
+	os.Setenv("VES_ALERTMANAGER_TLS_KEY", "/etc/ves-agent/key.pem")
+	s.NoError(InitConf(&conf))
+	s.False(conf.AlertManager.AuthEnabled())
+}
+
+func (s *ConfigurationTestSuite) TestAlertManagerReconcile() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.False(conf.AlertManager.Reconcile.Enabled())
+	s.Equal(5*time.Minute, conf.AlertManager.Reconcile.Interval)
+	s.Equal(30*time.Second, conf.AlertManager.Reconcile.Timeout)
+
+	s.file.WriteString("alertManager: " + LineBreak)
+	s.file.WriteString("  reconcile: " + LineBreak)
+	s.file.WriteString("    url: http://localhost:9093" + LineBreak)
+	s.file.WriteString("    interval: 1m" + LineBreak)
+	s.file.WriteString("    receiver: ves-agent" + LineBreak)
+	s.file.WriteString("    filters: ['team=\"platform\"']" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.True(conf.AlertManager.Reconcile.Enabled())
+	s.Equal("http://localhost:9093", conf.AlertManager.Reconcile.URL)
+	s.Equal(time.Minute, conf.AlertManager.Reconcile.Interval)
+	s.Equal("ves-agent", conf.AlertManager.Reconcile.Receiver)
+	s.Equal([]string{`team="platform"`}, conf.AlertManager.Reconcile.Filters)
+}
+
+func (s *ConfigurationTestSuite) TestFaultRules() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+	s.file.WriteString("fault: " + LineBreak)
+	s.file.WriteString("  rules: " + LineBreak)
+	s.file.WriteString("    - name: kubernetes" + LineBreak)
+	s.file.WriteString("      matchers: " + LineBreak)
+	s.file.WriteString("        - label: namespace" + LineBreak)
+	s.file.WriteString("          regex: payments|billing" + LineBreak)
+	s.file.WriteString("      sourceName: '{{.labels.pod}}'" + LineBreak)
+	s.file.WriteString("      clearAlarmCondition: '{{.labels.alertname}}Cleared'" + LineBreak)
+	s.file.WriteString("      additionalInformation: " + LineBreak)
+	s.file.WriteString("        - name: namespace" + LineBreak)
+	s.file.WriteString("          expr: '{{.labels.namespace}}'" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.Len(conf.Fault.Rules, 1)
+	rule := conf.Fault.Rules[0]
+	s.Equal("kubernetes", rule.Name)
+	s.Equal([]Matcher{{Label: "namespace", Regex: "payments|billing"}}, rule.Matchers)
+	s.Equal("{{.labels.pod}}", rule.SourceName)
+	s.Equal("{{.labels.alertname}}Cleared", rule.ClearAlarmCondition)
+	s.Equal([]Label{{Name: "namespace", Expr: "{{.labels.namespace}}"}}, rule.AdditionalInformation)
+}
+
+func (s *ConfigurationTestSuite) TestFaultTTL() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.False(conf.Fault.ExpirationEnabled())
+	s.Equal(time.Minute, conf.Fault.SweepInterval)
+
+	s.file.WriteString("fault: " + LineBreak)
+	s.file.WriteString("  sweepInterval: 30s" + LineBreak)
+	s.file.WriteString("  ttls: " + LineBreak)
+	s.file.WriteString("    - alertName: NodeFailure" + LineBreak)
+	s.file.WriteString("      ttl: 1h" + LineBreak)
+	s.file.WriteString("    - severity: minor" + LineBreak)
+	s.file.WriteString("      ttl: 0s" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.True(conf.Fault.ExpirationEnabled())
+	s.Equal(30*time.Second, conf.Fault.SweepInterval)
+	s.Equal([]FaultTTL{{AlertName: "NodeFailure", TTL: time.Hour}, {Severity: "minor"}}, conf.Fault.TTLs)
+	s.EqualValues(0, conf.Fault.DefaultTTL)
+}
+
+func (s *ConfigurationTestSuite) TestFaultRepeats() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+	s.file.WriteString("fault: " + LineBreak)
+	s.file.WriteString("  repeats: " + LineBreak)
+	s.file.WriteString("    - alertName: NodeFailure" + LineBreak)
+	s.file.WriteString("      policy: onChange" + LineBreak)
+	s.file.WriteString("    - severity: minor" + LineBreak)
+	s.file.WriteString("      policy: interval" + LineBreak)
+	s.file.WriteString("      interval: 30m" + LineBreak)
+	s.file.WriteString("  defaultRepeat: " + LineBreak)
+	s.file.WriteString("    policy: always" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.Equal([]FaultRepeat{
+		{AlertName: "NodeFailure", Policy: RepeatOnChange},
+		{Severity: "minor", Policy: RepeatInterval, Interval: 30 * time.Minute},
+	}, conf.Fault.Repeats)
+	s.Equal(FaultRepeat{Policy: RepeatAlways}, conf.Fault.DefaultRepeat)
+}
+
+func (s *ConfigurationTestSuite) TestFaultHistorySize() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.Equal(1000, conf.Fault.HistorySize)
+
+	s.file.WriteString("fault: " + LineBreak)
+	s.file.WriteString("  historySize: 50" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.Equal(50, conf.Fault.HistorySize)
+}
+
+func (s *ConfigurationTestSuite) TestFaultIDs() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.Equal(FaultIDConfiguration{Format: FaultIDSequential, Prefix: "fault", Width: 10}, conf.Fault.IDs)
+
+	s.file.WriteString("fault: " + LineBreak)
+	s.file.WriteString("  ids: " + LineBreak)
+	s.file.WriteString("    format: uuid" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.Equal(FaultIDUUID, conf.Fault.IDs.Format)
+}
+
+func (s *ConfigurationTestSuite) TestSNMP() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.False(conf.SNMP.Enabled())
+
+	s.file.WriteString("snmp: " + LineBreak)
+	s.file.WriteString("  bind: 0.0.0.0:1162" + LineBreak)
+	s.file.WriteString("  community: public" + LineBreak)
+	s.file.WriteString("  traps: " + LineBreak)
+	s.file.WriteString("    - name: linkDown" + LineBreak)
+	s.file.WriteString("      raiseOid: 1.3.6.1.6.3.1.1.5.3" + LineBreak)
+	s.file.WriteString("      clearOid: 1.3.6.1.6.3.1.1.5.4" + LineBreak)
+	s.file.WriteString("      varbinds: " + LineBreak)
+	s.file.WriteString("        ifIndex: 1.3.6.1.2.1.2.2.1.1" + LineBreak)
+	s.file.WriteString("      labels: " + LineBreak)
+	s.file.WriteString("        alertname: LinkDown" + LineBreak)
+	s.file.WriteString("        id: '{{ .vars.ifIndex }}'" + LineBreak)
+	s.file.WriteString("      annotations: " + LineBreak)
+	s.file.WriteString("        service: Network" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.True(conf.SNMP.Enabled())
+	s.Equal("0.0.0.0:1162", conf.SNMP.Bind)
+	s.Equal("public", conf.SNMP.Community)
+	s.Equal([]TrapRule{{
+		Name:        "linkDown",
+		RaiseOID:    "1.3.6.1.6.3.1.1.5.3",
+		ClearOID:    "1.3.6.1.6.3.1.1.5.4",
+		Varbinds:    map[string]string{"ifIndex": "1.3.6.1.2.1.2.2.1.1"},
+		Labels:      map[string]string{"alertname": "LinkDown", "id": "{{ .vars.ifIndex }}"},
+		Annotations: map[string]string{"service": "Network"},
+	}}, conf.SNMP.Traps)
+}
+
+func (s *ConfigurationTestSuite) TestSyslog() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.False(conf.Syslog.Enabled())
+	s.Equal(100, conf.Syslog.BatchSize)
+	s.Equal(time.Second, conf.Syslog.BatchInterval)
+	s.Equal(100, conf.Syslog.RateLimit.Burst)
+	s.Equal("virtualMachine", conf.Syslog.SourceType)
+	s.Equal(100, conf.Syslog.MaxConnections)
+	s.Equal(5*time.Minute, conf.Syslog.IdleTimeout)
+
+	s.file.WriteString("syslog: " + LineBreak)
+	s.file.WriteString("  tcp: 0.0.0.0:1514" + LineBreak)
+	s.file.WriteString("  maxConnections: 10" + LineBreak)
+	s.file.WriteString("  idleTimeout: 30s" + LineBreak)
+	s.file.WriteString("  rateLimit: " + LineBreak)
+	s.file.WriteString("    rate: 10" + LineBreak)
+	s.file.WriteString("  filters: " + LineBreak)
+	s.file.WriteString("    - facilities: [auth, local0]" + LineBreak)
+	s.file.WriteString("      severity: warning" + LineBreak)
+	s.file.WriteString("      regex: failed" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.True(conf.Syslog.Enabled())
+	s.Equal("0.0.0.0:1514", conf.Syslog.TCP)
+	s.Equal(10, conf.Syslog.MaxConnections)
+	s.Equal(30*time.Second, conf.Syslog.IdleTimeout)
+	s.Equal(10.0, conf.Syslog.RateLimit.Rate)
+	s.Equal([]SyslogFilter{{Facilities: []string{"auth", "local0"}, Severity: "warning", Regex: "failed"}}, conf.Syslog.Filters)
+}
+
+func (s *ConfigurationTestSuite) TestHostMetrics() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.False(conf.Measurement.Host.Enabled)
+	s.Equal("/proc", conf.Measurement.Host.ProcRoot)
+
+	s.file.WriteString("measurement: " + LineBreak)
+	s.file.WriteString("  host: " + LineBreak)
+	s.file.WriteString("    enabled: true" + LineBreak)
+	s.file.WriteString("    procRoot: /host/proc" + LineBreak)
+	s.file.WriteString("    vmId: vm1" + LineBreak)
+	s.file.WriteString("    filesystems: [/, /data]" + LineBreak)
+	s.file.WriteString("    disks: [sda]" + LineBreak)
+	s.file.WriteString("    interfaces: [eth0, eth1]" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.Equal(HostMetricsConfiguration{
+		Enabled:     true,
+		ProcRoot:    "/host/proc",
+		VMID:        "vm1",
+		Filesystems: []string{"/", "/data"},
+		Disks:       []string{"sda"},
+		Interfaces:  []string{"eth0", "eth1"},
+	}, conf.Measurement.Host)
+}
+
+func (s *ConfigurationTestSuite) TestPrometheusQueries() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.Equal(4, conf.Measurement.Prometheus.Workers)
+	s.Zero(conf.Measurement.Prometheus.QueryTimeout)
+	s.Equal(5, conf.Measurement.Prometheus.QuarantineAfter)
+	s.Equal(time.Hour, conf.Measurement.Prometheus.QuarantineDuration)
+	s.Empty(conf.Measurement.Prometheus.FailedRulesField)
+
+	s.file.WriteString("measurement: " + LineBreak)
+	s.file.WriteString("  prometheus: " + LineBreak)
+	s.file.WriteString("    workers: 8" + LineBreak)
+	s.file.WriteString("    queryTimeout: 20s" + LineBreak)
+	s.file.WriteString("    quarantineAfter: 0" + LineBreak)
+	s.file.WriteString("    quarantineDuration: 10m" + LineBreak)
+	s.file.WriteString("    failedRulesField: failedRules" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.Equal(8, conf.Measurement.Prometheus.Workers)
+	s.Equal(20*time.Second, conf.Measurement.Prometheus.QueryTimeout)
+	s.Zero(conf.Measurement.Prometheus.QuarantineAfter)
+	s.Equal(10*time.Minute, conf.Measurement.Prometheus.QuarantineDuration)
+	s.Equal("failedRules", conf.Measurement.Prometheus.FailedRulesField)
+}
+
+func (s *ConfigurationTestSuite) TestPrometheusAuth() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+	s.file.WriteString("measurement: " + LineBreak)
+	s.file.WriteString("  prometheus: " + LineBreak)
+	s.file.WriteString("    address: https://thanos:10902" + LineBreak)
+	s.file.WriteString("    tls: " + LineBreak)
+	s.file.WriteString("      ca: /etc/ves-agent/ca.pem" + LineBreak)
+	s.file.WriteString("      cert: /etc/ves-agent/cert.pem" + LineBreak)
+	s.file.WriteString("      key: /etc/ves-agent/key.pem" + LineBreak)
+	s.file.WriteString("      serverName: thanos.example.com" + LineBreak)
+	s.file.WriteString("    bearerTokenFile: /var/run/secrets/token" + LineBreak)
+	s.file.WriteString("    headers: " + LineBreak)
+	s.file.WriteString("      X-Scope-OrgID: tenant1" + LineBreak)
+	defer os.Unsetenv("VES_MEASUREMENT_PROMETHEUS_USER")
+	os.Setenv("VES_MEASUREMENT_PROMETHEUS_USER", "prom")
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.Equal(PrometheusTLSConfig{
+		CA:         "/etc/ves-agent/ca.pem",
+		Cert:       "/etc/ves-agent/cert.pem",
+		Key:        "/etc/ves-agent/key.pem",
+		ServerName: "thanos.example.com",
+	}, conf.Measurement.Prometheus.TLS)
+	s.Equal("/var/run/secrets/token", conf.Measurement.Prometheus.BearerTokenFile)
+	s.Empty(conf.Measurement.Prometheus.BearerToken)
+	s.Equal("prom", conf.Measurement.Prometheus.User)
+	// Header names are case insensitive
+	s.Equal(map[string]string{"x-scope-orgid": "tenant1"}, conf.Measurement.Prometheus.Headers)
+}
+
+func (s *ConfigurationTestSuite) TestPrometheusSources() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+	s.file.WriteString("measurement: " + LineBreak)
+	s.file.WriteString("  prometheus: " + LineBreak)
+	s.file.WriteString("    sources: " + LineBreak)
+	s.file.WriteString("      - name: local" + LineBreak)
+	s.file.WriteString("        address: http://localhost:9090" + LineBreak)
+	s.file.WriteString("        retention: 2h" + LineBreak)
+	s.file.WriteString("      - name: thanos" + LineBreak)
+	s.file.WriteString("        address: https://thanos:10902" + LineBreak)
+	s.file.WriteString("        bearerTokenFile: /var/run/secrets/token" + LineBreak)
+	s.file.WriteString("        tls: " + LineBreak)
+	s.file.WriteString("          ca: /etc/ves-agent/ca.pem" + LineBreak)
+	s.file.WriteString("    rules: " + LineBreak)
+	s.file.WriteString("      defaults: " + LineBreak)
+	s.file.WriteString("        sources: [local, thanos]" + LineBreak)
+	s.file.WriteString("      metrics: " + LineBreak)
+	s.file.WriteString("        - target: ConcurrentSessions" + LineBreak)
+	s.file.WriteString("          expr: sessions" + LineBreak)
+	s.file.WriteString("        - target: ConfiguredEntities" + LineBreak)
+	s.file.WriteString("          expr: entities" + LineBreak)
+	s.file.WriteString("          sources: [thanos]" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	prom := conf.Measurement.Prometheus
+	s.Equal([]PrometheusSource{
+		{Name: "local", Retention: 2 * time.Hour, PrometheusConnection: PrometheusConnection{Address: "http://localhost:9090"}},
+		{Name: "thanos", PrometheusConnection: PrometheusConnection{
+			Address:         "https://thanos:10902",
+			BearerTokenFile: "/var/run/secrets/token",
+			TLS:             PrometheusTLSConfig{CA: "/etc/ves-agent/ca.pem"},
+		}},
+	}, prom.Sources)
+	s.Equal([]string{"local", "thanos"}, prom.Rules.Metrics[0].WithDefaults(prom.Rules.DefaultValues).Sources)
+	s.Equal([]string{"thanos"}, prom.Rules.Metrics[1].WithDefaults(prom.Rules.DefaultValues).Sources)
+}
+
+func (s *ConfigurationTestSuite) TestBackfill() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.Zero(conf.Measurement.Backfill.Chunk)
+	s.Equal(10*time.Second, conf.Measurement.Backfill.Delay)
+
+	s.file.WriteString("measurement: " + LineBreak)
+	s.file.WriteString("  backfill: " + LineBreak)
+	s.file.WriteString("    chunk: 1h" + LineBreak)
+	s.file.WriteString("    delay: 30s" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.Equal(time.Hour, conf.Measurement.Backfill.Chunk)
+	s.Equal(30*time.Second, conf.Measurement.Backfill.Delay)
+}
+
+func (s *ConfigurationTestSuite) TestLogs() {
+	s.file.WriteString("primaryCollector: " + LineBreak)
+	s.file.WriteString("  user: user" + LineBreak)
+	s.file.WriteString("  password: pass" + LineBreak)
+
+	var conf VESAgentConfiguration
+	s.NoError(InitConf(&conf))
+	s.False(conf.Logs.Enabled())
+	s.Equal(time.Second, conf.Logs.PollInterval)
+
+	s.file.WriteString("logs: " + LineBreak)
+	s.file.WriteString("  pollInterval: 5s" + LineBreak)
+	s.file.WriteString("  files: " + LineBreak)
+	s.file.WriteString("    - path: /var/log/app.log" + LineBreak)
+	s.file.WriteString("      rules: " + LineBreak)
+	s.file.WriteString("        - name: disk" + LineBreak)
+	s.file.WriteString("          raise: 'I/O error on (?P<disk>\\w+)'" + LineBreak)
+	s.file.WriteString("          clear: 'Disk (?P<disk>\\w+) recovered'" + LineBreak)
+	s.file.WriteString("          timeout: 10m" + LineBreak)
+	s.file.WriteString("          sourceName: '{{.hostname}}'" + LineBreak)
+	s.file.WriteString("          specificProblem: 'Disk {{.groups.disk}} failure'" + LineBreak)
+	s.file.WriteString("          labels: " + LineBreak)
+	s.file.WriteString("            alertname: DiskFailure" + LineBreak)
+	s.NoError(InitConf(&conf))
+	s.True(conf.Logs.Enabled())
+	s.True(conf.Logs.TimeoutsEnabled())
+	s.Equal(5*time.Second, conf.Logs.PollInterval)
+	s.Require().Len(conf.Logs.Files, 1)
+	s.Equal("/var/log/app.log", conf.Logs.Files[0].Path)
+	s.Equal([]LogRule{{
+		Name:            "disk",
+		Raise:           `I/O error on (?P<disk>\w+)`,
+		Clear:           `Disk (?P<disk>\w+) recovered`,
+		Timeout:         10 * time.Minute,
+		SourceName:      "{{.hostname}}",
+		SpecificProblem: "Disk {{.groups.disk}} failure",
+		Labels:          map[string]string{"alertname": "DiskFailure"},
+	}}, conf.Logs.Files[0].Rules)
+}
+
 func checkAll(s *ConfigurationTestSuite, cli bool) {
 	var conf VESAgentConfiguration
 	err := InitConf(&conf)
@@ -0,0 +1,122 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package config
+
+import "time"
+
+// Matcher matches the value of an alert's label against a regular expression.
+// The expression is anchored, and an absent label has an empty value
+type Matcher struct {
+	Label string `mapstructure:"label"`
+	Regex string `mapstructure:"regex"`
+}
+
+// FaultRule defines how to map alerts into VES fault events.
+// Except for `Name` and `Matchers`, all fields are template expressions.
+// Empty fields fallback to the default mapping
+type FaultRule struct {
+	Name                  string    `mapstructure:"name"`                  // Rule name, for logging purpose
+	Matchers              []Matcher `mapstructure:"matchers"`              // Matchers an alert must all satisfy for the rule to apply
+	SourceName            string    `mapstructure:"sourceName"`            // VES source name
+	AlarmCondition        string    `mapstructure:"alarmCondition"`        // Alarm condition of raised faults
+	ClearAlarmCondition   string    `mapstructure:"clearAlarmCondition"`   // Alarm condition of cleared faults
+	SpecificProblem       string    `mapstructure:"specificProblem"`       // Specific problem of raised faults
+	ClearSpecificProblem  string    `mapstructure:"clearSpecificProblem"`  // Specific problem of cleared faults
+	EventSourceType       string    `mapstructure:"eventSourceType"`       // VES event source type
+	VfStatus              string    `mapstructure:"vfStatus"`              // VES virtual function status
+	EventCategory         string    `mapstructure:"eventCategory"`         // VES event category
+	AlarmInterfaceA       string    `mapstructure:"alarmInterfaceA"`       // VES alarm interface A
+	Identity              string    `mapstructure:"identity"`              // Key identifying the fault, shared by its raise and clear
+	AdditionalInformation []Label   `mapstructure:"additionalInformation"` // VES alarm additional information fields
+}
+
+// SeverityMapping maps a value of alerts' `severity` label to a VES severity and priority
+type SeverityMapping struct {
+	Value    string `mapstructure:"value"`    // Value of the severity label, case insensitive
+	Severity string `mapstructure:"severity"` // VES fault severity
+	Priority string `mapstructure:"priority"` // VES event priority. Defaults to the priority of the VES severity
+}
+
+// Policies applied to alerts whose severity is not mapped
+const (
+	UnknownSeverityReject  = "reject"  // Reject the alert with an error
+	UnknownSeverityDrop    = "drop"    // Silently ignore the alert
+	UnknownSeverityDefault = "default" // Use the default severity
+)
+
+// FaultTTL is the time to live of faults whose alert is not refreshed
+type FaultTTL struct {
+	AlertName string        `mapstructure:"alertName"` // Name of alerts the TTL applies to. Any if empty
+	Severity  string        `mapstructure:"severity"`  // VES severity of faults the TTL applies to. Any if empty
+	TTL       time.Duration `mapstructure:"ttl"`       // Time to live. 0 means faults never expire
+}
+
+// Policies applied to the repeated notifications of a firing alert
+const (
+	RepeatAlways   = "always"   // Send a fault event for each notification
+	RepeatOnChange = "onChange" // Send a fault event only if the severity or the description changed
+	RepeatInterval = "interval" // Send a fault event at most once per interval
+)
+
+// FaultRepeat is the policy applied to the repeated notifications of alerts which raised a fault
+type FaultRepeat struct {
+	AlertName string        `mapstructure:"alertName"` // Name of alerts the policy applies to. Any if empty
+	Severity  string        `mapstructure:"severity"`  // VES severity of faults the policy applies to. Any if empty
+	Policy    string        `mapstructure:"policy"`    // Repeat policy. Defaults to "always"
+	Interval  time.Duration `mapstructure:"interval"`  // Minimum interval between fault events, for the "interval" policy
+}
+
+// Formats of fault event IDs
+const (
+	FaultIDSequential = "sequential" // Prefix followed by the zero padded fault index
+	FaultIDUUID       = "uuid"       // Random UUID
+)
+
+// FaultIDConfiguration defines the format of fault event IDs
+type FaultIDConfiguration struct {
+	Format string `mapstructure:"format"` // Event ID format. Defaults to "sequential"
+	Prefix string `mapstructure:"prefix"` // Prefix of sequential event IDs
+	Width  int    `mapstructure:"width"`  // Number of digits of sequential event IDs, up to 10. Indexes wrap around past the largest one
+}
+
+// FaultConfiguration parameters
+type FaultConfiguration struct {
+	Rules           []FaultRule          `mapstructure:"rules"`           // Mapping rules. The first rule matching an alert applies
+	Severities      []SeverityMapping    `mapstructure:"severities"`      // Additional severity mappings. VES severity names are always mapped
+	DefaultSeverity SeverityMapping      `mapstructure:"defaultSeverity"` // Severity of unmapped values, for the "default" policy
+	UnknownSeverity string               `mapstructure:"unknownSeverity"` // Policy for unmapped severity values. Defaults to "reject"
+	TTLs            []FaultTTL           `mapstructure:"ttls"`            // Faults time to live. The first TTL matching a fault applies
+	DefaultTTL      time.Duration        `mapstructure:"defaultTTL"`      // Time to live of faults matching no TTL. 0 means faults never expire
+	SweepInterval   time.Duration        `mapstructure:"sweepInterval"`   // Interval between each check for expired faults
+	Repeats         []FaultRepeat        `mapstructure:"repeats"`         // Repeat policies. The first policy matching a fault applies
+	DefaultRepeat   FaultRepeat          `mapstructure:"defaultRepeat"`   // Repeat policy of faults matching no policy
+	HistorySize     int                  `mapstructure:"historySize"`     // Number of records kept in the fault history
+	IDs             FaultIDConfiguration `mapstructure:"ids"`             // Format of fault event IDs
+}
+
+// ExpirationEnabled returns true if some faults may expire
+func (cfg FaultConfiguration) ExpirationEnabled() bool {
+	if cfg.DefaultTTL > 0 {
+		return true
+	}
+	for _, ttl := range cfg.TTLs {
+		if ttl.TTL > 0 {
+			return true
+		}
+	}
+	return false
+}
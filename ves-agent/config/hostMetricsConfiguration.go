@@ -0,0 +1,28 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package config
+
+// HostMetricsConfiguration parameters of the built-in host metrics source, reading the
+// statistics of the Linux kernel from procfs and statfs
+type HostMetricsConfiguration struct {
+	Enabled     bool     `mapstructure:"enabled"`     // Collect host metrics
+	ProcRoot    string   `mapstructure:"procRoot"`    // Root of procfs. Defaults to /proc
+	VMID        string   `mapstructure:"vmId"`        // Source name of the measurements. Defaults to the hostname
+	Filesystems []string `mapstructure:"filesystems"` // Mount points of the reported filesystems. Defaults to /
+	Disks       []string `mapstructure:"disks"`       // Names of the reported disks. All but loop and ram devices if empty
+	Interfaces  []string `mapstructure:"interfaces"`  // Names of the reported network interfaces. All but lo if empty
+}
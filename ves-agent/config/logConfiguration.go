@@ -0,0 +1,64 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package config
+
+import "time"
+
+// LogConfiguration parameters of the log files tailing, raising and clearing faults from the lines of log files
+type LogConfiguration struct {
+	Files        []LogFile     `mapstructure:"files"`        // Tailed log files
+	PollInterval time.Duration `mapstructure:"pollInterval"` // Interval between each check of the files for new lines
+}
+
+// Enabled returns true if some log files are tailed
+func (cfg LogConfiguration) Enabled() bool {
+	return len(cfg.Files) > 0
+}
+
+// TimeoutsEnabled returns true if some faults raised from log lines are cleared after a timeout
+func (cfg LogConfiguration) TimeoutsEnabled() bool {
+	for _, file := range cfg.Files {
+		for _, rule := range file.Rules {
+			if rule.Timeout > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LogFile is a log file tailed for lines raising or clearing faults.
+// The file is followed when rotated, either renamed or truncated
+type LogFile struct {
+	Path  string    `mapstructure:"path"`  // Path of the log file
+	Rules []LogRule `mapstructure:"rules"` // Rules applied to each new line of the file
+}
+
+// LogRule defines how to map log lines into alerts, which are then processed like the ones
+// received from Alertmanager. Except for `Name`, `Raise`, `Clear` and `Timeout`, all fields
+// are template expressions
+type LogRule struct {
+	Name                 string            `mapstructure:"name"`                 // Rule name, for logging purpose
+	Raise                string            `mapstructure:"raise"`                // Regular expression of lines raising the fault
+	Clear                string            `mapstructure:"clear"`                // Regular expression of lines clearing the fault. Optional
+	Timeout              time.Duration     `mapstructure:"timeout"`              // Time after which the fault is cleared if no line raised it again. 0 means never
+	SourceName           string            `mapstructure:"sourceName"`           // VES source name, set as the `system_name` label
+	SpecificProblem      string            `mapstructure:"specificProblem"`      // Specific problem of raised faults, set as the `description` annotation
+	ClearSpecificProblem string            `mapstructure:"clearSpecificProblem"` // Specific problem of cleared faults. Defaults to specificProblem
+	Labels               map[string]string `mapstructure:"labels"`               // Labels of the alert. `alertname` is required, and raise and clear lines must give the same labels
+	Annotations          map[string]string `mapstructure:"annotations"`          // Annotations of the alert
+}
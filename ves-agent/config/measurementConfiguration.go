@@ -30,13 +30,33 @@ type Label struct {
 // MetricRule defines how to retrieve metrics and map them
 // into a list of evel.EventMeasurement struct
 type MetricRule struct {
-	Target         string  `mapstructure:"target"`          // Target VES event field
-	Expr           string  `mapstructure:"expr"`            // Prometheus query expression
-	VMIDLabel      string  `mapstructure:"vmId"`            // Metric label holding the VNF ID
-	Labels         []Label `mapstructure:"labels"`          // Set of VES fields to map to values of given label
-	ObjectName     string  `mapstructure:"object_name"`     // JSON Object Name
-	ObjectInstance string  `mapstructure:"object_instance"` // JSON Object instance
-	ObjectKeys     []Label `mapstructure:"object_keys"`     // JSON Object keys
+	Target         string        `mapstructure:"target"`          // Target VES event field
+	Expr           string        `mapstructure:"expr"`            // Prometheus query expression
+	VMIDLabel      string        `mapstructure:"vmId"`            // Metric label holding the VNF ID
+	Labels         []Label       `mapstructure:"labels"`          // Set of VES fields to map to values of given label
+	ObjectName     string        `mapstructure:"object_name"`     // JSON Object Name
+	ObjectInstance string        `mapstructure:"object_instance"` // JSON Object instance
+	ObjectKeys     []Label       `mapstructure:"object_keys"`     // JSON Object keys
+	ArrayName      string        `mapstructure:"array_name"`      // Template expression giving the name of additional measurements arrays
+	FieldName      string        `mapstructure:"field_name"`      // Template expression giving the name of additional fields
+	Rounding       string        `mapstructure:"rounding"`        // Rounding of values set to integer fields: round (default), floor, ceil or trunc
+	Value          string        `mapstructure:"value"`           // Template expression giving the value of string and additional fields
+	Thresholds     []Threshold   `mapstructure:"thresholds"`      // Values of string fields by metric value. The first threshold reached applies
+	Histogram      HistogramRule `mapstructure:"histogram"`       // Mapping of histograms into latency distributions
+	Sources        []string      `mapstructure:"sources"`         // Names of the Prometheus sources queried, in failover order. All sources if empty
+}
+
+// HistogramRule defines how the `le` buckets of a Prometheus histogram fill `LatencyDistribution`
+type HistogramRule struct {
+	Scale float64 `mapstructure:"scale"` // Factor applied to bucket bounds and mean latency, eg: 1000 for seconds into milliseconds
+	Sum   string  `mapstructure:"sum"`   // Optional query of the per-interval sum of observations, to derive MeanRequestLatency
+	Count string  `mapstructure:"count"` // Optional query of the per-interval count of observations, to derive MeanRequestLatency
+}
+
+// Threshold gives the value of a string field when the metric value reaches `Min`
+type Threshold struct {
+	Min   float64 `mapstructure:"min"`   // Lowest metric value of the threshold
+	Value string  `mapstructure:"value"` // Value of the field
 }
 
 func (rule MetricRule) hasLabel(name string) bool {
@@ -62,6 +82,12 @@ func (rule MetricRule) WithDefaults(def *MetricRule) MetricRule {
 	if rule.VMIDLabel == "" {
 		rule.VMIDLabel = def.VMIDLabel
 	}
+	if rule.Rounding == "" {
+		rule.Rounding = def.Rounding
+	}
+	if len(rule.Sources) == 0 {
+		rule.Sources = def.Sources
+	}
 	labels := make([]Label, len(rule.Labels))
 	copy(labels, rule.Labels)
 	rule.Labels = labels
@@ -79,18 +105,60 @@ type MetricRules struct {
 	Metrics       []MetricRule `mapstructure:"metrics"`  // List of query and mapping of rules
 }
 
+// PrometheusTLSConfig parameters of HTTPS connections to Prometheus
+type PrometheusTLSConfig struct {
+	CA                 string `mapstructure:"ca"`                 // Path to CA certificates file (PEM) verifying Prometheus certificate. System CAs if empty
+	Cert               string `mapstructure:"cert"`               // Path to client certificate file (PEM), read at each connection
+	Key                string `mapstructure:"key"`                // Path to client private key file (PEM), read at each connection
+	ServerName         string `mapstructure:"serverName"`         // Name verified in Prometheus certificate. Host of the address if empty
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify"` // Don't verify Prometheus certificate
+}
+
+// PrometheusConnection parameters of the connection to a Prometheus API
+type PrometheusConnection struct {
+	Address         string              `mapstructure:"address"`         // Base URL to prometheus API
+	TLS             PrometheusTLSConfig `mapstructure:"tls"`             // HTTPS parameters
+	User            string              `mapstructure:"user"`            // Basic authentication username
+	Password        string              `mapstructure:"password"`        // Basic authentication password
+	PasswordFile    string              `mapstructure:"passwordFile"`    // Path to the file holding the basic authentication password, read at each request
+	BearerToken     string              `mapstructure:"bearerToken"`     // Token sent in bearer authorization header
+	BearerTokenFile string              `mapstructure:"bearerTokenFile"` // Path to the file holding the bearer token, read at each request
+	Headers         map[string]string   `mapstructure:"headers"`         // Custom headers added to requests, e.g. X-Scope-OrgID
+}
+
+// PrometheusSource parameters of a named Prometheus API, queried by rules
+type PrometheusSource struct {
+	Name                 string        `mapstructure:"name"`      // Name of the source, selecting it in rules
+	Retention            time.Duration `mapstructure:"retention"` // Age of the oldest data kept by the source. 0 for no limit
+	PrometheusConnection `mapstructure:",squash"`
+}
+
 // PrometheusConfig parameters
 type PrometheusConfig struct {
-	Address   string        `mapstructure:"address"`   // Base URL to prometheus API
-	Timeout   time.Duration `mapstructure:"timeout"`   // API request timeout
-	KeepAlive time.Duration `mapstructure:"keepalive"` // HTTP Keep-Alive
-	Rules     MetricRules   `mapstructure:"rules"`     // Querying rules
+	PrometheusConnection `mapstructure:",squash"` // Connection to the default source, when no source is configured
+	Timeout              time.Duration            `mapstructure:"timeout"`            // API request timeout
+	KeepAlive            time.Duration            `mapstructure:"keepalive"`          // HTTP Keep-Alive
+	Sources              []PrometheusSource       `mapstructure:"sources"`            // Named sources, in failover order. Replace the default source if not empty
+	Workers              int                      `mapstructure:"workers"`            // Number of queries run in parallel
+	QueryTimeout         time.Duration            `mapstructure:"queryTimeout"`       // Timeout of each query. 0 for none
+	QuarantineAfter      int                      `mapstructure:"quarantineAfter"`    // Consecutive failed collections before quarantining a rule. 0 for never
+	QuarantineDuration   time.Duration            `mapstructure:"quarantineDuration"` // Duration quarantined rules are not evaluated for
+	FailedRulesField     string                   `mapstructure:"failedRulesField"`   // Name of the additional field listing failed rules. Empty for none
+	Rules                MetricRules              `mapstructure:"rules"`              // Querying rules
+}
+
+// BackfillConfiguration parameters of the catch-up of long collection windows, e.g. after an outage
+type BackfillConfiguration struct {
+	Chunk time.Duration `mapstructure:"chunk"` // Maximum timeframe size of a collection. 0 to catch up in one collection
+	Delay time.Duration `mapstructure:"delay"` // Delay between 2 catch-up collections
 }
 
 // MeasurementConfiguration parameters
 type MeasurementConfiguration struct {
-	DomainAbbreviation   string           `mapstructure:"domainAbbreviation"`   // "Measurement" or "Mfvs"
-	DefaultInterval      time.Duration    `mapstructure:"defaultInterval"`      // Default measurement interval
-	MaxBufferingDuration time.Duration    `mapstructure:"maxBufferingDuration"` // Maximum timeframe size of buffering
-	Prometheus           PrometheusConfig `mapstructure:"prometheus"`           // Prometheus configuration
+	DomainAbbreviation   string                   `mapstructure:"domainAbbreviation"`   // "Measurement" or "Mfvs"
+	DefaultInterval      time.Duration            `mapstructure:"defaultInterval"`      // Default measurement interval
+	MaxBufferingDuration time.Duration            `mapstructure:"maxBufferingDuration"` // Maximum timeframe size of buffering
+	Backfill             BackfillConfiguration    `mapstructure:"backfill"`             // Catch-up of long timeframes
+	Prometheus           PrometheusConfig         `mapstructure:"prometheus"`           // Prometheus configuration
+	Host                 HostMetricsConfiguration `mapstructure:"host"`                 // Built-in host metrics configuration
 }
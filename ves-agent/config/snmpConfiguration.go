@@ -0,0 +1,41 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package config
+
+// SNMPConfiguration parameters of the SNMP trap receiver.
+// The receiver is disabled if no bind address is configured
+type SNMPConfiguration struct {
+	Bind      string     `mapstructure:"bind"`      // UDP address to listen on for traps, eg: 0.0.0.0:162
+	Community string     `mapstructure:"community"` // Community traps must have. Any community is accepted if empty
+	Traps     []TrapRule `mapstructure:"traps"`     // Mapping rules. The first rule matching a trap applies. Traps matching no rule are dropped
+}
+
+// Enabled returns true if the trap receiver has a bind address configured
+func (cfg SNMPConfiguration) Enabled() bool {
+	return cfg.Bind != ""
+}
+
+// TrapRule defines how to map SNMPv2c traps into alerts, which are then processed like
+// the ones received from Alertmanager. Values of `Labels` and `Annotations` are template expressions
+type TrapRule struct {
+	Name        string            `mapstructure:"name"`        // Rule name, for logging purpose
+	RaiseOID    string            `mapstructure:"raiseOid"`    // snmpTrapOID of the traps raising the fault
+	ClearOID    string            `mapstructure:"clearOid"`    // snmpTrapOID of the traps clearing the fault. Optional
+	Varbinds    map[string]string `mapstructure:"varbinds"`    // Names of varbinds used in templates, with their OID. Instance suffixes of varbind OIDs are ignored
+	Labels      map[string]string `mapstructure:"labels"`      // Labels of the alert. `alertname` is required, and raise and clear traps must give the same labels
+	Annotations map[string]string `mapstructure:"annotations"` // Annotations of the alert
+}
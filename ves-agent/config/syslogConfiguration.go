@@ -0,0 +1,51 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package config
+
+import "time"
+
+// SyslogConfiguration parameters of the syslog listener, forwarding syslog messages as VES syslog events.
+// The listener is disabled if neither an UDP nor a TCP bind address is configured
+type SyslogConfiguration struct {
+	UDP            string          `mapstructure:"udp"`            // UDP address to listen on, eg: 0.0.0.0:514
+	TCP            string          `mapstructure:"tcp"`            // TCP address to listen on, eg: 0.0.0.0:514
+	MaxConnections int             `mapstructure:"maxConnections"` // Maximum number of concurrent TCP connections. 0 means no limit
+	IdleTimeout    time.Duration   `mapstructure:"idleTimeout"`    // TCP connections are closed when no message is received for this duration. 0 means no timeout
+	Filters        []SyslogFilter  `mapstructure:"filters"`        // Messages must match one of these filters to be forwarded. All are forwarded if empty
+	RateLimit      SyslogRateLimit `mapstructure:"rateLimit"`      // Rate limit of forwarded messages, per source
+	BatchSize      int             `mapstructure:"batchSize"`      // Maximum number of events posted in a batch
+	BatchInterval  time.Duration   `mapstructure:"batchInterval"`  // Maximum time a message waits for its batch to be posted
+	SourceType     string          `mapstructure:"sourceType"`     // VES event source type of the events
+}
+
+// Enabled returns true if the syslog listener has a bind address configured
+func (cfg SyslogConfiguration) Enabled() bool {
+	return cfg.UDP != "" || cfg.TCP != ""
+}
+
+// SyslogFilter selects syslog messages. Empty fields match all messages
+type SyslogFilter struct {
+	Facilities []string `mapstructure:"facilities"` // Facility names (eg: auth, local0) or codes
+	Severity   string   `mapstructure:"severity"`   // Least severe severity name (eg: warning) or code
+	Regex      string   `mapstructure:"regex"`      // Regular expression the message text must match (not anchored)
+}
+
+// SyslogRateLimit parameters of the token bucket limiting the rate of messages from each source
+type SyslogRateLimit struct {
+	Rate  float64 `mapstructure:"rate"`  // Messages per second. 0 means no limit
+	Burst int     `mapstructure:"burst"` // Maximum number of messages in a burst
+}
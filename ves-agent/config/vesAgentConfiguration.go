@@ -28,6 +28,11 @@ type VESAgentConfiguration struct {
 	Measurement      MeasurementConfiguration  `mapstructure:"measurement,omitempty"`
 	Event            govel.EventConfiguration        `mapstructure:"event,omitempty"`
 	AlertManager     AlertManagerConfiguration `mapstructure:"alertManager,omitempty"`
+	Admin            AdminConfiguration        `mapstructure:"admin,omitempty"`
+	Fault            FaultConfiguration        `mapstructure:"fault,omitempty"`
+	SNMP             SNMPConfiguration         `mapstructure:"snmp,omitempty"`
+	Syslog           SyslogConfiguration       `mapstructure:"syslog,omitempty"`
+	Logs             LogConfiguration          `mapstructure:"logs,omitempty"`
 	Cluster          *ClusterConfiguration     `mapstructure:"cluster"` // Optional cluster config. If absent, fallbacks to single node mode
 	Debug            bool                      `mapstructure:"debug,omitempty"`
 	CaCert           string                    `mapstructure:"caCert,omitempty"` // Root certificate content
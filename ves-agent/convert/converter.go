@@ -18,9 +18,9 @@ package convert
 
 import (
 	"github.com/nokia/onap-vespa/govel"
-	"fmt"
-	"strconv"
+	"github.com/nokia/onap-vespa/ves-agent/config"
 	"strings"
+	"time"
 
 	"github.com/prometheus/alertmanager/template"
 	log "github.com/sirupsen/logrus"
@@ -28,14 +28,6 @@ import (
 
 const domain = "Fault"
 
-var severityToPriority = map[govel.Severity]govel.EventPriority{
-	govel.SeverityCritical: govel.PriorityHigh,
-	govel.SeverityMajor:    govel.PriorityMedium,
-	govel.SeverityMinor:    govel.PriorityLow,
-	govel.SeverityWarning:  govel.PriorityLow,
-	govel.SeverityNormal:   govel.PriorityNormal,
-}
-
 // CommitFunc is a function used to commit a fault conversion operation
 type CommitFunc func() error
 
@@ -47,64 +39,83 @@ func mustNotCall() error {
 // AlertToFault convert Alert to VES fault and store it into a map;
 // return status, id and a function used to finalize the operation.
 // The function returned must be called after having successfully sent the alert to VES
-// status could be: inError,alreadyExist, stored, cleared
+// status could be: inError,alreadyExist, stored, cleared, notExist, ignored, suppressed, stateError
 func AlertToFault(alert template.Alert, fm *FaultManager, namingCodes map[string]string) (StatusResult, *govel.EventFault, CommitFunc) {
+	return AlertToFaultFrom(alert, OriginAlertmanager, fm, namingCodes)
+}
+
+// AlertToFaultFrom is the same as AlertToFault, for an alert received from `origin`.
+// The origin is stored with a raised fault
+func AlertToFaultFrom(alert template.Alert, origin AlertOrigin, fm *FaultManager, namingCodes map[string]string) (StatusResult, *govel.EventFault, CommitFunc) {
 	var storeStatus StatusResult
-	var alertName string
-	var specificProblem string
 	//var eventFault *govel.EventFault
 	var id int32
 
 	label := alert.Labels
-	annotations := alert.Annotations
 	log.Debugln("convert alert to VES event fault: ", label["alertname"])
-	severity := govel.Severity(strings.ToUpper(label["severity"]))
-	priority, exist := severityToPriority[severity]
+	level, exist := fm.severities.get(label["severity"])
 	// check severity value consistence
 	if !exist {
-		log.Debugln("Error in severityToPriority convert for severity : " + label["severity"])
-		return InError, nil, mustNotCall
-	}
-	// depending of the alarm type (generic or specific) sourceName can be system_name or VNFC
-	sourceName := label["VNFC"]
-	if sourceName == "" {
-		sourceName = label["system_name"]
+		switch fm.severities.policy {
+		case config.UnknownSeverityDrop:
+			log.Infof("Alert %s dropped, unknown severity: %s", label["alertname"], label["severity"])
+			return Ignored, nil, mustNotCall
+		case config.UnknownSeverityDefault:
+			log.Debugf("Alert %s has unknown severity %s, using default", label["alertname"], label["severity"])
+			level = fm.severities.def
+		default:
+			log.Debugln("Error in severityToPriority convert for severity : " + label["severity"])
+			return InError, nil, mustNotCall
+		}
 	}
+	severity, priority := level.severity, level.priority
 
-	//faultName := label["id"] + "_" + annotations["service"] + "_" + sourceName
-	faultName := buildFaultName(label["id"], annotations["service"], sourceName)
+	// Evaluate mapping rules before touching the storage
+	fault, err := fm.mapping.mapAlert(alert)
+	if err != nil {
+		log.Errorf("Cannot map alert %s to fault: %s", label["alertname"], err.Error())
+		return InError, nil, mustNotCall
+	}
+	faultName := fault.name
+	sourceName := fault.sourceName
+	alertName := fault.condition
 	nfNamingCode := fm.GetEventConf().NfNamingCode
 
 	if alert.Status == "resolved" {
 		storeStatus, id = fm.clearFault(faultName)
-		alertName = annotations["clearAlertName"]
-		specificProblem = annotations["clearDescription"]
 		severity = "NORMAL"
 	} else {
 		storeStatus, id = fm.storeFault(faultName)
-		alertName = label["alertname"]
-		specificProblem = annotations["description"]
 	}
 
-	if storeStatus == InError || storeStatus == NotExist {
+	if storeStatus == InError || storeStatus == NotExist || storeStatus == StateError {
 		return storeStatus, nil, mustNotCall
 	}
+	if storeStatus != Cleared {
+		// Refresh the fault, so that it does not expire
+		if err := fm.state.SetFaultLastSeen(id, time.Now().Unix()); err != nil {
+			log.Error(err.Error())
+			return StateError, nil, mustNotCall
+		}
+	}
 
 	eventName := domain + "_" + nfNamingCode + "_" + alertName
-	vesID := fmt.Sprintf("fault%010d", id)
+	vesID := fm.eventID(id)
 	eventFault := govel.NewFault(
 		eventName,
 		vesID,
 		alertName,
-		specificProblem,
+		fault.specificProblem,
 		priority,
 		severity,
-		govel.SourceVirtualMachine,
-		govel.StatusActive,
+		fault.sourceType,
+		fault.vfStatus,
 		sourceName)
 
 	eventFault.NfNamingCode = nfNamingCode
 	eventFault.NfcNamingCode = namingCodes[sourceName]
+	eventFault.EventCategory = fault.category
+	eventFault.AlarmInterfaceA = fault.interfaceA
 
 	eventFault.Sequence = fm.state.GetFaultSn(id)
 
@@ -113,16 +124,27 @@ func AlertToFault(alert template.Alert, fm *FaultManager, namingCodes map[string
 	if storeStatus == Stored {
 		if err := fm.GetFaultState().SetFaultStartEpoch(id, eventFault.StartEpochMicrosec); err != nil {
 			log.Error(err.Error())
-			return InError, nil, nil
+			return StateError, nil, nil
+		}
+		// Keep the alert, for being able to clear the fault on reconciliation
+		if err := fm.GetFaultState().SetFaultAlert(id, alert.Labels, alert.Annotations, origin); err != nil {
+			log.Error(err.Error())
+			return StateError, nil, nil
 		}
 	} else {
 		eventFault.StartEpochMicrosec = fm.GetFaultState().GetFaultStartEpoch(id)
 	}
 
-	if aaiMapping, exist := annotations["aaiMapping"]; exist {
-		additionalInfos := buildAdditionalInfos(annotations["service"], aaiMapping)
-		if len(additionalInfos) != 0 {
-			eventFault.AlarmAdditionalInformation = additionalInfos
+	if len(fault.additionalInfos) != 0 {
+		eventFault.AlarmAdditionalInformation = fault.additionalInfos
+	}
+
+	hash := contentHash(eventFault)
+	if storeStatus == AlreadyExist {
+		repeat := fm.repeats.get(label["alertname"], severity)
+		if fm.suppressRepeat(id, repeat, hash, time.Now()) {
+			log.Debugf("Repeated alert %s suppressed for fault %s, with policy %s", label["alertname"], vesID, repeat.Policy)
+			return Suppressed, nil, mustNotCall
 		}
 	}
 
@@ -135,14 +157,113 @@ func AlertToFault(alert template.Alert, fm *FaultManager, namingCodes map[string
 			return fm.state.DeleteFaultInStorage(faultName)
 		}
 	} else {
-		commitFunc = func() error {
-			return fm.state.IncrementFaultSn(id)
-		}
+		commitFunc = fm.commitSent(id, eventFault, hash)
 	}
 
 	return storeStatus, eventFault, commitFunc
 }
 
+// mappedFault holds the fault fields mapped from an alert
+type mappedFault struct {
+	name            string // Fault identity
+	sourceName      string
+	condition       string
+	specificProblem string
+	sourceType      govel.SourceType
+	vfStatus        govel.VfStatus
+	category        string
+	interfaceA      string
+	additionalInfos []govel.EventField
+}
+
+// firstNotEmpty returns the first of `values` which is not empty
+func firstNotEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// mapAlert evaluates the fault fields of `alert` using the first matching rule.
+// Fields without rule expression get the default mapping. For cleared alerts, clear expressions
+// fallback to the raise ones
+func (mapping *faultMapping) mapAlert(alert template.Alert) (*mappedFault, error) {
+	var err error
+	label := alert.Labels
+	annotations := alert.Annotations
+	rule := mapping.match(label)
+	matched := rule != nil
+	if matched {
+		log.Debugf("Alert %s matches fault rule %s", label["alertname"], rule.Name)
+	} else {
+		rule = &faultRule{}
+	}
+	data := map[string]interface{}{
+		"labels":      map[string]string(label),
+		"annotations": map[string]string(annotations),
+		"status":      alert.Status,
+	}
+	fault := &mappedFault{}
+
+	// depending of the alarm type (generic or specific) sourceName can be system_name or VNFC
+	if fault.sourceName, err = mapping.eval(rule.SourceName, data, firstNotEmpty(label["VNFC"], label["system_name"])); err != nil {
+		return nil, err
+	}
+	data["sourceName"] = fault.sourceName
+
+	//faultName := label["id"] + "_" + annotations["service"] + "_" + sourceName
+	if fault.name, err = mapping.eval(rule.Identity, data, buildFaultName(label["id"], annotations["service"], fault.sourceName)); err != nil {
+		return nil, err
+	}
+
+	conditionExpr, problemExpr := rule.AlarmCondition, rule.SpecificProblem
+	defCondition, defProblem := label["alertname"], annotations["description"]
+	if alert.Status == "resolved" {
+		conditionExpr = firstNotEmpty(rule.ClearAlarmCondition, rule.AlarmCondition)
+		problemExpr = firstNotEmpty(rule.ClearSpecificProblem, rule.SpecificProblem)
+		defCondition, defProblem = annotations["clearAlertName"], annotations["clearDescription"]
+		if matched {
+			// Alerts handled by rules may not provide clear annotations
+			defCondition = firstNotEmpty(defCondition, label["alertname"])
+		}
+	}
+	if fault.condition, err = mapping.eval(conditionExpr, data, defCondition); err != nil {
+		return nil, err
+	}
+	if fault.specificProblem, err = mapping.eval(problemExpr, data, defProblem); err != nil {
+		return nil, err
+	}
+
+	sourceType, err := mapping.eval(rule.EventSourceType, data, string(govel.SourceVirtualMachine))
+	if err != nil {
+		return nil, err
+	}
+	fault.sourceType = govel.SourceType(sourceType)
+	vfStatus, err := mapping.eval(rule.VfStatus, data, string(govel.StatusActive))
+	if err != nil {
+		return nil, err
+	}
+	fault.vfStatus = govel.VfStatus(vfStatus)
+	if fault.category, err = mapping.eval(rule.EventCategory, data, ""); err != nil {
+		return nil, err
+	}
+	if fault.interfaceA, err = mapping.eval(rule.AlarmInterfaceA, data, ""); err != nil {
+		return nil, err
+	}
+
+	if len(rule.AdditionalInformation) > 0 {
+		fault.additionalInfos, err = mapping.evalAdditionalInformation(rule.AdditionalInformation, data)
+		if err != nil {
+			return nil, err
+		}
+	} else if aaiMapping, exist := annotations["aaiMapping"]; exist {
+		fault.additionalInfos = buildAdditionalInfos(annotations["service"], aaiMapping)
+	}
+	return fault, nil
+}
+
 // buildFaultName built faultName
 // faultName = <id>_<service>_<sourceName>
 func buildFaultName(id string, service string, sourceName string) string {
@@ -158,17 +279,6 @@ func buildFaultName(id string, service string, sourceName string) string {
 	return id + "_" + serv + "_" + sourceName
 }
 
-// GetFaultID return the id of the stored fault
-func GetFaultID(vesID string) int32 {
-	idString := vesID[5:]
-	id, err := strconv.Atoi(idString)
-	if err != nil {
-		log.Warning("problem to extract id from vesID")
-		return 0
-	}
-	return int32(id)
-}
-
 // buildAdditionalInfos build the alarmAdditionalInformation Events
 // aaiMapping contains the information label; format is <label1>*<label2>_...
 // service contains the information value; format is <val1>_<val2>_...
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
 
 	"github.com/stretchr/testify/suite"
 
@@ -140,6 +141,21 @@ var alertData5 = []byte(`
 			}
 	}`)
 
+var alertData6 = []byte(`
+	{
+			"status": "firing",
+			"labels": {
+				"alertname": "KubePodCrashLooping",
+				"severity": "major",
+				"namespace": "payments",
+				"pod": "api-5d8f7",
+				"team": "platform"
+			},
+			"annotations": {
+				"message": "Pod payments/api-5d8f7 is crash looping"
+			}
+	}`)
+
 type ConvertTestSuite struct {
 	suite.Suite
 	alert1      template.Alert
@@ -147,6 +163,7 @@ type ConvertTestSuite struct {
 	alert3      template.Alert
 	alert4      template.Alert
 	alert5      template.Alert
+	alert6      template.Alert
 	confEvent   govel.EventConfiguration
 	namingCodes map[string]string
 }
@@ -186,6 +203,10 @@ func (suite *ConvertTestSuite) SetupSuite() {
 	if err != nil {
 		suite.Fail("Error in unmarshall function for test alert5")
 	}
+	err = json.Unmarshal(alertData6, &suite.alert6)
+	if err != nil {
+		suite.Fail("Error in unmarshall function for test alert6")
+	}
 }
 
 func (suite *ConvertTestSuite) TestConvertAlertRaiseOK() {
@@ -422,3 +443,187 @@ func (suite *ConvertTestSuite) TestConvertAlert305Ok() {
 	suite.Equal("Memory high occupancy", eventFault.SpecificProblem)
 
 }
+
+var faultConf = config.FaultConfiguration{
+	Rules: []config.FaultRule{
+		{
+			Name:     "platform",
+			Matchers: []config.Matcher{{Label: "team", Regex: "platform|infra"}, {Label: "namespace", Regex: ".+"}},
+			SourceName:           "{{.labels.namespace}}-{{.labels.pod}}",
+			SpecificProblem:      "{{.annotations.message}}",
+			ClearSpecificProblem: "{{.labels.alertname}} resolved",
+			EventSourceType:      "other",
+			VfStatus:             "Idle",
+			EventCategory:        "{{.labels.team | upper}}",
+			AlarmInterfaceA:      "{{.labels.pod}}",
+			Identity:             "{{.labels.alertname}}_{{.sourceName}}",
+			AdditionalInformation: []config.Label{
+				{Name: "namespace", Expr: "{{.labels.namespace}}"},
+				{Name: "container", Expr: "{{.labels.container}}"},
+			},
+		},
+		{
+			Name:     "never",
+			Matchers: []config.Matcher{{Label: "alertname", Regex: "KubePodCrashLooping"}},
+			SourceName: "never",
+		},
+	},
+}
+
+func (suite *ConvertTestSuite) TestConvertAlertWithRule() {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &faultConf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+
+	status, eventFault, commit := AlertToFault(suite.alert6, fm, suite.namingCodes)
+	suite.Require().Equal(Stored, status)
+	suite.NoError(commit())
+	suite.Equal("fault0000000001", eventFault.EventID)
+	suite.Equal("Fault_hspx_KubePodCrashLooping", eventFault.EventName)
+	suite.Equal("KubePodCrashLooping", eventFault.AlarmCondition)
+	suite.Equal("Pod payments/api-5d8f7 is crash looping", eventFault.SpecificProblem)
+	suite.Equal("payments-api-5d8f7", eventFault.SourceName)
+	suite.Equal("MAJOR", string(eventFault.EventSeverity))
+	suite.Equal(govel.SourceOther, eventFault.EventSourceType)
+	suite.Equal(govel.StatusIdle, eventFault.VfStatus)
+	suite.Equal("PLATFORM", eventFault.EventCategory)
+	suite.Equal("api-5d8f7", eventFault.AlarmInterfaceA)
+	suite.Equal([]govel.EventField{{Name: "namespace", Value: "payments"}}, eventFault.AlarmAdditionalInformation)
+	suite.Equal(int32(1), fm.GetFaultState().GetFaultInStorage("KubePodCrashLooping_payments-api-5d8f7"))
+
+	// Clear uses the same identity, and fallbacks to the raise alarm condition
+	alert := suite.alert6
+	alert.Status = "resolved"
+	status, eventFault, commit = AlertToFault(alert, fm, suite.namingCodes)
+	suite.Require().Equal(Cleared, status)
+	suite.NoError(commit())
+	suite.Equal("fault0000000001", eventFault.EventID)
+	suite.Equal("KubePodCrashLooping", eventFault.AlarmCondition)
+	suite.Equal("KubePodCrashLooping resolved", eventFault.SpecificProblem)
+	suite.Equal("NORMAL", string(eventFault.EventSeverity))
+	suite.Equal(int32(0), fm.GetFaultState().GetFaultInStorage("KubePodCrashLooping_payments-api-5d8f7"))
+}
+
+func (suite *ConvertTestSuite) TestConvertAlertWithoutMatchingRule() {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &faultConf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+
+	// alert4 doesn't match any rule: default mapping applies
+	status, eventFault, _ := AlertToFault(suite.alert4, fm, suite.namingCodes)
+	suite.Require().Equal(Stored, status)
+	suite.Equal("FileSystemOccupancyCrossedLowThreshold", eventFault.AlarmCondition)
+	suite.Equal("mjves-ope-1", eventFault.SourceName)
+	suite.Equal(govel.SourceVirtualMachine, eventFault.EventSourceType)
+	suite.Equal(govel.StatusActive, eventFault.VfStatus)
+	suite.Equal("", eventFault.EventCategory)
+	suite.Equal("FileSystemName", eventFault.AlarmAdditionalInformation[0].Name)
+}
+
+func (suite *ConvertTestSuite) TestConvertAlertRuleError() {
+	conf := config.FaultConfiguration{Rules: []config.FaultRule{{Name: "failing", SourceName: `{{fail "boom"}}`}}}
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+
+	status, eventFault, _ := AlertToFault(suite.alert1, fm, suite.namingCodes)
+	suite.Equal(InError, status)
+	suite.Nil(eventFault)
+	// Nothing has been stored
+	idx, _ := fm.GetFaultState().NextFaultIndex(0)
+	suite.Equal(int32(1), idx)
+}
+
+func (suite *ConvertTestSuite) TestInvalidFaultRules() {
+	for _, rule := range []config.FaultRule{
+		{Name: "regex", Matchers: []config.Matcher{{Label: "team", Regex: "(platform"}}},
+		{Name: "label", Matchers: []config.Matcher{{Regex: "platform"}}},
+		{Name: "template", SourceName: "{{.labels.pod"},
+		{Name: "info", AdditionalInformation: []config.Label{{Name: "pod", Expr: "{{.labels.pod"}}},
+	} {
+		_, err := NewFaultManagerWithConfig(&suite.confEvent, &config.FaultConfiguration{Rules: []config.FaultRule{rule}}, nil)
+		suite.Error(err, rule.Name)
+	}
+}
+
+// withSeverity returns a copy of `alert` with label severity set to `severity`
+func withSeverity(alert template.Alert, severity string) template.Alert {
+	labels := make(template.KV, len(alert.Labels))
+	for k, v := range alert.Labels {
+		labels[k] = v
+	}
+	labels["severity"] = severity
+	alert.Labels = labels
+	return alert
+}
+
+var severityConf = config.FaultConfiguration{
+	Severities: []config.SeverityMapping{
+		{Value: "critical", Severity: "critical", Priority: "medium"},
+		{Value: "error", Severity: "major"},
+		{Value: "info", Severity: "normal", Priority: "low"},
+	},
+}
+
+func (suite *ConvertTestSuite) TestConvertAlertSeverityMapping() {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &severityConf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+
+	for _, tc := range []struct {
+		value    string
+		severity govel.Severity
+		priority govel.EventPriority
+	}{
+		{"Critical", govel.SeverityCritical, govel.PriorityMedium},
+		{"error", govel.SeverityMajor, govel.PriorityMedium},
+		{"warning", govel.SeverityWarning, govel.PriorityLow},
+		{"INFO", govel.SeverityNormal, govel.PriorityLow},
+	} {
+		status, eventFault, commit := AlertToFault(withSeverity(suite.alert1, tc.value), fm, suite.namingCodes)
+		suite.Require().NotEqual(InError, status, tc.value)
+		suite.Equal(tc.severity, eventFault.EventSeverity, tc.value)
+		suite.Equal(tc.priority, eventFault.Priority, tc.value)
+		suite.NoError(commit())
+	}
+}
+
+func (suite *ConvertTestSuite) TestConvertAlertUnknownSeverity() {
+	alert := withSeverity(suite.alert1, "debug")
+
+	// Rejected by default
+	status, eventFault, _ := AlertToFault(alert, NewFaultManager(&suite.confEvent), suite.namingCodes)
+	suite.Equal(InError, status)
+	suite.Nil(eventFault)
+
+	// Dropped
+	conf := config.FaultConfiguration{UnknownSeverity: config.UnknownSeverityDrop}
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+	status, eventFault, _ = AlertToFault(alert, fm, suite.namingCodes)
+	suite.Equal(Ignored, status)
+	suite.Nil(eventFault)
+	idx, _ := fm.GetFaultState().NextFaultIndex(0)
+	suite.Equal(int32(1), idx)
+
+	// Mapped to default
+	conf = config.FaultConfiguration{
+		UnknownSeverity: config.UnknownSeverityDefault,
+		DefaultSeverity: config.SeverityMapping{Severity: "minor"},
+	}
+	fm, err = NewFaultManagerWithConfig(&suite.confEvent, &conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+	status, eventFault, _ = AlertToFault(alert, fm, suite.namingCodes)
+	suite.Require().Equal(Stored, status)
+	suite.Equal(govel.SeverityMinor, eventFault.EventSeverity)
+	suite.Equal(govel.PriorityLow, eventFault.Priority)
+}
+
+func (suite *ConvertTestSuite) TestInvalidSeverities() {
+	for name, conf := range map[string]config.FaultConfiguration{
+		"severity": {Severities: []config.SeverityMapping{{Value: "error", Severity: "bad"}}},
+		"priority": {Severities: []config.SeverityMapping{{Value: "error", Severity: "major", Priority: "urgent"}}},
+		"value":    {Severities: []config.SeverityMapping{{Severity: "major"}}},
+		"policy":   {UnknownSeverity: "ignore"},
+		"default":  {UnknownSeverity: config.UnknownSeverityDefault},
+	} {
+		_, err := NewFaultManagerWithConfig(&suite.confEvent, &conf, nil)
+		suite.Error(err, name)
+	}
+}
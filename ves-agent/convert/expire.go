@@ -0,0 +1,136 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	log "github.com/sirupsen/logrus"
+)
+
+// TTLAnnotation is the annotation of alerts giving the time to live of the fault they raise.
+// It overrides the configured TTLs
+const TTLAnnotation = "faultTTL"
+
+// expiration holds the faults time to live configuration
+type expiration struct {
+	ttls []config.FaultTTL
+	def  time.Duration
+}
+
+// newExpiration validates the faults time to live from `conf`
+func newExpiration(conf *config.FaultConfiguration) (*expiration, error) {
+	exp := &expiration{}
+	if conf == nil {
+		return exp, nil
+	}
+	if conf.DefaultTTL < 0 {
+		return nil, fmt.Errorf("Invalid negative default TTL: %s", conf.DefaultTTL)
+	}
+	for _, ttl := range conf.TTLs {
+		if ttl.TTL < 0 {
+			return nil, fmt.Errorf("Invalid negative TTL %s for alert %q and severity %q", ttl.TTL, ttl.AlertName, ttl.Severity)
+		}
+		if _, ok := severityToPriority[govel.Severity(strings.ToUpper(ttl.Severity))]; ttl.Severity != "" && !ok {
+			return nil, fmt.Errorf("Invalid VES severity %q in TTL", ttl.Severity)
+		}
+	}
+	exp.ttls, exp.def = conf.TTLs, conf.DefaultTTL
+	return exp, nil
+}
+
+// ttl returns the time to live of a fault raised by an alert with `labels`.
+// 0 means the fault never expires
+func (fm *FaultManager) ttl(labels map[string]string) time.Duration {
+	level, ok := fm.severities.get(labels["severity"])
+	if !ok {
+		level = fm.severities.def
+	}
+	for _, ttl := range fm.expiration.ttls {
+		if matchFault(ttl.AlertName, ttl.Severity, labels["alertname"], level.severity) {
+			return ttl.TTL
+		}
+	}
+	return fm.expiration.def
+}
+
+// alertTTL returns the time to live of a fault raised by an alert with `labels` and `annotations`,
+// taken from the TTL annotation if valid, and from the configured TTLs otherwise
+func (fm *FaultManager) alertTTL(labels, annotations map[string]string) time.Duration {
+	if value, ok := annotations[TTLAnnotation]; ok {
+		ttl, err := time.ParseDuration(value)
+		if err == nil && ttl >= 0 {
+			return ttl
+		}
+		log.Warnf("Expiration: ignoring invalid %s annotation %q of alert %s", TTLAnnotation, value, labels["alertname"])
+	}
+	return fm.ttl(labels)
+}
+
+// Sweeper is a scheduler job looking for faults whose alert has not been received
+// for longer than their time to live. It returns resolved alerts for these faults
+type Sweeper struct {
+	fm *FaultManager
+}
+
+// NewSweeper creates a new sweeper of the faults of `fm`
+func NewSweeper(fm *FaultManager) *Sweeper {
+	return &Sweeper{fm: fm}
+}
+
+// Run the sweeper. The returned value is a slice of template.Alert
+func (sw *Sweeper) Run(from, to time.Time, interval time.Duration) (interface{}, error) {
+	state := sw.fm.GetFaultState()
+	faults := state.GetFaultsInStorage()
+	names := make([]string, 0, len(faults))
+	for name := range faults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	alerts := []template.Alert{}
+	for _, name := range names {
+		id := faults[name]
+		labels, annotations := state.GetFaultAlert(id)
+		ttl := sw.fm.alertTTL(labels, annotations)
+		if ttl <= 0 {
+			continue
+		}
+		lastSeen := time.Unix(state.GetFaultLastSeen(id), 0)
+		if state.GetFaultLastSeen(id) == 0 {
+			// Fault raised before last seen time was recorded
+			lastSeen = time.Unix(0, state.GetFaultStartEpoch(id)*int64(time.Microsecond))
+		}
+		if to.Sub(lastSeen) < ttl {
+			continue
+		}
+		if labels == nil {
+			log.Warnf("Expiration: cannot clear fault %s, its alert is unknown", name)
+			continue
+		}
+		log.Infof("Expiration: clearing fault %s, not seen since %s (TTL: %s)", name, lastSeen, ttl)
+		alerts = append(alerts, template.Alert{Status: "resolved", Labels: labels, Annotations: annotations, EndsAt: to})
+	}
+	return alerts, nil
+}
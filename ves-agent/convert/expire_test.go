@@ -0,0 +1,164 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExpireTestSuite struct {
+	suite.Suite
+	confEvent govel.EventConfiguration
+	conf      config.FaultConfiguration
+	raised    []template.Alert // Test alerts, from alertData1 to alertData5
+}
+
+func TestExpire(t *testing.T) {
+	suite.Run(t, new(ExpireTestSuite))
+}
+
+func (suite *ExpireTestSuite) SetupSuite() {
+	suite.confEvent = govel.EventConfiguration{MaxSize: 200, NfNamingCode: "hspx"}
+	suite.conf = config.FaultConfiguration{
+		TTLs: []config.FaultTTL{
+			{AlertName: "NodeFailure", TTL: 0},
+			{Severity: "minor", TTL: time.Hour},
+		},
+		DefaultTTL: 24 * time.Hour,
+	}
+	for _, data := range [][]byte{alertData1, alertData2, alertData3, alertData4, alertData5} {
+		var alert template.Alert
+		suite.Require().NoError(json.Unmarshal(data, &alert))
+		suite.raised = append(suite.raised, alert)
+	}
+}
+
+func (suite *ExpireTestSuite) TestTTL() {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &suite.conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+	suite.EqualValues(0, fm.ttl(suite.raised[0].Labels))
+	suite.Equal(time.Hour, fm.ttl(suite.raised[2].Labels))
+	suite.Equal(time.Hour, fm.ttl(suite.raised[3].Labels))
+	suite.Equal(24*time.Hour, fm.ttl(withSeverity(suite.raised[3], "major").Labels))
+	suite.Equal(24*time.Hour, fm.ttl(nil))
+
+	// Without configuration, faults never expire
+	suite.EqualValues(0, NewFaultManager(&suite.confEvent).ttl(suite.raised[3].Labels))
+}
+
+func (suite *ExpireTestSuite) TestAlertTTL() {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &suite.conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+	alert := suite.raised[3]
+	suite.Equal(time.Hour, fm.alertTTL(alert.Labels, alert.Annotations))
+	suite.Equal(10*time.Minute, fm.alertTTL(alert.Labels, map[string]string{TTLAnnotation: "10m"}))
+	suite.EqualValues(0, fm.alertTTL(alert.Labels, map[string]string{TTLAnnotation: "0s"}))
+	// Invalid values are ignored
+	suite.Equal(time.Hour, fm.alertTTL(alert.Labels, map[string]string{TTLAnnotation: "soon"}))
+	suite.Equal(time.Hour, fm.alertTTL(alert.Labels, map[string]string{TTLAnnotation: "-1m"}))
+
+	// The annotation applies without configured TTLs
+	suite.Equal(time.Minute, NewFaultManager(&suite.confEvent).alertTTL(alert.Labels, map[string]string{TTLAnnotation: "1m"}))
+}
+
+func (suite *ExpireTestSuite) TestSweep() {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &suite.conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+	state := fm.GetFaultState()
+	for _, alert := range []template.Alert{suite.raised[0], suite.raised[2], suite.raised[3], suite.raised[4]} {
+		status, _, commit := AlertToFault(alert, fm, nil)
+		suite.Require().Equal(Stored, status)
+		suite.NoError(commit())
+	}
+	for _, id := range state.GetFaultsInStorage() {
+		suite.InDelta(time.Now().Unix(), state.GetFaultLastSeen(id), 1)
+	}
+
+	// Nothing expired yet
+	res, err := NewSweeper(fm).Run(time.Now(), time.Now(), time.Minute)
+	suite.Require().NoError(err)
+	suite.Empty(res)
+
+	// alert3 and alert5 are not refreshed, while alert4 is
+	later := time.Now().Add(2 * time.Hour)
+	for _, id := range state.GetFaultsInStorage() {
+		suite.NoError(state.SetFaultLastSeen(id, later.Add(-90*time.Minute).Unix()))
+	}
+	status, _, _ := AlertToFault(suite.raised[3], fm, nil)
+	suite.Require().Equal(AlreadyExist, status)
+	fault, err := fm.mapping.mapAlert(suite.raised[3])
+	suite.Require().NoError(err)
+	suite.NoError(state.SetFaultLastSeen(state.GetFaultInStorage(fault.name), later.Add(-time.Minute).Unix()))
+
+	res, err = NewSweeper(fm).Run(later, later, time.Minute)
+	suite.Require().NoError(err)
+	alerts := res.([]template.Alert)
+	suite.Require().Len(alerts, 2)
+	suite.Equal("resolved", alerts[0].Status)
+	suite.Equal(suite.raised[2].Labels["alertname"], alerts[0].Labels["alertname"])
+	suite.Equal("resolved", alerts[1].Status)
+	suite.Equal(suite.raised[4].Labels["alertname"], alerts[1].Labels["alertname"])
+
+	// Clear is sent with NORMAL severity
+	status, eventFault, commit := AlertToFault(alerts[0], fm, nil)
+	suite.Require().Equal(Cleared, status)
+	suite.Equal(govel.SeverityNormal, eventFault.EventSeverity)
+	suite.Equal(suite.raised[2].Annotations["clearAlertName"], eventFault.AlarmCondition)
+	suite.NoError(commit())
+	suite.Len(state.GetFaultsInStorage(), 3)
+}
+
+func (suite *ExpireTestSuite) TestSweepWithoutLastSeen() {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &suite.conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+	state := fm.GetFaultState()
+
+	// Fault raised before last seen time was recorded expires from its start time
+	id, _ := state.NextFaultIndex(0)
+	suite.NoError(state.StoreFaultInStorage("old", id))
+	suite.NoError(state.InitAlertInfos(id, ""))
+	suite.NoError(state.SetFaultStartEpoch(id, time.Now().Add(-25*time.Hour).UnixNano()/int64(time.Microsecond)))
+	suite.NoError(state.SetFaultAlert(id, suite.raised[4].Labels, suite.raised[4].Annotations, OriginAlertmanager))
+	res, err := NewSweeper(fm).Run(time.Now(), time.Now(), time.Minute)
+	suite.Require().NoError(err)
+	suite.Len(res, 1)
+
+	// Fault without alert cannot be cleared
+	suite.NoError(state.SetFaultAlert(id, nil, nil, OriginAlertmanager))
+	res, err = NewSweeper(fm).Run(time.Now(), time.Now(), time.Minute)
+	suite.Require().NoError(err)
+	suite.Empty(res)
+}
+
+func (suite *ExpireTestSuite) TestInvalidTTL() {
+	for name, conf := range map[string]config.FaultConfiguration{
+		"default":  {DefaultTTL: -time.Minute},
+		"ttl":      {TTLs: []config.FaultTTL{{AlertName: "NodeFailure", TTL: -time.Minute}}},
+		"severity": {TTLs: []config.FaultTTL{{Severity: "bad", TTL: time.Minute}}},
+	} {
+		_, err := NewFaultManagerWithConfig(&suite.confEvent, &conf, nil)
+		suite.Error(err, name)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Errors returned by manual fault operations
+var (
+	// ErrFaultNotFound is returned when the fault is not active
+	ErrFaultNotFound = errors.New("Fault not found")
+	// ErrNoFaultEvent is returned when no event was recorded for the fault,
+	// which is the case of faults raised by older agent versions
+	ErrNoFaultEvent = errors.New("No event recorded for the fault")
+)
+
+// Fault describes an active fault
+type Fault struct {
+	ID          int32
+	Name        string // Fault identity
+	EventID     string
+	Sequence    int64 // Sequence number of the next event
+	StartEpoch  int64 // Epoch time (in microseconds) at which the fault was raised
+	SourceName  string
+	Labels      map[string]string // Labels of the alert which raised the fault
+	Annotations map[string]string // Annotations of the alert which raised the fault
+	LastSeen    int64             // Epoch time (in seconds) at which the alert was last received
+	LastSent    int64             // Epoch time (in seconds) at which the last event was sent
+	Event       []byte            // JSON payload of the last event sent, if known
+}
+
+// fault returns the description of the fault `name` stored with index `id`
+func (fm *FaultManager) fault(name string, id int32) Fault {
+	labels, annotations := fm.state.GetFaultAlert(id)
+	_, lastSent := fm.state.GetFaultSent(id)
+	fault := Fault{
+		ID:          id,
+		Name:        name,
+		EventID:     fm.eventID(id),
+		Sequence:    fm.state.GetFaultSn(id),
+		StartEpoch:  fm.state.GetFaultStartEpoch(id),
+		Labels:      labels,
+		Annotations: annotations,
+		LastSeen:    fm.state.GetFaultLastSeen(id),
+		LastSent:    lastSent,
+		Event:       fm.state.GetFaultEvent(id),
+	}
+	if fault.Event != nil {
+		event := govel.EventFault{}
+		if err := json.Unmarshal(fault.Event, &event); err != nil {
+			log.Warnf("Cannot decode last event of fault %s: %s", name, err.Error())
+		}
+		fault.SourceName = event.SourceName
+	}
+	return fault
+}
+
+// Faults returns the active faults, ordered by ID
+func (fm *FaultManager) Faults() []Fault {
+	stored := fm.state.GetFaultsInStorage()
+	faults := make([]Fault, 0, len(stored))
+	for name, id := range stored {
+		faults = append(faults, fm.fault(name, id))
+	}
+	sort.Slice(faults, func(i, j int) bool { return faults[i].ID < faults[j].ID })
+	return faults
+}
+
+// Fault returns the active fault with index `id`, if any
+func (fm *FaultManager) Fault(id int32) (Fault, bool) {
+	for name, faultID := range fm.state.GetFaultsInStorage() {
+		if faultID == id {
+			return fm.fault(name, id), true
+		}
+	}
+	return Fault{}, false
+}
+
+// commitSent returns a function recording that `event`, with content `hash`, was sent for fault `id`
+func (fm *FaultManager) commitSent(id int32, event *govel.EventFault, hash string) CommitFunc {
+	return func() error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return fm.state.CommitFaultSent(id, hash, time.Now().Unix(), payload)
+	}
+}
+
+// lastEvent returns a copy of the last event sent for fault `id`, updated with the next
+// sequence number and the current time
+func (fm *FaultManager) lastEvent(id int32) (Fault, *govel.EventFault, error) {
+	fault, ok := fm.Fault(id)
+	if !ok {
+		return fault, nil, ErrFaultNotFound
+	}
+	if fault.Event == nil {
+		return fault, nil, ErrNoFaultEvent
+	}
+	event := new(govel.EventFault)
+	if err := json.Unmarshal(fault.Event, event); err != nil {
+		return fault, nil, fmt.Errorf("Cannot decode last event of fault %s: %s", fault.Name, err.Error())
+	}
+	event.Sequence = fault.Sequence
+	event.LastEpochMicrosec = time.Now().UnixNano() / 1000
+	return fault, event, nil
+}
+
+// ResendFault builds a new event repeating the last event sent for the active fault `id`,
+// whatever its repeat policy is. The function returned must be called after having successfully
+// sent the event to VES
+func ResendFault(id int32, fm *FaultManager) (*govel.EventFault, CommitFunc, error) {
+	fault, event, err := fm.lastEvent(id)
+	if err != nil {
+		return nil, mustNotCall, err
+	}
+	log.Infof("Manual resend of fault %s with id %d", fault.Name, id)
+	return event, fm.commitSent(id, event, contentHash(event)), nil
+}
+
+// ClearFault builds a NORMAL event clearing the active fault `id`, from the last event sent for it.
+// The function returned deletes the fault, it must be called after having successfully
+// sent the event to VES
+func ClearFault(id int32, fm *FaultManager) (*govel.EventFault, CommitFunc, error) {
+	fault, event, err := fm.lastEvent(id)
+	if err != nil {
+		return nil, mustNotCall, err
+	}
+	log.Infof("Manual clear of fault %s with id %d", fault.Name, id)
+	event.EventSeverity = govel.SeverityNormal
+	return event, func() error {
+		return fm.state.DeleteFaultInStorage(fault.Name)
+	}, nil
+}
@@ -0,0 +1,142 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/suite"
+)
+
+type FaultsTestSuite struct {
+	suite.Suite
+	confEvent govel.EventConfiguration
+	conf      config.FaultConfiguration
+	raised    []template.Alert // Test alerts: alertData1 and alertData6
+}
+
+func TestFaults(t *testing.T) {
+	suite.Run(t, new(FaultsTestSuite))
+}
+
+func (suite *FaultsTestSuite) SetupSuite() {
+	suite.confEvent = govel.EventConfiguration{MaxSize: 200, NfNamingCode: "hspx"}
+	suite.conf = config.FaultConfiguration{
+		DefaultRepeat: config.FaultRepeat{Policy: config.RepeatOnChange},
+	}
+	for _, data := range [][]byte{alertData1, alertData6} {
+		var alert template.Alert
+		suite.Require().NoError(json.Unmarshal(data, &alert))
+		suite.raised = append(suite.raised, alert)
+	}
+}
+
+// raise creates a fault manager, with the test alerts raised and sent
+func (suite *FaultsTestSuite) raise() *FaultManager {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &suite.conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+	for _, alert := range suite.raised {
+		status, _, commit := AlertToFault(alert, fm, nil)
+		suite.Require().Equal(Stored, status)
+		suite.Require().NoError(commit())
+	}
+	return fm
+}
+
+func (suite *FaultsTestSuite) TestFaults() {
+	fm := suite.raise()
+	faults := fm.Faults()
+	suite.Require().Len(faults, 2)
+	suite.EqualValues(1, faults[0].ID)
+	suite.Equal("201_NodeSupervision_dpa2bhsxp5001vm001oam001", faults[0].Name)
+	suite.Equal("fault0000000001", faults[0].EventID)
+	suite.EqualValues(2, faults[0].Sequence)
+	suite.Equal("dpa2bhsxp5001vm001oam001", faults[0].SourceName)
+	suite.Equal("NodeFailure", faults[0].Labels["alertname"])
+	suite.InDelta(time.Now().Unix(), faults[0].LastSent, 1)
+	suite.EqualValues(2, faults[1].ID)
+
+	event := govel.EventFault{}
+	suite.Require().NoError(json.Unmarshal(faults[0].Event, &event))
+	suite.Equal("fault0000000001", event.EventID)
+	suite.EqualValues(1, event.Sequence)
+	suite.Equal(faults[0].StartEpoch, event.StartEpochMicrosec)
+
+	fault, ok := fm.Fault(2)
+	suite.True(ok)
+	suite.Equal(faults[1], fault)
+	_, ok = fm.Fault(3)
+	suite.False(ok)
+}
+
+func (suite *FaultsTestSuite) TestResend() {
+	fm := suite.raise()
+	last, _ := fm.Fault(1)
+
+	// Repeated alert is suppressed by its policy, while manual resend is not
+	status, _, _ := AlertToFault(suite.raised[0], fm, nil)
+	suite.Equal(Suppressed, status)
+	event, commit, err := ResendFault(1, fm)
+	suite.Require().NoError(err)
+	suite.EqualValues(2, event.Sequence)
+	suite.Equal(last.StartEpoch, event.StartEpochMicrosec)
+	suite.True(event.LastEpochMicrosec >= event.StartEpochMicrosec)
+	suite.Equal(govel.SeverityCritical, event.EventSeverity)
+	suite.NoError(commit())
+
+	fault, _ := fm.Fault(1)
+	suite.EqualValues(3, fault.Sequence)
+	sent := govel.EventFault{}
+	suite.Require().NoError(json.Unmarshal(fault.Event, &sent))
+	suite.EqualValues(2, sent.Sequence)
+
+	_, _, err = ResendFault(3, fm)
+	suite.Equal(ErrFaultNotFound, err)
+}
+
+func (suite *FaultsTestSuite) TestClear() {
+	fm := suite.raise()
+	event, commit, err := ClearFault(2, fm)
+	suite.Require().NoError(err)
+	suite.Equal(govel.SeverityNormal, event.EventSeverity)
+	suite.Equal("fault0000000002", event.EventID)
+	suite.EqualValues(2, event.Sequence)
+	suite.NoError(commit())
+
+	_, ok := fm.Fault(2)
+	suite.False(ok)
+	suite.Len(fm.Faults(), 1)
+	_, _, err = ClearFault(2, fm)
+	suite.Equal(ErrFaultNotFound, err)
+}
+
+func (suite *FaultsTestSuite) TestNoEvent() {
+	fm := suite.raise()
+	suite.NoError(fm.GetFaultState().SetFaultEvent(1, nil))
+	fault, _ := fm.Fault(1)
+	suite.Equal("", fault.SourceName)
+	_, _, err := ClearFault(1, fm)
+	suite.Equal(ErrNoFaultEvent, err)
+	_, _, err = ResendFault(1, fm)
+	suite.Equal(ErrNoFaultEvent, err)
+}
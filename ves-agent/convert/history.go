@@ -0,0 +1,143 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+
+	"github.com/prometheus/alertmanager/template"
+	log "github.com/sirupsen/logrus"
+)
+
+// FaultAction is the kind of operation recorded in the fault history
+type FaultAction string
+
+// Possible values for FaultAction
+const (
+	FaultRaise          FaultAction = "raise"          // New fault raised
+	FaultRepeat         FaultAction = "repeat"         // Repeated notification of a fault, with unchanged severity
+	FaultSeverityChange FaultAction = "severityChange" // Repeated notification of a fault, with a new severity
+	FaultClear          FaultAction = "clear"          // Fault cleared by a resolved alert
+	FaultExpire         FaultAction = "expire"         // Fault cleared by expiration, its alert not being received for its TTL
+	FaultReconcile      FaultAction = "reconcile"      // Fault cleared by reconciliation, its alert being no longer active in Alertmanager
+	FaultManualClear    FaultAction = "manualClear"    // Fault cleared through the administration API
+	FaultManualResend   FaultAction = "manualResend"   // Fault re-sent through the administration API
+)
+
+// FaultOutcome is the result of sending a fault event to VES collector
+type FaultOutcome string
+
+// Possible values for FaultOutcome
+const (
+	FaultSent   FaultOutcome = "sent"
+	FaultFailed FaultOutcome = "failed"
+)
+
+// FaultRecord is an entry of the fault history
+type FaultRecord struct {
+	ID               int64
+	Time             int64 // Epoch time (in seconds) at which the event was sent
+	Action           FaultAction
+	EventID          string
+	AlertName        string
+	SourceName       string
+	Severity         govel.Severity
+	PreviousSeverity govel.Severity // Severity of the previous event sent for the fault, if any
+	Sequence         int64
+	Outcome          FaultOutcome
+	Error            string // Error of the sending attempt, if any
+}
+
+// FaultHistoryState handles the history of the events sent for faults
+type FaultHistoryState interface {
+	// AppendFaultRecord appends `record` to the history, with the next record ID.
+	// Only the `retain` most recent records are kept
+	AppendFaultRecord(record FaultRecord, retain int) error
+	// GetFaultHistory returns the fault history, ordered by ID
+	GetFaultHistory() []FaultRecord
+}
+
+// FaultHistoryFilter selects fault history records. Empty fields match any record
+type FaultHistoryFilter struct {
+	SourceName string
+	AlertName  string
+	From, To   time.Time
+}
+
+// Match returns true if `record` matches the filter
+func (filter FaultHistoryFilter) Match(record FaultRecord) bool {
+	at := time.Unix(record.Time, 0)
+	return (filter.SourceName == "" || filter.SourceName == record.SourceName) &&
+		(filter.AlertName == "" || filter.AlertName == record.AlertName) &&
+		(filter.From.IsZero() || !at.Before(filter.From)) &&
+		(filter.To.IsZero() || at.Before(filter.To))
+}
+
+// lastSeverity returns the severity of the last event sent for fault `faultID`, if known
+func (fm *FaultManager) lastSeverity(faultID int32) govel.Severity {
+	payload := fm.state.GetFaultEvent(faultID)
+	if payload == nil {
+		return ""
+	}
+	event := govel.EventFault{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		log.Warnf("Cannot decode last event of fault %010d: %s", faultID, err.Error())
+	}
+	return event.EventSeverity
+}
+
+// record returns the history record of `action` sending `event`, for the fault raised by the alert named `alertName`
+func (fm *FaultManager) record(action FaultAction, alertName string, event *govel.EventFault) FaultRecord {
+	return FaultRecord{
+		Action:           action,
+		EventID:          event.EventID,
+		AlertName:        firstNotEmpty(alertName, event.AlarmCondition),
+		SourceName:       event.SourceName,
+		Severity:         event.EventSeverity,
+		PreviousSeverity: fm.lastSeverity(fm.GetFaultID(event.EventID)),
+		Sequence:         event.Sequence,
+	}
+}
+
+// AlertRecord returns the history record of sending `event`, converted from `alert` with result `status`.
+// Cleared faults are recorded with action `clearAction`: FaultClear for resolved alerts, or FaultExpire and FaultReconcile
+// for the alerts of expiration and reconciliation. It must be called before committing the conversion, as it
+// relies on the previous event sent for the fault
+func (fm *FaultManager) AlertRecord(status StatusResult, alert template.Alert, event *govel.EventFault, clearAction FaultAction) FaultRecord {
+	action := FaultRepeat
+	switch status {
+	case Stored:
+		action = FaultRaise
+	case Cleared:
+		action = clearAction
+	}
+	rec := fm.record(action, alert.Labels["alertname"], event)
+	if action == FaultRepeat && rec.PreviousSeverity != "" && rec.PreviousSeverity != rec.Severity {
+		rec.Action = FaultSeverityChange
+	}
+	return rec
+}
+
+// ManualRecord returns the history record of sending `event` for the manual `action` on fault `faultID`.
+// It must be called before committing the action, as it relies on the previous event sent for the fault
+func (fm *FaultManager) ManualRecord(action FaultAction, faultID int32, event *govel.EventFault) FaultRecord {
+	labels, _ := fm.state.GetFaultAlert(faultID)
+	return fm.record(action, labels["alertname"], event)
+}
@@ -0,0 +1,117 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/suite"
+)
+
+type HistoryTestSuite struct {
+	suite.Suite
+	confEvent govel.EventConfiguration
+	alert     template.Alert // Test alert alertData1
+}
+
+func TestHistory(t *testing.T) {
+	suite.Run(t, new(HistoryTestSuite))
+}
+
+func (suite *HistoryTestSuite) SetupSuite() {
+	suite.confEvent = govel.EventConfiguration{MaxSize: 200, NfNamingCode: "hspx"}
+	suite.Require().NoError(json.Unmarshal(alertData1, &suite.alert))
+}
+
+// send converts the alert, and returns its history record. The conversion is committed
+func (suite *HistoryTestSuite) send(fm *FaultManager, alert template.Alert) FaultRecord {
+	status, event, commit := AlertToFault(alert, fm, nil)
+	suite.Require().NotNil(event)
+	rec := fm.AlertRecord(status, alert, event, FaultClear)
+	suite.NoError(commit())
+	return rec
+}
+
+func (suite *HistoryTestSuite) TestAlertRecord() {
+	fm := NewFaultManager(&suite.confEvent)
+	rec := suite.send(fm, suite.alert)
+	suite.Equal(FaultRaise, rec.Action)
+	suite.Equal("fault0000000001", rec.EventID)
+	suite.Equal("NodeFailure", rec.AlertName)
+	suite.Equal("dpa2bhsxp5001vm001oam001", rec.SourceName)
+	suite.Equal(govel.SeverityCritical, rec.Severity)
+	suite.Equal(govel.Severity(""), rec.PreviousSeverity)
+	suite.EqualValues(1, rec.Sequence)
+
+	rec = suite.send(fm, suite.alert)
+	suite.Equal(FaultRepeat, rec.Action)
+	suite.Equal(govel.SeverityCritical, rec.PreviousSeverity)
+	suite.EqualValues(2, rec.Sequence)
+
+	rec = suite.send(fm, withSeverity(suite.alert, "major"))
+	suite.Equal(FaultSeverityChange, rec.Action)
+	suite.Equal(govel.SeverityMajor, rec.Severity)
+	suite.Equal(govel.SeverityCritical, rec.PreviousSeverity)
+
+	resolved := suite.alert
+	resolved.Status = "resolved"
+	rec = suite.send(fm, resolved)
+	suite.Equal(FaultClear, rec.Action)
+	suite.Equal(govel.SeverityNormal, rec.Severity)
+	suite.Equal(govel.SeverityMajor, rec.PreviousSeverity)
+	suite.EqualValues(4, rec.Sequence)
+}
+
+func (suite *HistoryTestSuite) TestSweepRecord() {
+	fm := NewFaultManager(&suite.confEvent)
+	suite.send(fm, suite.alert)
+	resolved := suite.alert
+	resolved.Status = "resolved"
+	status, event, _ := AlertToFault(resolved, fm, nil)
+	suite.Require().NotNil(event)
+	suite.Equal(FaultExpire, fm.AlertRecord(status, resolved, event, FaultExpire).Action)
+	suite.Equal(FaultReconcile, fm.AlertRecord(status, resolved, event, FaultReconcile).Action)
+}
+
+func (suite *HistoryTestSuite) TestManualRecord() {
+	fm := NewFaultManager(&suite.confEvent)
+	suite.send(fm, suite.alert)
+	event, _, err := ClearFault(1, fm)
+	suite.Require().NoError(err)
+	rec := fm.ManualRecord(FaultManualClear, 1, event)
+	suite.Equal(FaultManualClear, rec.Action)
+	suite.Equal("NodeFailure", rec.AlertName)
+	suite.Equal(govel.SeverityNormal, rec.Severity)
+	suite.Equal(govel.SeverityCritical, rec.PreviousSeverity)
+}
+
+func (suite *HistoryTestSuite) TestFilter() {
+	now := time.Now()
+	rec := FaultRecord{Time: now.Unix(), AlertName: "NodeFailure", SourceName: "ope-1"}
+	suite.True(FaultHistoryFilter{}.Match(rec))
+	suite.True(FaultHistoryFilter{SourceName: "ope-1", AlertName: "NodeFailure"}.Match(rec))
+	suite.False(FaultHistoryFilter{SourceName: "ope-2"}.Match(rec))
+	suite.False(FaultHistoryFilter{AlertName: "FileSystemFailure"}.Match(rec))
+	suite.True(FaultHistoryFilter{From: now.Add(-time.Minute), To: now.Add(time.Minute)}.Match(rec))
+	suite.False(FaultHistoryFilter{From: now.Add(time.Minute)}.Match(rec))
+	suite.False(FaultHistoryFilter{To: now.Add(-time.Minute)}.Match(rec))
+}
@@ -0,0 +1,127 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+)
+
+// ErrNoFreeIndex is returned when all the fault indexes are in use
+var ErrNoFreeIndex = errors.New("No free fault index")
+
+// maxWidth is the largest number of digits of sequential event IDs, as fault indexes are int32
+const maxWidth = 10
+
+// defaultIDs is the format of event IDs used when not configured, and by older agent versions
+var defaultIDs = config.FaultIDConfiguration{Format: config.FaultIDSequential, Prefix: "fault", Width: maxWidth}
+
+// idAllocator builds the event IDs of faults
+type idAllocator struct {
+	conf config.FaultIDConfiguration
+	max  int32 // Largest fault index. Indexes wrap around past it
+}
+
+// newIDAllocator validates the event IDs format from `conf`
+func newIDAllocator(conf *config.FaultConfiguration) (*idAllocator, error) {
+	ids := &idAllocator{conf: defaultIDs, max: math.MaxInt32}
+	if conf == nil || conf.IDs.Format == "" {
+		return ids, nil
+	}
+	ids.conf = conf.IDs
+	switch conf.IDs.Format {
+	case config.FaultIDUUID:
+	case config.FaultIDSequential:
+		if conf.IDs.Width < 1 || conf.IDs.Width > maxWidth {
+			return nil, fmt.Errorf("Invalid fault event ID width %d, must be between 1 and %d", conf.IDs.Width, maxWidth)
+		}
+		if max := math.Pow10(conf.IDs.Width) - 1; max < math.MaxInt32 {
+			ids.max = int32(max)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid fault event ID format %q", conf.IDs.Format)
+	}
+	return ids, nil
+}
+
+// eventID builds a new event ID for the fault with index `faultID`
+func (ids *idAllocator) eventID(faultID int32) (string, error) {
+	if ids.conf.Format == config.FaultIDUUID {
+		return newUUID()
+	}
+	return fmt.Sprintf("%s%0*d", ids.conf.Prefix, ids.conf.Width, faultID), nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// NextFreeIndex returns the first fault index following `last` which is not in use.
+// Indexes wrap around to 1 past `max`. A `max` of 0 means the largest int32
+func NextFreeIndex(last, max int32, inUse func(int32) bool) (int32, error) {
+	if max <= 0 {
+		max = math.MaxInt32
+	}
+	idx := last
+	for i := int32(0); i < max; i++ {
+		if idx < 1 || idx >= max {
+			idx = 1
+		} else {
+			idx++
+		}
+		if !inUse(idx) {
+			return idx, nil
+		}
+	}
+	return 0, ErrNoFreeIndex
+}
+
+// eventID returns the event ID of the fault with index `faultID`
+func (fm *FaultManager) eventID(faultID int32) string {
+	if id := fm.state.GetFaultEventID(faultID); id != "" {
+		return id
+	}
+	// Faults raised by older agent versions
+	return fmt.Sprintf("%s%0*d", defaultIDs.Prefix, defaultIDs.Width, faultID)
+}
+
+// GetFaultID returns the index of the active fault with event ID `eventID`, or 0 if there is none
+func (fm *FaultManager) GetFaultID(eventID string) int32 {
+	if id := fm.state.GetFaultIDByEventID(eventID); id != 0 {
+		return id
+	}
+	// Faults raised by older agent versions have no event ID in state, their event ID is built from their index
+	var id int32
+	if _, err := fmt.Sscanf(eventID, defaultIDs.Prefix+"%d", &id); err != nil || id < 1 {
+		return 0
+	}
+	if fm.state.GetFaultSn(id) == 0 || fm.state.GetFaultEventID(id) != "" || fm.eventID(id) != eventID {
+		return 0
+	}
+	return id
+}
@@ -0,0 +1,164 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"testing"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/suite"
+)
+
+type IDsTestSuite struct {
+	suite.Suite
+	confEvent govel.EventConfiguration
+}
+
+func TestIDs(t *testing.T) {
+	suite.Run(t, new(IDsTestSuite))
+}
+
+func (suite *IDsTestSuite) SetupSuite() {
+	suite.confEvent = govel.EventConfiguration{MaxSize: 200, NfNamingCode: "hspx"}
+}
+
+func (suite *IDsTestSuite) TestAllocatorDefaults() {
+	ids, err := newIDAllocator(nil)
+	suite.Require().NoError(err)
+	suite.EqualValues(math.MaxInt32, ids.max)
+	id, err := ids.eventID(12)
+	suite.NoError(err)
+	suite.Equal("fault0000000012", id)
+}
+
+func (suite *IDsTestSuite) TestAllocatorSequential() {
+	ids, err := newIDAllocator(&config.FaultConfiguration{IDs: config.FaultIDConfiguration{Format: config.FaultIDSequential, Prefix: "alarm-", Width: 3}})
+	suite.Require().NoError(err)
+	suite.EqualValues(999, ids.max)
+	id, err := ids.eventID(7)
+	suite.NoError(err)
+	suite.Equal("alarm-007", id)
+
+	for _, width := range []int{0, -1, 11} {
+		_, err = newIDAllocator(&config.FaultConfiguration{IDs: config.FaultIDConfiguration{Format: config.FaultIDSequential, Width: width}})
+		suite.Error(err)
+	}
+	_, err = newIDAllocator(&config.FaultConfiguration{IDs: config.FaultIDConfiguration{Format: "random"}})
+	suite.Error(err)
+}
+
+func (suite *IDsTestSuite) TestAllocatorUUID() {
+	ids, err := newIDAllocator(&config.FaultConfiguration{IDs: config.FaultIDConfiguration{Format: config.FaultIDUUID}})
+	suite.Require().NoError(err)
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	id1, err := ids.eventID(1)
+	suite.NoError(err)
+	suite.Regexp(uuid, id1)
+	id2, err := ids.eventID(1)
+	suite.NoError(err)
+	suite.NotEqual(id1, id2)
+}
+
+func (suite *IDsTestSuite) TestNextFreeIndex() {
+	inUse := map[int32]bool{2: true, 3: true}
+	used := func(idx int32) bool { return inUse[idx] }
+
+	idx, err := NextFreeIndex(0, 5, used)
+	suite.NoError(err)
+	suite.EqualValues(1, idx)
+	idx, err = NextFreeIndex(1, 5, used)
+	suite.NoError(err)
+	suite.EqualValues(4, idx)
+	idx, err = NextFreeIndex(5, 5, used)
+	suite.NoError(err)
+	suite.EqualValues(1, idx)
+	idx, err = NextFreeIndex(math.MaxInt32, 0, used)
+	suite.NoError(err)
+	suite.EqualValues(1, idx)
+
+	inUse = map[int32]bool{1: true, 2: true, 3: true}
+	_, err = NextFreeIndex(1, 3, used)
+	suite.Equal(ErrNoFreeIndex, err)
+}
+
+func (suite *IDsTestSuite) TestWrapAround() {
+	conf := config.FaultConfiguration{IDs: config.FaultIDConfiguration{Format: config.FaultIDSequential, Prefix: "f", Width: 1}}
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+
+	var alert template.Alert
+	suite.Require().NoError(json.Unmarshal(alertData1, &alert))
+	for i := 1; i <= 9; i++ {
+		alert.Labels["id"] = fmt.Sprint(i)
+		status, event, commit := AlertToFault(alert, fm, nil)
+		suite.Require().Equal(Stored, status)
+		suite.Require().NoError(commit())
+		suite.Equal(fmt.Sprintf("f%d", i), event.EventID)
+		suite.EqualValues(i, fm.GetFaultID(event.EventID))
+	}
+
+	// All indexes are used
+	alert.Labels["id"] = "10"
+	status, _, _ := AlertToFault(alert, fm, nil)
+	suite.NotEqual(Stored, status)
+
+	// Index 4 is reclaimed once its fault is cleared
+	fault, ok := fm.Fault(4)
+	suite.Require().True(ok)
+	suite.NoError(fm.GetFaultState().DeleteFaultInStorage(fault.Name))
+	suite.EqualValues(0, fm.GetFaultID("f4"))
+	status, event, commit := AlertToFault(alert, fm, nil)
+	suite.Require().Equal(Stored, status)
+	suite.Require().NoError(commit())
+	suite.Equal("f4", event.EventID)
+}
+
+func (suite *IDsTestSuite) TestLegacyFaultID() {
+	fm := NewFaultManager(&suite.confEvent)
+	state := fm.GetFaultState()
+	// Faults raised by older agent versions have no event ID in state
+	suite.NoError(state.StoreFaultInStorage("legacy", 12))
+	suite.NoError(state.InitAlertInfos(12, ""))
+	suite.EqualValues(12, fm.GetFaultID("fault0000000012"))
+	suite.EqualValues(0, fm.GetFaultID("fault12"))
+	suite.EqualValues(0, fm.GetFaultID("fault0000000013"))
+	suite.EqualValues(0, fm.GetFaultID("unknown"))
+
+	suite.NoError(state.StoreFaultInStorage("recent", 13))
+	suite.NoError(state.InitAlertInfos(13, "f13"))
+	suite.EqualValues(13, fm.GetFaultID("f13"))
+	suite.EqualValues(0, fm.GetFaultID("fault0000000013"))
+}
+
+func (suite *IDsTestSuite) TestPruneFaults() {
+	fm := NewFaultManager(&suite.confEvent)
+	state := fm.GetFaultState()
+	suite.NoError(state.InitAlertInfos(3, "fault0000000003"))
+	suite.NoError(state.StoreFaultInStorage("orphan", 4))
+	count, err := state.PruneFaults()
+	suite.NoError(err)
+	suite.Equal(2, count)
+	suite.Empty(state.GetFaultsInStorage())
+	suite.Equal("", state.GetFaultEventID(3))
+}
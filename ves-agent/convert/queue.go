@@ -0,0 +1,87 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"errors"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// ErrQueueFull is returned when too many received alerts are waiting to be processed
+var ErrQueueFull = errors.New("Too many alerts waiting to be processed")
+
+// AlertStatus is the processing status of a queued alert
+type AlertStatus string
+
+// Possible values for AlertStatus
+const (
+	AlertPending    AlertStatus = "pending"    // Not processed yet, or to be retried
+	AlertSent       AlertStatus = "sent"       // Converted and sent to VES collector
+	AlertIgnored    AlertStatus = "ignored"    // Nothing to send for this alert
+	AlertSuppressed AlertStatus = "suppressed" // Repeated alert, not sent according to its repeat policy
+	AlertFailed     AlertStatus = "failed"     // Cannot be converted, won't be retried
+)
+
+// AlertOrigin identifies the source of an alert
+type AlertOrigin string
+
+// Possible values for AlertOrigin
+const (
+	OriginAlertmanager AlertOrigin = ""     // Received from Alertmanager
+	OriginSNMP         AlertOrigin = "snmp" // Converted from a SNMP trap
+	OriginLogs         AlertOrigin = "logs" // Matched in a tailed log file
+)
+
+// Valid returns true if the origin is known
+func (origin AlertOrigin) Valid() bool {
+	switch origin {
+	case OriginAlertmanager, OriginSNMP, OriginLogs:
+		return true
+	}
+	return false
+}
+
+// QueuedAlert is an alert received from Alertmanager, or from another source, with its processing status
+type QueuedAlert struct {
+	ID          int64
+	Alert       template.Alert
+	Origin      AlertOrigin
+	Received    int64 // Epoch time (in seconds) at which the alert was received
+	Status      AlertStatus
+	Attempts    int
+	LastAttempt int64  // Epoch time (in seconds) of the last processing attempt, or 0
+	Error       string // Error of the last processing attempt, if any
+}
+
+// AlertQueueState handles the queue of received alerts, waiting to be processed
+type AlertQueueState interface {
+	// EnqueueAlerts appends the alerts received from `origin` at epoch time `received` to the queue, and returns their IDs.
+	// If `maxPending` is positive, alerts are rejected with ErrQueueFull when the queue would hold more pending alerts
+	EnqueueAlerts(alerts []template.Alert, origin AlertOrigin, received int64, maxPending int) ([]int64, error)
+	// NextQueuedAlert returns the oldest pending alert, if any
+	NextQueuedAlert() (QueuedAlert, bool)
+	// GetQueuedAlert returns the alert with ID `id`, if still known
+	GetQueuedAlert(id int64) (QueuedAlert, bool)
+	// GetQueuedAlerts returns all the known alerts, ordered by ID
+	GetQueuedAlerts() []QueuedAlert
+	// PendingAlerts returns the number of pending alerts
+	PendingAlerts() int
+	// SetQueuedAlertResult records the result of the attempt to process alert `id` at epoch time `at`.
+	// Only the `retain` most recent alerts which are no longer pending are kept
+	SetQueuedAlertResult(id int64, status AlertStatus, errMsg string, at int64, retain int) error
+}
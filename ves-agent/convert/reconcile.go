@@ -0,0 +1,156 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	log "github.com/sirupsen/logrus"
+)
+
+// AlertSource provides the alerts currently firing
+type AlertSource interface {
+	// ActiveAlerts returns the alerts currently firing
+	ActiveAlerts() ([]template.Alert, error)
+}
+
+// AlertmanagerClient fetches active alerts from Alertmanager's API v2
+type AlertmanagerClient struct {
+	url    string
+	client *http.Client
+}
+
+// gettableAlert is an alert as returned by Alertmanager's API v2
+type gettableAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+}
+
+// NewAlertmanagerClient creates a client querying the Alertmanager configured in `conf`
+func NewAlertmanagerClient(conf *config.ReconcileConfiguration) (*AlertmanagerClient, error) {
+	u, err := url.Parse(strings.TrimSuffix(conf.URL, "/") + "/api/v2/alerts")
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("active", "true")
+	if conf.Receiver != "" {
+		query.Set("receiver", conf.Receiver)
+	}
+	for _, filter := range conf.Filters {
+		query.Add("filter", filter)
+	}
+	u.RawQuery = query.Encode()
+	return &AlertmanagerClient{url: u.String(), client: &http.Client{Timeout: conf.Timeout}}, nil
+}
+
+// ActiveAlerts returns the alerts currently firing in Alertmanager
+func (am *AlertmanagerClient) ActiveAlerts() ([]template.Alert, error) {
+	resp, err := am.client.Get(am.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Alertmanager replied with status %s", resp.Status)
+	}
+	var body []gettableAlert
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("Cannot decode Alertmanager response: %s", err.Error())
+	}
+	alerts := make([]template.Alert, 0, len(body))
+	for _, a := range body {
+		alerts = append(alerts, template.Alert{
+			Status:       "firing",
+			Labels:       a.Labels,
+			Annotations:  a.Annotations,
+			StartsAt:     a.StartsAt,
+			EndsAt:       a.EndsAt,
+			GeneratorURL: a.GeneratorURL,
+		})
+	}
+	return alerts, nil
+}
+
+// Reconciler is a scheduler job comparing the stored faults with the active alerts.
+// It returns the alerts to be processed for the faults to match the active alerts:
+// resolved alerts for faults no longer firing, and firing alerts for missed faults.
+// Faults raised by alerts from other sources than Alertmanager are left untouched
+type Reconciler struct {
+	source AlertSource
+	fm     *FaultManager
+}
+
+// NewReconciler creates a new reconciler of the faults of `fm`, with the alerts from `source`
+func NewReconciler(source AlertSource, fm *FaultManager) *Reconciler {
+	return &Reconciler{source: source, fm: fm}
+}
+
+// Run the reconciliation. The returned value is a slice of template.Alert
+func (rec *Reconciler) Run(from, to time.Time, interval time.Duration) (interface{}, error) {
+	active, err := rec.source.ActiveAlerts()
+	if err != nil {
+		return nil, err
+	}
+	state := rec.fm.GetFaultState()
+	alerts := []template.Alert{}
+	firing := make(map[string]bool, len(active))
+	for _, alert := range active {
+		if _, ok := rec.fm.severities.get(alert.Labels["severity"]); !ok && rec.fm.severities.policy != config.UnknownSeverityDefault {
+			// Alert would not be raised
+			continue
+		}
+		fault, err := rec.fm.mapping.mapAlert(alert)
+		if err != nil {
+			log.Warnf("Reconciliation: cannot map alert %s to fault: %s", alert.Labels["alertname"], err.Error())
+			continue
+		}
+		firing[fault.name] = true
+		if state.GetFaultInStorage(fault.name) == 0 {
+			log.Infof("Reconciliation: raising missed fault %s", fault.name)
+			alerts = append(alerts, alert)
+		}
+	}
+	for name, id := range state.GetFaultsInStorage() {
+		if firing[name] {
+			continue
+		}
+		if state.GetFaultOrigin(id) != OriginAlertmanager {
+			// Alertmanager does not know faults raised from other sources
+			continue
+		}
+		labels, annotations := state.GetFaultAlert(id)
+		if labels == nil {
+			log.Warnf("Reconciliation: cannot clear fault %s, its alert is unknown", name)
+			continue
+		}
+		log.Infof("Reconciliation: clearing fault %s", name)
+		alerts = append(alerts, template.Alert{Status: "resolved", Labels: labels, Annotations: annotations, EndsAt: to})
+	}
+	return alerts, nil
+}
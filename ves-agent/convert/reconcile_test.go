@@ -0,0 +1,220 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReconcileTestSuite struct {
+	suite.Suite
+	alerts    []template.Alert // Alerts to be replied by the fake Alertmanager
+	status    int              // Status to be replied by the fake Alertmanager
+	query     url.Values       // Last query received by the fake Alertmanager
+	server    *httptest.Server
+	conf      config.ReconcileConfiguration
+	confEvent govel.EventConfiguration
+	raised    []template.Alert // Test alerts, from alertData1 to alertData6
+}
+
+func TestReconcile(t *testing.T) {
+	suite.Run(t, new(ReconcileTestSuite))
+}
+
+func (suite *ReconcileTestSuite) SetupSuite() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		suite.query = req.URL.Query()
+		if req.URL.Path != "/api/v2/alerts" {
+			resp.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if suite.status != http.StatusOK {
+			resp.WriteHeader(suite.status)
+			return
+		}
+		alerts := make([]map[string]interface{}, 0, len(suite.alerts))
+		for _, a := range suite.alerts {
+			alerts = append(alerts, map[string]interface{}{
+				"labels":      a.Labels,
+				"annotations": a.Annotations,
+				"startsAt":    a.StartsAt,
+				"endsAt":      a.EndsAt,
+				"fingerprint": "0123456789abcdef",
+				"status":      map[string]interface{}{"state": "active", "silencedBy": []string{}, "inhibitedBy": []string{}},
+				"receivers":   []map[string]string{{"name": "ves-agent"}},
+			})
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		suite.NoError(json.NewEncoder(resp).Encode(alerts))
+	}))
+	suite.conf = config.ReconcileConfiguration{URL: suite.server.URL + "/", Timeout: time.Second}
+	suite.confEvent = govel.EventConfiguration{MaxSize: 200, NfNamingCode: "hspx"}
+	for _, data := range [][]byte{alertData1, alertData2, alertData3, alertData4, alertData5, alertData6} {
+		var alert template.Alert
+		suite.Require().NoError(json.Unmarshal(data, &alert))
+		suite.raised = append(suite.raised, alert)
+	}
+}
+
+func (suite *ReconcileTestSuite) TearDownSuite() {
+	suite.server.Close()
+}
+
+func (suite *ReconcileTestSuite) SetupTest() {
+	suite.alerts = nil
+	suite.status = http.StatusOK
+	suite.query = nil
+}
+
+func (suite *ReconcileTestSuite) TestActiveAlerts() {
+	conf := suite.conf
+	conf.Receiver = "ves-.*"
+	conf.Filters = []string{`team="platform"`, `severity=~"critical|major"`}
+	am, err := NewAlertmanagerClient(&conf)
+	suite.Require().NoError(err)
+	suite.alerts = []template.Alert{suite.raised[0], suite.raised[5]}
+
+	alerts, err := am.ActiveAlerts()
+	suite.Require().NoError(err)
+	suite.Equal("true", suite.query.Get("active"))
+	suite.Equal("ves-.*", suite.query.Get("receiver"))
+	suite.Equal(conf.Filters, suite.query["filter"])
+	suite.Len(alerts, 2)
+	suite.Equal("firing", alerts[0].Status)
+	suite.Equal(suite.raised[0].Labels, alerts[0].Labels)
+	suite.Equal(suite.raised[0].Annotations, alerts[0].Annotations)
+	suite.Equal("KubePodCrashLooping", alerts[1].Labels["alertname"])
+}
+
+func (suite *ReconcileTestSuite) TestActiveAlertsError() {
+	am, err := NewAlertmanagerClient(&suite.conf)
+	suite.Require().NoError(err)
+	suite.status = http.StatusInternalServerError
+	_, err = am.ActiveAlerts()
+	suite.Error(err)
+
+	conf := suite.conf
+	conf.URL = "http://127.0.0.1:1"
+	am, err = NewAlertmanagerClient(&conf)
+	suite.Require().NoError(err)
+	_, err = am.ActiveAlerts()
+	suite.Error(err)
+}
+
+func (suite *ReconcileTestSuite) TestReconcile() {
+	fm := NewFaultManager(&suite.confEvent)
+	am, err := NewAlertmanagerClient(&suite.conf)
+	suite.Require().NoError(err)
+	rec := NewReconciler(am, fm)
+
+	// alert1 is raised, but its resolution is lost
+	status, _, commit := AlertToFault(suite.raised[0], fm, nil)
+	suite.Require().Equal(Stored, status)
+	suite.NoError(commit())
+	// alert3 is raised and still firing
+	status, _, commit = AlertToFault(suite.raised[2], fm, nil)
+	suite.Require().Equal(Stored, status)
+	suite.NoError(commit())
+	// alert4 is firing, but has been missed. Alert with unknown severity is ignored
+	suite.alerts = []template.Alert{suite.raised[2], suite.raised[3], withSeverity(suite.raised[1], "debug")}
+
+	res, err := rec.Run(time.Now(), time.Now(), time.Minute)
+	suite.Require().NoError(err)
+	alerts := res.([]template.Alert)
+	suite.Require().Len(alerts, 2)
+	suite.Equal("firing", alerts[0].Status)
+	suite.Equal(suite.raised[3].Labels, alerts[0].Labels)
+	suite.Equal("resolved", alerts[1].Status)
+	suite.Equal(suite.raised[0].Labels["alertname"], alerts[1].Labels["alertname"])
+
+	// Processing returned alerts converges
+	for _, alert := range alerts {
+		status, _, commit = AlertToFault(alert, fm, nil)
+		suite.NotEqual(InError, status)
+		suite.NoError(commit())
+	}
+	res, err = rec.Run(time.Now(), time.Now(), time.Minute)
+	suite.Require().NoError(err)
+	suite.Empty(res)
+	suite.Len(fm.GetFaultState().GetFaultsInStorage(), 2)
+}
+
+func (suite *ReconcileTestSuite) TestReconcileTrapFault() {
+	fm := NewFaultManager(&suite.confEvent)
+	am, err := NewAlertmanagerClient(&suite.conf)
+	suite.Require().NoError(err)
+
+	// Fault raised by a SNMP trap is unknown to Alertmanager, and must not be cleared
+	status, _, commit := AlertToFaultFrom(suite.raised[0], OriginSNMP, fm, nil)
+	suite.Require().Equal(Stored, status)
+	suite.NoError(commit())
+	res, err := NewReconciler(am, fm).Run(time.Now(), time.Now(), time.Minute)
+	suite.Require().NoError(err)
+	suite.Empty(res)
+	suite.Len(fm.GetFaultState().GetFaultsInStorage(), 1)
+}
+
+func (suite *ReconcileTestSuite) TestReconcileLogFault() {
+	fm := NewFaultManager(&suite.confEvent)
+	am, err := NewAlertmanagerClient(&suite.conf)
+	suite.Require().NoError(err)
+
+	// Fault raised from a log file is unknown to Alertmanager, and must not be cleared,
+	// while the fault raised from Alertmanager is
+	status, _, commit := AlertToFaultFrom(suite.raised[0], OriginLogs, fm, nil)
+	suite.Require().Equal(Stored, status)
+	suite.NoError(commit())
+	status, _, commit = AlertToFault(suite.raised[2], fm, nil)
+	suite.Require().Equal(Stored, status)
+	suite.NoError(commit())
+	res, err := NewReconciler(am, fm).Run(time.Now(), time.Now(), time.Minute)
+	suite.Require().NoError(err)
+	alerts := res.([]template.Alert)
+	suite.Require().Len(alerts, 1)
+	suite.Equal("resolved", alerts[0].Status)
+	suite.Equal(suite.raised[2].Labels["alertname"], alerts[0].Labels["alertname"])
+}
+
+func (suite *ReconcileTestSuite) TestReconcileUnknownAlert() {
+	fm := NewFaultManager(&suite.confEvent)
+	am, err := NewAlertmanagerClient(&suite.conf)
+	suite.Require().NoError(err)
+
+	// Fault stored without its alert cannot be cleared
+	state := fm.GetFaultState()
+	id, _ := state.NextFaultIndex(0)
+	suite.NoError(state.StoreFaultInStorage("unknown", id))
+	suite.NoError(state.InitAlertInfos(id, ""))
+	res, err := NewReconciler(am, fm).Run(time.Now(), time.Now(), time.Minute)
+	suite.Require().NoError(err)
+	suite.Empty(res)
+
+	suite.status = http.StatusServiceUnavailable
+	_, err = NewReconciler(am, fm).Run(time.Now(), time.Now(), time.Minute)
+	suite.Error(err)
+}
@@ -0,0 +1,104 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+)
+
+// repeatPolicies holds the policies applied to repeated notifications of firing alerts
+type repeatPolicies struct {
+	repeats []config.FaultRepeat
+	def     config.FaultRepeat
+}
+
+// validateRepeat checks the repeat policy `repeat`
+func validateRepeat(repeat config.FaultRepeat) error {
+	switch repeat.Policy {
+	case "", config.RepeatAlways, config.RepeatOnChange:
+	case config.RepeatInterval:
+		if repeat.Interval <= 0 {
+			return fmt.Errorf("Repeat policy %q for alert %q and severity %q requires a positive interval", repeat.Policy, repeat.AlertName, repeat.Severity)
+		}
+	default:
+		return fmt.Errorf("Invalid repeat policy %q for alert %q and severity %q", repeat.Policy, repeat.AlertName, repeat.Severity)
+	}
+	if _, ok := severityToPriority[govel.Severity(strings.ToUpper(repeat.Severity))]; repeat.Severity != "" && !ok {
+		return fmt.Errorf("Invalid VES severity %q in repeat policy", repeat.Severity)
+	}
+	return nil
+}
+
+// newRepeatPolicies validates the repeat policies from `conf`
+func newRepeatPolicies(conf *config.FaultConfiguration) (*repeatPolicies, error) {
+	repeats := &repeatPolicies{}
+	if conf == nil {
+		return repeats, nil
+	}
+	for _, repeat := range append(conf.Repeats, conf.DefaultRepeat) {
+		if err := validateRepeat(repeat); err != nil {
+			return nil, err
+		}
+	}
+	repeats.repeats, repeats.def = conf.Repeats, conf.DefaultRepeat
+	return repeats, nil
+}
+
+// get returns the policy applied to the repeated notifications of alert `alertName`,
+// raising faults of VES `severity`
+func (repeats *repeatPolicies) get(alertName string, severity govel.Severity) config.FaultRepeat {
+	for _, repeat := range repeats.repeats {
+		if matchFault(repeat.AlertName, repeat.Severity, alertName, severity) {
+			return repeat
+		}
+	}
+	return repeats.def
+}
+
+// matchFault returns true if the fault raised by alert `alertName` with VES `severity`
+// matches the expected `expAlertName` and `expSeverity`. Empty expected values match any
+func matchFault(expAlertName, expSeverity, alertName string, severity govel.Severity) bool {
+	return (expAlertName == "" || expAlertName == alertName) &&
+		(expSeverity == "" || strings.EqualFold(expSeverity, string(severity)))
+}
+
+// contentHash returns the hash of the fault event's fields whose change is notified
+// with the "onChange" repeat policy: severity and description
+func contentHash(event *govel.EventFault) string {
+	h := fnv.New64a()
+	h.Write([]byte(string(event.EventSeverity) + "\x00" + event.SpecificProblem))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// suppressRepeat returns true if the event with content `hash`, sent at `now` for the repeated
+// notification of the alert which raised fault `faultID`, should be suppressed according to `repeat` policy
+func (fm *FaultManager) suppressRepeat(faultID int32, repeat config.FaultRepeat, hash string, now time.Time) bool {
+	lastHash, lastSent := fm.state.GetFaultSent(faultID)
+	switch repeat.Policy {
+	case config.RepeatOnChange:
+		return hash == lastHash
+	case config.RepeatInterval:
+		return lastSent != 0 && now.Sub(time.Unix(lastSent, 0)) < repeat.Interval
+	}
+	return false
+}
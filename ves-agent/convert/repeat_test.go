@@ -0,0 +1,171 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/suite"
+)
+
+type RepeatTestSuite struct {
+	suite.Suite
+	confEvent govel.EventConfiguration
+	conf      config.FaultConfiguration
+	raised    []template.Alert // Test alerts: alertData1, alertData3 and alertData6
+}
+
+func TestRepeat(t *testing.T) {
+	suite.Run(t, new(RepeatTestSuite))
+}
+
+func (suite *RepeatTestSuite) SetupSuite() {
+	suite.confEvent = govel.EventConfiguration{MaxSize: 200, NfNamingCode: "hspx"}
+	suite.conf = config.FaultConfiguration{
+		Repeats: []config.FaultRepeat{
+			{AlertName: "NodeFailure", Policy: config.RepeatOnChange},
+			{Severity: "minor", Policy: config.RepeatInterval, Interval: time.Hour},
+		},
+		DefaultRepeat: config.FaultRepeat{Policy: config.RepeatAlways},
+	}
+	for _, data := range [][]byte{alertData1, alertData3, alertData6} {
+		var alert template.Alert
+		suite.Require().NoError(json.Unmarshal(data, &alert))
+		suite.raised = append(suite.raised, alert)
+	}
+}
+
+func (suite *RepeatTestSuite) newFaultManager() *FaultManager {
+	fm, err := NewFaultManagerWithConfig(&suite.confEvent, &suite.conf, NewFaultManager(&suite.confEvent).GetFaultState())
+	suite.Require().NoError(err)
+	return fm
+}
+
+// send converts the alert, and commits it if it's to be sent
+func (suite *RepeatTestSuite) send(fm *FaultManager, alert template.Alert) (StatusResult, *govel.EventFault) {
+	status, event, commit := AlertToFault(alert, fm, nil)
+	if event != nil {
+		suite.NoError(commit())
+	}
+	return status, event
+}
+
+// withDescription returns a copy of `alert` with annotation description set to `description`
+func withDescription(alert template.Alert, description string) template.Alert {
+	annotations := make(template.KV, len(alert.Annotations))
+	for k, v := range alert.Annotations {
+		annotations[k] = v
+	}
+	annotations["description"] = description
+	alert.Annotations = annotations
+	return alert
+}
+
+func (suite *RepeatTestSuite) TestPolicy() {
+	fm := suite.newFaultManager()
+	suite.Equal(config.RepeatOnChange, fm.repeats.get("NodeFailure", govel.SeverityMinor).Policy)
+	suite.Equal(config.RepeatInterval, fm.repeats.get("FileSystemFailure", govel.SeverityMinor).Policy)
+	suite.Equal(config.RepeatAlways, fm.repeats.get("FileSystemFailure", govel.SeverityMajor).Policy)
+
+	// Without configuration, repeated alerts are always sent
+	suite.Equal("", NewFaultManager(&suite.confEvent).repeats.get("NodeFailure", govel.SeverityMinor).Policy)
+}
+
+func (suite *RepeatTestSuite) TestOnChange() {
+	fm := suite.newFaultManager()
+	status, event := suite.send(fm, suite.raised[0])
+	suite.Require().Equal(Stored, status)
+	suite.EqualValues(1, event.Sequence)
+	hash, sent := fm.GetFaultState().GetFaultSent(fm.GetFaultState().GetFaultInStorage("201_NodeSupervision_dpa2bhsxp5001vm001oam001"))
+	suite.Equal(contentHash(event), hash)
+	suite.InDelta(time.Now().Unix(), sent, 1)
+
+	// Same content is suppressed
+	status, event = suite.send(fm, suite.raised[0])
+	suite.Equal(Suppressed, status)
+	suite.Nil(event)
+
+	// Changed description or severity are sent
+	status, event = suite.send(fm, withDescription(suite.raised[0], "VM node is still disconnected"))
+	suite.Require().Equal(AlreadyExist, status)
+	suite.EqualValues(2, event.Sequence)
+	status, _ = suite.send(fm, withDescription(suite.raised[0], "VM node is still disconnected"))
+	suite.Equal(Suppressed, status)
+	status, event = suite.send(fm, withSeverity(withDescription(suite.raised[0], "VM node is still disconnected"), "major"))
+	suite.Require().Equal(AlreadyExist, status)
+	suite.Equal(govel.SeverityMajor, event.EventSeverity)
+	suite.EqualValues(3, event.Sequence)
+
+	// Clear is never suppressed
+	resolved := suite.raised[0]
+	resolved.Status = "resolved"
+	status, _ = suite.send(fm, resolved)
+	suite.Equal(Cleared, status)
+}
+
+func (suite *RepeatTestSuite) TestInterval() {
+	fm := suite.newFaultManager()
+	state := fm.GetFaultState()
+	status, _ := suite.send(fm, suite.raised[1])
+	suite.Require().Equal(Stored, status)
+	status, _ = suite.send(fm, suite.raised[1])
+	suite.Equal(Suppressed, status)
+	status, _ = suite.send(fm, withDescription(suite.raised[1], "Audit failed again"))
+	suite.Equal(Suppressed, status)
+
+	// Resent once interval is elapsed
+	fault, err := fm.mapping.mapAlert(suite.raised[1])
+	suite.Require().NoError(err)
+	id := state.GetFaultInStorage(fault.name)
+	hash, _ := state.GetFaultSent(id)
+	suite.NoError(state.SetFaultSent(id, hash, time.Now().Add(-time.Hour).Unix()))
+	status, event := suite.send(fm, suite.raised[1])
+	suite.Require().Equal(AlreadyExist, status)
+	suite.EqualValues(2, event.Sequence)
+	status, _ = suite.send(fm, suite.raised[1])
+	suite.Equal(Suppressed, status)
+}
+
+func (suite *RepeatTestSuite) TestAlways() {
+	fm := suite.newFaultManager()
+	status, _ := suite.send(fm, suite.raised[2])
+	suite.Require().Equal(Stored, status)
+	status, event := suite.send(fm, withSeverity(suite.raised[2], "critical"))
+	suite.Require().Equal(AlreadyExist, status)
+	suite.EqualValues(2, event.Sequence)
+	status, event = suite.send(fm, withSeverity(suite.raised[2], "critical"))
+	suite.Require().Equal(AlreadyExist, status)
+	suite.EqualValues(3, event.Sequence)
+}
+
+func (suite *RepeatTestSuite) TestInvalidRepeat() {
+	for name, conf := range map[string]config.FaultConfiguration{
+		"policy":   {Repeats: []config.FaultRepeat{{AlertName: "NodeFailure", Policy: "never"}}},
+		"interval": {Repeats: []config.FaultRepeat{{AlertName: "NodeFailure", Policy: config.RepeatInterval}}},
+		"severity": {Repeats: []config.FaultRepeat{{Severity: "bad", Policy: config.RepeatOnChange}}},
+		"default":  {DefaultRepeat: config.FaultRepeat{Policy: config.RepeatInterval, Interval: -time.Minute}},
+	} {
+		_, err := NewFaultManagerWithConfig(&suite.confEvent, &conf, nil)
+		suite.Error(err, name)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/Masterminds/sprig"
+)
+
+// faultRule is a fault mapping rule, with its compiled matchers
+type faultRule struct {
+	config.FaultRule
+	matchers map[string]*regexp.Regexp
+}
+
+// matches returns true if `labels` satisfy all the rule's matchers
+func (rule *faultRule) matches(labels map[string]string) bool {
+	for label, re := range rule.matchers {
+		if !re.MatchString(labels[label]) {
+			return false
+		}
+	}
+	return true
+}
+
+// templates returns all the template expressions used by the rule
+func (rule *faultRule) templates() []string {
+	tmpls := []string{
+		rule.SourceName, rule.AlarmCondition, rule.ClearAlarmCondition, rule.SpecificProblem,
+		rule.ClearSpecificProblem, rule.EventSourceType, rule.VfStatus, rule.EventCategory,
+		rule.AlarmInterfaceA, rule.Identity,
+	}
+	for _, info := range rule.AdditionalInformation {
+		tmpls = append(tmpls, info.Expr)
+	}
+	return tmpls
+}
+
+// newFaultRule validates and compiles a fault mapping rule
+func newFaultRule(rule config.FaultRule) (*faultRule, error) {
+	matchers := make(map[string]*regexp.Regexp, len(rule.Matchers))
+	for _, m := range rule.Matchers {
+		if m.Label == "" {
+			return nil, fmt.Errorf("Fault rule %s: matcher without label", rule.Name)
+		}
+		re, err := regexp.Compile("^(?:" + m.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Fault rule %s: bad regex for label %s: %s", rule.Name, m.Label, err.Error())
+		}
+		matchers[m.Label] = re
+	}
+	return &faultRule{FaultRule: rule, matchers: matchers}, nil
+}
+
+// faultMapping holds the fault rules and evaluates their templates
+type faultMapping struct {
+	rules     []*faultRule
+	templates map[string]*template.Template // Cache for templates from rules (to avoid parsing them each time)
+}
+
+// newFaultMapping compiles the rules from `conf`, and parses all their templates
+func newFaultMapping(conf *config.FaultConfiguration) (*faultMapping, error) {
+	mapping := &faultMapping{templates: make(map[string]*template.Template)}
+	if conf == nil {
+		return mapping, nil
+	}
+	for _, r := range conf.Rules {
+		rule, err := newFaultRule(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range rule.templates() {
+			if _, err := mapping.parseTemplate(s); err != nil {
+				return nil, fmt.Errorf("Fault rule %s: bad template %s (%s)", rule.Name, s, err.Error())
+			}
+		}
+		mapping.rules = append(mapping.rules, rule)
+	}
+	return mapping, nil
+}
+
+// match returns the first rule matching `labels`, or nil if none does
+func (mapping *faultMapping) match(labels map[string]string) *faultRule {
+	for _, rule := range mapping.rules {
+		if rule.matches(labels) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (mapping *faultMapping) parseTemplate(s string) (*template.Template, error) {
+	if tmpl, ok := mapping.templates[s]; ok {
+		return tmpl, nil
+	}
+	tmpl, err := template.New("").Funcs(sprig.TxtFuncMap()).Option("missingkey=zero").Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	mapping.templates[s] = tmpl
+	return tmpl, nil
+}
+
+// eval evaluates the template expression `s` with `data`.
+// If `s` is empty, `def` is returned instead
+func (mapping *faultMapping) eval(s string, data interface{}, def string) (string, error) {
+	if s == "" {
+		return def, nil
+	}
+	tmpl, err := mapping.parseTemplate(s)
+	if err != nil {
+		return "", fmt.Errorf("Bad template: %s (%s)", s, err.Error())
+	}
+	buf := bytes.Buffer{}
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("Cannot execute template %s (%s)", s, err.Error())
+	}
+	return buf.String(), nil
+}
+
+// evalAdditionalInformation evaluates the rule's additional information fields.
+// Fields evaluated to an empty value are skipped
+func (mapping *faultMapping) evalAdditionalInformation(infos []config.Label, data interface{}) ([]govel.EventField, error) {
+	var fields []govel.EventField
+	for _, info := range infos {
+		v, err := mapping.eval(info.Expr, data, "")
+		if err != nil {
+			return nil, err
+		}
+		if v != "" {
+			fields = append(fields, govel.EventField{Name: info.Name, Value: v})
+		}
+	}
+	return fields, nil
+}
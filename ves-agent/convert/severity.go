@@ -0,0 +1,110 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+)
+
+var severityToPriority = map[govel.Severity]govel.EventPriority{
+	govel.SeverityCritical: govel.PriorityHigh,
+	govel.SeverityMajor:    govel.PriorityMedium,
+	govel.SeverityMinor:    govel.PriorityLow,
+	govel.SeverityWarning:  govel.PriorityLow,
+	govel.SeverityNormal:   govel.PriorityNormal,
+}
+
+var priorities = map[string]govel.EventPriority{
+	"high":   govel.PriorityHigh,
+	"medium": govel.PriorityMedium,
+	"normal": govel.PriorityNormal,
+	"low":    govel.PriorityLow,
+}
+
+// severityLevel is a VES severity with its priority
+type severityLevel struct {
+	severity govel.Severity
+	priority govel.EventPriority
+}
+
+// severityMapping maps values of alerts' severity label to VES severities
+type severityMapping struct {
+	levels map[string]severityLevel // Keyed by lower case label value
+	policy string
+	def    severityLevel
+}
+
+// newSeverityLevel validates a severity mapping and returns the matching level
+func newSeverityLevel(m config.SeverityMapping) (severityLevel, error) {
+	severity := govel.Severity(strings.ToUpper(m.Severity))
+	priority, ok := severityToPriority[severity]
+	if !ok {
+		return severityLevel{}, fmt.Errorf("Invalid VES severity %q for value %q", m.Severity, m.Value)
+	}
+	if m.Priority != "" {
+		if priority, ok = priorities[strings.ToLower(m.Priority)]; !ok {
+			return severityLevel{}, fmt.Errorf("Invalid VES priority %q for value %q", m.Priority, m.Value)
+		}
+	}
+	return severityLevel{severity: severity, priority: priority}, nil
+}
+
+// newSeverityMapping builds the severity mapping from `conf`. VES severity names are
+// always mapped, unless overridden by configuration
+func newSeverityMapping(conf *config.FaultConfiguration) (*severityMapping, error) {
+	mapping := &severityMapping{levels: make(map[string]severityLevel), policy: config.UnknownSeverityReject}
+	for severity, priority := range severityToPriority {
+		mapping.levels[strings.ToLower(string(severity))] = severityLevel{severity: severity, priority: priority}
+	}
+	if conf == nil {
+		return mapping, nil
+	}
+	for _, m := range conf.Severities {
+		if m.Value == "" {
+			return nil, fmt.Errorf("Severity mapping to %s without value", m.Severity)
+		}
+		level, err := newSeverityLevel(m)
+		if err != nil {
+			return nil, err
+		}
+		mapping.levels[strings.ToLower(m.Value)] = level
+	}
+	switch conf.UnknownSeverity {
+	case "", config.UnknownSeverityReject:
+	case config.UnknownSeverityDrop:
+		mapping.policy = conf.UnknownSeverity
+	case config.UnknownSeverityDefault:
+		level, err := newSeverityLevel(conf.DefaultSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid default severity: %s", err.Error())
+		}
+		mapping.policy, mapping.def = conf.UnknownSeverity, level
+	default:
+		return nil, fmt.Errorf("Invalid unknown severity policy: %s", conf.UnknownSeverity)
+	}
+	return mapping, nil
+}
+
+// get returns the level mapped to the severity label `value`, if any
+func (mapping *severityMapping) get(value string) (severityLevel, bool) {
+	level, ok := mapping.levels[strings.ToLower(value)]
+	return level, ok
+}
@@ -18,6 +18,7 @@ package convert
 
 import (
 	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
@@ -25,8 +26,8 @@ import (
 
 // FaultManagerState handles the alert internal state
 type FaultManagerState interface {
-	// NextFaultIndex return the Fault Index and increments it
-	NextFaultIndex() (int32, error)
+	// NextFaultIndex returns the next free Fault Index, wrapping around to 1 past `max`
+	NextFaultIndex(max int32) (int32, error)
 	// IncrementFaultSn increments the fault sequence number
 	IncrementFaultSn(faultID int32) error
 	// GetFaultSn return the fault sequence number
@@ -35,35 +36,125 @@ type FaultManagerState interface {
 	GetFaultStartEpoch(faultID int32) int64
 	// SetFaultStartEpoch set the startEpoch
 	SetFaultStartEpoch(faultID int32, epoch int64) error
-	// InitAlertInfos update the alertInfos map
-	InitAlertInfos(faultID int32) error
+	// InitAlertInfos initializes the state of fault `faultID`, with its event ID
+	InitAlertInfos(faultID int32, eventID string) error
+	// GetFaultEventID returns the event ID of the fault, or an empty string for faults raised by older agent versions
+	GetFaultEventID(faultID int32) string
+	// GetFaultIDByEventID returns the index of the fault with event ID `eventID`, or 0 if there is none.
+	// Faults raised by older agent versions, without event ID, are not found
+	GetFaultIDByEventID(eventID string) int32
+	// PruneFaults removes the partially stored faults: states without name, and names without state.
+	// It returns the number of removed faults
+	PruneFaults() (int, error)
 	// GetFaultInStorage checks if faultName already associated to an index
 	GetFaultInStorage(faultName string) int32
 	// StoreFaultInStorage stores the index associated to the faultName
 	StoreFaultInStorage(faultName string, faultID int32) error
 	// DeleteFaultInStorage delete Fault in storage
 	DeleteFaultInStorage(faultName string) error
+	// GetFaultsInStorage returns all the stored faultNames, with their index
+	GetFaultsInStorage() map[string]int32
+	// GetFaultAlert returns the labels and annotations of the alert which raised the fault
+	GetFaultAlert(faultID int32) (labels, annotations map[string]string)
+	// SetFaultAlert stores the labels, annotations and origin of the alert which raised the fault
+	SetFaultAlert(faultID int32, labels, annotations map[string]string, origin AlertOrigin) error
+	// GetFaultOrigin returns the origin of the alert which raised the fault
+	GetFaultOrigin(faultID int32) AlertOrigin
+	// GetFaultLastSeen returns the epoch time (in seconds) at which the fault's alert was last received
+	GetFaultLastSeen(faultID int32) int64
+	// SetFaultLastSeen sets the epoch time (in seconds) at which the fault's alert was last received
+	SetFaultLastSeen(faultID int32, epoch int64) error
+	// GetFaultSent returns the content hash and epoch time (in seconds) of the last event sent for the fault
+	GetFaultSent(faultID int32) (hash string, epoch int64)
+	// SetFaultSent sets the content hash and epoch time (in seconds) of the last event sent for the fault
+	SetFaultSent(faultID int32, hash string, epoch int64) error
+	// GetFaultEvent returns the JSON payload of the last event sent for the fault
+	GetFaultEvent(faultID int32) []byte
+	// SetFaultEvent sets the JSON payload of the last event sent for the fault
+	SetFaultEvent(faultID int32, event []byte) error
+	// CommitFaultSent records at once that an event was sent for the fault: it increments the fault sequence number,
+	// and sets the content hash, epoch time (in seconds) and JSON payload of the last event sent
+	CommitFaultSent(faultID int32, hash string, epoch int64, event []byte) error
 }
 
 // AlertInfos struct used to store sequence and startepoch of the alert
 type AlertInfos struct {
-	Sequence   int64
-	StartEpoch int64
+	EventID     string
+	Sequence    int64
+	StartEpoch  int64
+	Labels      map[string]string // Labels of the alert which raised the fault
+	Annotations map[string]string // Annotations of the alert which raised the fault
+	Origin      AlertOrigin       // Origin of the alert which raised the fault
+	LastSeen    int64             // Epoch time (in seconds) at which the alert was last received
+	Hash        string            // Content hash of the last event sent
+	LastSent    int64             // Epoch time (in seconds) at which the last event was sent
+	Event       []byte            // JSON payload of the last event sent
 }
 
 type inMemState struct {
-	index      int32
+	index      int32 // Last allocated index
 	storage    map[string]int32
 	alertInfos map[int32]*AlertInfos
+	eventIDs   map[string]int32 // Index of faults by event ID
+	storedIDs  map[int32]bool   // Indexes of the faults in storage
 }
 
-// NextFaultIndex return the Fault Index to use and increments it
-func (mem *inMemState) NextFaultIndex() (int32, error) {
-	i := mem.index
-	mem.index++
+// newInMemState creates an empty fault state stored in memory
+func newInMemState() *inMemState {
+	return &inMemState{
+		storage:    make(map[string]int32),
+		alertInfos: make(map[int32]*AlertInfos),
+		eventIDs:   make(map[string]int32),
+		storedIDs:  make(map[int32]bool),
+	}
+}
+
+// NextFaultIndex return the next free Fault Index
+func (mem *inMemState) NextFaultIndex(max int32) (int32, error) {
+	i, err := NextFreeIndex(mem.index, max, mem.inUse)
+	if err != nil {
+		return 0, err
+	}
+	mem.index = i
 	return i, nil
 }
 
+// inUse returns true if index `faultID` is used by a fault
+func (mem *inMemState) inUse(faultID int32) bool {
+	_, ok := mem.alertInfos[faultID]
+	return ok || mem.storedIDs[faultID]
+}
+
+// deleteAlertInfos removes the alertInfos of faultID, and its event ID from the index
+func (mem *inMemState) deleteAlertInfos(faultID int32) {
+	if infos, ok := mem.alertInfos[faultID]; ok && mem.eventIDs[infos.EventID] == faultID {
+		delete(mem.eventIDs, infos.EventID)
+	}
+	delete(mem.alertInfos, faultID)
+}
+
+// PruneFaults removes the partially stored faults
+func (mem *inMemState) PruneFaults() (int, error) {
+	n := 0
+	stored := make(map[int32]bool, len(mem.storage))
+	for name, id := range mem.storage {
+		if _, ok := mem.alertInfos[id]; !ok {
+			delete(mem.storage, name)
+			delete(mem.storedIDs, id)
+			n++
+			continue
+		}
+		stored[id] = true
+	}
+	for id := range mem.alertInfos {
+		if !stored[id] {
+			mem.deleteAlertInfos(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
 // GetFaultInStorage checks if faultName already exist
 // return the associated index if exist else return 0
 func (mem *inMemState) GetFaultInStorage(faultName string) int32 {
@@ -75,19 +166,63 @@ func (mem *inMemState) GetFaultInStorage(faultName string) int32 {
 
 // StoreFaultInStorage stores the faultID associated to the faultName
 func (mem *inMemState) StoreFaultInStorage(faultName string, faultID int32) error {
+	if id, ok := mem.storage[faultName]; ok {
+		delete(mem.storedIDs, id)
+	}
 	mem.storage[faultName] = faultID
+	mem.storedIDs[faultID] = true
 	return nil
 }
 
-// DeleteFaultInStorage delete the index associated to the faultName
+// DeleteFaultInStorage delete the index and alertInfos associated to the faultName
 func (mem *inMemState) DeleteFaultInStorage(faultName string) error {
+	if id, ok := mem.storage[faultName]; ok {
+		mem.deleteAlertInfos(id)
+		delete(mem.storedIDs, id)
+	}
 	delete(mem.storage, faultName)
 	return nil
 }
 
+// GetFaultsInStorage returns a copy of the stored faultNames, with their index
+func (mem *inMemState) GetFaultsInStorage() map[string]int32 {
+	faults := make(map[string]int32, len(mem.storage))
+	for k, v := range mem.storage {
+		faults[k] = v
+	}
+	return faults
+}
+
+// GetFaultAlert returns the labels and annotations of the alert which raised the faultID
+func (mem *inMemState) GetFaultAlert(faultID int32) (map[string]string, map[string]string) {
+	if infos, ok := mem.alertInfos[faultID]; ok {
+		return infos.Labels, infos.Annotations
+	}
+	return nil, nil
+}
+
+// SetFaultAlert stores the labels, annotations and origin of the alert which raised the faultID
+func (mem *inMemState) SetFaultAlert(faultID int32, labels, annotations map[string]string, origin AlertOrigin) error {
+	mem.alertInfos[faultID].Labels = labels
+	mem.alertInfos[faultID].Annotations = annotations
+	mem.alertInfos[faultID].Origin = origin
+	return nil
+}
+
+// GetFaultOrigin returns the origin of the alert which raised the faultID
+func (mem *inMemState) GetFaultOrigin(faultID int32) AlertOrigin {
+	if infos, ok := mem.alertInfos[faultID]; ok {
+		return infos.Origin
+	}
+	return OriginAlertmanager
+}
+
 // GetFaultSn return the sequence value of the faultID index
 func (mem *inMemState) GetFaultSn(faultID int32) int64 {
-	return mem.alertInfos[faultID].Sequence
+	if infos, ok := mem.alertInfos[faultID]; ok {
+		return infos.Sequence
+	}
+	return 0
 }
 
 // IncFaultSequenceNumber increment the sequence value of the faultID index
@@ -101,8 +236,78 @@ func (mem *inMemState) GetFaultStartEpoch(faultID int32) int64 {
 	return mem.alertInfos[faultID].StartEpoch
 }
 
-func (mem *inMemState) InitAlertInfos(faultID int32) error {
-	mem.alertInfos[faultID] = &AlertInfos{1, 0}
+func (mem *inMemState) InitAlertInfos(faultID int32, eventID string) error {
+	mem.deleteAlertInfos(faultID)
+	mem.alertInfos[faultID] = &AlertInfos{EventID: eventID, Sequence: 1}
+	if eventID != "" {
+		mem.eventIDs[eventID] = faultID
+	}
+	return nil
+}
+
+// GetFaultIDByEventID returns the index of the fault with event ID `eventID`, or 0
+func (mem *inMemState) GetFaultIDByEventID(eventID string) int32 {
+	return mem.eventIDs[eventID]
+}
+
+// GetFaultEventID returns the event ID of faultID
+func (mem *inMemState) GetFaultEventID(faultID int32) string {
+	if infos, ok := mem.alertInfos[faultID]; ok {
+		return infos.EventID
+	}
+	return ""
+}
+
+// GetFaultLastSeen returns the last time the alert of faultID was received
+func (mem *inMemState) GetFaultLastSeen(faultID int32) int64 {
+	if infos, ok := mem.alertInfos[faultID]; ok {
+		return infos.LastSeen
+	}
+	return 0
+}
+
+// SetFaultLastSeen sets the last time the alert of faultID was received
+func (mem *inMemState) SetFaultLastSeen(faultID int32, epoch int64) error {
+	mem.alertInfos[faultID].LastSeen = epoch
+	return nil
+}
+
+// GetFaultSent returns the content hash and time of the last event sent for faultID
+func (mem *inMemState) GetFaultSent(faultID int32) (string, int64) {
+	if infos, ok := mem.alertInfos[faultID]; ok {
+		return infos.Hash, infos.LastSent
+	}
+	return "", 0
+}
+
+// SetFaultSent sets the content hash and time of the last event sent for faultID
+func (mem *inMemState) SetFaultSent(faultID int32, hash string, epoch int64) error {
+	mem.alertInfos[faultID].Hash = hash
+	mem.alertInfos[faultID].LastSent = epoch
+	return nil
+}
+
+// GetFaultEvent returns the payload of the last event sent for faultID
+func (mem *inMemState) GetFaultEvent(faultID int32) []byte {
+	if infos, ok := mem.alertInfos[faultID]; ok {
+		return infos.Event
+	}
+	return nil
+}
+
+// SetFaultEvent sets the payload of the last event sent for faultID
+func (mem *inMemState) SetFaultEvent(faultID int32, event []byte) error {
+	mem.alertInfos[faultID].Event = event
+	return nil
+}
+
+// CommitFaultSent increments the sequence value of faultID, and sets the hash, time and payload of the last event sent
+func (mem *inMemState) CommitFaultSent(faultID int32, hash string, epoch int64, event []byte) error {
+	infos := mem.alertInfos[faultID]
+	infos.Sequence++
+	infos.Hash = hash
+	infos.LastSent = epoch
+	infos.Event = event
 	return nil
 }
 
@@ -114,9 +319,14 @@ func (mem *inMemState) SetFaultStartEpoch(faultID int32, epoch int64) error {
 
 // FaultManager struct used to manage and store fault
 type FaultManager struct {
-	state FaultManagerState
-	lock  *sync.Mutex
-	conf  *govel.EventConfiguration
+	state      FaultManagerState
+	lock       *sync.Mutex
+	conf       *govel.EventConfiguration
+	mapping    *faultMapping
+	severities *severityMapping
+	expiration *expiration
+	repeats    *repeatPolicies
+	ids        *idAllocator
 }
 
 // StatusResult describes the result of the operation on storage
@@ -129,24 +339,59 @@ const (
 	Stored       StatusResult = 2
 	Cleared      StatusResult = 3
 	NotExist     StatusResult = 4
+	Ignored      StatusResult = 5
+	Suppressed   StatusResult = 6
+	StateError   StatusResult = 7 // The fault state could not be updated. The alert can be processed again
 )
 
-// NewFaultManagerWithState with state management
-func NewFaultManagerWithState(conf *govel.EventConfiguration, state FaultManagerState) *FaultManager {
+// NewFaultManagerWithConfig with state management, and alert to fault mapping rules, severities,
+// time to live and repeat policies from `faultConf`. An error is returned if the configuration is not valid
+func NewFaultManagerWithConfig(conf *govel.EventConfiguration, faultConf *config.FaultConfiguration, state FaultManagerState) (*FaultManager, error) {
+	mapping, err := newFaultMapping(faultConf)
+	if err != nil {
+		return nil, err
+	}
+	severities, err := newSeverityMapping(faultConf)
+	if err != nil {
+		return nil, err
+	}
+	expiration, err := newExpiration(faultConf)
+	if err != nil {
+		return nil, err
+	}
+	repeats, err := newRepeatPolicies(faultConf)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := newIDAllocator(faultConf)
+	if err != nil {
+		return nil, err
+	}
 	return &FaultManager{
 		//index:   0,
 		//storage: make(map[string]int32),
 		//alertInfos: make(map[int32]*AlertInfos),
-		lock:  new(sync.Mutex),
-		conf:  conf,
-		state: state,
-	}
+		lock:       new(sync.Mutex),
+		conf:       conf,
+		state:      state,
+		mapping:    mapping,
+		severities: severities,
+		expiration: expiration,
+		repeats:    repeats,
+		ids:        ids,
+	}, nil
+}
+
+// NewFaultManagerWithState with state management
+func NewFaultManagerWithState(conf *govel.EventConfiguration, state FaultManagerState) *FaultManager {
+	fm, _ := NewFaultManagerWithConfig(conf, nil, state)
+	return fm
 }
 
 // NewFaultManager create a FaultManager
 // return a pointer on the FaultManager created
 func NewFaultManager(conf *govel.EventConfiguration) *FaultManager {
-	return NewFaultManagerWithState(conf, &inMemState{index: 1, storage: make(map[string]int32), alertInfos: make(map[int32]*AlertInfos)})
+	return NewFaultManagerWithState(conf, newInMemState())
 }
 
 // GetFaultState returns a reference to the underlying fault manager state
@@ -162,6 +407,7 @@ func (fm *FaultManager) GetEventConf() *govel.EventConfiguration {
 // storeFault check if fault not already exist in the storage and store it;
 // return status = stored and id = the new index in case of success;
 // return status = alreadyExist if fault already exist;
+// return status = stateError if the state could not be updated;
 func (fm *FaultManager) storeFault(faultName string) (StatusResult, int32) {
 	var id int32
 	var status StatusResult
@@ -177,21 +423,27 @@ func (fm *FaultManager) storeFault(faultName string) (StatusResult, int32) {
 		//fm.alertInfos[id].sequence++
 		//return status, id
 	} else {
-		faultID, err := fm.state.NextFaultIndex()
+		faultID, err := fm.state.NextFaultIndex(fm.ids.max)
 		if err != nil {
-			status = InError
-			return status, 0
+			log.Error(err.Error())
+			return InError, 0
 		}
-		if err = fm.state.StoreFaultInStorage(faultName, faultID); err != nil {
+		eventID, err := fm.ids.eventID(faultID)
+		if err != nil {
 			log.Error(err.Error())
 			return InError, 0
 		}
+		if err = fm.state.StoreFaultInStorage(faultName, faultID); err != nil {
+			log.Error(err.Error())
+			return StateError, 0
+		}
 		log.Infof("store fault: %s with index %d \n", faultName, faultID)
 		id = faultID
 		status = Stored
-		err = fm.state.InitAlertInfos(id)
+		err = fm.state.InitAlertInfos(id, eventID)
 		if err != nil {
-			status = InError
+			log.Error(err.Error())
+			status = StateError
 			return status, 0
 		}
 	}
@@ -17,8 +17,13 @@
 package ha
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/convert"
+
+	"github.com/prometheus/alertmanager/template"
 )
 
 const nullValue = "<null>"
@@ -35,18 +40,36 @@ const (
 	IncrementFaultIdx
 	UpdateFault
 	DeleteFault
+	EnqueueAlerts
+	UpdateQueuedAlert
+	AppendFaultRecord
+	InitFault
+	PruneFaults
+	IncrementSyslogIdx
 )
 
 // StateCmd is a state change command sent through commit logs
 type StateCmd struct {
 	// Kind of command
 	Type CmdType `json:"ty"`
+	// Fields for command of kind IncrementFaultIdx
+	IncrementFaultIdx *IncrementFaultIdxFields `json:"faultidx,omitempty"`
 	// Fields for command of kind UpdateScheduler
 	UpdateScheduler *UpdateSchedulerFields `json:"sched,omitempty"`
 	// Fields for command of kind UpdateFault
 	UpdateFault *UpdateFaultFields `json:"updatefault,omitempty"`
 	// Fields for command of kind DeleteFault
 	DeleteFault *DeleteFaultFields `json:"deletefault,omitempty"`
+	// Fields for command of kind EnqueueAlerts
+	EnqueueAlerts *EnqueueAlertsFields `json:"enqueue,omitempty"`
+	// Fields for command of kind UpdateQueuedAlert
+	UpdateQueuedAlert *UpdateQueuedAlertFields `json:"queued,omitempty"`
+	// Fields for command of kind AppendFaultRecord
+	AppendFaultRecord *AppendFaultRecordFields `json:"history,omitempty"`
+	// Fields for command of kind InitFault
+	InitFault *InitFaultFields `json:"initfault,omitempty"`
+	// Fields for command of kind IncrementSyslogIdx
+	IncrementSyslogIdx *IncrementSyslogIdxFields `json:"syslogidx,omitempty"`
 }
 
 func (cmd *StateCmd) String() string {
@@ -60,11 +83,23 @@ func (cmd *StateCmd) String() string {
 	case UpdateScheduler:
 		return fmt.Sprintf("UpdateScheduler => %s", cmd.UpdateScheduler.String())
 	case IncrementFaultIdx:
-		return "IncrementFaultIdx"
+		return fmt.Sprintf("IncrementFaultIdx => %s", cmd.IncrementFaultIdx.String())
 	case UpdateFault:
 		return fmt.Sprintf("UpdateFault => %s", cmd.UpdateFault.String())
 	case DeleteFault:
 		return fmt.Sprintf("DeleteFault => %s", cmd.DeleteFault.String())
+	case EnqueueAlerts:
+		return fmt.Sprintf("EnqueueAlerts => %s", cmd.EnqueueAlerts.String())
+	case UpdateQueuedAlert:
+		return fmt.Sprintf("UpdateQueuedAlert => %s", cmd.UpdateQueuedAlert.String())
+	case AppendFaultRecord:
+		return fmt.Sprintf("AppendFaultRecord => %s", cmd.AppendFaultRecord.String())
+	case InitFault:
+		return fmt.Sprintf("InitFault => %s", cmd.InitFault.String())
+	case PruneFaults:
+		return "PruneFaults"
+	case IncrementSyslogIdx:
+		return fmt.Sprintf("IncrementSyslogIdx => %s", cmd.IncrementSyslogIdx.String())
 	default:
 		return fmt.Sprintf("Unknown command type: %d", cmd.Type)
 	}
@@ -78,6 +113,10 @@ type UpdateSchedulerFields struct {
 	Interval *time.Duration `json:"intv,omitempty"`
 	// New value of next run epoch time (in seconds), if updated, or nil
 	Next *int64 `json:"nxt,omitempty"`
+	// Start epoch time (in seconds) of the acknowledged window, if acknowledged, or nil
+	AckFrom *int64 `json:"ackf,omitempty"`
+	// End epoch time (in seconds) of the acknowledged window, if acknowledged, or nil
+	AckTo *int64 `json:"ackt,omitempty"`
 }
 
 // UpdateFaultFields holds the fields for command of kind UpdateFault
@@ -90,6 +129,20 @@ type UpdateFaultFields struct {
 	SequenceNumber *int64 `json:"sn,omitempty"`
 	// New value of startEpoch
 	StartEpoch *int64 `json:"epoch,omitempty"`
+	// Labels of the alert which raised the fault, if updated, or nil
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations of the alert which raised the fault, if updated, or nil
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Origin of the alert which raised the fault, set with its labels and annotations
+	Origin convert.AlertOrigin `json:"origin,omitempty"`
+	// Epoch time (in seconds) at which the alert was last received, if updated, or nil
+	LastSeen *int64 `json:"seen,omitempty"`
+	// Content hash of the last event sent for the fault, if updated, or nil
+	Hash *string `json:"hash,omitempty"`
+	// Epoch time (in seconds) at which the last event was sent, if updated, or nil
+	LastSent *int64 `json:"sent,omitempty"`
+	// JSON payload of the last event sent for the fault, if updated, or nil
+	Event json.RawMessage `json:"event,omitempty"`
 }
 
 // DeleteFaultFields holds the fields for command of kind DeleteFault
@@ -98,6 +151,60 @@ type DeleteFaultFields struct {
 	FaultName string `json:"faultName"`
 }
 
+// EnqueueAlertsFields holds the fields for command of kind EnqueueAlerts
+type EnqueueAlertsFields struct {
+	// Alerts to append to the queue
+	Alerts []template.Alert `json:"alerts"`
+	// Origin of the alerts
+	Origin convert.AlertOrigin `json:"origin,omitempty"`
+	// Epoch time (in seconds) at which the alerts were received
+	Received int64 `json:"recv"`
+	// Maximum number of pending alerts in the queue. 0 means no limit
+	MaxPending int `json:"max,omitempty"`
+}
+
+// UpdateQueuedAlertFields holds the fields for command of kind UpdateQueuedAlert
+type UpdateQueuedAlertFields struct {
+	// ID of the queued alert
+	ID int64 `json:"id"`
+	// Status of the alert after the processing attempt
+	Status convert.AlertStatus `json:"status"`
+	// Error of the processing attempt, if any
+	Error string `json:"err,omitempty"`
+	// Epoch time (in seconds) of the processing attempt
+	At int64 `json:"at"`
+	// Number of alerts no longer pending to keep in the queue
+	Retain int `json:"retain"`
+}
+
+// IncrementFaultIdxFields holds the fields for command of kind IncrementFaultIdx
+type IncrementFaultIdxFields struct {
+	// Largest fault index, past which indexes wrap around
+	Max int32 `json:"max"`
+}
+
+// InitFaultFields holds the fields for command of kind InitFault
+type InitFaultFields struct {
+	// FaultID of the fault to initialize
+	FaultID int32 `json:"faultId"`
+	// Event ID of the fault
+	EventID string `json:"eventId"`
+}
+
+// IncrementSyslogIdxFields holds the fields for command of kind IncrementSyslogIdx
+type IncrementSyslogIdxFields struct {
+	// Number of indexes to reserve
+	Count int `json:"count"`
+}
+
+// AppendFaultRecordFields holds the fields for command of kind AppendFaultRecord
+type AppendFaultRecordFields struct {
+	// Record to append to the fault history
+	Record convert.FaultRecord `json:"record"`
+	// Number of records to keep in the history
+	Retain int `json:"retain"`
+}
+
 func (fields *UpdateSchedulerFields) String() string {
 	if fields == nil {
 		return nullValue
@@ -107,6 +214,10 @@ func (fields *UpdateSchedulerFields) String() string {
 		t := time.Unix(*fields.Next, 0)
 		nxt = &t
 	}
+	if fields.AckFrom != nil && fields.AckTo != nil {
+		return fmt.Sprintf("name: %s, interval: %s, next: %s, ack: [%s, %s]", fields.Name, fields.Interval, nxt,
+			time.Unix(*fields.AckFrom, 0), time.Unix(*fields.AckTo, 0))
+	}
 	return fmt.Sprintf("name: %s, interval: %s, next: %s", fields.Name, fields.Interval, nxt)
 }
 
@@ -131,3 +242,46 @@ func (fields *DeleteFaultFields) String() string {
 	}
 	return fmt.Sprintf("faultName: %s", fields.FaultName)
 }
+
+func (fields *EnqueueAlertsFields) String() string {
+	if fields == nil {
+		return nullValue
+	}
+	return fmt.Sprintf("alerts: %d, origin: %q, received: %s, maxPending: %d", len(fields.Alerts), fields.Origin, time.Unix(fields.Received, 0), fields.MaxPending)
+}
+
+func (fields *UpdateQueuedAlertFields) String() string {
+	if fields == nil {
+		return nullValue
+	}
+	return fmt.Sprintf("id: %d, status: %s, error: %s, at: %s", fields.ID, fields.Status, fields.Error, time.Unix(fields.At, 0))
+}
+
+func (fields *AppendFaultRecordFields) String() string {
+	if fields == nil {
+		return nullValue
+	}
+	return fmt.Sprintf("action: %s, eventId: %s, sn: %d, outcome: %s, time: %s", fields.Record.Action, fields.Record.EventID,
+		fields.Record.Sequence, fields.Record.Outcome, time.Unix(fields.Record.Time, 0))
+}
+
+func (fields *IncrementFaultIdxFields) String() string {
+	if fields == nil {
+		return nullValue
+	}
+	return fmt.Sprintf("max: %d", fields.Max)
+}
+
+func (fields *IncrementSyslogIdxFields) String() string {
+	if fields == nil {
+		return nullValue
+	}
+	return fmt.Sprintf("count: %d", fields.Count)
+}
+
+func (fields *InitFaultFields) String() string {
+	if fields == nil {
+		return nullValue
+	}
+	return fmt.Sprintf("faultId: %10d, eventId: %s", fields.FaultID, fields.EventID)
+}
@@ -23,6 +23,8 @@ import (
 	"io"
 	"time"
 
+	"github.com/nokia/onap-vespa/ves-agent/convert"
+
 	"github.com/hashicorp/raft"
 	log "github.com/sirupsen/logrus"
 )
@@ -54,6 +56,11 @@ func (fsm *FSM) NextRun(sched string) time.Time {
 	return fsm.state.NextRun(sched)
 }
 
+// LastAck returns the boundaries of the last acknowledged execution window
+func (fsm *FSM) LastAck(sched string) (time.Time, time.Time) {
+	return fsm.state.LastAck(sched)
+}
+
 // GetFaultSn return the fault sequence number
 func (fsm *FSM) GetFaultSn(fault int32) int64 {
 	return fsm.state.GetFaultSn(fault)
@@ -64,9 +71,14 @@ func (fsm *FSM) GetFaultStartEpoch(fault int32) int64 {
 	return fsm.state.GetFaultStartEpoch(fault)
 }
 
-// InitAlertInfos update the alertInfos map
-func (fsm *FSM) InitAlertInfos(fault int32) error {
-	return fsm.state.InitAlertInfos(fault)
+// GetFaultEventID returns the event ID of the fault
+func (fsm *FSM) GetFaultEventID(fault int32) string {
+	return fsm.state.GetFaultEventID(fault)
+}
+
+// GetFaultIDByEventID returns the index of the fault with event ID `eventID`, or 0
+func (fsm *FSM) GetFaultIDByEventID(eventID string) int32 {
+	return fsm.state.GetFaultIDByEventID(eventID)
 }
 
 // GetFaultInStorage checks if faultName already associated to an index
@@ -79,6 +91,61 @@ func (fsm *FSM) DeleteFaultInStorage(faultName string) error {
 	return fsm.state.DeleteFaultInStorage(faultName)
 }
 
+// GetFaultsInStorage returns all the stored faultNames, with their index
+func (fsm *FSM) GetFaultsInStorage() map[string]int32 {
+	return fsm.state.GetFaultsInStorage()
+}
+
+// GetFaultAlert returns the labels and annotations of the alert which raised the fault
+func (fsm *FSM) GetFaultAlert(fault int32) (map[string]string, map[string]string) {
+	return fsm.state.GetFaultAlert(fault)
+}
+
+// GetFaultOrigin returns the origin of the alert which raised the fault
+func (fsm *FSM) GetFaultOrigin(fault int32) convert.AlertOrigin {
+	return fsm.state.GetFaultOrigin(fault)
+}
+
+// GetFaultLastSeen returns the epoch time at which the fault's alert was last received
+func (fsm *FSM) GetFaultLastSeen(fault int32) int64 {
+	return fsm.state.GetFaultLastSeen(fault)
+}
+
+// NextQueuedAlert returns the oldest pending alert, if any
+func (fsm *FSM) NextQueuedAlert() (convert.QueuedAlert, bool) {
+	return fsm.state.NextQueuedAlert()
+}
+
+// GetQueuedAlert returns the queued alert with ID `id`, if still known
+func (fsm *FSM) GetQueuedAlert(id int64) (convert.QueuedAlert, bool) {
+	return fsm.state.GetQueuedAlert(id)
+}
+
+// GetQueuedAlerts returns all the known queued alerts
+func (fsm *FSM) GetQueuedAlerts() []convert.QueuedAlert {
+	return fsm.state.GetQueuedAlerts()
+}
+
+// PendingAlerts returns the number of pending alerts
+func (fsm *FSM) PendingAlerts() int {
+	return fsm.state.PendingAlerts()
+}
+
+// GetFaultHistory returns the fault history
+func (fsm *FSM) GetFaultHistory() []convert.FaultRecord {
+	return fsm.state.GetFaultHistory()
+}
+
+// GetFaultEvent returns the JSON payload of the last event sent for the fault
+func (fsm *FSM) GetFaultEvent(fault int32) []byte {
+	return fsm.state.GetFaultEvent(fault)
+}
+
+// GetFaultSent returns the content hash and epoch time of the last event sent for the fault
+func (fsm *FSM) GetFaultSent(fault int32) (string, int64) {
+	return fsm.state.GetFaultSent(fault)
+}
+
 // Apply applies a Raft log to this FSM
 func (fsm *FSM) Apply(logEntry *raft.Log) interface{} {
 	var cmd StateCmd
@@ -90,6 +157,10 @@ func (fsm *FSM) Apply(logEntry *raft.Log) interface{} {
 		log.Infof("Apply Log - [%s]", cmd.String())
 	}
 	res, err := fsm.processCmd(cmd)
+	if err == convert.ErrQueueFull {
+		// Rejected alerts are logged by the receiver
+		return err
+	}
 	if err != nil {
 		log.Errorf("Cannot appy command: %s", err.Error())
 		return err
@@ -108,7 +179,7 @@ func (fsm *FSM) Restore(input io.ReadCloser) error {
 	log.Info("Restoring snapshot")
 	defer func() {
 		if err := input.Close(); err != nil {
-			log.Errorf(err.Error())
+			log.Error(err.Error())
 		}
 	}()
 	snapshot := AgentStateSnapshot{}
@@ -125,14 +196,48 @@ func (fsm *FSM) processCmd(cmd StateCmd) (interface{}, error) {
 		return fsm.state.NextMeasurementIndex()
 	case IncrementHeartbeatIdx:
 		return fsm.state.NextHeartbeatIndex()
+	case IncrementSyslogIdx:
+		if cmd.IncrementSyslogIdx == nil {
+			return nil, errors.New("IncrementSyslogIdx field is absent")
+		}
+		return fsm.state.NextSyslogIndexes(cmd.IncrementSyslogIdx.Count)
 	case UpdateScheduler:
 		return nil, fsm.handleSchedulerUpdate(cmd.UpdateScheduler)
 	case IncrementFaultIdx:
-		return fsm.state.NextFaultIndex()
+		// Commands from older versions have no fields, and no maximum index
+		var max int32
+		if cmd.IncrementFaultIdx != nil {
+			max = cmd.IncrementFaultIdx.Max
+		}
+		return fsm.state.NextFaultIndex(max)
 	case UpdateFault:
 		return nil, fsm.handleFaultUpdate(cmd.UpdateFault)
 	case DeleteFault:
 		return nil, fsm.state.DeleteFaultInStorage(cmd.DeleteFault.FaultName)
+	case EnqueueAlerts:
+		if cmd.EnqueueAlerts == nil {
+			return nil, errors.New("EnqueueAlerts field is absent")
+		}
+		return fsm.state.EnqueueAlerts(cmd.EnqueueAlerts.Alerts, cmd.EnqueueAlerts.Origin, cmd.EnqueueAlerts.Received, cmd.EnqueueAlerts.MaxPending)
+	case UpdateQueuedAlert:
+		fields := cmd.UpdateQueuedAlert
+		if fields == nil {
+			return nil, errors.New("UpdateQueuedAlert field is absent")
+		}
+		return nil, fsm.state.SetQueuedAlertResult(fields.ID, fields.Status, fields.Error, fields.At, fields.Retain)
+	case AppendFaultRecord:
+		fields := cmd.AppendFaultRecord
+		if fields == nil {
+			return nil, errors.New("AppendFaultRecord field is absent")
+		}
+		return nil, fsm.state.AppendFaultRecord(fields.Record, fields.Retain)
+	case InitFault:
+		if cmd.InitFault == nil {
+			return nil, errors.New("InitFault field is absent")
+		}
+		return nil, fsm.state.InitAlertInfos(cmd.InitFault.FaultID, cmd.InitFault.EventID)
+	case PruneFaults:
+		return fsm.state.PruneFaults()
 	default:
 		return nil, fmt.Errorf("Unknown command type: %d", cmd.Type)
 	}
@@ -147,6 +252,9 @@ func (fsm *FSM) handleSchedulerUpdate(fields *UpdateSchedulerFields) error {
 			return err
 		}
 	}
+	if fields.AckFrom != nil && fields.AckTo != nil && fields.Next != nil {
+		return fsm.state.Acknowledge(fields.Name, time.Unix(*fields.AckFrom, 0), time.Unix(*fields.AckTo, 0), time.Unix(*fields.Next, 0))
+	}
 	if fields.Next != nil {
 		if err := fsm.state.UpdateNextRun(fields.Name, time.Unix(*fields.Next, 0)); err != nil {
 			return err
@@ -168,6 +276,10 @@ func (fsm *FSM) handleFaultUpdate(fields *UpdateFaultFields) error {
 				return err
 			}
 		}
+		if fields.SequenceNumber != nil && fields.Hash != nil && fields.LastSent != nil && fields.Event != nil {
+			// An event was sent for the fault
+			return fsm.state.CommitFaultSent(*fields.FaultID, *fields.Hash, *fields.LastSent, fields.Event)
+		}
 		if fields.SequenceNumber != nil {
 			//debugMsg = debugMsg + "sn " + *fields.SequenceNumber
 			if err := fsm.state.IncrementFaultSn(*fields.FaultID); err != nil {
@@ -180,6 +292,26 @@ func (fsm *FSM) handleFaultUpdate(fields *UpdateFaultFields) error {
 				return err
 			}
 		}
+		if fields.Labels != nil || fields.Annotations != nil {
+			if err := fsm.state.SetFaultAlert(*fields.FaultID, fields.Labels, fields.Annotations, fields.Origin); err != nil {
+				return err
+			}
+		}
+		if fields.LastSeen != nil {
+			if err := fsm.state.SetFaultLastSeen(*fields.FaultID, *fields.LastSeen); err != nil {
+				return err
+			}
+		}
+		if fields.Hash != nil && fields.LastSent != nil {
+			if err := fsm.state.SetFaultSent(*fields.FaultID, *fields.Hash, *fields.LastSent); err != nil {
+				return err
+			}
+		}
+		if fields.Event != nil {
+			if err := fsm.state.SetFaultEvent(*fields.FaultID, fields.Event); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -18,6 +18,7 @@ package ha
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -25,10 +26,12 @@ import (
 	"path/filepath"
 	"time"
 	"github.com/nokia/onap-vespa/ves-agent/config"
+	"github.com/nokia/onap-vespa/ves-agent/convert"
 
 	"github.com/hashicorp/raft-boltdb"
 
 	"github.com/hashicorp/raft"
+	"github.com/prometheus/alertmanager/template"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -45,6 +48,7 @@ type Cluster struct {
 	raft     *raft.Raft
 	leaderCh <-chan bool
 	fsm      *FSM
+	peers    config.Peers
 }
 
 // NewCluster creates and start a new cluster around `state`.
@@ -74,10 +78,12 @@ func NewCluster(datadir string, cfg *config.ClusterConfiguration, state Snapshot
 		raft.LogStore
 	}
 	var needBootstrap bool
+	var peers config.Peers
 
 	if cfg != nil && len(cfg.Peers) > 0 {
 		log.Info("Initializing Raft cluster")
 		conf.LocalID = raft.ServerID(cfg.ID)
+		peers = cfg.Peers
 		servers = cfg.Peers.Servers()
 		myself, ok := cfg.Peers.GetPeer(cfg.ID)
 		if !ok {
@@ -128,6 +134,7 @@ func NewCluster(datadir string, cfg *config.ClusterConfiguration, state Snapshot
 		raft:     node,
 		leaderCh: leaderCh,
 		fsm:      fsm,
+		peers:    peers,
 	}
 
 	if needBootstrap {
@@ -151,6 +158,25 @@ func (cluster *Cluster) LeaderCh() <-chan bool {
 	return cluster.leaderCh
 }
 
+// IsLeader returns true if the current node is the cluster's leader
+func (cluster *Cluster) IsLeader() bool {
+	return cluster.raft.State() == raft.Leader
+}
+
+// LeaderAPI returns the REST API address of the current cluster's leader,
+// as configured for it in peers list
+func (cluster *Cluster) LeaderAPI() (string, error) {
+	addr := cluster.raft.Leader()
+	if addr == "" {
+		return "", errors.New("No known cluster leader")
+	}
+	peer, ok := cluster.peers.GetPeerByAddress(string(addr))
+	if !ok || peer.API == "" {
+		return "", fmt.Errorf("No API address configured for leader %s", addr)
+	}
+	return peer.API, nil
+}
+
 func (cluster *Cluster) bootstrap(peers []raft.Server) error {
 	log.Info("Bootstrapping Raft Cluster")
 	return cluster.raft.BootstrapCluster(raft.Configuration{
@@ -198,6 +224,15 @@ func (cluster *Cluster) NextHeartbeatIndex() (int64, error) {
 	return idx.(int64), nil
 }
 
+// NextSyslogIndexes reserves `count` event indexes, and returns the first one
+func (cluster *Cluster) NextSyslogIndexes(count int) (int64, error) {
+	idx, err := cluster.apply(StateCmd{Type: IncrementSyslogIdx, IncrementSyslogIdx: &IncrementSyslogIdxFields{Count: count}})
+	if err != nil {
+		return 0, err
+	}
+	return idx.(int64), nil
+}
+
 // NextRun returns the time at which next execution should occure
 func (cluster *Cluster) NextRun(sched string) time.Time {
 	return cluster.fsm.NextRun(sched)
@@ -210,6 +245,18 @@ func (cluster *Cluster) UpdateNextRun(sched string, next time.Time) error {
 	return err
 }
 
+// LastAck returns the boundaries of the last acknowledged execution window
+func (cluster *Cluster) LastAck(sched string) (time.Time, time.Time) {
+	return cluster.fsm.LastAck(sched)
+}
+
+// Acknowledge records the acknowledged execution window and sets the time of the next execution
+func (cluster *Cluster) Acknowledge(sched string, from, to, next time.Time) error {
+	frm, t, nxt := from.Unix(), to.Unix(), next.Unix()
+	_, err := cluster.apply(StateCmd{Type: UpdateScheduler, UpdateScheduler: &UpdateSchedulerFields{Name: sched, Next: &nxt, AckFrom: &frm, AckTo: &t}})
+	return err
+}
+
 // Interval returns the scheduler exceution interval
 func (cluster *Cluster) Interval(sched string) time.Duration {
 	return cluster.fsm.Interval(sched)
@@ -254,14 +301,34 @@ func (cluster *Cluster) SetFaultStartEpoch(faultID int32, epoch int64) error {
 	return err
 }
 
-// InitAlertInfos update the alertInfos map
-func (cluster *Cluster) InitAlertInfos(faultID int32) error {
-	return cluster.fsm.InitAlertInfos(faultID)
+// InitAlertInfos initializes the state of the fault, with its event ID
+func (cluster *Cluster) InitAlertInfos(faultID int32, eventID string) error {
+	_, err := cluster.apply(StateCmd{Type: InitFault, InitFault: &InitFaultFields{FaultID: faultID, EventID: eventID}})
+	return err
+}
+
+// GetFaultIDByEventID returns the index of the fault with event ID `eventID`, or 0
+func (cluster *Cluster) GetFaultIDByEventID(eventID string) int32 {
+	return cluster.fsm.GetFaultIDByEventID(eventID)
+}
+
+// GetFaultEventID returns the event ID of the fault
+func (cluster *Cluster) GetFaultEventID(faultID int32) string {
+	return cluster.fsm.GetFaultEventID(faultID)
+}
+
+// PruneFaults removes the partially stored faults, and returns their number
+func (cluster *Cluster) PruneFaults() (int, error) {
+	n, err := cluster.apply(StateCmd{Type: PruneFaults})
+	if err != nil {
+		return 0, err
+	}
+	return n.(int), nil
 }
 
-// NextFaultIndex return the Fault Index and increments it
-func (cluster *Cluster) NextFaultIndex() (int32, error) {
-	idx, err := cluster.apply(StateCmd{Type: IncrementFaultIdx})
+// NextFaultIndex returns the next free Fault Index, wrapping around to 1 past `max`
+func (cluster *Cluster) NextFaultIndex(max int32) (int32, error) {
+	idx, err := cluster.apply(StateCmd{Type: IncrementFaultIdx, IncrementFaultIdx: &IncrementFaultIdxFields{Max: max}})
 	if err != nil {
 		return 0, err
 	}
@@ -280,9 +347,124 @@ func (cluster *Cluster) StoreFaultInStorage(faultName string, faultID int32) err
 	return err
 }
 
+// GetFaultsInStorage returns all the stored faultNames, with their index
+func (cluster *Cluster) GetFaultsInStorage() map[string]int32 {
+	return cluster.fsm.GetFaultsInStorage()
+}
+
+// GetFaultAlert returns the labels and annotations of the alert which raised the fault
+func (cluster *Cluster) GetFaultAlert(faultID int32) (map[string]string, map[string]string) {
+	return cluster.fsm.GetFaultAlert(faultID)
+}
+
+// SetFaultAlert stores the labels, annotations and origin of the alert which raised the fault
+func (cluster *Cluster) SetFaultAlert(faultID int32, labels, annotations map[string]string, origin convert.AlertOrigin) error {
+	_, err := cluster.apply(StateCmd{Type: UpdateFault, UpdateFault: &UpdateFaultFields{FaultID: &faultID, Labels: labels, Annotations: annotations, Origin: origin}})
+	return err
+}
+
+// GetFaultOrigin returns the origin of the alert which raised the fault
+func (cluster *Cluster) GetFaultOrigin(faultID int32) convert.AlertOrigin {
+	return cluster.fsm.GetFaultOrigin(faultID)
+}
+
+// GetFaultLastSeen returns the epoch time at which the fault's alert was last received
+func (cluster *Cluster) GetFaultLastSeen(faultID int32) int64 {
+	return cluster.fsm.GetFaultLastSeen(faultID)
+}
+
+// SetFaultLastSeen sets the epoch time at which the fault's alert was last received
+func (cluster *Cluster) SetFaultLastSeen(faultID int32, epoch int64) error {
+	_, err := cluster.apply(StateCmd{Type: UpdateFault, UpdateFault: &UpdateFaultFields{FaultID: &faultID, LastSeen: &epoch}})
+	return err
+}
+
+// GetFaultSent returns the content hash and epoch time of the last event sent for the fault
+func (cluster *Cluster) GetFaultSent(faultID int32) (string, int64) {
+	return cluster.fsm.GetFaultSent(faultID)
+}
+
+// SetFaultSent sets the content hash and epoch time of the last event sent for the fault
+func (cluster *Cluster) SetFaultSent(faultID int32, hash string, epoch int64) error {
+	_, err := cluster.apply(StateCmd{Type: UpdateFault, UpdateFault: &UpdateFaultFields{FaultID: &faultID, Hash: &hash, LastSent: &epoch}})
+	return err
+}
+
+// GetFaultEvent returns the JSON payload of the last event sent for the fault
+func (cluster *Cluster) GetFaultEvent(faultID int32) []byte {
+	return cluster.fsm.GetFaultEvent(faultID)
+}
+
+// SetFaultEvent sets the JSON payload of the last event sent for the fault
+func (cluster *Cluster) SetFaultEvent(faultID int32, event []byte) error {
+	_, err := cluster.apply(StateCmd{Type: UpdateFault, UpdateFault: &UpdateFaultFields{FaultID: &faultID, Event: event}})
+	return err
+}
+
+// CommitFaultSent increments the fault sequence number, and sets the content hash, epoch time and JSON payload
+// of the last event sent for the fault, in a single state command
+func (cluster *Cluster) CommitFaultSent(faultID int32, hash string, epoch int64, event []byte) error {
+	sn := int64(1)
+	_, err := cluster.apply(StateCmd{Type: UpdateFault, UpdateFault: &UpdateFaultFields{
+		FaultID:        &faultID,
+		SequenceNumber: &sn,
+		Hash:           &hash,
+		LastSent:       &epoch,
+		Event:          event,
+	}})
+	return err
+}
+
 // DeleteFaultInStorage delete Fault in storage
 func (cluster *Cluster) DeleteFaultInStorage(faultName string) error {
 	//fmt.Printf("raft msg DeleteFaultInStorage faultName:%s ", faultName)
 	_, err := cluster.apply(StateCmd{Type: DeleteFault, DeleteFault: &DeleteFaultFields{FaultName: faultName}})
 	return err
 }
+
+// EnqueueAlerts appends the alerts received from `origin` at epoch time `received` to the queue, and returns their IDs.
+// If `maxPending` is positive, alerts are rejected with convert.ErrQueueFull when the queue would hold more pending alerts
+func (cluster *Cluster) EnqueueAlerts(alerts []template.Alert, origin convert.AlertOrigin, received int64, maxPending int) ([]int64, error) {
+	ids, err := cluster.apply(StateCmd{Type: EnqueueAlerts, EnqueueAlerts: &EnqueueAlertsFields{Alerts: alerts, Origin: origin, Received: received, MaxPending: maxPending}})
+	if err != nil {
+		return nil, err
+	}
+	return ids.([]int64), nil
+}
+
+// NextQueuedAlert returns the oldest pending alert, if any
+func (cluster *Cluster) NextQueuedAlert() (convert.QueuedAlert, bool) {
+	return cluster.fsm.NextQueuedAlert()
+}
+
+// GetQueuedAlert returns the queued alert with ID `id`, if still known
+func (cluster *Cluster) GetQueuedAlert(id int64) (convert.QueuedAlert, bool) {
+	return cluster.fsm.GetQueuedAlert(id)
+}
+
+// GetQueuedAlerts returns all the known queued alerts
+func (cluster *Cluster) GetQueuedAlerts() []convert.QueuedAlert {
+	return cluster.fsm.GetQueuedAlerts()
+}
+
+// PendingAlerts returns the number of pending alerts
+func (cluster *Cluster) PendingAlerts() int {
+	return cluster.fsm.PendingAlerts()
+}
+
+// SetQueuedAlertResult records the result of the attempt to process queued alert `id`
+func (cluster *Cluster) SetQueuedAlertResult(id int64, status convert.AlertStatus, errMsg string, at int64, retain int) error {
+	_, err := cluster.apply(StateCmd{Type: UpdateQueuedAlert, UpdateQueuedAlert: &UpdateQueuedAlertFields{ID: id, Status: status, Error: errMsg, At: at, Retain: retain}})
+	return err
+}
+
+// AppendFaultRecord appends `record` to the fault history. Only the `retain` most recent records are kept
+func (cluster *Cluster) AppendFaultRecord(record convert.FaultRecord, retain int) error {
+	_, err := cluster.apply(StateCmd{Type: AppendFaultRecord, AppendFaultRecord: &AppendFaultRecordFields{Record: record, Retain: retain}})
+	return err
+}
+
+// GetFaultHistory returns the fault history, ordered by ID
+func (cluster *Cluster) GetFaultHistory() []convert.FaultRecord {
+	return cluster.fsm.GetFaultHistory()
+}
@@ -20,7 +20,11 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/convert"
+
 	"github.com/hashicorp/raft"
+	"github.com/prometheus/alertmanager/template"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -38,12 +42,49 @@ type SnapshotableAgentState interface {
 type SchedulerStateSnapshot struct {
 	Interval time.Duration `json:"interval"`
 	Next     time.Time     `json:"time"`
+	AckFrom  time.Time     `json:"ack_from"`
+	AckTo    time.Time     `json:"ack_to"`
 }
 
 // AlertInfosStateSnapShot is a snapshot of an alert info
 type AlertInfosStateSnapShot struct {
-	Sn    int64 `json:"sn"`
-	Epoch int64 `json:"epoch"`
+	EventID     string              `json:"eventId,omitempty"`
+	Sn          int64               `json:"sn"`
+	Epoch       int64               `json:"epoch"`
+	Labels      map[string]string   `json:"labels,omitempty"`
+	Annotations map[string]string   `json:"annotations,omitempty"`
+	Origin      convert.AlertOrigin `json:"origin,omitempty"`
+	LastSeen    int64               `json:"seen,omitempty"`
+	Hash        string              `json:"hash,omitempty"`
+	LastSent    int64               `json:"sent,omitempty"`
+	Event       json.RawMessage     `json:"event,omitempty"`
+}
+
+// QueuedAlertStateSnapshot is a snapshot of a queued alert
+type QueuedAlertStateSnapshot struct {
+	ID          int64               `json:"id"`
+	Alert       template.Alert      `json:"alert"`
+	Origin      convert.AlertOrigin `json:"origin,omitempty"`
+	Received    int64               `json:"recv"`
+	Status      convert.AlertStatus `json:"status"`
+	Attempts    int                 `json:"attempts,omitempty"`
+	LastAttempt int64               `json:"last,omitempty"`
+	Error       string              `json:"err,omitempty"`
+}
+
+// FaultRecordStateSnapshot is a snapshot of a fault history record
+type FaultRecordStateSnapshot struct {
+	ID               int64                `json:"id"`
+	Time             int64                `json:"time"`
+	Action           convert.FaultAction  `json:"action"`
+	EventID          string               `json:"eventId"`
+	AlertName        string               `json:"alertName,omitempty"`
+	SourceName       string               `json:"sourceName,omitempty"`
+	Severity         govel.Severity       `json:"severity"`
+	PreviousSeverity govel.Severity       `json:"prevSeverity,omitempty"`
+	Sequence         int64                `json:"sn"`
+	Outcome          convert.FaultOutcome `json:"outcome"`
+	Error            string               `json:"err,omitempty"`
 }
 
 // AgentStateSnapshot holds a serializable copy of agent state
@@ -54,6 +95,11 @@ type AgentStateSnapshot struct {
 	FaultIdx     int32                             `json:"fault_idx"`
 	AlertInfos   map[int32]AlertInfosStateSnapShot `json:"alertInfos"`
 	StorageFault map[string]int32                  `json:"storageFault"`
+	QueueIdx     int64                             `json:"queue_idx"`
+	Queue        []QueuedAlertStateSnapshot        `json:"queue,omitempty"`
+	HistoryIdx   int64                             `json:"history_idx"`
+	History      []FaultRecordStateSnapshot        `json:"history,omitempty"`
+	SyslogIdx    int64                             `json:"syslog_idx"`
 }
 
 // Persist serialize the snapshot to the given output sink
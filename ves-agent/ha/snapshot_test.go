@@ -21,8 +21,11 @@ import (
 	"os"
 	"testing"
 	"time"
+	"github.com/nokia/onap-vespa/govel"
 	"github.com/nokia/onap-vespa/ves-agent/config"
+	"github.com/nokia/onap-vespa/ves-agent/convert"
 
+	"github.com/prometheus/alertmanager/template"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -37,22 +40,55 @@ func fillState(state AgentState, now time.Time) error {
 			return err
 		}
 	}
+	if _, err := state.NextSyslogIndexes(7); err != nil {
+		return err
+	}
 	if err := state.UpdateScheduler("foobar", 170*time.Minute, now); err != nil {
 		return err
 	}
-	faultIdx, err := state.NextFaultIndex()
+	if err := state.Acknowledge("foobar", now.Add(-340*time.Minute), now.Add(-170*time.Minute), now); err != nil {
+		return err
+	}
+	faultIdx, err := state.NextFaultIndex(0)
 	if err != nil {
 		return err
 	}
 	if err = state.StoreFaultInStorage("MyFault", faultIdx); err != nil {
 		return err
 	}
-	if err = state.InitAlertInfos(faultIdx); err != nil {
+	if err = state.InitAlertInfos(faultIdx, "fault0000000001"); err != nil {
 		return err
 	}
 	if err = state.IncrementFaultSn(faultIdx); err != nil {
 		return err
 	}
+	if err = state.SetFaultAlert(faultIdx, map[string]string{"alertname": "MyAlert"}, map[string]string{"description": "My alert"}, convert.OriginSNMP); err != nil {
+		return err
+	}
+	if err = state.SetFaultLastSeen(faultIdx, 654321); err != nil {
+		return err
+	}
+	if err = state.SetFaultSent(faultIdx, "0123456789abcdef", 654320); err != nil {
+		return err
+	}
+	if err = state.SetFaultEvent(faultIdx, []byte(`{"commonEventHeader":{"eventId":"fault0000000001"}}`)); err != nil {
+		return err
+	}
+	alerts := []template.Alert{
+		{Status: "firing", Labels: map[string]string{"alertname": "MyAlert"}},
+		{Status: "resolved", Labels: map[string]string{"alertname": "MyAlert"}},
+	}
+	if _, err = state.EnqueueAlerts(alerts, convert.OriginSNMP, 654321, 0); err != nil {
+		return err
+	}
+	if err = state.SetQueuedAlertResult(1, convert.AlertFailed, "Cannot convert Fault to VES event", 654322, 10); err != nil {
+		return err
+	}
+	record := convert.FaultRecord{Time: 654323, Action: convert.FaultRaise, EventID: "fault0000000001", AlertName: "MyAlert",
+		Severity: govel.SeverityMajor, Sequence: 1, Outcome: convert.FaultFailed, Error: "Unavailable"}
+	if err = state.AppendFaultRecord(record, 10); err != nil {
+		return err
+	}
 	return state.SetFaultStartEpoch(faultIdx, 123456)
 }
 
@@ -72,15 +108,18 @@ func (s *SnapshotTestSuite) TestSnapshotAndRestore() {
 	snap := state.Snapshot()
 	s.EqualValues(10, snap.HbIdx)
 	s.EqualValues(15, snap.MeasIdx)
+	s.EqualValues(7, snap.SyslogIdx)
 	s.Len(snap.Schedulers, 1)
 	sched, ok := snap.Schedulers["foobar"]
 	s.True(ok)
 	s.Equal(now.UTC(), sched.Next)
 	s.Equal(170*time.Minute, sched.Interval)
+	s.Equal(now.Add(-170*time.Minute).UTC(), sched.AckTo)
 
 	newState := NewInMemState()
 	newState.Restore(snap)
 	s.EqualValues(state, newState)
+	s.Equal(int32(1), newState.GetFaultIDByEventID("fault0000000001"))
 }
 
 func (s *SnapshotTestSuite) TestFsmSnapshotAndRetsore() {
@@ -18,12 +18,16 @@ package ha
 
 import (
 	"errors"
+	"sort"
+	"sync"
 	"time"
 	"github.com/nokia/onap-vespa/ves-agent/convert"
 	"github.com/nokia/onap-vespa/ves-agent/heartbeat"
 	"github.com/nokia/onap-vespa/ves-agent/metrics"
 	"github.com/nokia/onap-vespa/ves-agent/scheduler"
+	"github.com/nokia/onap-vespa/ves-agent/syslog"
 
+	"github.com/prometheus/alertmanager/template"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -34,11 +38,16 @@ type AgentState interface {
 	heartbeat.MonitorState
 	metrics.CollectorState
 	convert.FaultManagerState
+	convert.AlertQueueState
+	convert.FaultHistoryState
+	syslog.ForwarderState
 }
 
 type schedulerState struct {
 	interval time.Duration
 	next     time.Time
+	ackFrom  time.Time
+	ackTo    time.Time
 }
 
 type inMemState struct {
@@ -48,6 +57,14 @@ type inMemState struct {
 	faultIdx   int32
 	alertInfos map[int32]*convert.AlertInfos
 	storage    map[string]int32
+	eventIDs   map[string]int32 // Index of faults by event ID, derived from alertInfos
+	storedIDs  map[int32]bool   // Indexes of the faults in storage, derived from storage
+	queueMutex sync.Mutex // Protects queueIdx and queue, which are read outside of the FSM goroutine
+	queueIdx   int64
+	queue      []*convert.QueuedAlert // Ordered by ID
+	historyIdx int64
+	history    []convert.FaultRecord // Ordered by ID
+	syslogIdx  int64
 }
 
 // NewInMemState creates a new snapshotable state stored in memory
@@ -56,9 +73,19 @@ func NewInMemState() SnapshotableAgentState {
 		schedulers: make(map[string]*schedulerState),
 		alertInfos: make(map[int32]*convert.AlertInfos),
 		storage:    make(map[string]int32),
+		eventIDs:   make(map[string]int32),
+		storedIDs:  make(map[int32]bool),
 	}
 }
 
+// localTime converts t to local time, keeping zero values untouched
+func localTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+	return t.Local()
+}
+
 func (state *inMemState) getOrCreateScheduler(name string) *schedulerState {
 	scheduler, ok := state.schedulers[name]
 	if !ok {
@@ -80,6 +107,15 @@ func (state *inMemState) NextHeartbeatIndex() (int64, error) {
 	return idx, nil
 }
 
+func (state *inMemState) NextSyslogIndexes(count int) (int64, error) {
+	if count < 1 {
+		return 0, errors.New("Invalid number of syslog indexes")
+	}
+	idx := state.syslogIdx
+	state.syslogIdx += int64(count)
+	return idx, nil
+}
+
 func (state *inMemState) NextRun(sched string) time.Time {
 	sch, ok := state.schedulers[sched]
 	if !ok {
@@ -113,20 +149,109 @@ func (state *inMemState) UpdateScheduler(sched string, interval time.Duration, n
 	return nil
 }
 
-// IncrementFaultIdx return the new FaultId to use (FaultManagerState implementation)
-func (state *inMemState) NextFaultIndex() (int32, error) {
-	state.faultIdx++
-	idx := state.faultIdx
+func (state *inMemState) LastAck(sched string) (time.Time, time.Time) {
+	sch, ok := state.schedulers[sched]
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+	return sch.ackFrom, sch.ackTo
+}
+
+func (state *inMemState) Acknowledge(sched string, from, to, next time.Time) error {
+	schd := state.getOrCreateScheduler(sched)
+	schd.ackFrom = from
+	schd.ackTo = to
+	schd.next = next
+	return nil
+}
+
+// NextFaultIndex return the next free FaultId to use, wrapping around past `max` (FaultManagerState implementation)
+func (state *inMemState) NextFaultIndex(max int32) (int32, error) {
+	idx, err := convert.NextFreeIndex(state.faultIdx, max, state.faultInUse)
+	if err != nil {
+		return 0, err
+	}
+	state.faultIdx = idx
 	log.Debugf("NextFaultIndex for fault: %d\n", idx)
 	return idx, nil
 }
 
-func (state *inMemState) InitAlertInfos(faultID int32) error {
+// faultInUse returns true if index `faultID` is used by a fault
+func (state *inMemState) faultInUse(faultID int32) bool {
+	_, ok := state.alertInfos[faultID]
+	return ok || state.storedIDs[faultID]
+}
+
+// deleteAlertInfos removes the state of faultID, and its event ID from the index
+func (state *inMemState) deleteAlertInfos(faultID int32) {
+	if fault, ok := state.alertInfos[faultID]; ok && state.eventIDs[fault.EventID] == faultID {
+		delete(state.eventIDs, fault.EventID)
+	}
+	delete(state.alertInfos, faultID)
+}
+
+// indexFaults rebuilds the indexes of faults by event ID, and of stored faults
+func (state *inMemState) indexFaults() {
+	state.eventIDs = make(map[string]int32, len(state.alertInfos))
+	for id, fault := range state.alertInfos {
+		if fault.EventID != "" {
+			state.eventIDs[fault.EventID] = id
+		}
+	}
+	state.storedIDs = make(map[int32]bool, len(state.storage))
+	for _, id := range state.storage {
+		state.storedIDs[id] = true
+	}
+}
+
+// InitAlertInfos initializes the state of faultID, with its event ID (FaultManagerState implementation)
+func (state *inMemState) InitAlertInfos(faultID int32, eventID string) error {
 	log.Debugf("InitAlertInfos for fault: %010d\n", faultID)
-	state.alertInfos[faultID] = &convert.AlertInfos{Sequence: 1, StartEpoch: 0}
+	state.deleteAlertInfos(faultID)
+	state.alertInfos[faultID] = &convert.AlertInfos{EventID: eventID, Sequence: 1, StartEpoch: 0}
+	if eventID != "" {
+		state.eventIDs[eventID] = faultID
+	}
 	return nil
 }
 
+// GetFaultEventID returns the event ID of faultID (FaultManagerState implementation)
+func (state *inMemState) GetFaultEventID(faultID int32) string {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		return fault.EventID
+	}
+	return ""
+}
+
+// GetFaultIDByEventID returns the index of the fault with event ID `eventID`, or 0 (FaultManagerState implementation)
+func (state *inMemState) GetFaultIDByEventID(eventID string) int32 {
+	return state.eventIDs[eventID]
+}
+
+// PruneFaults removes the partially stored faults (FaultManagerState implementation)
+func (state *inMemState) PruneFaults() (int, error) {
+	n := 0
+	stored := make(map[int32]bool, len(state.storage))
+	for name, id := range state.storage {
+		if _, ok := state.alertInfos[id]; !ok {
+			log.Warnf("Pruning fault %s with index %010d, without state", name, id)
+			delete(state.storage, name)
+			delete(state.storedIDs, id)
+			n++
+			continue
+		}
+		stored[id] = true
+	}
+	for id := range state.alertInfos {
+		if !stored[id] {
+			log.Warnf("Pruning orphaned state of fault index %010d", id)
+			state.deleteAlertInfos(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
 // GetFaultInStorage checks if faultName already associated to an index
 func (state *inMemState) GetFaultInStorage(faultName string) int32 {
 	if val, ok := state.storage[faultName]; ok {
@@ -138,7 +263,11 @@ func (state *inMemState) GetFaultInStorage(faultName string) int32 {
 // StoreFaultInStorage stores the index associated to the faultName
 func (state *inMemState) StoreFaultInStorage(faultName string, faultID int32) error {
 	log.Debugf("state StoreFaultInStorage for fault %s with index %010d", faultName, faultID)
+	if id, ok := state.storage[faultName]; ok {
+		delete(state.storedIDs, id)
+	}
 	state.storage[faultName] = faultID
+	state.storedIDs[faultID] = true
 	return nil
 }
 
@@ -146,12 +275,117 @@ func (state *inMemState) StoreFaultInStorage(faultName string, faultID int32) er
 func (state *inMemState) DeleteFaultInStorage(faultName string) error {
 	log.Debugf("state DeleteFaultInStorage for fault %s", faultName)
 	if id, ok := state.storage[faultName]; ok {
-		delete(state.alertInfos, id)
+		state.deleteAlertInfos(id)
+		delete(state.storedIDs, id)
 	}
 	delete(state.storage, faultName)
 	return nil
 }
 
+// GetFaultsInStorage returns a copy of the stored faultNames, with their index (FaultManagerState implementation)
+func (state *inMemState) GetFaultsInStorage() map[string]int32 {
+	faults := make(map[string]int32, len(state.storage))
+	for k, v := range state.storage {
+		faults[k] = v
+	}
+	return faults
+}
+
+// GetFaultAlert returns the labels and annotations of the alert which raised the faultID (FaultManagerState implementation)
+func (state *inMemState) GetFaultAlert(faultID int32) (map[string]string, map[string]string) {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		return fault.Labels, fault.Annotations
+	}
+	return nil, nil
+}
+
+// SetFaultAlert stores the labels, annotations and origin of the alert which raised the faultID (FaultManagerState implementation)
+func (state *inMemState) SetFaultAlert(faultID int32, labels, annotations map[string]string, origin convert.AlertOrigin) error {
+	log.Debugf("state SetFaultAlert for fault: %010d", faultID)
+	if fault, ok := state.alertInfos[faultID]; ok {
+		fault.Labels = labels
+		fault.Annotations = annotations
+		fault.Origin = origin
+		return nil
+	}
+	return errors.New("Fault does not exist")
+}
+
+// GetFaultOrigin returns the origin of the alert which raised the faultID (FaultManagerState implementation)
+func (state *inMemState) GetFaultOrigin(faultID int32) convert.AlertOrigin {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		return fault.Origin
+	}
+	return convert.OriginAlertmanager
+}
+
+// GetFaultLastSeen returns the last time the alert of faultID was received (FaultManagerState implementation)
+func (state *inMemState) GetFaultLastSeen(faultID int32) int64 {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		return fault.LastSeen
+	}
+	return 0
+}
+
+// SetFaultLastSeen sets the last time the alert of faultID was received (FaultManagerState implementation)
+func (state *inMemState) SetFaultLastSeen(faultID int32, epoch int64) error {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		fault.LastSeen = epoch
+		return nil
+	}
+	return errors.New("Fault does not exist")
+}
+
+// GetFaultSent returns the content hash and time of the last event sent for faultID (FaultManagerState implementation)
+func (state *inMemState) GetFaultSent(faultID int32) (string, int64) {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		return fault.Hash, fault.LastSent
+	}
+	return "", 0
+}
+
+// SetFaultSent sets the content hash and time of the last event sent for faultID (FaultManagerState implementation)
+func (state *inMemState) SetFaultSent(faultID int32, hash string, epoch int64) error {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		fault.Hash = hash
+		fault.LastSent = epoch
+		return nil
+	}
+	return errors.New("Fault does not exist")
+}
+
+// GetFaultEvent returns the payload of the last event sent for faultID (FaultManagerState implementation)
+func (state *inMemState) GetFaultEvent(faultID int32) []byte {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		return fault.Event
+	}
+	return nil
+}
+
+// SetFaultEvent sets the payload of the last event sent for faultID (FaultManagerState implementation)
+func (state *inMemState) SetFaultEvent(faultID int32, event []byte) error {
+	if fault, ok := state.alertInfos[faultID]; ok {
+		fault.Event = event
+		return nil
+	}
+	return errors.New("Fault does not exist")
+}
+
+// CommitFaultSent increments the sequence Number of the faultID index, and sets the content hash, time and payload
+// of the last event sent for it (FaultManagerState implementation)
+func (state *inMemState) CommitFaultSent(faultID int32, hash string, epoch int64, event []byte) error {
+	log.Debugf("state CommitFaultSent for fault index %010d", faultID)
+	fault, ok := state.alertInfos[faultID]
+	if !ok {
+		return errors.New("Fault does not exist")
+	}
+	fault.Sequence++
+	fault.Hash = hash
+	fault.LastSent = epoch
+	fault.Event = event
+	return nil
+}
+
 // GetFaultSequence return the sequence Number of the faultID index (FaultManagerState implementation)
 func (state *inMemState) GetFaultSn(faultID int32) int64 {
 	if fault, ok := state.alertInfos[faultID]; ok {
@@ -184,7 +418,7 @@ func (state *inMemState) SetFaultStartEpoch(faultID int32, epoch int64) error {
 	_, ok := state.alertInfos[faultID]
 	if !ok {
 		log.Errorf("state SetFaultStartEpoch create alertInfos for fault index %010d", faultID)
-		if err := state.InitAlertInfos(faultID); err != nil {
+		if err := state.InitAlertInfos(faultID, ""); err != nil {
 			return err
 		}
 	}
@@ -192,9 +426,144 @@ func (state *inMemState) SetFaultStartEpoch(faultID int32, epoch int64) error {
 	return nil
 }
 
+// EnqueueAlerts appends the alerts received from `origin` to the queue, and returns their IDs.
+// Alerts are rejected if the queue would hold more than `maxPending` pending alerts (AlertQueueState implementation)
+func (state *inMemState) EnqueueAlerts(alerts []template.Alert, origin convert.AlertOrigin, received int64, maxPending int) ([]int64, error) {
+	state.queueMutex.Lock()
+	defer state.queueMutex.Unlock()
+	if maxPending > 0 && state.pendingAlerts()+len(alerts) > maxPending {
+		return nil, convert.ErrQueueFull
+	}
+	ids := make([]int64, 0, len(alerts))
+	for _, alert := range alerts {
+		state.queueIdx++
+		state.queue = append(state.queue, &convert.QueuedAlert{ID: state.queueIdx, Alert: alert, Origin: origin, Received: received, Status: convert.AlertPending})
+		ids = append(ids, state.queueIdx)
+	}
+	return ids, nil
+}
+
+// NextQueuedAlert returns the oldest pending alert, if any (AlertQueueState implementation)
+func (state *inMemState) NextQueuedAlert() (convert.QueuedAlert, bool) {
+	state.queueMutex.Lock()
+	defer state.queueMutex.Unlock()
+	for _, alert := range state.queue {
+		if alert.Status == convert.AlertPending {
+			return *alert, true
+		}
+	}
+	return convert.QueuedAlert{}, false
+}
+
+// findQueuedAlert returns the position of alert `id` in the queue, or -1
+func (state *inMemState) findQueuedAlert(id int64) int {
+	i := sort.Search(len(state.queue), func(i int) bool { return state.queue[i].ID >= id })
+	if i < len(state.queue) && state.queue[i].ID == id {
+		return i
+	}
+	return -1
+}
+
+// GetQueuedAlert returns the alert with ID `id`, if still known (AlertQueueState implementation)
+func (state *inMemState) GetQueuedAlert(id int64) (convert.QueuedAlert, bool) {
+	state.queueMutex.Lock()
+	defer state.queueMutex.Unlock()
+	if i := state.findQueuedAlert(id); i >= 0 {
+		return *state.queue[i], true
+	}
+	return convert.QueuedAlert{}, false
+}
+
+// GetQueuedAlerts returns a copy of all the known alerts (AlertQueueState implementation)
+func (state *inMemState) GetQueuedAlerts() []convert.QueuedAlert {
+	state.queueMutex.Lock()
+	defer state.queueMutex.Unlock()
+	alerts := make([]convert.QueuedAlert, 0, len(state.queue))
+	for _, alert := range state.queue {
+		alerts = append(alerts, *alert)
+	}
+	return alerts
+}
+
+// PendingAlerts returns the number of pending alerts (AlertQueueState implementation)
+func (state *inMemState) PendingAlerts() int {
+	state.queueMutex.Lock()
+	defer state.queueMutex.Unlock()
+	return state.pendingAlerts()
+}
+
+// pendingAlerts returns the number of pending alerts. The caller must hold queueMutex
+func (state *inMemState) pendingAlerts() int {
+	pending := 0
+	for _, alert := range state.queue {
+		if alert.Status == convert.AlertPending {
+			pending++
+		}
+	}
+	return pending
+}
+
+// SetQueuedAlertResult records the result of a processing attempt of alert `id` (AlertQueueState implementation)
+func (state *inMemState) SetQueuedAlertResult(id int64, status convert.AlertStatus, errMsg string, at int64, retain int) error {
+	state.queueMutex.Lock()
+	defer state.queueMutex.Unlock()
+	i := state.findQueuedAlert(id)
+	if i < 0 {
+		return errors.New("Queued alert does not exist")
+	}
+	alert := state.queue[i]
+	alert.Status = status
+	alert.Error = errMsg
+	alert.LastAttempt = at
+	alert.Attempts++
+	if status != convert.AlertPending {
+		state.pruneQueue(retain)
+	}
+	return nil
+}
+
+// pruneQueue forgets the oldest alerts which are no longer pending, keeping only `retain` of them.
+// The caller must hold queueMutex
+func (state *inMemState) pruneQueue(retain int) {
+	done := len(state.queue) - state.pendingAlerts()
+	if done <= retain {
+		return
+	}
+	queue := make([]*convert.QueuedAlert, 0, len(state.queue)-done+retain)
+	for _, alert := range state.queue {
+		if alert.Status != convert.AlertPending && done > retain {
+			done--
+			continue
+		}
+		queue = append(queue, alert)
+	}
+	state.queue = queue
+}
+
+// AppendFaultRecord appends `record` to the fault history, keeping only the `retain` most recent records (FaultHistoryState implementation)
+func (state *inMemState) AppendFaultRecord(record convert.FaultRecord, retain int) error {
+	state.historyIdx++
+	record.ID = state.historyIdx
+	state.history = append(state.history, record)
+	if retain > 0 && len(state.history) > retain {
+		history := make([]convert.FaultRecord, retain)
+		copy(history, state.history[len(state.history)-retain:])
+		state.history = history
+	}
+	return nil
+}
+
+// GetFaultHistory returns a copy of the fault history (FaultHistoryState implementation)
+func (state *inMemState) GetFaultHistory() []convert.FaultRecord {
+	history := make([]convert.FaultRecord, len(state.history))
+	copy(history, state.history)
+	return history
+}
+
 func (state *inMemState) Snapshot() *AgentStateSnapshot {
 	snapshot := new(AgentStateSnapshot)
 	snapshot.HbIdx = state.hbIdx
+	snapshot.SyslogIdx = state.syslogIdx
 	snapshot.MeasIdx = state.measIdx
 	snapshot.FaultIdx = state.faultIdx
 	snapshot.Schedulers = make(map[string]SchedulerStateSnapshot)
@@ -202,24 +571,66 @@ func (state *inMemState) Snapshot() *AgentStateSnapshot {
 		snapshot.Schedulers[k] = SchedulerStateSnapshot{
 			Interval: v.interval,
 			Next:     v.next.UTC(),
+			AckFrom:  v.ackFrom.UTC(),
+			AckTo:    v.ackTo.UTC(),
 		}
 	}
 	snapshot.AlertInfos = make(map[int32]AlertInfosStateSnapShot)
 	for k, v := range state.alertInfos {
 		snapshot.AlertInfos[k] = AlertInfosStateSnapShot{
-			Sn:    v.Sequence,
-			Epoch: v.StartEpoch,
+			EventID:     v.EventID,
+			Sn:          v.Sequence,
+			Epoch:       v.StartEpoch,
+			Labels:      v.Labels,
+			Annotations: v.Annotations,
+			Origin:      v.Origin,
+			LastSeen:    v.LastSeen,
+			Hash:        v.Hash,
+			LastSent:    v.LastSent,
+			Event:       v.Event,
 		}
 	}
 	snapshot.StorageFault = make(map[string]int32)
 	for k, v := range state.storage {
 		snapshot.StorageFault[k] = v
 	}
+	state.queueMutex.Lock()
+	defer state.queueMutex.Unlock()
+	snapshot.QueueIdx = state.queueIdx
+	for _, v := range state.queue {
+		snapshot.Queue = append(snapshot.Queue, QueuedAlertStateSnapshot{
+			ID:          v.ID,
+			Alert:       v.Alert,
+			Origin:      v.Origin,
+			Received:    v.Received,
+			Status:      v.Status,
+			Attempts:    v.Attempts,
+			LastAttempt: v.LastAttempt,
+			Error:       v.Error,
+		})
+	}
+	snapshot.HistoryIdx = state.historyIdx
+	for _, v := range state.history {
+		snapshot.History = append(snapshot.History, FaultRecordStateSnapshot{
+			ID:               v.ID,
+			Time:             v.Time,
+			Action:           v.Action,
+			EventID:          v.EventID,
+			AlertName:        v.AlertName,
+			SourceName:       v.SourceName,
+			Severity:         v.Severity,
+			PreviousSeverity: v.PreviousSeverity,
+			Sequence:         v.Sequence,
+			Outcome:          v.Outcome,
+			Error:            v.Error,
+		})
+	}
 	return snapshot
 }
 
 func (state *inMemState) Restore(snapshot *AgentStateSnapshot) {
 	state.hbIdx = snapshot.HbIdx
+	state.syslogIdx = snapshot.SyslogIdx
 	state.measIdx = snapshot.MeasIdx
 	state.faultIdx = snapshot.FaultIdx
 	state.schedulers = make(map[string]*schedulerState)
@@ -227,15 +638,59 @@ func (state *inMemState) Restore(snapshot *AgentStateSnapshot) {
 		state.schedulers[k] = &schedulerState{
 			interval: v.Interval,
 			next:     v.Next.Local(),
+			ackFrom:  localTime(v.AckFrom),
+			ackTo:    localTime(v.AckTo),
 		}
 	}
 	for k, v := range snapshot.AlertInfos {
 		state.alertInfos[k] = &convert.AlertInfos{
-			Sequence:   v.Sn,
-			StartEpoch: v.Epoch,
+			EventID:     v.EventID,
+			Sequence:    v.Sn,
+			StartEpoch:  v.Epoch,
+			Labels:      v.Labels,
+			Annotations: v.Annotations,
+			Origin:      v.Origin,
+			LastSeen:    v.LastSeen,
+			Hash:        v.Hash,
+			LastSent:    v.LastSent,
+			Event:       v.Event,
 		}
 	}
 	for k, v := range snapshot.StorageFault {
 		state.storage[k] = v
 	}
+	state.indexFaults()
+	state.queueMutex.Lock()
+	defer state.queueMutex.Unlock()
+	state.queueIdx = snapshot.QueueIdx
+	state.queue = nil
+	for _, v := range snapshot.Queue {
+		state.queue = append(state.queue, &convert.QueuedAlert{
+			ID:          v.ID,
+			Alert:       v.Alert,
+			Origin:      v.Origin,
+			Received:    v.Received,
+			Status:      v.Status,
+			Attempts:    v.Attempts,
+			LastAttempt: v.LastAttempt,
+			Error:       v.Error,
+		})
+	}
+	state.historyIdx = snapshot.HistoryIdx
+	state.history = nil
+	for _, v := range snapshot.History {
+		state.history = append(state.history, convert.FaultRecord{
+			ID:               v.ID,
+			Time:             v.Time,
+			Action:           v.Action,
+			EventID:          v.EventID,
+			AlertName:        v.AlertName,
+			SourceName:       v.SourceName,
+			Severity:         v.Severity,
+			PreviousSeverity: v.PreviousSeverity,
+			Sequence:         v.Sequence,
+			Outcome:          v.Outcome,
+			Error:            v.Error,
+		})
+	}
 }
@@ -17,9 +17,13 @@
 package ha
 
 import (
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/nokia/onap-vespa/ves-agent/convert"
+
+	"github.com/prometheus/alertmanager/template"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -74,6 +78,20 @@ func (s *StateTestSuite) TestHeartbeatIndex() {
 	s.EqualValues(2, meas)
 }
 
+func (s *StateTestSuite) TestSyslogIndexes() {
+	idx, err := s.state.NextSyslogIndexes(3)
+	s.NoError(err)
+	s.EqualValues(0, idx)
+	idx, err = s.state.NextSyslogIndexes(1)
+	s.NoError(err)
+	s.EqualValues(3, idx)
+	_, err = s.state.NextSyslogIndexes(0)
+	s.Error(err)
+	idx, err = s.state.NextSyslogIndexes(2)
+	s.NoError(err)
+	s.EqualValues(4, idx)
+}
+
 func (s *StateTestSuite) TestScheduler() {
 	schn := "test"
 	now := time.Now()
@@ -94,17 +112,82 @@ func (s *StateTestSuite) TestScheduler() {
 	s.Equal(now.Unix(), s.state.NextRun(schn).Unix())
 }
 
+func (s *StateTestSuite) TestSchedulerAcknowledge() {
+	schn := "test"
+	now := time.Now()
+	from, to := s.state.LastAck(schn)
+	s.True(from.IsZero())
+	s.True(to.IsZero())
+
+	s.NoError(s.state.Acknowledge(schn, now.Add(-10*time.Second), now, now.Add(10*time.Second)))
+	from, to = s.state.LastAck(schn)
+	s.Equal(now.Add(-10*time.Second).Unix(), from.Unix())
+	s.Equal(now.Unix(), to.Unix())
+	s.Equal(now.Add(10*time.Second).Unix(), s.state.NextRun(schn).Unix())
+}
+
 func (s *StateTestSuite) TestNextFaultIndex() {
-	idx, _ := s.state.NextFaultIndex()
+	idx, _ := s.state.NextFaultIndex(0)
 	s.Equal(int32(1), idx)
-	idx, _ = s.state.NextFaultIndex()
+	idx, _ = s.state.NextFaultIndex(0)
 	s.Equal(int32(2), idx)
-	idx, _ = s.state.NextFaultIndex()
+	idx, _ = s.state.NextFaultIndex(0)
 	s.Equal(int32(3), idx)
 }
 
+func (s *StateTestSuite) TestNextFaultIndexWrap() {
+	for i := int32(1); i <= 3; i++ {
+		idx, err := s.state.NextFaultIndex(3)
+		s.NoError(err)
+		s.Equal(i, idx)
+	}
+	// Indexes in use are skipped after wrapping around
+	s.NoError(s.state.StoreFaultInStorage("fault1", 1))
+	s.NoError(s.state.InitAlertInfos(1, "fault1"))
+	s.NoError(s.state.InitAlertInfos(2, "fault2"))
+	idx, err := s.state.NextFaultIndex(3)
+	s.NoError(err)
+	s.Equal(int32(3), idx)
+	s.NoError(s.state.StoreFaultInStorage("fault3", 3))
+	_, err = s.state.NextFaultIndex(3)
+	s.Equal(convert.ErrNoFreeIndex, err)
+}
+
+func (s *StateTestSuite) TestPruneFaults() {
+	s.NoError(s.state.StoreFaultInStorage("complete", 1))
+	s.NoError(s.state.InitAlertInfos(1, "fault1"))
+	s.NoError(s.state.StoreFaultInStorage("nostate", 2))
+	s.NoError(s.state.InitAlertInfos(3, "fault3"))
+	n, err := s.state.PruneFaults()
+	s.NoError(err)
+	s.Equal(2, n)
+	s.Equal(map[string]int32{"complete": 1}, s.state.GetFaultsInStorage())
+	s.Equal("fault1", s.state.GetFaultEventID(1))
+	s.Equal("", s.state.GetFaultEventID(3))
+	s.Equal(int32(1), s.state.GetFaultIDByEventID("fault1"))
+	s.Zero(s.state.GetFaultIDByEventID("fault3"))
+	// Index 2 is free again
+	idx, err := s.state.NextFaultIndex(2)
+	s.NoError(err)
+	s.Equal(int32(2), idx)
+}
+
+func (s *StateTestSuite) TestFaultIDByEventID() {
+	s.Zero(s.state.GetFaultIDByEventID("fault0000000012"))
+	s.NoError(s.state.StoreFaultInStorage("myfault", 12))
+	s.NoError(s.state.InitAlertInfos(12, "fault0000000012"))
+	s.Equal(int32(12), s.state.GetFaultIDByEventID("fault0000000012"))
+	// Faults of older agent versions have no event ID
+	s.NoError(s.state.StoreFaultInStorage("oldfault", 13))
+	s.NoError(s.state.InitAlertInfos(13, ""))
+	s.Zero(s.state.GetFaultIDByEventID(""))
+
+	s.NoError(s.state.DeleteFaultInStorage("myfault"))
+	s.Zero(s.state.GetFaultIDByEventID("fault0000000012"))
+}
+
 func (s *StateTestSuite) TestFaultSN() {
-	s.state.InitAlertInfos(12)
+	s.state.InitAlertInfos(12, "fault0000000012")
 	s.Equal(int64(1), s.state.GetFaultSn(12))
 	s.NoError(s.state.IncrementFaultSn(12))
 	s.Equal(int64(2), s.state.GetFaultSn(12))
@@ -123,7 +206,7 @@ func (s *StateTestSuite) TestFaultStore() {
 }
 
 func (s *StateTestSuite) TestFaultEpoch() {
-	s.state.InitAlertInfos(12)
+	s.state.InitAlertInfos(12, "fault0000000012")
 	s.Equal(int64(0), s.state.GetFaultStartEpoch(12))
 	s.state.SetFaultStartEpoch(12, 12345)
 	s.Equal(int64(12345), s.state.GetFaultStartEpoch(12))
@@ -132,3 +215,184 @@ func (s *StateTestSuite) TestFaultEpoch() {
 	s.state.SetFaultStartEpoch(42, 54321)
 	s.Equal(int64(54321), s.state.GetFaultStartEpoch(42))
 }
+
+func (s *StateTestSuite) TestFaultAlert() {
+	labels := map[string]string{"alertname": "NodeFailure"}
+	annotations := map[string]string{"description": "Node is down"}
+	s.Error(s.state.SetFaultAlert(12, labels, annotations, convert.OriginAlertmanager))
+	s.state.StoreFaultInStorage("myfault", 12)
+	s.state.InitAlertInfos(12, "fault0000000012")
+	l, a := s.state.GetFaultAlert(12)
+	s.Nil(l)
+	s.Nil(a)
+	s.Equal(convert.OriginAlertmanager, s.state.GetFaultOrigin(12))
+	s.NoError(s.state.SetFaultAlert(12, labels, annotations, convert.OriginSNMP))
+	l, a = s.state.GetFaultAlert(12)
+	s.Equal(labels, l)
+	s.Equal(annotations, a)
+	s.Equal(convert.OriginSNMP, s.state.GetFaultOrigin(12))
+	s.Equal(map[string]int32{"myfault": 12}, s.state.GetFaultsInStorage())
+
+	s.state.DeleteFaultInStorage("myfault")
+	l, _ = s.state.GetFaultAlert(12)
+	s.Nil(l)
+	s.Empty(s.state.GetFaultsInStorage())
+}
+
+func (s *StateTestSuite) TestFaultLastSeen() {
+	s.Error(s.state.SetFaultLastSeen(12, 54321))
+	s.state.StoreFaultInStorage("myfault", 12)
+	s.state.InitAlertInfos(12, "fault0000000012")
+	s.Equal(int64(0), s.state.GetFaultLastSeen(12))
+	s.NoError(s.state.SetFaultLastSeen(12, 54321))
+	s.Equal(int64(54321), s.state.GetFaultLastSeen(12))
+
+	s.state.DeleteFaultInStorage("myfault")
+	s.Equal(int64(0), s.state.GetFaultLastSeen(12))
+}
+
+func (s *StateTestSuite) TestFaultSent() {
+	s.Error(s.state.SetFaultSent(12, "0123456789abcdef", 54321))
+	s.state.StoreFaultInStorage("myfault", 12)
+	s.state.InitAlertInfos(12, "fault0000000012")
+	hash, sent := s.state.GetFaultSent(12)
+	s.Equal("", hash)
+	s.Equal(int64(0), sent)
+	s.NoError(s.state.SetFaultSent(12, "0123456789abcdef", 54321))
+	hash, sent = s.state.GetFaultSent(12)
+	s.Equal("0123456789abcdef", hash)
+	s.Equal(int64(54321), sent)
+
+	s.state.DeleteFaultInStorage("myfault")
+	hash, _ = s.state.GetFaultSent(12)
+	s.Equal("", hash)
+}
+
+func (s *StateTestSuite) TestCommitFaultSent() {
+	s.Error(s.state.CommitFaultSent(12, "0123456789abcdef", 54321, []byte(`{"faultFields":{}}`)))
+	s.state.StoreFaultInStorage("myfault", 12)
+	s.state.InitAlertInfos(12, "fault0000000012")
+	s.NoError(s.state.CommitFaultSent(12, "0123456789abcdef", 54321, []byte(`{"faultFields":{}}`)))
+	s.Equal(int64(2), s.state.GetFaultSn(12))
+	hash, sent := s.state.GetFaultSent(12)
+	s.Equal("0123456789abcdef", hash)
+	s.Equal(int64(54321), sent)
+	s.Equal(`{"faultFields":{}}`, string(s.state.GetFaultEvent(12)))
+}
+
+func (s *StateTestSuite) TestFaultEvent() {
+	s.Error(s.state.SetFaultEvent(12, []byte(`{"faultFields":{}}`)))
+	s.state.StoreFaultInStorage("myfault", 12)
+	s.state.InitAlertInfos(12, "fault0000000012")
+	s.Nil(s.state.GetFaultEvent(12))
+	s.NoError(s.state.SetFaultEvent(12, []byte(`{"faultFields":{}}`)))
+	s.Equal(`{"faultFields":{}}`, string(s.state.GetFaultEvent(12)))
+
+	s.state.DeleteFaultInStorage("myfault")
+	s.Nil(s.state.GetFaultEvent(12))
+}
+
+func (s *StateTestSuite) TestAlertQueue() {
+	_, ok := s.state.NextQueuedAlert()
+	s.False(ok)
+	alerts := []template.Alert{
+		{Status: "firing", Labels: map[string]string{"alertname": "Alert1"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "Alert2"}},
+		{Status: "resolved", Labels: map[string]string{"alertname": "Alert1"}},
+	}
+	ids, err := s.state.EnqueueAlerts(alerts, convert.OriginAlertmanager, 12345, 0)
+	s.NoError(err)
+	s.Equal([]int64{1, 2, 3}, ids)
+	s.Equal(3, s.state.PendingAlerts())
+	queued, ok := s.state.NextQueuedAlert()
+	s.True(ok)
+	s.Equal(convert.QueuedAlert{ID: 1, Alert: alerts[0], Received: 12345, Status: convert.AlertPending}, queued)
+
+	// Retried alert stays at the head of the queue
+	s.NoError(s.state.SetQueuedAlertResult(1, convert.AlertPending, "Unavailable", 12346, 1))
+	queued, _ = s.state.NextQueuedAlert()
+	s.Equal(int64(1), queued.ID)
+	s.Equal(1, queued.Attempts)
+	s.Equal("Unavailable", queued.Error)
+	s.Equal(int64(12346), queued.LastAttempt)
+
+	s.NoError(s.state.SetQueuedAlertResult(1, convert.AlertSent, "", 12347, 1))
+	s.NoError(s.state.SetQueuedAlertResult(2, convert.AlertFailed, "Bad alert", 12348, 1))
+	queued, _ = s.state.NextQueuedAlert()
+	s.Equal(int64(3), queued.ID)
+	s.Equal(1, s.state.PendingAlerts())
+
+	// Only the most recent processed alert is retained
+	_, ok = s.state.GetQueuedAlert(1)
+	s.False(ok)
+	queued, ok = s.state.GetQueuedAlert(2)
+	s.True(ok)
+	s.Equal(convert.AlertFailed, queued.Status)
+	s.Equal("Bad alert", queued.Error)
+	all := s.state.GetQueuedAlerts()
+	s.Len(all, 2)
+	s.Equal(int64(2), all[0].ID)
+	s.Equal(int64(3), all[1].ID)
+
+	s.Error(s.state.SetQueuedAlertResult(1, convert.AlertSent, "", 12349, 1))
+	ids, err = s.state.EnqueueAlerts(alerts[:1], convert.OriginAlertmanager, 12350, 2)
+	s.NoError(err)
+	s.Equal([]int64{4}, ids)
+
+	// Alerts beyond the maximum number of pending alerts are rejected
+	_, err = s.state.EnqueueAlerts(alerts[:1], convert.OriginAlertmanager, 12351, 2)
+	s.Equal(convert.ErrQueueFull, err)
+	s.Equal(2, s.state.PendingAlerts())
+	ids, err = s.state.EnqueueAlerts(alerts[:1], convert.OriginAlertmanager, 12351, 3)
+	s.NoError(err)
+	s.Equal([]int64{5}, ids)
+}
+
+func (s *StateTestSuite) TestAlertQueueConcurrency() {
+	alert := template.Alert{Status: "firing", Labels: map[string]string{"alertname": "Alert1"}}
+	var accepted, rejected int
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := s.state.EnqueueAlerts([]template.Alert{alert}, convert.OriginAlertmanager, 12345, 5)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err == convert.ErrQueueFull {
+				rejected++
+			} else if s.NoError(err) {
+				accepted++
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			s.state.GetQueuedAlerts()
+			s.state.PendingAlerts()
+		}()
+	}
+	wg.Wait()
+	s.Equal(5, accepted)
+	s.Equal(5, rejected)
+	s.Equal(5, s.state.PendingAlerts())
+}
+
+func (s *StateTestSuite) TestFaultHistory() {
+	s.Empty(s.state.GetFaultHistory())
+	for sn := int64(1); sn <= 3; sn++ {
+		s.NoError(s.state.AppendFaultRecord(convert.FaultRecord{Time: 12345 + sn, Action: convert.FaultRepeat, EventID: "fault0000000012", Sequence: sn, Outcome: convert.FaultSent}, 2))
+	}
+
+	// Only the 2 most recent records are retained
+	history := s.state.GetFaultHistory()
+	s.Require().Len(history, 2)
+	s.Equal(int64(2), history[0].ID)
+	s.Equal(int64(2), history[0].Sequence)
+	s.Equal(int64(3), history[1].ID)
+	s.Equal(int64(12348), history[1].Time)
+
+	// Returned history is a copy
+	history[0].Sequence = 42
+	s.Equal(int64(2), s.state.GetFaultHistory()[0].Sequence)
+}
@@ -21,8 +21,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
-	"net/http"
+	"strconv"
+	"sync"
 	"text/template"
 	"time"
 	"github.com/nokia/onap-vespa/ves-agent/config"
@@ -34,7 +34,6 @@ import (
 
 	"github.com/prometheus/common/model"
 
-	"github.com/prometheus/client_golang/api"
 	"github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
@@ -59,43 +58,87 @@ func (mem *inMemState) NextMeasurementIndex() (int64, error) {
 type Collector struct {
 	state       CollectorState                // Measurement state
 	rules       config.MetricRules            // Rules for querying data and building the VES events
-	api         v1.API                        // Prometheus API
+	api         v1.API                        // Prometheus API of the first source
+	sources     []*source                     // Prometheus sources, in failover order
+	sourcesInit sync.Once                     // Defaults the sources of a collector created without them
 	max         time.Duration                 // Max collection timeframe duration
 	domainAbr   string                        // Domain abbreviation for measurements
 	evtCfg      *govel.EventConfiguration    // Generals event configuration
 	templates   map[string]*template.Template // Cache for templates from rules (to avoid parsing them each time)
+	setters     map[string]*FieldSetter       // Cache for compiled target fields from rules
 	namingCodes map[string]string             // Cache for VnfcNamingCode from VnfcName
+	host        *HostSource                   // Host metrics source. Nil if disabled
+	workers     int                           // Number of queries run in parallel
+	timeout     time.Duration                 // Timeout of each query. 0 for none
+	statuses    []RuleStatus                  // Collection status of rules
+	quarantine  int                           // Consecutive failed collections before quarantining a rule. 0 for never
+	quarDur     time.Duration                 // Duration quarantined rules are not evaluated for
+	failedField string                        // Name of the additional field listing failed rules. Empty for none
+	now         func() time.Time              // Current time, for quarantines. time.Now if nil
+	report      *RuleReport                   // Report of the rule evaluated by a dry run. Nil otherwise
 }
 
 // NewCollectorWithState creates a new Prometheus Metrics collector from provided configuration
 func NewCollectorWithState(cfg *config.MeasurementConfiguration, evtCfg *govel.EventConfiguration, namingCodes map[string]string, state CollectorState) (*Collector, error) {
-	log.Info("Initializing Prometheus Measurement Collector to ", cfg.Prometheus.Address)
-	clientCfg := api.Config{
-		Address: cfg.Prometheus.Address,
-		RoundTripper: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   cfg.Prometheus.Timeout,
-				KeepAlive: cfg.Prometheus.KeepAlive,
-			}).DialContext,
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
-	}
-	client, err := api.NewClient(clientCfg)
+	log.Info("Initializing Prometheus Measurement Collector")
+	for _, rule := range cfg.Prometheus.Rules.Metrics {
+		if err := ValidateRule(rule.WithDefaults(cfg.Prometheus.Rules.DefaultValues)); err != nil {
+			return nil, err
+		}
+	}
+	col, err := newCollector(cfg, evtCfg, namingCodes, state)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range cfg.Prometheus.Rules.Metrics {
+		if err := col.checkSources(rule.WithDefaults(cfg.Prometheus.Rules.DefaultValues)); err != nil {
+			return nil, err
+		}
+	}
+	return col, nil
+}
+
+// newCollector creates a new Prometheus Metrics collector from provided configuration, without validating its rules
+func newCollector(cfg *config.MeasurementConfiguration, evtCfg *govel.EventConfiguration, namingCodes map[string]string, state CollectorState) (*Collector, error) {
+	sources, err := newSources(&cfg.Prometheus)
 	if err != nil {
 		return nil, err
 	}
+	var host *HostSource
+	if cfg.Host.Enabled {
+		log.Info("Initializing host metrics source from ", cfg.Host.ProcRoot)
+		if host, err = NewHostSource(&cfg.Host); err != nil {
+			return nil, err
+		}
+	}
 	return &Collector{
-		api:         v1.NewAPI(client),
+		api:         sources[0].api,
+		sources:     sources,
 		rules:       cfg.Prometheus.Rules,
 		max:         cfg.MaxBufferingDuration,
 		domainAbr:   cfg.DomainAbbreviation,
 		evtCfg:      evtCfg,
 		templates:   make(map[string]*template.Template),
+		setters:     make(map[string]*FieldSetter),
 		state:       state,
 		namingCodes: namingCodes,
+		host:        host,
+		workers:     cfg.Prometheus.Workers,
+		timeout:     cfg.Prometheus.QueryTimeout,
+		quarantine:  cfg.Prometheus.QuarantineAfter,
+		quarDur:     cfg.Prometheus.QuarantineDuration,
+		failedField: cfg.Prometheus.FailedRulesField,
 	}, nil
 }
 
+// timeNow returns the current time, from `col.now` if set
+func (col *Collector) timeNow() time.Time {
+	if col.now != nil {
+		return col.now()
+	}
+	return time.Now()
+}
+
 // NewCollector creates a new Prometheus Metrics collector from provided configuration
 func NewCollector(cfg *config.MeasurementConfiguration, evtCfg *govel.EventConfiguration, namingCodes map[string]string) (*Collector, error) {
 	return NewCollectorWithState(cfg, evtCfg, namingCodes, &inMemState{index: 0})
@@ -132,6 +175,23 @@ func (col *Collector) parseTemplate(s string, strict bool) (*template.Template,
 	return tmpl, nil
 }
 
+// fieldSetter returns the compiled field path of the rule target `target`. Target fields are
+// compiled once, and not for each sample
+func (col *Collector) fieldSetter(target string) (*FieldSetter, error) {
+	if col.setters == nil {
+		col.setters = make(map[string]*FieldSetter)
+	}
+	if setter, ok := col.setters[target]; ok {
+		return setter, nil
+	}
+	setter, err := CompileField(target)
+	if err != nil {
+		return nil, err
+	}
+	col.setters[target] = setter
+	return setter, nil
+}
+
 func (col *Collector) execTemplate(s string, data interface{}, strict bool) (string, error) {
 	tmpl, err := col.parseTemplate(s, strict)
 	if err != nil {
@@ -155,15 +215,89 @@ func (col *Collector) Run(from, to time.Time, interval time.Duration) (interface
 func (col *Collector) CollectMetrics(from, to time.Time, interval time.Duration) (EventMeasurementSet, error) {
 	from = col.adjustCollectionStartTime(from, to, interval)
 	// Create new measurement set builder
+	var stateErr error // Error of the collector state, failing the whole collection
+	metrics := col.newMeasurementSetBuilder(interval, &stateErr)
+
+	// Initialize a range with interval (prometheus API)
+	rng := v1.Range{
+		Start: from,
+		End:   to,
+		Step:  interval,
+	}
+
+	log.Info("Starting metrics collection")
+	start := time.Now()
+	now := col.timeNow()
+	// Quarantined rules are skipped
+	statuses := col.ruleStatuses()
+	rules := []config.MetricRule{}
+	ruleStatuses := []*RuleStatus{}
+	failed := []config.MetricRule{}
+	for i, rule := range col.rules.Metrics {
+		rule = rule.WithDefaults(col.rules.DefaultValues)
+		if statuses[i].quarantined(now) {
+			failed = append(failed, rule)
+			continue
+		}
+		rules = append(rules, rule)
+		ruleStatuses = append(ruleStatuses, &statuses[i])
+	}
+	// Query prometheus in parallel, once per distinct expression
+	results := col.runQueries(col.ruleQueries(rules, rng), rng)
+	errs := make([]error, len(rules))
+	for i, rule := range rules {
+		// Iterate over rules in order, convert and collect results
+		// into the measurement set builder. Failing rules are skipped
+		errs[i] = col.collectRule(&metrics, rule, rng, results)
+		if errs[i] != nil && stateErr != nil {
+			return nil, stateErr
+		}
+	}
+	var lastErr error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, rules[i])
+			lastErr = err
+		}
+	}
+	// Failures of all rules are likely caused by Prometheus. They don't count for quarantine,
+	// and the collection fails to be retried later
+	partial := len(failed) < len(col.rules.Metrics)
+	for i, err := range errs {
+		if err != nil {
+			log.Errorf("Metric rule %d (%s) failed: %s", ruleStatuses[i].Index, rules[i].Expr, err.Error())
+			ruleStatuses[i].failed(err, now, partial, col.quarantine, col.quarDur)
+		} else {
+			ruleStatuses[i].succeeded()
+		}
+	}
+	if !partial && lastErr != nil {
+		return nil, lastErr
+	}
+	reportFailedRules(metrics.Measurements(), col.failedField, failed)
+	if col.host != nil {
+		if err := col.host.Collect(&metrics, to); err != nil {
+			return nil, err
+		}
+	}
+	log.Infof("Metrics collection completed in %s", time.Since(start))
+	// Return the built measurement set
+	return metrics.Measurements(), nil
+}
+
+// newMeasurementSetBuilder creates a builder of the measurement events of a collection at `interval`.
+// Errors of the collector state are stored in `stateErr`
+func (col *Collector) newMeasurementSetBuilder(interval time.Duration, stateErr *error) EventMeasurementSetBuilder {
 	VNFName := col.evtCfg.VNFName
 	nfNamingCode := col.evtCfg.NfNamingCode
 	evtName := col.domainAbr + "_" + nfNamingCode + "_Measurements"
-	metrics := NewEventMeasurementSetBuilder(MeasurementFactoryFunc(func(vmID string, timestamp time.Time) (*govel.EventMeasurements, error) {
+	return NewEventMeasurementSetBuilder(MeasurementFactoryFunc(func(vmID string, timestamp time.Time) (*govel.EventMeasurements, error) {
 		if vmID == "" {
 			vmID = VNFName
 		}
 		id, err := col.state.NextMeasurementIndex()
 		if err != nil {
+			*stateErr = err
 			return nil, err
 		}
 		meas := govel.NewMeasurements(evtName, fmt.Sprintf("Measurements%.10d", id), vmID, interval, timestamp.Add(-interval), timestamp)
@@ -171,39 +305,36 @@ func (col *Collector) CollectMetrics(from, to time.Time, interval time.Duration)
 		meas.NfcNamingCode = col.namingCodes[vmID]
 		return meas, nil
 	}))
+}
 
-	// Initialize a range with interval (prometheus API)
-	rng := v1.Range{
-		Start: from,
-		End:   to,
-		Step:  interval,
-	}
-
-	log.Info("Starting metrics collection")
-	start := time.Now()
-	for _, rule := range col.rules.Metrics {
-		// Iterate over rules, query prometheus, convert and collect results
-		// into the measurement set builder
-		if err := col.collectFromRule(&metrics, rule.WithDefaults(col.rules.DefaultValues), rng); err != nil {
-			return nil, err
+// collectRule collects the results of `rule` into `metrics`. What a failing rule wrote is rolled back,
+// so that its measurements are not partially sent
+func (col *Collector) collectRule(metrics *EventMeasurementSetBuilder, rule config.MetricRule, rng v1.Range, results queryResults) error {
+	metrics.Checkpoint()
+	err := col.collectFromRule(metrics, rule, rng, results)
+	if err != nil {
+		if e := metrics.Rollback(); e != nil {
+			log.Panicf("Cannot roll back measurements of rule %s: %s", rule.Expr, e.Error())
 		}
 	}
-	log.Infof("Metrics collection completed in %s", time.Since(start))
-	// Return the built measurement set
-	return metrics.Measurements(), nil
+	return err
 }
 
-func (col *Collector) collectFromRule(metrics *EventMeasurementSetBuilder, rule config.MetricRule, rng v1.Range) error {
+func (col *Collector) collectFromRule(metrics *EventMeasurementSetBuilder, rule config.MetricRule, rng v1.Range, results queryResults) error {
+	if rule.Target == "LatencyDistribution" {
+		return col.collectHistogram(metrics, rule, rng, results)
+	}
 	data := map[string]interface{}{"interval": int(rng.Step.Seconds())}
 	expr, err := col.execTemplate(rule.Expr, data, true)
 	if err != nil {
 		return err
 	}
-	// Query prometheus server
-	res, err := col.getMatrix(expr, rng)
+	// Get the prometheus query result
+	res, err := results.matrix(rule, expr)
 	if err != nil {
 		return err
 	}
+	col.reportSeries(len(res))
 
 	for _, meas := range res {
 		labels := map[string]string{}
@@ -216,20 +347,46 @@ func (col *Collector) collectFromRule(metrics *EventMeasurementSetBuilder, rule
 		if err != nil {
 			return fmt.Errorf("Cannot evaluate vmID: %s", err.Error())
 		}
+		if vnfc == "" {
+			// Measurements of the VNF itself
+			if col.report != nil {
+				col.warnf("Series %s gives no vmID, VNF name is used", meas.Metric.String())
+			}
+			vnfc = col.evtCfg.VNFName
+		}
 		data["vmId"] = vnfc
 		target, err := col.execTemplate(rule.Target, data, true)
-		if err != nil || target == "" {
-			// return fmt.Errorf("Cannot evaluate target: %s", err.Error())
+		if err != nil {
 			// Ignore metrics not having valid a target defined
-			log.Warnf("Cannot evaluate target: %s", err.Error())
+			col.warnf("Cannot evaluate target of series %s: %s", meas.Metric.String(), err.Error())
+			continue
+		}
+		if target == "" {
+			col.warnf("Ignoring series %s: empty target", meas.Metric.String())
 			continue
 		}
 
 		// Create the composite VES metric key
 		keys := MeasKeys{}
 		lbls := rule.Labels
-		if target == "AdditionalObjects" {
+		var setter *FieldSetter
+		var arrayName, fieldName string
+		switch target {
+		case "AdditionalObjects":
 			lbls = rule.ObjectKeys
+		case "AdditionalMeasurements":
+			if arrayName, err = col.execTemplate(rule.ArrayName, data, true); err != nil {
+				return fmt.Errorf("Cannot evaluate array name: %s", err.Error())
+			}
+			fallthrough
+		case "AdditionalFields":
+			if fieldName, err = col.execTemplate(rule.FieldName, data, true); err != nil {
+				return fmt.Errorf("Cannot evaluate field name: %s", err.Error())
+			}
+		default:
+			if setter, err = col.fieldSetter(target); err != nil {
+				return err
+			}
 		}
 		for _, label := range lbls {
 
@@ -244,10 +401,40 @@ func (col *Collector) collectFromRule(metrics *EventMeasurementSetBuilder, rule
 			timestamp := val.Timestamp.Time()
 			log.Debugf("Got metric %s{%s} => time: %s, VNFC: %s, value: %f", target, keys.String(), timestamp.String(), vnfc, val.Value)
 			var err error
-			if target == "AdditionalObjects" {
+			switch {
+			case target == "AdditionalObjects":
 				err = metrics.SetAdditionalObject(vnfc, rule.ObjectName, rule.ObjectInstance, timestamp, float64(val.Value), keys)
-			} else {
-				err = metrics.Set(target, vnfc, timestamp, float64(val.Value), keys)
+			case target == "AdditionalMeasurements" || target == "AdditionalFields":
+				value, ok, err := col.additionalValue(rule, data, float64(val.Value))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					col.warnf("Ignoring metric %s{%s}: value %f reaches no threshold", target, keys.String(), val.Value)
+					continue
+				}
+				if target == "AdditionalFields" {
+					err = metrics.SetAdditionalField(vnfc, fieldName, timestamp, value)
+				} else {
+					err = metrics.SetAdditionalMeasurement(vnfc, arrayName, fieldName, timestamp, value)
+				}
+				if err != nil {
+					return err
+				}
+			case rule.Value != "" || len(rule.Thresholds) > 0:
+				value, ok, err := col.textValue(rule, data, float64(val.Value))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					col.warnf("Ignoring metric %s{%s}: value %f reaches no threshold", target, keys.String(), val.Value)
+					continue
+				}
+				if err := metrics.SetFieldString(setter, vnfc, timestamp, value, keys); err != nil {
+					return err
+				}
+			default:
+				err = metrics.SetField(setter, vnfc, timestamp, float64(val.Value), rule.Rounding, keys)
 			}
 			if err != nil {
 				return err
@@ -257,9 +444,43 @@ func (col *Collector) collectFromRule(metrics *EventMeasurementSetBuilder, rule
 	return nil
 }
 
-func (col *Collector) getMatrix(query string, r v1.Range) (model.Matrix, error) {
+// additionalValue returns the value of additional fields set by `rule` for the metric value `value`.
+// It's given by the rule thresholds or value expression like string fields, and defaults to the
+// metric value formatted with the fewest digits needed
+func (col *Collector) additionalValue(rule config.MetricRule, data map[string]interface{}, value float64) (string, bool, error) {
+	if rule.Value == "" && len(rule.Thresholds) == 0 {
+		return strconv.FormatFloat(value, 'f', -1, 64), true, nil
+	}
+	return col.textValue(rule, data, value)
+}
+
+// textValue returns the value of string fields set by `rule` for the metric value `value`: the value of
+// the first threshold reached, or the evaluation of the value expression with `data`.
+// False is returned if no threshold is reached and the rule has no value expression
+func (col *Collector) textValue(rule config.MetricRule, data map[string]interface{}, value float64) (string, bool, error) {
+	for _, threshold := range rule.Thresholds {
+		if value >= threshold.Min {
+			return threshold.Value, true, nil
+		}
+	}
+	if rule.Value == "" {
+		return "", false, nil
+	}
+	data["value"] = value
+	defer delete(data, "value")
+	text, err := col.execTemplate(rule.Value, data, true)
+	return text, err == nil, err
+}
+
+func (col *Collector) getMatrix(api v1.API, query string, r v1.Range) (model.Matrix, error) {
 	log.Debugf("Prometheus query : %s", query)
-	result, err := col.api.QueryRange(context.Background(), query, r)
+	ctx := context.Background()
+	if col.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, col.timeout)
+		defer cancel()
+	}
+	result, err := api.QueryRange(ctx, query, r)
 	if err != nil {
 		return nil, err
 	}
@@ -20,6 +20,9 @@ import (
 	"github.com/nokia/onap-vespa/govel"
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 	"github.com/nokia/onap-vespa/ves-agent/config"
@@ -113,7 +116,7 @@ func TestCollector(t *testing.T) {
 func (s *CollectorTestSuite) TestNew() {
 	col, err := NewCollector(&config.MeasurementConfiguration{
 		Prometheus: config.PrometheusConfig{
-			Address: "http://127.0.0.1:9090",
+			PrometheusConnection: config.PrometheusConnection{Address: "http://127.0.0.1:9090"},
 		}},
 		&s.confEvent,
 		s.namingCodes,
@@ -123,7 +126,7 @@ func (s *CollectorTestSuite) TestNew() {
 
 	col, err = NewCollector(&config.MeasurementConfiguration{
 		Prometheus: config.PrometheusConfig{
-			Address: "127.0.0.1:9090",
+			PrometheusConnection: config.PrometheusConnection{Address: "127.0.0.1:9090"},
 		}},
 		&s.confEvent,
 		s.namingCodes,
@@ -162,7 +165,7 @@ func (s *CollectorTestSuite) TestGetMatrix() {
 	}
 	api.On("QueryRange", mock.Anything, "foobar", mock.Anything).Once().Return(matrix, nil)
 
-	m, err := collector.getMatrix("foobar", v1.Range{})
+	m, err := collector.getMatrix(&api, "foobar", v1.Range{})
 
 	s.NoError(err)
 	s.EqualValues(matrix, m)
@@ -180,7 +183,7 @@ func (s *CollectorTestSuite) TestGetMatrixError() {
 	var r *model.Matrix
 	api.On("QueryRange", mock.Anything, "foobar", mock.Anything).Once().Return(r, errors.New("foobar error"))
 
-	m, err := collector.getMatrix("foobar", v1.Range{})
+	m, err := collector.getMatrix(&api, "foobar", v1.Range{})
 
 	s.Error(err)
 	s.Nil(m)
@@ -190,7 +193,7 @@ func (s *CollectorTestSuite) TestGetMatrixError() {
 	var r2 model.Vector
 	api.On("QueryRange", mock.Anything, "foobar", mock.Anything).Once().Return(&r2, nil)
 
-	m, err = collector.getMatrix("foobar", v1.Range{})
+	m, err = collector.getMatrix(&api, "foobar", v1.Range{})
 
 	s.Error(err)
 	s.Nil(m)
@@ -266,6 +269,33 @@ func (s *CollectorTestSuite) TestCollectCpuMetrics() {
 	api.AssertExpectations(s.T())
 }
 
+func (s *CollectorTestSuite) TestCollectWithoutVMID() {
+	api := APIMock{}
+	collector := Collector{
+		state: &inMemState{},
+		api:   &api,
+		rules: config.MetricRules{
+			Metrics: []config.MetricRule{
+				{Expr: "sessions", Target: "ConcurrentSessions", VMIDLabel: `{{index .labels "VNFC"}}`},
+			},
+		},
+		evtCfg:      &s.confEvent,
+		namingCodes: s.namingCodes,
+	}
+	api.On("QueryRange", mock.Anything, "sessions", mock.Anything).Once().Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{}, Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: 3}}},
+	}, nil)
+	// Series without vmID are measurements of the VNF
+	measSet, err := collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), time.Second)
+	s.Require().NoError(err)
+	s.Require().Len(measSet, 1)
+	s.Equal("VNFName", measSet[0].SourceName)
+	s.Require().NotNil(measSet[0].ConcurrentSessions)
+	s.EqualValues(3, *measSet[0].ConcurrentSessions)
+	s.Empty(collector.RuleStatuses()[0].LastError)
+	api.AssertExpectations(s.T())
+}
+
 func (s *CollectorTestSuite) TestCollectCpuMetricsFailed() {
 	api := APIMock{}
 	collector := Collector{
@@ -341,7 +371,8 @@ func (s *CollectorTestSuite) TestCollectMemMetrics() {
 			},
 		},
 	}
-	api.On("QueryRange", mock.Anything, "foobar", mock.Anything).Twice().Return(memMatrix, nil)
+	// Both rules have the same expression, which is queried once
+	api.On("QueryRange", mock.Anything, "foobar", mock.Anything).Once().Return(memMatrix, nil)
 	measSet, err := collector.CollectMetrics(time.Unix(0, 0), time.Now(), 1*time.Second)
 	s.NoError(err)
 	s.Len(measSet, 6)
@@ -363,7 +394,7 @@ func (s *CollectorTestSuite) TestCollectMemMetrics() {
 	s.EqualValues(42, measSet[3].MemoryUsageArray[0].MemoryUsed)
 	api.AssertExpectations(s.T())
 
-	api.On("QueryRange", mock.Anything, "foobar", mock.Anything).Twice().Return(memMatrix, nil)
+	api.On("QueryRange", mock.Anything, "foobar", mock.Anything).Once().Return(memMatrix, nil)
 	collector.state = &inMemState{} // Reset state
 	meas2, err := collector.Run(time.Unix(0, 0), time.Now(), 1*time.Second)
 	s.NoError(err)
@@ -477,6 +508,89 @@ func (s *CollectorTestSuite) TestCollectMemMetricInvalidTargetField() {
 	api.AssertExpectations(s.T())
 }
 
+func (s *CollectorTestSuite) TestCollectTypedMetrics() {
+	api := APIMock{}
+	collector := Collector{
+		state: &inMemState{},
+		api:   &api,
+		rules: config.MetricRules{
+			Metrics: []config.MetricRule{
+				{
+					Expr:      "sessions",
+					Target:    "ConcurrentSessions",
+					VMIDLabel: "{{.labels.VNFC}}",
+					Rounding:  RoundUp,
+				},
+				{
+					Expr:       "errors",
+					Target:     "VNICPerformanceArray.ValuesAreSuspect",
+					VMIDLabel:  "{{.labels.VNFC}}",
+					Labels:     []config.Label{{Name: "VNICIdentifier", Expr: "{{.labels.NIC}}"}},
+					Thresholds: []config.Threshold{{Min: 1, Value: "true"}},
+				},
+				{
+					Expr:      "drops",
+					Target:    "VNICPerformanceArray.ValuesAreSuspect",
+					VMIDLabel: "{{.labels.VNFC}}",
+					Labels:    []config.Label{{Name: "VNICIdentifier", Expr: "{{.labels.NIC}}"}},
+					Value:     "{{if gt .value 10.0}}true{{else}}false{{end}}",
+				},
+			},
+		},
+		evtCfg:      &s.confEvent,
+		namingCodes: s.namingCodes,
+	}
+
+	sessions := model.Matrix{
+		&model.SampleStream{
+			Metric: model.Metric{"VNFC": "ope-1"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: model.SampleValue(12.2)}},
+		},
+	}
+	errs := model.Matrix{
+		&model.SampleStream{
+			Metric: model.Metric{"VNFC": "ope-1", "NIC": "eth0"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: model.SampleValue(3)}},
+		},
+		&model.SampleStream{
+			Metric: model.Metric{"VNFC": "ope-1", "NIC": "eth1"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: model.SampleValue(0)}},
+		},
+	}
+	drops := model.Matrix{
+		&model.SampleStream{
+			Metric: model.Metric{"VNFC": "ope-1", "NIC": "eth2"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: model.SampleValue(5)}},
+		},
+	}
+	api.On("QueryRange", mock.Anything, "sessions", mock.Anything).Once().Return(sessions, nil)
+	api.On("QueryRange", mock.Anything, "errors", mock.Anything).Once().Return(errs, nil)
+	api.On("QueryRange", mock.Anything, "drops", mock.Anything).Once().Return(drops, nil)
+	meas, err := collector.CollectMetrics(time.Unix(0, 0), time.Now(), 1*time.Second)
+	s.Require().NoError(err)
+	s.Require().Len(meas, 1)
+	s.EqualValues(13, *meas[0].ConcurrentSessions)
+	// The value of eth1 reaches no threshold
+	s.Require().Len(meas[0].VNICPerformanceArray, 2)
+	s.Equal("eth0", meas[0].VNICPerformanceArray[0].VNICIdentifier)
+	s.Equal(govel.True, meas[0].VNICPerformanceArray[0].ValuesAreSuspect)
+	s.Equal("eth2", meas[0].VNICPerformanceArray[1].VNICIdentifier)
+	s.Equal(govel.False, meas[0].VNICPerformanceArray[1].ValuesAreSuspect)
+	api.AssertExpectations(s.T())
+}
+
+func (s *CollectorTestSuite) TestInvalidRules() {
+	conf := config.MeasurementConfiguration{Prometheus: config.PrometheusConfig{PrometheusConnection: config.PrometheusConnection{Address: "http://localhost:9090"}}}
+	conf.Prometheus.Rules.Metrics = []config.MetricRule{{Expr: "sessions", Target: "ConcurrentSessions", Rounding: "up"}}
+	_, err := NewCollector(&conf, &s.confEvent, s.namingCodes)
+	s.Error(err)
+
+	conf.Prometheus.Rules.DefaultValues = &config.MetricRule{Rounding: RoundDown}
+	conf.Prometheus.Rules.Metrics[0].Rounding = ""
+	_, err = NewCollector(&conf, &s.confEvent, s.namingCodes)
+	s.NoError(err)
+}
+
 func (s *CollectorTestSuite) TestCollectAdditionalJSONMetrics() {
 	api := APIMock{}
 	collector := Collector{
@@ -522,3 +636,320 @@ func (s *CollectorTestSuite) TestCollectAdditionalJSONMetrics() {
 	s.Equal(float64(12), v)
 	api.AssertExpectations(s.T())
 }
+
+func (s *CollectorTestSuite) TestCollectAdditionalFields() {
+	api := APIMock{}
+	collector := Collector{
+		state: &inMemState{},
+		api:   &api,
+		rules: config.MetricRules{
+			Metrics: []config.MetricRule{
+				{
+					Expr:      "queues",
+					Target:    "AdditionalMeasurements",
+					VMIDLabel: "{{.labels.VNFC}}",
+					ArrayName: "queues",
+					FieldName: "{{.labels.queue}}",
+				},
+				{
+					Expr:      "pools",
+					Target:    "AdditionalMeasurements",
+					VMIDLabel: "{{.labels.VNFC}}",
+					ArrayName: "{{.labels.group}}",
+					FieldName: "{{.labels.pool}}Usage",
+					Value:     `{{printf "%.1f" .value}}%`,
+				},
+				{
+					Expr:       "state",
+					Target:     "AdditionalFields",
+					VMIDLabel:  "{{.labels.VNFC}}",
+					FieldName:  "state",
+					Thresholds: []config.Threshold{{Min: 1, Value: "active"}, {Min: 0, Value: "standby"}},
+				},
+			},
+		},
+		evtCfg:      &s.confEvent,
+		namingCodes: s.namingCodes,
+	}
+
+	sample := func(value float64) []model.SamplePair {
+		return []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: model.SampleValue(value)}}
+	}
+	api.On("QueryRange", mock.Anything, "queues", mock.Anything).Once().Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "queue": "in"}, Values: sample(12)},
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "queue": "out"}, Values: sample(0.5)},
+	}, nil)
+	api.On("QueryRange", mock.Anything, "pools", mock.Anything).Once().Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "group": "queues", "pool": "buffer"}, Values: sample(42.25)},
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "group": "memory", "pool": "heap"}, Values: sample(80)},
+	}, nil)
+	api.On("QueryRange", mock.Anything, "state", mock.Anything).Once().Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1"}, Values: sample(1)},
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-2"}, Values: sample(-1)},
+	}, nil)
+	meas, err := collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 1*time.Second)
+	s.NoError(err)
+	// Values reaching no threshold are ignored
+	s.Len(meas, 1)
+	s.Equal([]govel.NamedArrayOfFields{
+		{Name: "queues", ArrayOfFields: []govel.Field{{Name: "in", Value: "12"}, {Name: "out", Value: "0.5"}, {Name: "bufferUsage", Value: "42.2%"}}},
+		{Name: "memory", ArrayOfFields: []govel.Field{{Name: "heapUsage", Value: "80.0%"}}},
+	}, meas[0].AdditionalMeasurements)
+	s.Equal([]govel.Field{{Name: "state", Value: "active"}}, meas[0].AdditionalFields)
+	api.AssertExpectations(s.T())
+}
+
+// slowAPI delays the range queries of the mocked API, and records how many of them run at once
+type slowAPI struct {
+	APIMock
+	delays  map[string]time.Duration
+	lock    sync.Mutex
+	running int
+	peak    int
+}
+
+func (m *slowAPI) QueryRange(ctx context.Context, query string, r v1.Range) (model.Value, error) {
+	m.lock.Lock()
+	m.running++
+	if m.running > m.peak {
+		m.peak = m.running
+	}
+	m.lock.Unlock()
+	defer func() {
+		m.lock.Lock()
+		m.running--
+		m.lock.Unlock()
+	}()
+	select {
+	case <-time.After(m.delays[query]):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return m.APIMock.QueryRange(ctx, query, r)
+}
+
+func (s *CollectorTestSuite) TestCollectConcurrently() {
+	api := slowAPI{delays: map[string]time.Duration{}}
+	collector := Collector{
+		state:       &inMemState{},
+		api:         &api,
+		evtCfg:      &s.confEvent,
+		namingCodes: s.namingCodes,
+		workers:     3,
+	}
+	for i := 0; i < 6; i++ {
+		// Queries of first rules are the slowest
+		expr := fmt.Sprintf("cpu%d", i)
+		api.delays[expr] = time.Duration(6-i) * 20 * time.Millisecond
+		collector.rules.Metrics = append(collector.rules.Metrics, config.MetricRule{
+			Expr:      expr,
+			Target:    "CPUUsageArray.PercentUsage",
+			VMIDLabel: "{{.labels.VNFC}}",
+			Labels:    []config.Label{{Name: "CPUIdentifier", Expr: strconv.Itoa(i)}},
+		})
+		api.On("QueryRange", mock.Anything, expr, mock.Anything).Once().Return(model.Matrix{
+			&model.SampleStream{
+				Metric: model.Metric{"VNFC": "ope-1"},
+				Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: model.SampleValue(i)}},
+			},
+		}, nil)
+	}
+	// Duplicated expressions are queried once
+	collector.rules.Metrics = append(collector.rules.Metrics, config.MetricRule{
+		Expr:      "cpu{{sub .interval 1}}",
+		Target:    "CPUUsageArray.CPUIdle",
+		VMIDLabel: "{{.labels.VNFC}}",
+		Labels:    []config.Label{{Name: "CPUIdentifier", Expr: "0"}},
+	})
+
+	meas, err := collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 1*time.Second)
+	s.NoError(err)
+	s.Equal(3, api.peak)
+	s.Len(meas, 1)
+	// Results are merged in rules order
+	s.Len(meas[0].CPUUsageArray, 6)
+	for i, cpu := range meas[0].CPUUsageArray {
+		s.Equal(strconv.Itoa(i), cpu.CPUIdentifier)
+		s.EqualValues(i, cpu.PercentUsage)
+	}
+	s.EqualValues(0, *meas[0].CPUUsageArray[0].CPUIdle)
+	api.AssertExpectations(s.T())
+
+	// Queries timeout
+	collector.timeout = 50 * time.Millisecond
+	api.delays = map[string]time.Duration{"cpu3": time.Minute}
+	api.On("QueryRange", mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	_, err = collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 1*time.Second)
+	s.NoError(err)
+	s.Equal(1, collector.RuleStatuses()[3].Failures)
+	s.Contains(collector.RuleStatuses()[3].LastError, "deadline")
+	delete(api.delays, "cpu3")
+	_, err = collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 1*time.Second)
+	s.NoError(err)
+	s.Zero(collector.RuleStatuses()[3].Failures)
+}
+
+func (s *CollectorTestSuite) TestCollectPartialResults() {
+	api := APIMock{}
+	now := time.Unix(1000, 0)
+	collector := Collector{
+		state: &inMemState{},
+		api:   &api,
+		rules: config.MetricRules{
+			DefaultValues: &config.MetricRule{VMIDLabel: "{{.labels.VNFC}}"},
+			Metrics: []config.MetricRule{
+				{Expr: "sessions", Target: "ConcurrentSessions"},
+				{Expr: "broken", Target: "ConfiguredEntities"},
+				{Expr: "invalid", Target: "{{.labels.field}}"},
+				{Expr: "{{.unknown}}", Target: "ConfiguredEntities"},
+			},
+		},
+		evtCfg:      &s.confEvent,
+		namingCodes: s.namingCodes,
+		quarantine:  2,
+		quarDur:     time.Hour,
+		failedField: "failedRules",
+		now:         func() time.Time { return now },
+	}
+	sample := []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: model.SampleValue(12)}}
+	api.On("QueryRange", mock.Anything, "sessions", mock.Anything).Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1"}, Values: sample},
+	}, nil)
+	api.On("QueryRange", mock.Anything, "broken", mock.Anything).Return(model.Matrix{}, errors.New("bad_data"))
+	api.On("QueryRange", mock.Anything, "invalid", mock.Anything).Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "field": "VNICPerformanceArray.ValuesAreSuspect"}, Values: sample},
+	}, nil)
+
+	// Failed rules are reported, the others are collected
+	for i := 0; i < 2; i++ {
+		meas, err := collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 10*time.Second)
+		s.NoError(err)
+		s.Len(meas, 1)
+		s.EqualValues(12, *meas[0].ConcurrentSessions)
+		s.Nil(meas[0].ConfiguredEntities)
+		s.Empty(meas[0].VNICPerformanceArray)
+		s.Equal([]govel.Field{{Name: "failedRules", Value: "broken; invalid; {{.unknown}}"}}, meas[0].AdditionalFields)
+	}
+	statuses := collector.RuleStatuses()
+	s.Len(statuses, 4)
+	s.Equal(RuleStatus{Index: 0, Expr: "sessions", Target: "ConcurrentSessions"}, statuses[0])
+	s.Equal(RuleStatus{
+		Index:            1,
+		Expr:             "broken",
+		Target:           "ConfiguredEntities",
+		Failures:         2,
+		TotalFailures:    2,
+		LastError:        "bad_data",
+		LastFailure:      now,
+		QuarantinedUntil: now.Add(time.Hour),
+	}, statuses[1])
+	s.Equal(2, statuses[2].Failures)
+	s.Equal(2, statuses[3].Failures)
+	api.AssertNumberOfCalls(s.T(), "QueryRange", 6)
+
+	// Quarantined rules are not evaluated, until the end of the quarantine
+	now = now.Add(30 * time.Minute)
+	meas, err := collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 10*time.Second)
+	s.NoError(err)
+	s.Len(meas, 1)
+	s.Equal([]govel.Field{{Name: "failedRules", Value: "broken; invalid; {{.unknown}}"}}, meas[0].AdditionalFields)
+	api.AssertNumberOfCalls(s.T(), "QueryRange", 7)
+	now = now.Add(time.Hour)
+	_, err = collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 10*time.Second)
+	s.NoError(err)
+	api.AssertNumberOfCalls(s.T(), "QueryRange", 10)
+	s.Equal(3, collector.RuleStatuses()[1].Failures)
+	s.Equal(now.Add(time.Hour), collector.RuleStatuses()[1].QuarantinedUntil)
+
+	// The collection fails if all evaluated rules fail, without counting for quarantine
+	collector.statuses = nil
+	api.ExpectedCalls = nil
+	api.On("QueryRange", mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, errors.New("unavailable"))
+	_, err = collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 10*time.Second)
+	s.Error(err)
+	statuses = collector.RuleStatuses()
+	s.Zero(statuses[0].Failures)
+	s.Equal(1, statuses[0].TotalFailures)
+	s.Equal("unavailable", statuses[0].LastError)
+}
+
+func (s *CollectorTestSuite) TestCollectFailedRuleRollback() {
+	api := APIMock{}
+	collector := Collector{
+		state: &inMemState{},
+		api:   &api,
+		rules: config.MetricRules{
+			DefaultValues: &config.MetricRule{VMIDLabel: "{{.labels.VNFC}}"},
+			Metrics: []config.MetricRule{
+				{Expr: "sessions", Target: "ConcurrentSessions"},
+				{Expr: "partial", Target: "{{.labels.field}}"},
+			},
+		},
+		evtCfg:      &s.confEvent,
+		namingCodes: s.namingCodes,
+		failedField: "failedRules",
+	}
+	sample := []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: model.SampleValue(12)}}
+	api.On("QueryRange", mock.Anything, "sessions", mock.Anything).Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1"}, Values: sample},
+	}, nil)
+	api.On("QueryRange", mock.Anything, "partial", mock.Anything).Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "field": "ConfiguredEntities"}, Values: sample},
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-2", "field": "ConfiguredEntities"}, Values: sample},
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "field": "UnknownField"}, Values: sample},
+	}, nil)
+
+	// Series of the failed rule collected before its failure are not sent
+	meas, err := collector.CollectMetrics(time.Unix(0, 0), time.Unix(10, 0), 10*time.Second)
+	s.Require().NoError(err)
+	s.Require().Len(meas, 1)
+	s.Equal("ope-1", meas[0].SourceName)
+	s.EqualValues(12, *meas[0].ConcurrentSessions)
+	s.Nil(meas[0].ConfiguredEntities)
+	s.Equal([]govel.Field{{Name: "failedRules", Value: "partial"}}, meas[0].AdditionalFields)
+}
+
+// benchmarkCollect collects a matrix of `series` CPU usage series spread over 10 VMs, each series
+// having `steps` samples
+func benchmarkCollect(b *testing.B, series, steps int) {
+	matrix := make(model.Matrix, series)
+	for i := range matrix {
+		values := make([]model.SamplePair, steps)
+		for t := range values {
+			values[t] = model.SamplePair{Timestamp: model.TimeFromUnix(int64(10 * (t + 1))), Value: model.SampleValue(t)}
+		}
+		matrix[i] = &model.SampleStream{
+			Metric: model.Metric{"VNFC": model.LabelValue(fmt.Sprintf("ope-%d", i%10)), "VCID": model.LabelValue(strconv.Itoa(i / 10))},
+			Values: values,
+		}
+	}
+	api := APIMock{}
+	api.On("QueryRange", mock.Anything, "foobar", mock.Anything).Return(matrix, nil)
+	collector := Collector{
+		state: &inMemState{},
+		api:   &api,
+		rules: config.MetricRules{
+			Metrics: []config.MetricRule{
+				{
+					Expr:      "foobar",
+					Target:    "CPUUsageArray.PercentUsage",
+					VMIDLabel: "{{.labels.VNFC}}",
+					Labels: []config.Label{
+						{Name: "CPUIdentifier", Expr: "{{.labels.VCID}}"},
+					},
+				},
+			},
+		},
+		evtCfg:      &govel.EventConfiguration{VNFName: "VNFName", NfNamingCode: "hsxp"},
+		namingCodes: map[string]string{},
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := collector.CollectMetrics(time.Unix(0, 0), time.Unix(int64(10*steps), 0), 10*time.Second); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCollect1000Series60Steps(b *testing.B) { benchmarkCollect(b, 1000, 60) }
+func BenchmarkCollect5000Series60Steps(b *testing.B) { benchmarkCollect(b, 5000, 60) }
@@ -0,0 +1,137 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/govel/schema"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// RuleReport is the result of the dry run of a metric rule
+type RuleReport struct {
+	Index    int      `json:"index"`              // Index of the rule in the configuration
+	Expr     string   `json:"expr"`               // Query expression of the rule
+	Target   string   `json:"target"`             // Target of the rule
+	Series   int      `json:"series"`             // Number of series returned by the rule query
+	Error    string   `json:"error,omitempty"`    // Error failing the rule. Empty if the rule succeeded
+	Warnings []string `json:"warnings,omitempty"` // Series ignored by the rule
+}
+
+// DryRunReport is the result of the dry run of metric rules
+type DryRunReport struct {
+	Rules        []RuleReport        `json:"rules"`                  // Reports of the evaluated rules
+	Events       EventMeasurementSet `json:"events"`                 // Measurement events built by the rules
+	SchemaErrors []string            `json:"schemaErrors,omitempty"` // Schema violations of the events
+}
+
+// Failed tells if a rule failed, or if events are not valid
+func (report *DryRunReport) Failed() bool {
+	for _, rule := range report.Rules {
+		if rule.Error != "" {
+			return true
+		}
+	}
+	return len(report.SchemaErrors) > 0
+}
+
+// NewDryRunCollector creates a Prometheus Metrics collector from provided configuration, to evaluate
+// its rules with DryRun. Unlike NewCollector, it accepts invalid rules, and host metrics are disabled
+func NewDryRunCollector(cfg *config.MeasurementConfiguration, evtCfg *govel.EventConfiguration, namingCodes map[string]string) (*Collector, error) {
+	dryCfg := *cfg
+	dryCfg.Host.Enabled = false
+	return newCollector(&dryCfg, evtCfg, namingCodes, &inMemState{index: 0})
+}
+
+// DryRun evaluates the metric rules on the window from `from` to `to` at `interval`, and reports
+// the measurement events they build, without sending them. If `index` is not negative, only the
+// rule with this index is evaluated. Invalid rules are reported, and don't prevent evaluating the others
+func (col *Collector) DryRun(index int, from, to time.Time, interval time.Duration) (*DryRunReport, error) {
+	if index >= len(col.rules.Metrics) {
+		return nil, fmt.Errorf("Unknown metric rule %d: %d rules are configured", index, len(col.rules.Metrics))
+	}
+	report := &DryRunReport{Rules: []RuleReport{}}
+	rules := []config.MetricRule{}
+	positions := []int{} // Position of the reports of valid rules
+	for i, rule := range col.rules.Metrics {
+		if index >= 0 && i != index {
+			continue
+		}
+		rule = rule.WithDefaults(col.rules.DefaultValues)
+		ruleReport := RuleReport{Index: i, Expr: rule.Expr, Target: rule.Target}
+		if err := ValidateRule(rule); err != nil {
+			ruleReport.Error = err.Error()
+		} else if err := col.checkSources(rule); err != nil {
+			ruleReport.Error = err.Error()
+		} else {
+			rules = append(rules, rule)
+			positions = append(positions, len(report.Rules))
+		}
+		report.Rules = append(report.Rules, ruleReport)
+	}
+
+	var stateErr error
+	metrics := col.newMeasurementSetBuilder(interval, &stateErr)
+	rng := v1.Range{Start: from, End: to, Step: interval}
+	results := col.runQueries(col.ruleQueries(rules, rng), rng)
+	for i, rule := range rules {
+		col.report = &report.Rules[positions[i]]
+		if err := col.collectRule(&metrics, rule, rng, results); err != nil {
+			col.report.Error = err.Error()
+		}
+		col.report = nil
+	}
+	report.Events = metrics.Measurements()
+
+	// Validate events as they would be sent
+	batch := report.Events.Batch()
+	batch.UpdateReportingEntityName(col.evtCfg.ReportingEntityName)
+	batch.UpdateReportingEntityID(col.evtCfg.ReportingEntityID)
+	for _, evt := range report.Events {
+		errs, err := schema.V2841().Errors(map[string]interface{}{"event": evt})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range errs {
+			report.SchemaErrors = append(report.SchemaErrors, fmt.Sprintf("%s: %s", evt.EventID, e))
+		}
+	}
+	return report, nil
+}
+
+// warnf logs a warning about a series ignored by the rule being evaluated,
+// and adds it to the rule report in a dry run
+func (col *Collector) warnf(format string, args ...interface{}) {
+	log.Warnf(format, args...)
+	if col.report != nil {
+		col.report.Warnings = append(col.report.Warnings, fmt.Sprintf(format, args...))
+	}
+}
+
+// reportSeries counts `n` series returned to the rule evaluated by a dry run
+func (col *Collector) reportSeries(n int) {
+	if col.report != nil {
+		col.report.Series += n
+	}
+}
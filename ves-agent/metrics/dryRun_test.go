@@ -0,0 +1,121 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type DryRunTestSuite struct {
+	suite.Suite
+}
+
+func TestDryRun(t *testing.T) {
+	suite.Run(t, new(DryRunTestSuite))
+}
+
+func (s *DryRunTestSuite) TestDryRun() {
+	api := APIMock{}
+	collector := Collector{
+		state: &inMemState{},
+		api:   &api,
+		rules: config.MetricRules{
+			DefaultValues: &config.MetricRule{VMIDLabel: `{{index .labels "VNFC"}}`},
+			Metrics: []config.MetricRule{
+				{
+					Expr:   "cpu",
+					Target: "CPUUsageArray.PercentUsage",
+					Labels: []config.Label{{Name: "CPUIdentifier", Expr: "{{.labels.VCID}}"}},
+				},
+				{Expr: "unknown", Target: "UnknownField"},
+				{Expr: "sessions", Target: "{{.labels.target}}"},
+				{Expr: "failing", Target: "ConcurrentSessions"},
+			},
+		},
+		evtCfg: &govel.EventConfiguration{
+			VNFName:             "VNFName",
+			NfNamingCode:        "hsxp",
+			ReportingEntityName: "ves-agent",
+		},
+		namingCodes: map[string]string{"ope-1": "oam"},
+	}
+
+	sample := []model.SamplePair{{Timestamp: model.TimeFromUnix(10), Value: 12}}
+	api.On("QueryRange", mock.Anything, "cpu", mock.Anything).Once().Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "VCID": "1"}, Values: sample},
+		&model.SampleStream{Metric: model.Metric{"VCID": "2"}, Values: sample},
+	}, nil)
+	api.On("QueryRange", mock.Anything, "sessions", mock.Anything).Once().Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1"}, Values: sample},
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "target": "Unknown"}, Values: sample},
+	}, nil)
+	api.On("QueryRange", mock.Anything, "failing", mock.Anything).Once().Return(model.Matrix{}, errors.New("Prometheus is down"))
+	report, err := collector.DryRun(-1, time.Unix(0, 0), time.Unix(10, 0), time.Second)
+	s.NoError(err)
+	api.AssertExpectations(s.T())
+	s.True(report.Failed())
+	s.Len(report.Rules, 4)
+	// Series without vmId are reported, and use the VNF name
+	s.Equal(RuleReport{
+		Index:    0,
+		Expr:     "cpu",
+		Target:   "CPUUsageArray.PercentUsage",
+		Series:   2,
+		Warnings: []string{`Series {VCID="2"} gives no vmID, VNF name is used`},
+	}, report.Rules[0])
+	// Invalid rules are not evaluated
+	s.Equal(1, report.Rules[1].Index)
+	s.Zero(report.Rules[1].Series)
+	s.Contains(report.Rules[1].Error, "UnknownField")
+	// Series whose target can't be evaluated are ignored, unknown targets fail the rule
+	s.Equal(2, report.Rules[2].Series)
+	s.Len(report.Rules[2].Warnings, 1)
+	s.Contains(report.Rules[2].Warnings[0], "Cannot evaluate target")
+	s.Contains(report.Rules[2].Error, "Unknown")
+	s.Equal("Prometheus is down", report.Rules[3].Error)
+
+	// Events are built by the rules, and validated
+	s.Require().Len(report.Events, 2)
+	s.Equal("ope-1", report.Events[0].SourceName)
+	s.Equal("VNFName", report.Events[1].SourceName)
+	s.Empty(report.SchemaErrors)
+	// Events are not sent, so the reporting entity is set like when sending them
+	s.Equal("ves-agent", report.Events[0].ReportingEntityName)
+
+	// A single rule can be evaluated
+	api.On("QueryRange", mock.Anything, "cpu", mock.Anything).Once().Return(model.Matrix{
+		&model.SampleStream{Metric: model.Metric{"VNFC": "ope-1", "VCID": "1"}, Values: sample},
+	}, nil)
+	report, err = collector.DryRun(0, time.Unix(0, 0), time.Unix(10, 0), time.Second)
+	s.NoError(err)
+	api.AssertExpectations(s.T())
+	s.False(report.Failed())
+	s.Len(report.Rules, 1)
+	s.Len(report.Events, 1)
+
+	_, err = collector.DryRun(4, time.Unix(0, 0), time.Unix(10, 0), time.Second)
+	s.Error(err)
+}
@@ -0,0 +1,212 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+)
+
+// FieldKind is the kind of value a measurement field holds
+type FieldKind int
+
+// Kinds of measurement fields
+const (
+	FloatField  FieldKind = iota // Floating point number
+	IntField                     // Integer, metric values are rounded
+	StringField                  // Free string
+	EnumField                    // String restricted to a set of values
+)
+
+func (kind FieldKind) String() string {
+	switch kind {
+	case FloatField:
+		return "float"
+	case IntField:
+		return "integer"
+	case StringField:
+		return "string"
+	case EnumField:
+		return "enum"
+	}
+	return "unknown"
+}
+
+// enumValues lists the values allowed in enum field types
+var enumValues = map[reflect.Type][]string{
+	reflect.TypeOf(govel.ValuesAreSuspect("")): {string(govel.True), string(govel.False)},
+}
+
+// Rounding methods of metric values assigned to integer fields
+const (
+	RoundNearest = "round" // To the nearest integer, halves away from zero
+	RoundDown    = "floor" // To the greatest lower integer
+	RoundUp      = "ceil"  // To the least greater integer
+	RoundTrunc   = "trunc" // Toward zero
+)
+
+// roundings are the rounding functions by method
+var roundings = map[string]func(float64) float64{
+	"":           math.Round,
+	RoundNearest: math.Round,
+	RoundDown:    math.Floor,
+	RoundUp:      math.Ceil,
+	RoundTrunc:   math.Trunc,
+}
+
+// fieldKind returns the kind of fields of type `typ`
+func fieldKind(typ reflect.Type) (FieldKind, bool) {
+	if _, ok := enumValues[typ]; ok {
+		return EnumField, true
+	}
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return FloatField, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return IntField, true
+	case reflect.String:
+		return StringField, true
+	}
+	return 0, false
+}
+
+// Field returns the kind of the measurement field `fields`, a dot separated path in
+// `govel.EventMeasurements`, and the allowed values of enum fields
+func Field(fields string) (FieldKind, []string, error) {
+	setter, err := CompileField(fields)
+	if err != nil {
+		return 0, nil, err
+	}
+	kind, values := setter.Kind()
+	return kind, values, nil
+}
+
+// ValidateRule checks that the rule `rule` can set its target field. Targets which are template
+// expressions are only known during collection, and their rules are checked then
+func ValidateRule(rule config.MetricRule) error {
+	if _, ok := roundings[rule.Rounding]; !ok {
+		return fmt.Errorf("Rule %s: invalid rounding %q", rule.Expr, rule.Rounding)
+	}
+	switch {
+	case rule.Target == "AdditionalMeasurements" && (rule.ArrayName == "" || rule.FieldName == ""):
+		return fmt.Errorf("Rule %s: target %s requires an array name and a field name", rule.Expr, rule.Target)
+	case rule.Target == "AdditionalFields" && rule.FieldName == "":
+		return fmt.Errorf("Rule %s: target %s requires a field name", rule.Expr, rule.Target)
+	case rule.Target == "LatencyDistribution":
+		if rule.Histogram.Scale < 0 {
+			return fmt.Errorf("Rule %s: invalid histogram scale %f", rule.Expr, rule.Histogram.Scale)
+		}
+		if (rule.Histogram.Sum == "") != (rule.Histogram.Count == "") {
+			return fmt.Errorf("Rule %s: histogram sum and count queries must be both set", rule.Expr)
+		}
+		return nil
+	}
+	if isAdditionalTarget(rule.Target) || strings.Contains(rule.Target, "{{") {
+		return nil
+	}
+	kind, values, err := Field(rule.Target)
+	if err != nil {
+		return fmt.Errorf("Rule %s: %s", rule.Expr, err.Error())
+	}
+	textual := rule.Value != "" || len(rule.Thresholds) > 0
+	switch kind {
+	case FloatField, IntField:
+		if textual {
+			return fmt.Errorf("Rule %s: %s target %s cannot have a value nor thresholds", rule.Expr, kind, rule.Target)
+		}
+	case StringField, EnumField:
+		if !textual {
+			return fmt.Errorf("Rule %s: %s target %s requires a value or thresholds", rule.Expr, kind, rule.Target)
+		}
+		if kind == EnumField {
+			for _, threshold := range rule.Thresholds {
+				if err := checkEnum(values, threshold.Value); err != nil {
+					return fmt.Errorf("Rule %s: %s", rule.Expr, err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isAdditionalTarget tells if `target` is one of the rule targets filling the additional,
+// free form, structures of measurement events rather than a field
+func isAdditionalTarget(target string) bool {
+	return target == "AdditionalObjects" || target == "AdditionalMeasurements" || target == "AdditionalFields"
+}
+
+// checkEnum checks that `value` is one of `values`
+func checkEnum(values []string, value string) error {
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid value %q, expecting one of %s", value, strings.Join(values, ", "))
+}
+
+// assigner sets leaf fields of measurement structures
+type assigner func(field reflect.Value) error
+
+// numberAssigner returns an assigner of the metric value `value`. Values assigned to
+// integer fields are rounded with `rounding`
+func numberAssigner(value float64, rounding string) assigner {
+	return func(field reflect.Value) error {
+		kind, ok := fieldKind(field.Type())
+		switch {
+		case ok && kind == FloatField:
+			field.SetFloat(value)
+			return nil
+		case ok && kind == IntField:
+			round, ok := roundings[rounding]
+			if !ok {
+				return fmt.Errorf("Invalid rounding %q", rounding)
+			}
+			rounded := round(value)
+			if math.IsNaN(rounded) || math.IsInf(rounded, 0) || rounded != float64(int64(rounded)) || field.OverflowInt(int64(rounded)) {
+				return fmt.Errorf("Value %f overflows %s", value, field.Type().String())
+			}
+			field.SetInt(int64(rounded))
+			return nil
+		}
+		return fmt.Errorf("Cannot assign a float64 to %s", field.Type().String())
+	}
+}
+
+// stringAssigner returns an assigner of the string `value`. Values of enum fields are checked
+func stringAssigner(value string) assigner {
+	return func(field reflect.Value) error {
+		kind, ok := fieldKind(field.Type())
+		switch {
+		case ok && kind == StringField:
+			field.SetString(value)
+			return nil
+		case ok && kind == EnumField:
+			if err := checkEnum(enumValues[field.Type()], value); err != nil {
+				return err
+			}
+			field.SetString(value)
+			return nil
+		}
+		return fmt.Errorf("Cannot assign a string to %s", field.Type().String())
+	}
+}
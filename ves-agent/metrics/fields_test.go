@@ -0,0 +1,100 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FieldsTestSuite struct {
+	suite.Suite
+}
+
+func TestFields(t *testing.T) {
+	suite.Run(t, new(FieldsTestSuite))
+}
+
+func (s *FieldsTestSuite) TestField() {
+	for fields, expected := range map[string]FieldKind{
+		"CPUUsageArray.PercentUsage":                            FloatField,
+		"CPUUsageArray.CPUIdle":                                 FloatField,
+		"MeanRequestLatency":                                    FloatField,
+		"ConcurrentSessions":                                    IntField,
+		"ConfiguredEntities":                                    IntField,
+		"VnfcScalingMetric":                                     IntField,
+		"NumberOfMediaPortsInUse":                               IntField,
+		"FeatureUsageArray.FeatureUtilization":                  IntField,
+		"CodecUsageArray.NumberInUse":                           IntField,
+		"VNICPerformanceArray.VNICIdentifier":                   StringField,
+		"VNICPerformanceArray.ValuesAreSuspect":                 EnumField,
+		"VNICPerformanceArray.ReceivedOctetsAccumulated":        FloatField,
+		"LatencyDistribution.HighEndOfLatencyBucket":            FloatField,
+		"AdditionalObjects.ObjectInstances.ObjectKeys.KeyOrder": IntField,
+	} {
+		kind, values, err := Field(fields)
+		s.NoError(err, fields)
+		s.Equal(expected, kind, fields)
+		if kind == EnumField {
+			s.Equal([]string{"true", "false"}, values)
+		} else {
+			s.Empty(values)
+		}
+	}
+
+	for _, fields := range []string{"Unknown", "CPUUsageArray.Unknown", "CPUUsageArray.PercentUsage.Value", "CPUUsageArray", "AdditionalObjects.ObjectInstances.ObjectInstance"} {
+		_, _, err := Field(fields)
+		s.Error(err, fields)
+	}
+}
+
+func (s *FieldsTestSuite) TestValidateRule() {
+	for _, rule := range []config.MetricRule{
+		{Expr: "cpu", Target: "CPUUsageArray.PercentUsage"},
+		{Expr: "sessions", Target: "ConcurrentSessions", Rounding: RoundUp},
+		{Expr: "features", Target: "FeatureUsageArray.FeatureUtilization", Rounding: RoundTrunc},
+		{Expr: "errors", Target: "VNICPerformanceArray.ValuesAreSuspect", Thresholds: []config.Threshold{{Min: 1, Value: "true"}, {Min: 0, Value: "false"}}},
+		{Expr: "errors", Target: "VNICPerformanceArray.ValuesAreSuspect", Value: `{{if gt .value 0.0}}true{{else}}false{{end}}`},
+		{Expr: "any", Target: "{{.labels.VESField}}"},
+		{Expr: "objects", Target: "AdditionalObjects"},
+		{Expr: "measurements", Target: "AdditionalMeasurements", ArrayName: "{{.labels.group}}", FieldName: "{{.labels.name}}"},
+		{Expr: "fields", Target: "AdditionalFields", FieldName: "{{.labels.name}}", Value: `{{printf "%.2f" .value}}`},
+		{Expr: "buckets", Target: "LatencyDistribution"},
+		{Expr: "buckets", Target: "LatencyDistribution", Histogram: config.HistogramRule{Scale: 1000, Sum: "sum", Count: "count"}},
+	} {
+		s.NoError(ValidateRule(rule), rule.Expr)
+	}
+
+	for _, rule := range []config.MetricRule{
+		{Expr: "unknown", Target: "CPUUsageArray.Unknown"},
+		{Expr: "rounding", Target: "ConcurrentSessions", Rounding: "up"},
+		{Expr: "value", Target: "ConcurrentSessions", Value: "12"},
+		{Expr: "thresholds", Target: "CPUUsageArray.PercentUsage", Thresholds: []config.Threshold{{Min: 1, Value: "true"}}},
+		{Expr: "noValue", Target: "VNICPerformanceArray.ValuesAreSuspect"},
+		{Expr: "enum", Target: "VNICPerformanceArray.ValuesAreSuspect", Thresholds: []config.Threshold{{Min: 1, Value: "yes"}}},
+		{Expr: "noArrayName", Target: "AdditionalMeasurements", FieldName: "{{.labels.name}}"},
+		{Expr: "noFieldName", Target: "AdditionalMeasurements", ArrayName: "{{.labels.group}}"},
+		{Expr: "noName", Target: "AdditionalFields"},
+		{Expr: "scale", Target: "LatencyDistribution", Histogram: config.HistogramRule{Scale: -1}},
+		{Expr: "noCount", Target: "LatencyDistribution", Histogram: config.HistogramRule{Sum: "sum"}},
+	} {
+		s.Error(ValidateRule(rule), rule.Expr)
+	}
+}
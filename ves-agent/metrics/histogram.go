@@ -0,0 +1,205 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// bucketLabel is the label holding the upper bound of Prometheus histogram buckets
+const bucketLabel = "le"
+
+// histogramKey identifies the samples of a VM at a timestamp
+type histogramKey struct {
+	vmID      string
+	timestamp model.Time
+}
+
+// sortHistogramKeys sorts `keys` by VM and timestamp
+func sortHistogramKeys(keys []histogramKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].vmID != keys[j].vmID {
+			return keys[i].vmID < keys[j].vmID
+		}
+		return keys[i].timestamp < keys[j].timestamp
+	})
+}
+
+// collectHistogram fills `LatencyDistribution` of measurement events from the `le` buckets returned by
+// the rule expression, and `MeanRequestLatency` from the sum and count queries when configured.
+// Series of the same VM are summed by bucket
+func (col *Collector) collectHistogram(metrics *EventMeasurementSetBuilder, rule config.MetricRule, rng v1.Range, results queryResults) error {
+	data := map[string]interface{}{"interval": int(rng.Step.Seconds())}
+	expr, err := col.execTemplate(rule.Expr, data, true)
+	if err != nil {
+		return err
+	}
+	res, err := results.matrix(rule, expr)
+	if err != nil {
+		return err
+	}
+	col.reportSeries(len(res))
+	// Cumulative counts of the buckets of VMs by timestamp and upper bound
+	points := make(map[histogramKey]map[float64]float64)
+	for _, meas := range res {
+		bound, err := strconv.ParseFloat(string(meas.Metric[bucketLabel]), 64)
+		if err != nil {
+			col.warnf("Ignoring histogram series %s: invalid %s label", meas.Metric.String(), bucketLabel)
+			continue
+		}
+		vmID, err := col.histogramVMID(rule, data, meas.Metric)
+		if err != nil {
+			return err
+		}
+		for _, val := range meas.Values {
+			if math.IsNaN(float64(val.Value)) {
+				continue
+			}
+			key := histogramKey{vmID: vmID, timestamp: val.Timestamp}
+			if points[key] == nil {
+				points[key] = make(map[float64]float64)
+			}
+			points[key][bound] += float64(val.Value)
+		}
+	}
+	scale := rule.Histogram.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	keys := make([]histogramKey, 0, len(points))
+	for key := range points {
+		keys = append(keys, key)
+	}
+	sortHistogramKeys(keys)
+	for _, key := range keys {
+		meas, err := metrics.Measurement(key.vmID, key.timestamp.Time())
+		if err != nil {
+			return err
+		}
+		meas.LatencyDistribution = latencyDistribution(points[key], scale)
+	}
+
+	if rule.Histogram.Sum == "" || rule.Histogram.Count == "" {
+		return nil
+	}
+	sums, err := col.histogramTotals(rule, rule.Histogram.Sum, data, results)
+	if err != nil {
+		return err
+	}
+	counts, err := col.histogramTotals(rule, rule.Histogram.Count, data, results)
+	if err != nil {
+		return err
+	}
+	keys = keys[:0]
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sortHistogramKeys(keys)
+	for _, key := range keys {
+		sum, ok := sums[key]
+		if !ok || counts[key] == 0 {
+			continue
+		}
+		if err := metrics.Set("MeanRequestLatency", key.vmID, key.timestamp.Time(), scale*sum/counts[key], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// histogramVMID evaluates the VM ID of the histogram series with labels `metric`
+func (col *Collector) histogramVMID(rule config.MetricRule, data map[string]interface{}, metric model.Metric) (string, error) {
+	labels := map[string]string{}
+	for lab, val := range metric {
+		labels[string(lab)] = string(val)
+	}
+	data["labels"] = labels
+	vmID, err := col.execTemplate(rule.VMIDLabel, data, true)
+	if err != nil {
+		return "", fmt.Errorf("Cannot evaluate vmID: %s", err.Error())
+	}
+	if vmID == "" {
+		// Measurements of the VNF itself
+		if col.report != nil {
+			col.warnf("Series %s gives no vmID, VNF name is used", metric.String())
+		}
+		vmID = col.evtCfg.VNFName
+	}
+	return vmID, nil
+}
+
+// histogramTotals gets the result of the query template `query`, and sums its values by VM and timestamp
+func (col *Collector) histogramTotals(rule config.MetricRule, query string, data map[string]interface{}, results queryResults) (map[histogramKey]float64, error) {
+	delete(data, "labels")
+	expr, err := col.execTemplate(query, data, true)
+	if err != nil {
+		return nil, err
+	}
+	res, err := results.matrix(rule, expr)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[histogramKey]float64)
+	for _, meas := range res {
+		vmID, err := col.histogramVMID(rule, data, meas.Metric)
+		if err != nil {
+			return nil, err
+		}
+		for _, val := range meas.Values {
+			if !math.IsNaN(float64(val.Value)) {
+				totals[histogramKey{vmID: vmID, timestamp: val.Timestamp}] += float64(val.Value)
+			}
+		}
+	}
+	return totals, nil
+}
+
+// latencyDistribution converts the cumulative counts of histogram buckets by upper bound into latency
+// buckets, counting the observations between the previous bound (or 0) and their own. Bounds are
+// multiplied by `scale`, and the high end of the `+Inf` bucket is omitted
+func latencyDistribution(cumulative map[float64]float64, scale float64) []govel.LatencyBucketMeasure {
+	bounds := make([]float64, 0, len(cumulative))
+	for bound := range cumulative {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+	buckets := make([]govel.LatencyBucketMeasure, len(bounds))
+	low, prev := 0.0, 0.0
+	for i, bound := range bounds {
+		// Counts computed by Prometheus with increase() are extrapolated,
+		// and may not be exactly cumulative
+		count := math.Max(cumulative[bound]-prev, 0)
+		prev = math.Max(cumulative[bound], prev)
+		lowEnd := low * scale
+		buckets[i] = govel.LatencyBucketMeasure{CountsInTheBucket: count, LowEndOfLatencyBucket: &lowEnd}
+		if !math.IsInf(bound, 1) {
+			highEnd := bound * scale
+			buckets[i].HighEndOfLatencyBucket = &highEnd
+		}
+		low = bound
+	}
+	return buckets
+}
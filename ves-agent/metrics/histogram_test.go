@@ -0,0 +1,135 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type HistogramTestSuite struct {
+	suite.Suite
+}
+
+func TestHistogram(t *testing.T) {
+	suite.Run(t, new(HistogramTestSuite))
+}
+
+func pfloat(f float64) *float64 {
+	return &f
+}
+
+func (s *HistogramTestSuite) TestLatencyDistribution() {
+	s.Empty(latencyDistribution(map[float64]float64{}, 1))
+	buckets := latencyDistribution(map[float64]float64{0.1: 2, 0.5: 7, 1: 6.5, math.Inf(1): 10}, 1000)
+	s.Equal([]govel.LatencyBucketMeasure{
+		{CountsInTheBucket: 2, LowEndOfLatencyBucket: pfloat(0), HighEndOfLatencyBucket: pfloat(100)},
+		{CountsInTheBucket: 5, LowEndOfLatencyBucket: pfloat(100), HighEndOfLatencyBucket: pfloat(500)},
+		// Extrapolated counts may decrease
+		{CountsInTheBucket: 0, LowEndOfLatencyBucket: pfloat(500), HighEndOfLatencyBucket: pfloat(1000)},
+		{CountsInTheBucket: 3, LowEndOfLatencyBucket: pfloat(1000)},
+	}, buckets)
+}
+
+func (s *HistogramTestSuite) TestCollectHistogram() {
+	api := APIMock{}
+	collector := Collector{
+		state: &inMemState{},
+		api:   &api,
+		rules: config.MetricRules{
+			Metrics: []config.MetricRule{
+				{
+					Expr:      "increase(latency_bucket[{{.interval}}s])",
+					Target:    "LatencyDistribution",
+					VMIDLabel: "{{.labels.VNFC}}",
+					Histogram: config.HistogramRule{
+						Scale: 1000,
+						Sum:   "increase(latency_sum[{{.interval}}s])",
+						Count: "increase(latency_count[{{.interval}}s])",
+					},
+				},
+			},
+		},
+		evtCfg:      &govel.EventConfiguration{VNFName: "VNFName", NfNamingCode: "hsxp"},
+		namingCodes: map[string]string{},
+	}
+
+	series := func(vnfc, le string, values ...float64) *model.SampleStream {
+		stream := &model.SampleStream{Metric: model.Metric{"VNFC": model.LabelValue(vnfc)}}
+		if le != "" {
+			stream.Metric[bucketLabel] = model.LabelValue(le)
+		}
+		for i, v := range values {
+			stream.Values = append(stream.Values, model.SamplePair{Timestamp: model.TimeFromUnix(int64(10 * (i + 1))), Value: model.SampleValue(v)})
+		}
+		return stream
+	}
+	api.On("QueryRange", mock.Anything, "increase(latency_bucket[10s])", mock.Anything).Once().Return(model.Matrix{
+		series("ope-1", "+Inf", 4, 10),
+		series("ope-1", "0.5", 3, 8),
+		series("ope-1", "0.1", 1, 2),
+		// Series of the same VM are summed
+		series("ope-1", "0.1", 1, 0),
+		series("ope-2", "0.1", 5),
+		series("ope-2", "+Inf", 5),
+		series("ope-2", "invalid", 5),
+	}, nil)
+	api.On("QueryRange", mock.Anything, "increase(latency_sum[10s])", mock.Anything).Once().Return(model.Matrix{
+		series("ope-1", "", 1, 3),
+		series("ope-2", "", 0.25),
+	}, nil)
+	api.On("QueryRange", mock.Anything, "increase(latency_count[10s])", mock.Anything).Once().Return(model.Matrix{
+		series("ope-1", "", 4, 10),
+		series("ope-2", "", 0),
+	}, nil)
+
+	meas, err := collector.CollectMetrics(time.Unix(0, 0), time.Unix(20, 0), 10*time.Second)
+	s.NoError(err)
+	s.Len(meas, 3)
+	s.Equal("ope-1", meas[0].SourceName)
+	s.EqualValues(10000000, meas[0].LastEpochMicrosec)
+	s.Equal([]govel.LatencyBucketMeasure{
+		{CountsInTheBucket: 2, LowEndOfLatencyBucket: pfloat(0), HighEndOfLatencyBucket: pfloat(100)},
+		{CountsInTheBucket: 1, LowEndOfLatencyBucket: pfloat(100), HighEndOfLatencyBucket: pfloat(500)},
+		{CountsInTheBucket: 1, LowEndOfLatencyBucket: pfloat(500)},
+	}, meas[0].LatencyDistribution)
+	s.Equal(250.0, *meas[0].MeanRequestLatency)
+	s.Equal("ope-1", meas[1].SourceName)
+	s.EqualValues(20000000, meas[1].LastEpochMicrosec)
+	s.Equal([]govel.LatencyBucketMeasure{
+		{CountsInTheBucket: 2, LowEndOfLatencyBucket: pfloat(0), HighEndOfLatencyBucket: pfloat(100)},
+		{CountsInTheBucket: 6, LowEndOfLatencyBucket: pfloat(100), HighEndOfLatencyBucket: pfloat(500)},
+		{CountsInTheBucket: 2, LowEndOfLatencyBucket: pfloat(500)},
+	}, meas[1].LatencyDistribution)
+	s.Equal(300.0, *meas[1].MeanRequestLatency)
+	s.Equal("ope-2", meas[2].SourceName)
+	s.Equal([]govel.LatencyBucketMeasure{
+		{CountsInTheBucket: 5, LowEndOfLatencyBucket: pfloat(0), HighEndOfLatencyBucket: pfloat(100)},
+		{CountsInTheBucket: 0, LowEndOfLatencyBucket: pfloat(100)},
+	}, meas[2].LatencyDistribution)
+	// No mean latency without observations
+	s.Nil(meas[2].MeanRequestLatency)
+	api.AssertExpectations(s.T())
+}
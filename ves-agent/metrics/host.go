@@ -0,0 +1,325 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sectorSize is the size of the sectors counted in /proc/diskstats
+const sectorSize = 512
+
+// hostSample is a sample of the cumulative statistics of the host
+type hostSample struct {
+	time   time.Time
+	cpus   map[string]cpuStats
+	disks  map[string]diskStats
+	ifaces map[string]netStats
+}
+
+// HostSource collects the metrics of the host from procfs and statfs, and fills the
+// CPU, memory, disk, filesystem and network interface usages of measurement events
+type HostSource struct {
+	procRoot    string
+	vmID        string
+	filesystems []string
+	disks       map[string]bool // Reported disks. All but loop and ram devices if nil
+	interfaces  map[string]bool // Reported network interfaces. All but lo if nil
+	statfs      func(path string) (total, used float64, err error)
+	now         func() time.Time
+	prev        *hostSample // Sample of the previous collection, rates are computed from
+}
+
+// toSet returns the set of `names`, or nil if empty
+func toSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// NewHostSource creates a new host metrics source from provided configuration
+func NewHostSource(conf *config.HostMetricsConfiguration) (*HostSource, error) {
+	host := &HostSource{
+		procRoot:    conf.ProcRoot,
+		vmID:        conf.VMID,
+		filesystems: conf.Filesystems,
+		disks:       toSet(conf.Disks),
+		interfaces:  toSet(conf.Interfaces),
+		statfs:      statfs,
+		now:         time.Now,
+	}
+	if host.procRoot == "" {
+		host.procRoot = "/proc"
+	}
+	if host.vmID == "" {
+		var err error
+		if host.vmID, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	if len(host.filesystems) == 0 {
+		host.filesystems = []string{"/"}
+	}
+	return host, nil
+}
+
+// reportDisk returns true if the disk `name` is reported
+func (host *HostSource) reportDisk(name string) bool {
+	if host.disks != nil {
+		return host.disks[name]
+	}
+	return !strings.HasPrefix(name, "loop") && !strings.HasPrefix(name, "ram")
+}
+
+// reportInterface returns true if the network interface `name` is reported
+func (host *HostSource) reportInterface(name string) bool {
+	if host.interfaces != nil {
+		return host.interfaces[name]
+	}
+	return name != "lo"
+}
+
+// Collect reads the host statistics, and fills the measurement event of the host at `timestamp`.
+// Rates are computed over the time elapsed since the previous collection, so CPU and disk usages,
+// and network interface deltas, are missing from the first one. Statistics which cannot be read are skipped
+func (host *HostSource) Collect(metrics *EventMeasurementSetBuilder, timestamp time.Time) error {
+	meas, err := metrics.Measurement(host.vmID, timestamp)
+	if err != nil {
+		return err
+	}
+	sample := &hostSample{time: host.now()}
+	if sample.cpus, err = readCPUStats(host.procRoot); err != nil {
+		log.Warnf("Host metrics: cannot read CPU statistics: %s", err.Error())
+	}
+	if sample.disks, err = readDiskStats(host.procRoot); err != nil {
+		log.Warnf("Host metrics: cannot read disk statistics: %s", err.Error())
+	}
+	if sample.ifaces, err = readNetStats(host.procRoot); err != nil {
+		log.Warnf("Host metrics: cannot read network interface statistics: %s", err.Error())
+	}
+	if mem, err := readMeminfo(host.procRoot); err != nil {
+		log.Warnf("Host metrics: cannot read memory statistics: %s", err.Error())
+	} else {
+		meas.MemoryUsageArray = append(meas.MemoryUsageArray, host.memoryUsage(mem))
+	}
+	meas.FilesystemUsageArray = append(meas.FilesystemUsageArray, host.filesystemUsages()...)
+	meas.VNICPerformanceArray = append(meas.VNICPerformanceArray, host.vnicPerformances(sample)...)
+	if prev := host.prev; prev != nil {
+		if elapsed := sample.time.Sub(prev.time).Seconds(); elapsed > 0 {
+			meas.CPUUsageArray = append(meas.CPUUsageArray, cpuUsages(prev.cpus, sample.cpus)...)
+			meas.DiskUsageArray = append(meas.DiskUsageArray, host.diskUsages(prev.disks, sample.disks, elapsed)...)
+		}
+	}
+	host.prev = sample
+	return nil
+}
+
+// sortedKeys returns the keys of a map of statistics, sorted
+func sortedKeys(stats interface{}) []string {
+	keys := []string{}
+	switch m := stats.(type) {
+	case map[string]cpuStats:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]diskStats:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]netStats:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ref returns a pointer to `v`
+func ref(v float64) *float64 {
+	return &v
+}
+
+// memoryUsage returns the memory usage from the statistics `mem` of /proc/meminfo
+func (host *HostSource) memoryUsage(mem map[string]float64) govel.MemoryUsage {
+	slab := mem["SReclaimable"] + mem["SUnreclaim"]
+	return govel.MemoryUsage{
+		VMIdentifier:     host.vmID,
+		MemoryConfigured: ref(mem["MemTotal"]),
+		MemoryFree:       mem["MemFree"],
+		MemoryBuffered:   ref(mem["Buffers"]),
+		MemoryCached:     ref(mem["Cached"]),
+		MemorySlabRecl:   ref(mem["SReclaimable"]),
+		MemorySlabUnrecl: ref(mem["SUnreclaim"]),
+		MemoryUsed:       mem["MemTotal"] - mem["MemFree"] - mem["Buffers"] - mem["Cached"] - slab,
+	}
+}
+
+// filesystemUsages returns the usages of the reported filesystems, in gigabytes
+func (host *HostSource) filesystemUsages() []govel.FilesystemUsage {
+	usages := []govel.FilesystemUsage{}
+	for _, path := range host.filesystems {
+		total, used, err := host.statfs(path)
+		if err != nil {
+			log.Warnf("Host metrics: cannot read statistics of filesystem %s: %s", path, err.Error())
+			continue
+		}
+		usages = append(usages, govel.FilesystemUsage{FilesystemName: path, BlockConfigured: total / 1e9, BlockUsed: used / 1e9})
+	}
+	return usages
+}
+
+// cpuUsages returns the usages of the CPUs between samples `prev` and `cur`, in percents.
+// The aggregate of all CPUs is identified as "all", and the others by their number
+func cpuUsages(prev, cur map[string]cpuStats) []govel.CPUUsage {
+	usages := []govel.CPUUsage{}
+	for _, name := range sortedKeys(cur) {
+		before, ok := prev[name]
+		if !ok {
+			continue
+		}
+		after := cur[name]
+		total := after.total() - before.total()
+		if total <= 0 {
+			continue
+		}
+		percent := func(after, before float64) *float64 {
+			return ref(100 * (after - before) / total)
+		}
+		id := strings.TrimPrefix(name, "cpu")
+		if id == "" {
+			id = "all"
+		}
+		usage := govel.CPUUsage{
+			CPUIdentifier:     id,
+			CPUIdle:           percent(after.idle, before.idle),
+			CPUUsageInterrupt: percent(after.irq, before.irq),
+			CPUUsageNice:      percent(after.nice, before.nice),
+			CPUUsageSoftIRQ:   percent(after.softirq, before.softirq),
+			CPUUsageSteal:     percent(after.steal, before.steal),
+			CPUUsageSystem:    percent(after.system, before.system),
+			CPUUsageUser:      percent(after.user, before.user),
+			CPUWait:           percent(after.iowait, before.iowait),
+		}
+		usage.PercentUsage = 100 - *usage.CPUIdle - *usage.CPUWait
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// diskUsages returns the usages of the reported disks between samples `prev` and `cur`,
+// taken `elapsed` seconds apart
+func (host *HostSource) diskUsages(prev, cur map[string]diskStats, elapsed float64) []govel.DiskUsage {
+	usages := []govel.DiskUsage{}
+	for _, name := range sortedKeys(cur) {
+		before, ok := prev[name]
+		if !ok || !host.reportDisk(name) {
+			continue
+		}
+		after := cur[name]
+		if after.reads < before.reads || after.writes < before.writes || after.ioTime < before.ioTime {
+			// Counters reset
+			continue
+		}
+		rate := func(after, before float64) *float64 {
+			return ref((after - before) / elapsed)
+		}
+		usage := govel.DiskUsage{
+			DiskIdentifier:            name,
+			DiskOpsReadAvg:            rate(after.reads, before.reads),
+			DiskOpsWriteAvg:           rate(after.writes, before.writes),
+			DiskMergedReadAvg:         rate(after.readsMerged, before.readsMerged),
+			DiskMergedWriteAvg:        rate(after.writesMerged, before.writesMerged),
+			DiskOctetsReadAvg:         rate(sectorSize*after.sectorsRead, sectorSize*before.sectorsRead),
+			DiskOctetsWriteAvg:        rate(sectorSize*after.sectorsWritten, sectorSize*before.sectorsWritten),
+			DiskIoTimeAvg:             rate(after.ioTime, before.ioTime),
+			DiskPendingOperationsLast: ref(after.inProgress),
+		}
+		if reads := after.reads - before.reads; reads > 0 {
+			usage.DiskTimeReadAvg = ref((after.readTime - before.readTime) / reads)
+		}
+		if writes := after.writes - before.writes; writes > 0 {
+			usage.DiskTimeWriteAvg = ref((after.writeTime - before.writeTime) / writes)
+		}
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// vnicPerformances returns the accumulated counters of the reported network interfaces in `sample`,
+// with their deltas since the previous sample, if any. Values are suspect when counters were reset
+func (host *HostSource) vnicPerformances(sample *hostSample) []govel.VNICPerformance {
+	perfs := []govel.VNICPerformance{}
+	for _, name := range sortedKeys(sample.ifaces) {
+		if !host.reportInterface(name) {
+			continue
+		}
+		after := sample.ifaces[name]
+		perf := govel.VNICPerformance{
+			VNICIdentifier:                         name,
+			ValuesAreSuspect:                       govel.False,
+			ReceivedOctetsAccumulated:              ref(after.rxBytes),
+			ReceivedTotalPacketsAccumulated:        ref(after.rxPackets),
+			ReceivedErrorPacketsAccumulated:        ref(after.rxErrors),
+			ReceivedDiscardedPacketsAccumulated:    ref(after.rxDropped),
+			ReceivedMulticastPacketsAccumulated:    ref(after.rxMulticast),
+			TransmittedOctetsAccumulated:           ref(after.txBytes),
+			TransmittedTotalPacketsAccumulated:     ref(after.txPackets),
+			TransmittedErrorPacketsAccumulated:     ref(after.txErrors),
+			TransmittedDiscardedPacketsAccumulated: ref(after.txDropped),
+		}
+		var before netStats
+		ok := host.prev != nil
+		if ok {
+			before, ok = host.prev.ifaces[name]
+		}
+		switch {
+		case !ok:
+		case after.rxBytes < before.rxBytes || after.rxPackets < before.rxPackets ||
+			after.txBytes < before.txBytes || after.txPackets < before.txPackets:
+			perf.ValuesAreSuspect = govel.True
+		default:
+			delta := func(after, before float64) *float64 {
+				return ref(after - before)
+			}
+			perf.ReceivedOctetsDelta = delta(after.rxBytes, before.rxBytes)
+			perf.ReceivedTotalPacketsDelta = delta(after.rxPackets, before.rxPackets)
+			perf.ReceivedErrorPacketsDelta = delta(after.rxErrors, before.rxErrors)
+			perf.ReceivedDiscardedPacketsDelta = delta(after.rxDropped, before.rxDropped)
+			perf.ReceivedMulticastPacketsDelta = delta(after.rxMulticast, before.rxMulticast)
+			perf.TransmittedOctetsDelta = delta(after.txBytes, before.txBytes)
+			perf.TransmittedTotalPacketsDelta = delta(after.txPackets, before.txPackets)
+			perf.TransmittedErrorPacketsDelta = delta(after.txErrors, before.txErrors)
+			perf.TransmittedDiscardedPacketsDelta = delta(after.txDropped, before.txDropped)
+		}
+		perfs = append(perfs, perf)
+	}
+	return perfs
+}
@@ -0,0 +1,218 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HostTestSuite struct {
+	suite.Suite
+	host *HostSource
+	now  time.Time
+}
+
+func TestHost(t *testing.T) {
+	suite.Run(t, new(HostTestSuite))
+}
+
+func (suite *HostTestSuite) SetupTest() {
+	var err error
+	suite.host, err = NewHostSource(&config.HostMetricsConfiguration{ProcRoot: "testdata/proc/t0", VMID: "vm1", Filesystems: []string{"/", "/data"}})
+	suite.Require().NoError(err)
+	suite.now = time.Unix(1560000000, 0)
+	suite.host.now = func() time.Time { return suite.now }
+	suite.host.statfs = func(path string) (float64, float64, error) {
+		if path == "/data" {
+			return 0, 0, errors.New("No such file or directory")
+		}
+		return 100e9, 25e9, nil
+	}
+}
+
+// collect collects the host metrics, and returns the measurement event
+func (suite *HostTestSuite) collect() *govel.EventMeasurements {
+	timestamp := time.Now()
+	metrics := NewEventMeasurementSetBuilder(MeasurementFactoryFunc(func(vmID string, timestamp time.Time) (*govel.EventMeasurements, error) {
+		return govel.NewMeasurements("test", "id", vmID, time.Minute, timestamp.Add(-time.Minute), timestamp), nil
+	}))
+	suite.Require().NoError(suite.host.Collect(&metrics, timestamp))
+	meas := metrics.Measurements()
+	suite.Require().Len(meas, 1)
+	suite.Equal("vm1", meas[0].SourceName)
+	return meas[0]
+}
+
+func (suite *HostTestSuite) TestDefaults() {
+	host, err := NewHostSource(&config.HostMetricsConfiguration{})
+	suite.Require().NoError(err)
+	suite.Equal("/proc", host.procRoot)
+	suite.NotEmpty(host.vmID)
+	suite.Equal([]string{"/"}, host.filesystems)
+	suite.True(host.reportDisk("sda"))
+	suite.False(host.reportDisk("loop0"))
+	suite.False(host.reportDisk("ram0"))
+	suite.True(host.reportInterface("eth0"))
+	suite.False(host.reportInterface("lo"))
+
+	host, err = NewHostSource(&config.HostMetricsConfiguration{Disks: []string{"sdb"}, Interfaces: []string{"lo"}})
+	suite.Require().NoError(err)
+	suite.False(host.reportDisk("sda"))
+	suite.True(host.reportDisk("sdb"))
+	suite.False(host.reportInterface("eth0"))
+	suite.True(host.reportInterface("lo"))
+}
+
+func (suite *HostTestSuite) TestFirstCollection() {
+	meas := suite.collect()
+
+	suite.Require().Len(meas.MemoryUsageArray, 1)
+	mem := meas.MemoryUsageArray[0]
+	suite.Equal("vm1", mem.VMIdentifier)
+	suite.Equal(8000000.0, *mem.MemoryConfigured)
+	suite.Equal(2000000.0, mem.MemoryFree)
+	suite.Equal(500000.0, *mem.MemoryBuffered)
+	suite.Equal(2500000.0, *mem.MemoryCached)
+	suite.Equal(300000.0, *mem.MemorySlabRecl)
+	suite.Equal(200000.0, *mem.MemorySlabUnrecl)
+	suite.Equal(2500000.0, mem.MemoryUsed)
+
+	suite.Equal([]govel.FilesystemUsage{{FilesystemName: "/", BlockConfigured: 100, BlockUsed: 25}}, meas.FilesystemUsageArray)
+
+	suite.Require().Len(meas.VNICPerformanceArray, 1)
+	vnic := meas.VNICPerformanceArray[0]
+	suite.Equal("eth0", vnic.VNICIdentifier)
+	suite.Equal(govel.False, vnic.ValuesAreSuspect)
+	suite.Equal(100000.0, *vnic.ReceivedOctetsAccumulated)
+	suite.Equal(1000.0, *vnic.ReceivedTotalPacketsAccumulated)
+	suite.Equal(1.0, *vnic.ReceivedErrorPacketsAccumulated)
+	suite.Equal(2.0, *vnic.ReceivedDiscardedPacketsAccumulated)
+	suite.Equal(10.0, *vnic.ReceivedMulticastPacketsAccumulated)
+	suite.Equal(50000.0, *vnic.TransmittedOctetsAccumulated)
+	suite.Equal(500.0, *vnic.TransmittedTotalPacketsAccumulated)
+	suite.Equal(3.0, *vnic.TransmittedErrorPacketsAccumulated)
+	suite.Equal(4.0, *vnic.TransmittedDiscardedPacketsAccumulated)
+	suite.Nil(vnic.ReceivedOctetsDelta)
+
+	// Rates need a previous collection
+	suite.Empty(meas.CPUUsageArray)
+	suite.Empty(meas.DiskUsageArray)
+}
+
+func (suite *HostTestSuite) TestRates() {
+	suite.collect()
+	suite.host.procRoot = "testdata/proc/t1"
+	suite.now = suite.now.Add(10 * time.Second)
+	meas := suite.collect()
+
+	suite.Require().Len(meas.CPUUsageArray, 3)
+	for i, id := range []string{"all", "0", "1"} {
+		cpu := meas.CPUUsageArray[i]
+		suite.Equal(id, cpu.CPUIdentifier)
+		suite.InDelta(30.0, cpu.PercentUsage, 1e-9)
+		suite.InDelta(20.0, *cpu.CPUUsageUser, 1e-9)
+		suite.InDelta(10.0, *cpu.CPUUsageSystem, 1e-9)
+		suite.InDelta(70.0, *cpu.CPUIdle, 1e-9)
+		suite.InDelta(0.0, *cpu.CPUWait, 1e-9)
+		suite.InDelta(0.0, *cpu.CPUUsageNice, 1e-9)
+	}
+
+	suite.Require().Len(meas.DiskUsageArray, 2)
+	disk := meas.DiskUsageArray[0]
+	suite.Equal("sda", disk.DiskIdentifier)
+	suite.Equal(10.0, *disk.DiskOpsReadAvg)
+	suite.Equal(10.0, *disk.DiskOpsWriteAvg)
+	suite.Equal(2.0, *disk.DiskMergedReadAvg)
+	suite.Equal(1.0, *disk.DiskMergedWriteAvg)
+	suite.Equal(1024000.0, *disk.DiskOctetsReadAvg)
+	suite.Equal(1024000.0, *disk.DiskOctetsWriteAvg)
+	suite.Equal(100.0, *disk.DiskIoTimeAvg)
+	suite.Equal(4.0, *disk.DiskTimeReadAvg)
+	suite.Equal(10.0, *disk.DiskTimeWriteAvg)
+	suite.Equal(3.0, *disk.DiskPendingOperationsLast)
+	// Idle disk
+	disk = meas.DiskUsageArray[1]
+	suite.Equal("sda1", disk.DiskIdentifier)
+	suite.Equal(0.0, *disk.DiskOpsReadAvg)
+	suite.Nil(disk.DiskTimeReadAvg)
+
+	suite.Require().Len(meas.VNICPerformanceArray, 1)
+	vnic := meas.VNICPerformanceArray[0]
+	suite.Equal(1000000000.0, *vnic.ReceivedOctetsAccumulated)
+	suite.Equal(999900000.0, *vnic.ReceivedOctetsDelta)
+	suite.Equal(1000.0, *vnic.ReceivedTotalPacketsDelta)
+	suite.Equal(0.0, *vnic.ReceivedErrorPacketsDelta)
+	suite.Equal(1.0, *vnic.ReceivedDiscardedPacketsDelta)
+	suite.Equal(5.0, *vnic.ReceivedMulticastPacketsDelta)
+	suite.Equal(10000.0, *vnic.TransmittedOctetsDelta)
+	suite.Equal(100.0, *vnic.TransmittedTotalPacketsDelta)
+
+	// Counters going backwards are reset counters
+	suite.host.procRoot = "testdata/proc/t0"
+	suite.now = suite.now.Add(10 * time.Second)
+	meas = suite.collect()
+	suite.Equal(govel.True, meas.VNICPerformanceArray[0].ValuesAreSuspect)
+	suite.Nil(meas.VNICPerformanceArray[0].ReceivedOctetsDelta)
+	suite.Require().Len(meas.DiskUsageArray, 1)
+	suite.Equal("sda1", meas.DiskUsageArray[0].DiskIdentifier)
+}
+
+func (suite *HostTestSuite) TestMissingStatistics() {
+	suite.host.procRoot = "testdata/proc/missing"
+	meas := suite.collect()
+	suite.Empty(meas.MemoryUsageArray)
+	suite.Empty(meas.VNICPerformanceArray)
+	suite.Len(meas.FilesystemUsageArray, 1)
+}
+
+func (suite *HostTestSuite) TestCollector() {
+	conf := config.MeasurementConfiguration{
+		DomainAbbreviation: "Mvfs",
+		Host:               config.HostMetricsConfiguration{Enabled: true, ProcRoot: "testdata/proc/t0", VMID: "vm1"},
+	}
+	col, err := NewCollector(&conf, &govel.EventConfiguration{VNFName: "vnf", NfNamingCode: "hspx"}, map[string]string{})
+	suite.Require().NoError(err)
+	suite.Require().NotNil(col.host)
+	col.host.statfs = suite.host.statfs
+
+	to := time.Now()
+	meas, err := col.CollectMetrics(to.Add(-time.Minute), to, time.Minute)
+	suite.Require().NoError(err)
+	suite.Require().Len(meas, 1)
+	suite.Equal("vm1", meas[0].SourceName)
+	suite.Equal(to.UnixNano()/1000, meas[0].LastEpochMicrosec)
+	suite.Len(meas[0].MemoryUsageArray, 1)
+}
+
+func (suite *HostTestSuite) TestParse() {
+	_, err := readCPUStats("testdata")
+	suite.Error(err)
+	cpus, err := readCPUStats("testdata/proc/t0")
+	suite.NoError(err)
+	suite.Len(cpus, 3)
+	ifaces, err := readNetStats("testdata/proc/t1")
+	suite.NoError(err)
+	suite.Equal(1000000000.0, ifaces["eth0"].rxBytes)
+	suite.Equal(2000.0, ifaces["eth0"].rxPackets)
+}
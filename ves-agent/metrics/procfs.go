@@ -0,0 +1,170 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cpuStats are the cumulative times spent by a CPU in each mode, in USER_HZ
+type cpuStats struct {
+	user, nice, system, idle, iowait, irq, softirq, steal float64
+}
+
+// total returns the sum of the times of all modes
+func (stats cpuStats) total() float64 {
+	return stats.user + stats.nice + stats.system + stats.idle + stats.iowait + stats.irq + stats.softirq + stats.steal
+}
+
+// diskStats are the cumulative I/O statistics of a disk, from /proc/diskstats
+type diskStats struct {
+	reads, readsMerged, sectorsRead, readTime       float64
+	writes, writesMerged, sectorsWritten, writeTime float64
+	inProgress, ioTime                              float64
+}
+
+// netStats are the cumulative statistics of a network interface, from /proc/net/dev
+type netStats struct {
+	rxBytes, rxPackets, rxErrors, rxDropped, rxMulticast float64
+	txBytes, txPackets, txErrors, txDropped              float64
+}
+
+// scanFile calls `line` for each line of file `name` of procfs root `root`
+func scanFile(root, name string, line func(fields []string) error) error {
+	file, err := os.Open(filepath.Join(root, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := line(strings.Fields(scanner.Text())); err != nil {
+			return fmt.Errorf("Cannot parse %s: %s", name, err.Error())
+		}
+	}
+	return scanner.Err()
+}
+
+// parseFloats parses the numbers of `fields`
+func parseFloats(fields []string) ([]float64, error) {
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = float64(v)
+	}
+	return values, nil
+}
+
+// readCPUStats reads the statistics of each CPU from /proc/stat. The aggregate of all CPUs is named "cpu"
+func readCPUStats(root string) (map[string]cpuStats, error) {
+	cpus := map[string]cpuStats{}
+	err := scanFile(root, "stat", func(fields []string) error {
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			return nil
+		}
+		if len(fields) < 5 {
+			return fmt.Errorf("too few fields for %s", fields[0])
+		}
+		// Older kernels do not report all the modes
+		values, err := parseFloats(fields[1:])
+		if err != nil {
+			return err
+		}
+		values = append(values, make([]float64, 8)...)
+		cpus[fields[0]] = cpuStats{
+			user: values[0], nice: values[1], system: values[2], idle: values[3],
+			iowait: values[4], irq: values[5], softirq: values[6], steal: values[7],
+		}
+		return nil
+	})
+	return cpus, err
+}
+
+// readMeminfo reads the memory statistics from /proc/meminfo, in kibibytes
+func readMeminfo(root string) (map[string]float64, error) {
+	mem := map[string]float64{}
+	err := scanFile(root, "meminfo", func(fields []string) error {
+		if len(fields) < 2 {
+			return nil
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		mem[strings.TrimSuffix(fields[0], ":")] = float64(v)
+		return nil
+	})
+	return mem, err
+}
+
+// readDiskStats reads the statistics of each disk from /proc/diskstats
+func readDiskStats(root string) (map[string]diskStats, error) {
+	disks := map[string]diskStats{}
+	err := scanFile(root, "diskstats", func(fields []string) error {
+		if len(fields) < 14 {
+			return nil
+		}
+		v, err := parseFloats(fields[3:14])
+		if err != nil {
+			return err
+		}
+		disks[fields[2]] = diskStats{
+			reads: v[0], readsMerged: v[1], sectorsRead: v[2], readTime: v[3],
+			writes: v[4], writesMerged: v[5], sectorsWritten: v[6], writeTime: v[7],
+			inProgress: v[8], ioTime: v[9],
+		}
+		return nil
+	})
+	return disks, err
+}
+
+// readNetStats reads the statistics of each network interface from /proc/net/dev
+func readNetStats(root string) (map[string]netStats, error) {
+	ifaces := map[string]netStats{}
+	err := scanFile(root, "net/dev", func(fields []string) error {
+		if len(fields) == 0 || !strings.Contains(fields[0], ":") {
+			// Headers
+			return nil
+		}
+		// The name and the first counter are not separated when the counter is large
+		name := fields[0][:strings.Index(fields[0], ":")]
+		if rest := fields[0][len(name)+1:]; rest != "" {
+			fields = append([]string{name, rest}, fields[1:]...)
+		}
+		if len(fields) < 17 {
+			return fmt.Errorf("too few fields for %s", name)
+		}
+		v, err := parseFloats(fields[1:17])
+		if err != nil {
+			return err
+		}
+		ifaces[name] = netStats{
+			rxBytes: v[0], rxPackets: v[1], rxErrors: v[2], rxDropped: v[3], rxMulticast: v[7],
+			txBytes: v[8], txPackets: v[9], txErrors: v[10], txDropped: v[11],
+		}
+		return nil
+	})
+	return ifaces, err
+}
@@ -0,0 +1,117 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// queryResult is the result of a Prometheus range query
+type queryResult struct {
+	matrix model.Matrix
+	err    error
+}
+
+// query is a range query of an expanded expression, sent to a selection of Prometheus sources
+type query struct {
+	sources string // Comma separated names of the sources queried, in failover order. All sources if empty
+	expr    string // Expanded query expression
+}
+
+// ruleQuery returns the query of the expanded expression `expr` of `rule`
+func ruleQuery(rule config.MetricRule, expr string) query {
+	return query{sources: strings.Join(rule.Sources, ","), expr: expr}
+}
+
+// queryResults are the results of the range queries of a collection
+type queryResults map[query]queryResult
+
+// matrix returns the result of the query `expr` of `rule`
+func (results queryResults) matrix(rule config.MetricRule, expr string) (model.Matrix, error) {
+	res, ok := results[ruleQuery(rule, expr)]
+	if !ok {
+		return nil, fmt.Errorf("Query %s has not been run", expr)
+	}
+	return res.matrix, res.err
+}
+
+// ruleQueries returns the queries of `rules` over `rng`, in rules order.
+// Identical queries are only returned once. Expressions which cannot be expanded are
+// skipped, their rule failing when evaluated
+func (col *Collector) ruleQueries(rules []config.MetricRule, rng v1.Range) []query {
+	data := map[string]interface{}{"interval": int(rng.Step.Seconds())}
+	seen := make(map[query]bool)
+	queries := []query{}
+	for _, rule := range rules {
+		exprs := []string{rule.Expr}
+		if rule.Target == "LatencyDistribution" && rule.Histogram.Sum != "" && rule.Histogram.Count != "" {
+			exprs = append(exprs, rule.Histogram.Sum, rule.Histogram.Count)
+		}
+		for _, e := range exprs {
+			expr, err := col.execTemplate(e, data, true)
+			if err != nil {
+				continue
+			}
+			if q := ruleQuery(rule, expr); !seen[q] {
+				seen[q] = true
+				queries = append(queries, q)
+			}
+		}
+	}
+	return queries
+}
+
+// runQueries runs the range queries `queries` over `rng`, with up to `col.workers` queries in parallel
+func (col *Collector) runQueries(queries []query, rng v1.Range) queryResults {
+	workers := col.workers
+	if workers > len(queries) {
+		workers = len(queries)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]queryResult, len(queries))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].matrix, results[i].err = col.runQuery(queries[i], rng)
+			}
+		}()
+	}
+	for i := range queries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	res := make(queryResults, len(queries))
+	for i, q := range queries {
+		res[q] = results[i]
+	}
+	return res
+}
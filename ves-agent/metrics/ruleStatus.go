@@ -0,0 +1,101 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RuleStatus is the collection status of a metric rule
+type RuleStatus struct {
+	Index            int       // Index of the rule in the configuration
+	Expr             string    // Query expression of the rule
+	Target           string    // Target of the rule
+	Failures         int       // Number of consecutive collections the rule failed in
+	TotalFailures    int       // Number of collections the rule failed in since startup
+	LastError        string    // Error of the last failure
+	LastFailure      time.Time // Time of the last failure. Zero if the rule never failed
+	QuarantinedUntil time.Time // End of the rule quarantine. Zero if the rule is not quarantined
+}
+
+// ruleStatuses returns the status of the collector rules, creating them on first use
+func (col *Collector) ruleStatuses() []RuleStatus {
+	if len(col.statuses) != len(col.rules.Metrics) {
+		col.statuses = make([]RuleStatus, len(col.rules.Metrics))
+		for i, rule := range col.rules.Metrics {
+			rule = rule.WithDefaults(col.rules.DefaultValues)
+			col.statuses[i] = RuleStatus{Index: i, Expr: rule.Expr, Target: rule.Target}
+		}
+	}
+	return col.statuses
+}
+
+// RuleStatuses returns a copy of the collection status of all metric rules
+func (col *Collector) RuleStatuses() []RuleStatus {
+	statuses := make([]RuleStatus, len(col.rules.Metrics))
+	copy(statuses, col.ruleStatuses())
+	return statuses
+}
+
+// quarantined tells if the rule with status `status` is quarantined at time `now`
+func (status *RuleStatus) quarantined(now time.Time) bool {
+	return status.QuarantinedUntil.After(now)
+}
+
+// failed records a failure of the rule with status `status` at time `now`. When `count` is true,
+// the failure counts for quarantine, and the rule is quarantined after `after` consecutive failures
+func (status *RuleStatus) failed(err error, now time.Time, count bool, after int, duration time.Duration) {
+	status.TotalFailures++
+	status.LastError = err.Error()
+	status.LastFailure = now
+	if !count {
+		return
+	}
+	status.Failures++
+	if after > 0 && status.Failures >= after {
+		status.QuarantinedUntil = now.Add(duration)
+		log.Errorf("Metric rule %d (%s) failed in %d consecutive collections, quarantined until %s", status.Index, status.Expr, status.Failures, status.QuarantinedUntil.Format(time.RFC3339))
+	}
+}
+
+// succeeded records a successful evaluation of the rule with status `status`
+func (status *RuleStatus) succeeded() {
+	status.Failures = 0
+	status.QuarantinedUntil = time.Time{}
+}
+
+// reportFailedRules adds the additional field `field` listing the expressions of the rules `failed`
+// to all events of `set`
+func reportFailedRules(set EventMeasurementSet, field string, failed []config.MetricRule) {
+	if field == "" || len(failed) == 0 {
+		return
+	}
+	exprs := make([]string, len(failed))
+	for i, rule := range failed {
+		exprs[i] = rule.Expr
+	}
+	value := strings.Join(exprs, "; ")
+	for _, evt := range set {
+		evt.AdditionalFields = append(evt.AdditionalFields, govel.Field{Name: field, Value: value})
+	}
+}
@@ -17,13 +17,13 @@
 package metrics
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
 	"strings"
 	"time"
-	"github.com/nokia/onap-vespa/govel"
 
+	"github.com/nokia/onap-vespa/govel"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -38,8 +38,7 @@ func (keys MeasKeys) String() string {
 			log.Panic(err)
 		}
 	}
-	s := bld.String()
-	return s[:len(s)-1]
+	return strings.TrimSuffix(bld.String(), ",")
 }
 
 // JSONObjectKeys transform this set of key/values pairs into a list
@@ -92,19 +91,35 @@ func (f MeasurementFactoryFunc) Create(vmID string, timestamp time.Time) (*govel
 	return f(vmID, timestamp)
 }
 
+// eventKey identifies a measurement event in a set
+type eventKey struct {
+	vmID      string
+	timestamp int64 // Last epoch, in microseconds
+}
+
 // EventMeasurementSetBuilder is an utility to construct a set of measurement events
 type EventMeasurementSetBuilder struct {
-	set      EventMeasurementSet // The measurement set under construction
-	provider MeasurementFactory  // A provider to create new govel.EventMeasurements
-	valid    bool                // Can this builder still be used ?
+	set      EventMeasurementSet                   // The measurement set under construction
+	events   map[eventKey]*govel.EventMeasurements // Events of the set, by VM and timestamp
+	slices   map[sliceKey]*sliceIndex              // Indexes of slice entries by composite key
+	setters  map[string]*FieldSetter               // Compiled field paths
+	provider MeasurementFactory                    // A provider to create new govel.EventMeasurements
+	valid    bool                                  // Can this builder still be used ?
+	saved    map[eventKey][]byte                   // Events modified since the checkpoint, as JSON at the checkpoint. Nil for created events, or without checkpoint
+	size     int                                   // Size of the set at the checkpoint
 }
 
 // NewEventMeasurementSetBuilder creates a new EventMeasurementSetBuilder using the provided
-// measurement factory. If an error occurs during value insertion, the full set will be
-// invalidated, and trying to use the EventMeasurementSetBuilder after that will cause a panic
+// measurement factory. Values which cannot be assigned to their field are rejected without
+// modifying the set. If another error occurs during value insertion, the full set will be
+// invalidated, and trying to use the EventMeasurementSetBuilder after that will cause a panic,
+// unless it's rolled back to its checkpoint
 func NewEventMeasurementSetBuilder(fact MeasurementFactory) EventMeasurementSetBuilder {
 	return EventMeasurementSetBuilder{
 		set:      EventMeasurementSet{},
+		events:   make(map[eventKey]*govel.EventMeasurements),
+		slices:   make(map[sliceKey]*sliceIndex),
+		setters:  make(map[string]*FieldSetter),
 		provider: fact,
 		valid:    true,
 	}
@@ -119,29 +134,106 @@ func (bld *EventMeasurementSetBuilder) checkValid() {
 
 func (bld *EventMeasurementSetBuilder) invalidate() {
 	// In case of error, invalidate this builder
-	// It must need to br created again
-	bld.set = EventMeasurementSet{}
+	// It must need to br created again, unless rolled back to its checkpoint
 	bld.valid = false
+	if bld.saved == nil {
+		bld.set = EventMeasurementSet{}
+		bld.events = nil
+		bld.slices = nil
+	}
+}
+
+// Checkpoint starts recording the changes to the set, for them to be discarded by Rollback.
+// The previous checkpoint, if any, is replaced
+func (bld *EventMeasurementSetBuilder) Checkpoint() {
+	bld.checkValid()
+	bld.saved = make(map[eventKey][]byte)
+	bld.size = len(bld.set)
+}
+
+// Rollback restores the set as it was at the checkpoint, including after an error invalidating the builder.
+// The checkpoint is kept
+func (bld *EventMeasurementSetBuilder) Rollback() error {
+	if bld.saved == nil {
+		return errors.New("EventMeasurementSetBuilder has no checkpoint")
+	}
+	modified := make(map[*govel.EventMeasurements]bool, len(bld.saved))
+	for key, data := range bld.saved {
+		evt := bld.events[key]
+		modified[evt] = true
+		if data == nil {
+			delete(bld.events, key)
+			continue
+		}
+		restored := govel.EventMeasurements{}
+		if err := json.Unmarshal(data, &restored); err != nil {
+			bld.saved = nil
+			bld.invalidate()
+			return err
+		}
+		*evt = restored
+	}
+	bld.set = bld.set[:bld.size]
+	// Indexes of the restored slices are rebuilt on next use
+	for key := range bld.slices {
+		if modified[key.event] {
+			delete(bld.slices, key)
+		}
+	}
+	bld.saved = make(map[eventKey][]byte)
+	bld.valid = true
+	return nil
+}
+
+// save records event `evt` with key `key` as it was at the checkpoint, before it's modified
+func (bld *EventMeasurementSetBuilder) save(key eventKey, evt *govel.EventMeasurements) error {
+	if bld.saved == nil {
+		return nil
+	}
+	if _, ok := bld.saved[key]; ok {
+		return nil
+	}
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	bld.saved[key] = data
+	return nil
 }
 
 // find returns a pointer to the EventMeasurement in the set for VM with id vmID with the provided timestamp.
 // If does not exist, creates it and store it before returning it
 func (bld *EventMeasurementSetBuilder) find(vmID string, timestamp time.Time) (*govel.EventMeasurements, error) {
-	t := timestamp.UnixNano() / 1000
-	for _, evt := range bld.set {
-		if evt.LastEpochMicrosec == t && evt.SourceName == vmID {
-			return evt, nil
+	key := eventKey{vmID: vmID, timestamp: timestamp.UnixNano() / 1000}
+	if evt, ok := bld.events[key]; ok {
+		if err := bld.save(key, evt); err != nil {
+			return nil, err
 		}
+		return evt, nil
 	}
 	evt, err := bld.provider.Create(vmID, timestamp)
 	if err != nil {
 		return nil, err
 	}
 	bld.set = append(bld.set, evt)
+	bld.events[key] = evt
+	if bld.saved != nil {
+		bld.saved[key] = nil
+	}
 	// evt can be returned since it's a pointer (bld.set holds pointer data)
 	return evt, nil
 }
 
+// Measurement returns the measurement event of VM with ID "vmID" at time "timestamp", creating it if needed.
+// It's used to fill the measurement structures directly, rather than field by field
+func (bld *EventMeasurementSetBuilder) Measurement(vmID string, timestamp time.Time) (*govel.EventMeasurements, error) {
+	bld.checkValid()
+	if len(vmID) == 0 {
+		return nil, errors.New("VmID cannot be empty")
+	}
+	return bld.find(vmID, timestamp)
+}
+
 // Measurements returns the built EventMeasurementSet
 func (bld *EventMeasurementSetBuilder) Measurements() EventMeasurementSet {
 	bld.checkValid()
@@ -149,26 +241,128 @@ func (bld *EventMeasurementSetBuilder) Measurements() EventMeasurementSet {
 }
 
 // Set sets the metric given by "fields" for VM with ID "vmID" at time "timestamp" with value "values".
-// "keys" are used when and array is encountered to select the entry (or set the entry when missing)
+// "keys" are used when and array is encountered to select the entry (or set the entry when missing).
+// Values set to integer fields are rounded to the nearest integer
 //
-// Returns an error or nil. If an error occurs, the EventMeasurementSetBuilder must not be used anymore
-// Panics if an error occurred in the previous call
+// Returns an error or nil. If "fields" is invalid, the EventMeasurementSetBuilder must not be used anymore
+// Panics if the EventMeasurementSetBuilder has been invalidated by a previous call
 func (bld *EventMeasurementSetBuilder) Set(fields string, vmID string, timestamp time.Time, value float64, keys MeasKeys) error {
+	return bld.SetRounded(fields, vmID, timestamp, value, RoundNearest, keys)
+}
+
+// SetRounded sets the metric given by "fields" like `Set`, rounding values set to integer fields with "rounding"
+func (bld *EventMeasurementSetBuilder) SetRounded(fields string, vmID string, timestamp time.Time, value float64, rounding string, keys MeasKeys) error {
+	setter, err := bld.compile(fields)
+	if err != nil {
+		return err
+	}
+	return bld.SetField(setter, vmID, timestamp, value, rounding, keys)
+}
+
+// SetString sets the string or enum field given by "fields" like `Set`. Values of enum fields are checked
+func (bld *EventMeasurementSetBuilder) SetString(fields string, vmID string, timestamp time.Time, value string, keys MeasKeys) error {
+	setter, err := bld.compile(fields)
+	if err != nil {
+		return err
+	}
+	return bld.SetFieldString(setter, vmID, timestamp, value, keys)
+}
+
+// SetField sets the metric with the compiled field path "setter" like `SetRounded`
+func (bld *EventMeasurementSetBuilder) SetField(setter *FieldSetter, vmID string, timestamp time.Time, value float64, rounding string, keys MeasKeys) error {
+	return bld.assign(setter, vmID, timestamp, numberAssigner(value, rounding), keys)
+}
+
+// SetFieldString sets the string or enum field with the compiled field path "setter" like `SetString`
+func (bld *EventMeasurementSetBuilder) SetFieldString(setter *FieldSetter, vmID string, timestamp time.Time, value string, keys MeasKeys) error {
+	return bld.assign(setter, vmID, timestamp, stringAssigner(value), keys)
+}
+
+// compile returns the compiled field path of "fields", compiling it on first use.
+// If "fields" is invalid, the EventMeasurementSetBuilder is invalidated
+func (bld *EventMeasurementSetBuilder) compile(fields string) (*FieldSetter, error) {
 	bld.checkValid()
+	if setter, ok := bld.setters[fields]; ok {
+		return setter, nil
+	}
 	if len(fields) == 0 {
-		return errors.New("Fields cannot be empty")
+		return nil, errors.New("Fields cannot be empty")
+	}
+	setter, err := CompileField(fields)
+	if err != nil {
+		bld.invalidate()
+		return nil, err
 	}
+	bld.setters[fields] = setter
+	return setter, nil
+}
+
+// assign assigns the field with the compiled path "setter" with "assign"
+func (bld *EventMeasurementSetBuilder) assign(setter *FieldSetter, vmID string, timestamp time.Time, assign assigner, keys MeasKeys) error {
+	bld.checkValid()
 	if len(vmID) == 0 {
 		return errors.New("VmID cannot be empty")
 	}
+	if err := setter.check(assign); err != nil {
+		return err
+	}
 	// Get or create the govel.EventMeasurements
 	metric, err := bld.find(vmID, timestamp)
 	if err != nil {
 		return err
 	}
-	subfields := strings.Split(fields, ".")
-	// Use reflection to set the structure field
-	err = setField(reflect.ValueOf(metric), subfields, value, keys)
+	err = setter.set(metric, bld.slices, assign, keys)
+	if err != nil {
+		bld.invalidate()
+	}
+	return err
+}
+
+// Compiled paths of the values of additional fields and additional measurements
+var (
+	additionalFieldValue       = mustCompileField("AdditionalFields.Value")
+	additionalMeasurementValue = mustCompileField("AdditionalMeasurements.ArrayOfFields.Value")
+)
+
+// mustCompileField compiles the field path `fields`, and panics if it's invalid
+func mustCompileField(fields string) *FieldSetter {
+	setter, err := CompileField(fields)
+	if err != nil {
+		log.Panic(err)
+	}
+	return setter
+}
+
+// SetAdditionalField sets the value of the additional field named "name" of VM with ID "vmID" at time "timestamp".
+// The field is appended to `AdditionalFields` when missing, and its value replaced otherwise
+func (bld *EventMeasurementSetBuilder) SetAdditionalField(vmID, name string, timestamp time.Time, value string) error {
+	if len(name) == 0 {
+		return errors.New("SetAdditionalField() - Name cannot be empty")
+	}
+	return bld.assign(additionalFieldValue, vmID, timestamp, stringAssigner(value), MeasKeys{"Name": name})
+}
+
+// SetAdditionalMeasurement sets the value of the field named "name" in the additional measurements array
+// named "array" of VM with ID "vmID" at time "timestamp". The array and field are appended when missing,
+// so that rules setting fields of the same array share it
+func (bld *EventMeasurementSetBuilder) SetAdditionalMeasurement(vmID, array, name string, timestamp time.Time, value string) error {
+	if len(array) == 0 || len(name) == 0 {
+		return errors.New("SetAdditionalMeasurement() - Names cannot be empty")
+	}
+	bld.checkValid()
+	if len(vmID) == 0 {
+		return errors.New("VmID cannot be empty")
+	}
+	assign := stringAssigner(value)
+	if err := additionalMeasurementValue.check(assign); err != nil {
+		return err
+	}
+	metric, err := bld.find(vmID, timestamp)
+	if err != nil {
+		return err
+	}
+	keys := []MeasKeys{{"Name": array}, {"Name": name}}
+	err = additionalMeasurementValue.setNested(metric, bld.slices, assign, func(n int) MeasKeys { return keys[n] })
 	if err != nil {
 		bld.invalidate()
 	}
@@ -213,107 +407,3 @@ func (bld *EventMeasurementSetBuilder) SetAdditionalObject(vmID, objectName, obj
 	instance.ObjectInstance[objectInstance] = value
 	return nil
 }
-
-/***********************************************************
- *                                                         *
- * Functions below are a set of helpers used to manipulate *
- * measurements structures using some reflection.          *
- * It clearly miss some comments for now :(                *
- *                                                         *
- ***********************************************************/
-
-func setField(parent reflect.Value, subfields []string, value float64, keys MeasKeys) error {
-	if !parent.IsValid() {
-		return errors.New("Cannot set field, parent is not valid")
-	}
-	// println(parent.Type().String())
-	if parent.Kind() == reflect.Ptr {
-		if parent.IsNil() {
-			newVal := reflect.New(parent.Type().Elem())
-			parent.Set(newVal)
-		}
-		return setField(parent.Elem(), subfields, value, keys)
-	}
-	if len(subfields) == 0 {
-		switch parent.Kind() {
-		case reflect.Float64, reflect.Float32:
-			parent.SetFloat(value)
-			return nil
-		default:
-			return fmt.Errorf("Cannot assign a float64 to %s", parent.Type().String())
-		}
-	} else {
-		switch parent.Kind() {
-		case reflect.Slice:
-			return setSliceField(parent, subfields, value, keys)
-		case reflect.Struct:
-			field := parent.FieldByName(subfields[0])
-			if !field.IsValid() {
-				return fmt.Errorf("Invalid field %s on type %s", subfields[0], parent.Type().String())
-			}
-			return setField(field, subfields[1:], value, keys)
-		default:
-			return fmt.Errorf("Cannot access subfields of type %s", parent.Type().String())
-		}
-	}
-}
-
-func setSliceField(parent reflect.Value, fields []string, value float64, keys MeasKeys) error {
-	// elemType := parent.Type().Elem()
-	// f, ok := elemType.FieldByName(fields[0])
-	// if !ok {
-	// 	return fmt.Errorf("Field %s does not exists on %s", fields[0], parent.Type().String())
-	// }
-	// if f.Type.Kind() != reflect.Float64 && f.Type.Kind() != reflect.Float32 {
-	// 	return fmt.Errorf("Field %s on %s is not a float (is %s)", fields[0], parent.Type().String(), f.Type.String())
-	// }
-
-	ref := sliceSearch(parent, keys)
-	if !ref.IsValid() {
-		ref = sliceAppendNew(parent, keys)
-	}
-	v := ref.FieldByName(fields[0])
-	return setField(v, fields[1:], value, keys)
-}
-
-// Search in the slice the element matching the composite key.
-// If not found, return a zero reflect.Value
-func sliceSearch(parent reflect.Value, keys MeasKeys) reflect.Value {
-searchLoop:
-	for i := 0; i < parent.Len(); i++ {
-		entry := parent.Index(i)
-		// compare all key/value pairs from composite key
-		for k, v := range keys {
-			field := entry.FieldByName(k)
-			if !field.IsValid() {
-				// Ignore if field does not exist en struct
-				continue
-			} else if field.String() != v {
-				continue searchLoop
-			}
-		}
-		return entry
-	}
-	return reflect.Value{}
-}
-
-// Create and append a new element with proper type and fields
-// initialized from composite key fields
-func sliceAppendNew(parent reflect.Value, keys MeasKeys) reflect.Value {
-	// Create a new Zero value with slice contained type
-	obj := reflect.New(parent.Type().Elem()).Elem()
-	// Set composite key fields
-	for k, v := range keys {
-		field := obj.FieldByName(k)
-		if !field.IsValid() {
-			// Ignore not found fields
-			continue
-		}
-		field.SetString(v)
-	}
-	// Append new element to slice
-	parent.Set(reflect.Append(parent, obj))
-	// return a reference to the element. It's really a pointer
-	// but a reflected value
-	return parent.Index(parent.Len() - 1)
-}
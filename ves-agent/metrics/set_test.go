@@ -17,9 +17,11 @@
 package metrics
 
 import (
+	"fmt"
+	"github.com/nokia/onap-vespa/govel"
+	"strconv"
 	"testing"
 	"time"
-	"github.com/nokia/onap-vespa/govel"
 
 	"github.com/stretchr/testify/assert"
 
@@ -170,6 +172,148 @@ func (s *MeasurementSetBuilderSuite) TestSetUnexistingSliceField() {
 	s.Error(err)
 }
 
+func (s *MeasurementSetBuilderSuite) TestSetInteger() {
+	bld := NewEventMeasurementSetBuilder(measTestFactory(10 * time.Second))
+	now := time.Now()
+	s.NoError(bld.Set("ConcurrentSessions", "id", now, 12.5, nil))
+	s.NoError(bld.SetRounded("ConfiguredEntities", "id", now, 12.5, RoundDown, nil))
+	s.NoError(bld.SetRounded("VnfcScalingMetric", "id", now, 12.1, RoundUp, nil))
+	s.NoError(bld.SetRounded("NumberOfMediaPortsInUse", "id", now, -12.9, RoundTrunc, nil))
+	s.NoError(bld.SetRounded("FeatureUsageArray.FeatureUtilization", "id", now, 41.6, RoundNearest, MeasKeys{"FeatureIdentifier": "f1"}))
+	meas := bld.Measurements()
+	s.Require().Len(meas, 1)
+	s.EqualValues(13, *meas[0].ConcurrentSessions)
+	s.EqualValues(12, *meas[0].ConfiguredEntities)
+	s.EqualValues(13, *meas[0].VnfcScalingMetric)
+	s.EqualValues(-12, *meas[0].NumberOfMediaPortsInUse)
+	s.Require().Len(meas[0].FeatureUsageArray, 1)
+	s.Equal("f1", meas[0].FeatureUsageArray[0].FeatureIdentifier)
+	s.EqualValues(42, meas[0].FeatureUsageArray[0].FeatureUtilization)
+
+	s.Error(bld.SetRounded("ConcurrentSessions", "id", now, 1e30, RoundNearest, nil))
+	bld = NewEventMeasurementSetBuilder(measTestFactory(10 * time.Second))
+	s.Error(bld.SetRounded("ConcurrentSessions", "id", now, 1, "up", nil))
+}
+
+func (s *MeasurementSetBuilderSuite) TestSetString() {
+	bld := NewEventMeasurementSetBuilder(measTestFactory(10 * time.Second))
+	now := time.Now()
+	s.NoError(bld.SetString("VNICPerformanceArray.ValuesAreSuspect", "id", now, "true", MeasKeys{"VNICIdentifier": "eth0"}))
+	meas := bld.Measurements()
+	s.Require().Len(meas[0].VNICPerformanceArray, 1)
+	s.Equal(govel.True, meas[0].VNICPerformanceArray[0].ValuesAreSuspect)
+
+	// Enum values are checked, and rejected values don't modify the set
+	s.Error(bld.SetString("VNICPerformanceArray.ValuesAreSuspect", "id", now, "maybe", MeasKeys{"VNICIdentifier": "eth0"}))
+	s.Error(bld.SetString("VNICPerformanceArray.ValuesAreSuspect", "id2", now, "maybe", MeasKeys{"VNICIdentifier": "eth1"}))
+	meas = bld.Measurements()
+	s.Len(meas, 1)
+	s.Len(meas[0].VNICPerformanceArray, 1)
+	s.Equal(govel.True, meas[0].VNICPerformanceArray[0].ValuesAreSuspect)
+	bld = NewEventMeasurementSetBuilder(measTestFactory(10 * time.Second))
+	s.Error(bld.SetString("CPUUsageArray.PercentUsage", "id", now, "12", MeasKeys{"CPUIdentifier": "0"}))
+	bld = NewEventMeasurementSetBuilder(measTestFactory(10 * time.Second))
+	s.Error(bld.Set("VNICPerformanceArray.ValuesAreSuspect", "id", now, 1, MeasKeys{"VNICIdentifier": "eth0"}))
+}
+
+func (s *MeasurementSetBuilderSuite) TestSetKeyedEntries() {
+	interval := 10 * time.Second
+	bld := NewEventMeasurementSetBuilder(measTestFactory(interval))
+	now := time.Now()
+
+	// Entries appended without the builder are selected by their keys
+	meas, err := bld.Measurement("vmid", now)
+	s.NoError(err)
+	meas.VNICPerformanceArray = append(meas.VNICPerformanceArray, govel.VNICPerformance{VNICIdentifier: "eth0", ValuesAreSuspect: govel.True})
+	s.NoError(bld.Set("VNICPerformanceArray.ReceivedOctetsDelta", "vmid", now, 10, MeasKeys{"VNICIdentifier": "eth1"}))
+	s.NoError(bld.Set("VNICPerformanceArray.ReceivedOctetsDelta", "vmid", now, 11, MeasKeys{"VNICIdentifier": "eth0", "VMIdentifier": "vmid"}))
+	s.NoError(bld.SetString("VNICPerformanceArray.ValuesAreSuspect", "vmid", now, "false", MeasKeys{"VNICIdentifier": "eth1"}))
+	// Entries of nested slices are selected within their parent entry
+	s.NoError(bld.Set("AdditionalObjects.ObjectInstances.ObjectKeys.KeyOrder", "vmid", now, 1, MeasKeys{"ObjectName": "obj1", "KeyName": "k1"}))
+	s.NoError(bld.Set("AdditionalObjects.ObjectInstances.ObjectKeys.KeyOrder", "vmid", now, 2, MeasKeys{"ObjectName": "obj2", "KeyName": "k1"}))
+	s.NoError(bld.Set("AdditionalObjects.ObjectInstances.ObjectKeys.KeyOrder", "vmid", now, 3, MeasKeys{"ObjectName": "obj1", "KeyName": "k2"}))
+
+	s.Len(bld.Measurements(), 1)
+	s.Len(meas.VNICPerformanceArray, 2)
+	s.Equal("eth0", meas.VNICPerformanceArray[0].VNICIdentifier)
+	s.Equal(govel.True, meas.VNICPerformanceArray[0].ValuesAreSuspect)
+	s.EqualValues(11, *meas.VNICPerformanceArray[0].ReceivedOctetsDelta)
+	s.Equal("eth1", meas.VNICPerformanceArray[1].VNICIdentifier)
+	s.Equal(govel.False, meas.VNICPerformanceArray[1].ValuesAreSuspect)
+	s.EqualValues(10, *meas.VNICPerformanceArray[1].ReceivedOctetsDelta)
+	s.Len(meas.AdditionalObjects, 2)
+	s.Equal("obj1", meas.AdditionalObjects[0].ObjectName)
+	s.Len(meas.AdditionalObjects[0].ObjectInstances, 1)
+	keys := meas.AdditionalObjects[0].ObjectInstances[0].ObjectKeys
+	s.Len(keys, 2)
+	s.Equal("k1", keys[0].KeyName)
+	s.EqualValues(1, *keys[0].KeyOrder)
+	s.Equal("k2", keys[1].KeyName)
+	s.EqualValues(3, *keys[1].KeyOrder)
+	s.Equal("obj2", meas.AdditionalObjects[1].ObjectName)
+	s.Len(meas.AdditionalObjects[1].ObjectInstances[0].ObjectKeys, 1)
+}
+
+func (s *MeasurementSetBuilderSuite) TestRollback() {
+	interval := 10 * time.Second
+	bld := NewEventMeasurementSetBuilder(measTestFactory(interval))
+	now := time.Now()
+	s.Error(bld.Rollback())
+
+	s.NoError(bld.Set("VNICPerformanceArray.ReceivedOctetsDelta", "vm1", now, 10, MeasKeys{"VNICIdentifier": "eth0"}))
+	s.NoError(bld.SetAdditionalObject("vm1", "obj", "inst", now, 1, MeasKeys{"k": "v"}))
+	meas := bld.Measurements()[0]
+	bld.Checkpoint()
+	s.NoError(bld.Set("VNICPerformanceArray.ReceivedOctetsDelta", "vm1", now, 11, MeasKeys{"VNICIdentifier": "eth0"}))
+	s.NoError(bld.Set("VNICPerformanceArray.ReceivedOctetsDelta", "vm1", now, 12, MeasKeys{"VNICIdentifier": "eth1"}))
+	s.NoError(bld.Set("ConcurrentSessions", "vm2", now, 5, nil))
+	s.Len(bld.Measurements(), 2)
+
+	// Changes since the checkpoint are discarded
+	s.NoError(bld.Rollback())
+	s.Len(bld.Measurements(), 1)
+	s.Equal(meas, bld.Measurements()[0])
+	s.Len(meas.VNICPerformanceArray, 1)
+	s.EqualValues(10, *meas.VNICPerformanceArray[0].ReceivedOctetsDelta)
+	s.Equal(1.0, meas.AdditionalObjects[0].ObjectInstances[0].ObjectInstance["inst"])
+
+	// Slice entries are selected again after the rollback, and an invalidated builder can be rolled back
+	s.NoError(bld.Set("VNICPerformanceArray.ReceivedOctetsDelta", "vm1", now, 13, MeasKeys{"VNICIdentifier": "eth1"}))
+	bld.invalidate()
+	s.NoError(bld.Rollback())
+	s.NoError(bld.Set("VNICPerformanceArray.ReceivedOctetsDelta", "vm1", now, 14, MeasKeys{"VNICIdentifier": "eth1"}))
+	s.Len(meas.VNICPerformanceArray, 2)
+	s.EqualValues(14, *meas.VNICPerformanceArray[1].ReceivedOctetsDelta)
+}
+
+func (s *MeasurementSetBuilderSuite) TestSetAdditionalFields() {
+	bld := NewEventMeasurementSetBuilder(measTestFactory(10 * time.Second))
+	now := time.Now()
+	s.Error(bld.SetAdditionalField("id1", "", now, "1"))
+	s.Error(bld.SetAdditionalMeasurement("id1", "", "f1", now, "1"))
+	s.Error(bld.SetAdditionalMeasurement("id1", "a1", "", now, "1"))
+	s.NoError(bld.SetAdditionalField("id1", "f1", now, "1"))
+	s.NoError(bld.SetAdditionalField("id1", "f2", now, "2"))
+	s.NoError(bld.SetAdditionalField("id1", "f1", now, "3"))
+	s.NoError(bld.SetAdditionalMeasurement("id1", "a1", "f1", now, "4"))
+	s.NoError(bld.SetAdditionalMeasurement("id1", "a2", "f1", now, "5"))
+	s.NoError(bld.SetAdditionalMeasurement("id1", "a1", "f2", now, "6"))
+	s.NoError(bld.SetAdditionalMeasurement("id1", "a1", "f1", now, "7"))
+	s.NoError(bld.SetAdditionalMeasurement("id2", "a1", "f1", now, "8"))
+
+	meas := bld.Measurements()
+	s.Len(meas, 2)
+	s.Equal([]govel.Field{{Name: "f1", Value: "3"}, {Name: "f2", Value: "2"}}, meas[0].AdditionalFields)
+	s.Equal([]govel.NamedArrayOfFields{
+		{Name: "a1", ArrayOfFields: []govel.Field{{Name: "f1", Value: "7"}, {Name: "f2", Value: "6"}}},
+		{Name: "a2", ArrayOfFields: []govel.Field{{Name: "f1", Value: "5"}}},
+	}, meas[0].AdditionalMeasurements)
+	s.Empty(meas[1].AdditionalFields)
+	s.Equal([]govel.NamedArrayOfFields{
+		{Name: "a1", ArrayOfFields: []govel.Field{{Name: "f1", Value: "8"}}},
+	}, meas[1].AdditionalMeasurements)
+}
+
 func (s *MeasurementSetBuilderSuite) TestSetAdditionalObject() {
 	interval := 10 * time.Second
 	tt := time.Now()
@@ -230,3 +374,31 @@ func TestMeasKeyMatchJSONObjectKeys(t *testing.T) {
 	k = MeasKeys{"k1": "v1", "k2": "v2", "k3": "v3"}
 	assert.False(t, k.MatchJSONObjectKeys(o))
 }
+
+// benchmarkSet fills measurement sets with matrices of `series` CPU usage series spread over
+// 10 VMs, each series having `steps` samples, in the order the collector inserts them
+func benchmarkSet(b *testing.B, series, steps int) {
+	start := time.Now()
+	vmIDs := make([]string, 10)
+	for i := range vmIDs {
+		vmIDs[i] = fmt.Sprintf("vm%d", i)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		bld := NewEventMeasurementSetBuilder(measTestFactory(10 * time.Second))
+		for i := 0; i < series; i++ {
+			vmID := vmIDs[i%len(vmIDs)]
+			keys := MeasKeys{"CPUIdentifier": strconv.Itoa(i / len(vmIDs))}
+			for t := 0; t < steps; t++ {
+				if err := bld.Set("CPUUsageArray.PercentUsage", vmID, start.Add(time.Duration(t)*10*time.Second), float64(t), keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkSet100Series10Steps(b *testing.B)  { benchmarkSet(b, 100, 10) }
+func BenchmarkSet100Series360Steps(b *testing.B) { benchmarkSet(b, 100, 360) }
+func BenchmarkSet1000Series60Steps(b *testing.B) { benchmarkSet(b, 1000, 60) }
+func BenchmarkSet5000Series60Steps(b *testing.B) { benchmarkSet(b, 5000, 60) }
@@ -0,0 +1,217 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nokia/onap-vespa/govel"
+)
+
+// FieldSetter is a compiled path to a field of `govel.EventMeasurements`. Paths are resolved
+// once with reflection, so that setting samples only walks field indexes
+type FieldSetter struct {
+	fields string     // The dot separated path
+	steps  []pathStep // Fields to go through, from the event to the leaf field
+	leaf   reflect.Type
+	kind   FieldKind
+}
+
+// pathStep selects a field of a structure, and an entry of it when the field is a slice
+type pathStep struct {
+	name  string
+	index []int // Index of the field in its structure
+	ptr   bool  // The field is a pointer, allocated when nil
+	slice bool  // The field is a slice of structures, entries are selected by keys
+	// keys are the indexes of the string fields of slice entries, by name.
+	// Only those can be part of entry composite keys
+	keys map[string][]int
+}
+
+// CompileField compiles the dot separated path `fields` of a measurement field
+func CompileField(fields string) (*FieldSetter, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("Fields cannot be empty")
+	}
+	setter := &FieldSetter{fields: fields}
+	typ := reflect.TypeOf(govel.EventMeasurements{})
+	for _, name := range strings.Split(fields, ".") {
+		if typ.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("Cannot access subfields of type %s", typ.String())
+		}
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			return nil, fmt.Errorf("Invalid field %s on type %s", name, typ.String())
+		}
+		step := pathStep{name: name, index: field.Index}
+		typ = field.Type
+		if typ.Kind() == reflect.Ptr {
+			step.ptr = true
+			typ = typ.Elem()
+		}
+		if typ.Kind() == reflect.Slice {
+			typ = typ.Elem()
+			if typ.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("Cannot access subfields of type %s", typ.String())
+			}
+			step.slice = true
+			step.keys = stringFields(typ)
+		}
+		setter.steps = append(setter.steps, step)
+	}
+	last := setter.steps[len(setter.steps)-1]
+	if last.slice {
+		return nil, fmt.Errorf("Field %s of type []%s cannot be set from metrics", fields, typ.String())
+	}
+	kind, ok := fieldKind(typ)
+	if !ok {
+		return nil, fmt.Errorf("Field %s of type %s cannot be set from metrics", fields, typ.String())
+	}
+	setter.leaf = typ
+	setter.kind = kind
+	return setter, nil
+}
+
+// stringFields returns the indexes of the string fields of structure type `typ`, by name
+func stringFields(typ reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.Type.Kind() == reflect.String {
+			fields[f.Name] = f.Index
+		}
+	}
+	return fields
+}
+
+// Kind returns the kind of the field, and the allowed values of enum fields
+func (setter *FieldSetter) Kind() (FieldKind, []string) {
+	return setter.kind, enumValues[setter.leaf]
+}
+
+func (setter *FieldSetter) String() string {
+	return setter.fields
+}
+
+// sliceKey identifies a slice field of a measurement event, and the key fields selecting its entries
+type sliceKey struct {
+	event *govel.EventMeasurements
+	path  string // Path to the slice, with indexes of the entries of parent slices
+	keys  string // Names of the key fields
+}
+
+// sliceIndex maps composite keys of a slice field to entry indexes
+type sliceIndex struct {
+	entries map[string]int
+	indexed int // Number of slice entries already indexed
+}
+
+// entryKeys returns the names of the `keys` which are fields of the slice entries, sorted
+func (step *pathStep) entryKeys(keys MeasKeys) []string {
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		if _, ok := step.keys[k]; ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check checks that `assign` accepts the leaf field, without modifying any event
+func (setter *FieldSetter) check(assign assigner) error {
+	return assign(reflect.New(setter.leaf).Elem())
+}
+
+// set walks the compiled path in measurement event `event`, selecting or creating slice entries
+// matching `keys` through `index`, and assigns the leaf field
+func (setter *FieldSetter) set(event *govel.EventMeasurements, index map[sliceKey]*sliceIndex, assign assigner, keys MeasKeys) error {
+	return setter.setNested(event, index, assign, func(int) MeasKeys { return keys })
+}
+
+// setNested is like `set`, selecting entries of the n-th slice of the path with `keys(n)`.
+// It's used when the key fields of nested slices have the same names
+func (setter *FieldSetter) setNested(event *govel.EventMeasurements, index map[sliceKey]*sliceIndex, assign assigner, keys func(n int) MeasKeys) error {
+	v := reflect.ValueOf(event).Elem()
+	path := ""
+	slices := 0
+	for i := range setter.steps {
+		step := &setter.steps[i]
+		v = v.FieldByIndex(step.index)
+		if step.ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		path += "." + step.name
+		if step.slice {
+			n := step.entry(v, event, path, index, keys(slices))
+			slices++
+			v = v.Index(n)
+			path += "[" + strconv.Itoa(n) + "]"
+		}
+	}
+	return assign(v)
+}
+
+// entry returns the index of the entry of `slice` matching `keys`, appending a new entry
+// initialized from the keys when none matches. Only the keys which are fields of the entries
+// are compared, and the first matching entry is selected
+func (step *pathStep) entry(slice reflect.Value, event *govel.EventMeasurements, path string, index map[sliceKey]*sliceIndex, keys MeasKeys) int {
+	names := step.entryKeys(keys)
+	id := sliceKey{event: event, path: path, keys: strings.Join(names, ",")}
+	idx, ok := index[id]
+	if !ok {
+		idx = &sliceIndex{entries: make(map[string]int)}
+		index[id] = idx
+	}
+	// Index entries appended since last time, possibly without the builder
+	for ; idx.indexed < slice.Len(); idx.indexed++ {
+		entry := slice.Index(idx.indexed)
+		values := make([]string, len(names))
+		for i, name := range names {
+			values[i] = entry.FieldByIndex(step.keys[name]).String()
+		}
+		k := strings.Join(values, "\x00")
+		if _, ok := idx.entries[k]; !ok {
+			idx.entries[k] = idx.indexed
+		}
+	}
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = keys[name]
+	}
+	k := strings.Join(values, "\x00")
+	if n, ok := idx.entries[k]; ok {
+		return n
+	}
+	// Create and append a new entry with its composite key fields set
+	entry := reflect.New(slice.Type().Elem()).Elem()
+	for _, name := range names {
+		entry.FieldByIndex(step.keys[name]).SetString(keys[name])
+	}
+	slice.Set(reflect.Append(slice, entry))
+	n := slice.Len() - 1
+	idx.entries[k] = n
+	idx.indexed = slice.Len()
+	return n
+}
@@ -0,0 +1,230 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/prometheus/client_golang/api"
+	"github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// defaultSource is the name of the source built from the top level Prometheus connection parameters,
+// when no source is configured
+const defaultSource = "default"
+
+// SourceHealth is the health of a Prometheus source
+type SourceHealth struct {
+	Name          string    // Name of the source
+	Address       string    // Base URL of the source API
+	Healthy       bool      // False if the last query sent to the source failed
+	Failures      int       // Number of consecutive failed queries
+	TotalFailures int       // Number of failed queries since startup
+	LastError     string    // Error of the last failure
+	LastFailure   time.Time // Time of the last failure. Zero if the source never failed
+	LastSuccess   time.Time // Time of the last successful query. Zero if none
+}
+
+// source is a Prometheus API queried by rules
+type source struct {
+	name      string        // Name of the source
+	api       v1.API        // Prometheus API
+	retention time.Duration // Age of the oldest data kept by the source. 0 for no limit
+	mutex     sync.Mutex    // Protects health, as queries run in parallel
+	health    SourceHealth  // Health of the source
+}
+
+// newSource creates the source named `name` querying `api` at `address`
+func newSource(name, address string, api v1.API, retention time.Duration) *source {
+	return &source{
+		name:      name,
+		api:       api,
+		retention: retention,
+		health:    SourceHealth{Name: name, Address: address, Healthy: true},
+	}
+}
+
+// newSources creates the Prometheus sources of `cfg`, in failover order.
+// The default source is created from the top level parameters if no source is configured
+func newSources(cfg *config.PrometheusConfig) ([]*source, error) {
+	srcCfgs := cfg.Sources
+	if len(srcCfgs) == 0 {
+		srcCfgs = []config.PrometheusSource{{Name: defaultSource, PrometheusConnection: cfg.PrometheusConnection}}
+	}
+	sources := make([]*source, 0, len(srcCfgs))
+	names := make(map[string]bool)
+	for _, srcCfg := range srcCfgs {
+		if srcCfg.Name == "" || strings.Contains(srcCfg.Name, ",") {
+			return nil, fmt.Errorf("Invalid Prometheus source name '%s'", srcCfg.Name)
+		}
+		if names[srcCfg.Name] {
+			return nil, fmt.Errorf("Duplicate Prometheus source %s", srcCfg.Name)
+		}
+		names[srcCfg.Name] = true
+		transport, err := newPrometheusTransport(&srcCfg.PrometheusConnection, cfg.Timeout, cfg.KeepAlive)
+		if err != nil {
+			return nil, fmt.Errorf("Prometheus source %s: %s", srcCfg.Name, err.Error())
+		}
+		client, err := api.NewClient(api.Config{Address: srcCfg.Address, RoundTripper: transport})
+		if err != nil {
+			return nil, fmt.Errorf("Prometheus source %s: %s", srcCfg.Name, err.Error())
+		}
+		log.Infof("Prometheus source %s at %s", srcCfg.Name, srcCfg.Address)
+		sources = append(sources, newSource(srcCfg.Name, srcCfg.Address, v1.NewAPI(client), srcCfg.Retention))
+	}
+	return sources, nil
+}
+
+// promSources returns the Prometheus sources of the collector, in failover order.
+// A collector created without sources queries its API as the default source, created once
+// as collections may run concurrently
+func (col *Collector) promSources() []*source {
+	col.sourcesInit.Do(func() {
+		if len(col.sources) == 0 && col.api != nil {
+			col.sources = []*source{newSource(defaultSource, "", col.api, 0)}
+		}
+	})
+	return col.sources
+}
+
+// checkSources checks that the sources selected by `rule` exist
+func (col *Collector) checkSources(rule config.MetricRule) error {
+	for _, name := range rule.Sources {
+		found := false
+		for _, src := range col.promSources() {
+			found = found || src.name == name
+		}
+		if !found {
+			return fmt.Errorf("Rule %s: Unknown Prometheus source %s", rule.Expr, name)
+		}
+	}
+	return nil
+}
+
+// SourcesHealth returns the health of the Prometheus sources, in failover order
+func (col *Collector) SourcesHealth() []SourceHealth {
+	sources := col.promSources()
+	health := make([]SourceHealth, len(sources))
+	for i, src := range sources {
+		src.mutex.Lock()
+		health[i] = src.health
+		src.mutex.Unlock()
+	}
+	return health
+}
+
+// querySources returns the sources to query over `rng` at time `now`, in failover order: the sources
+// named `names`, or all sources if empty, which keep data from the start of `rng`. Healthy sources come first
+func (col *Collector) querySources(names []string, rng v1.Range, now time.Time) []*source {
+	candidates := []*source{}
+	if len(names) == 0 {
+		candidates = append(candidates, col.promSources()...)
+	}
+	for _, name := range names {
+		for _, src := range col.promSources() {
+			if src.name == name {
+				candidates = append(candidates, src)
+			}
+		}
+	}
+	healthy, unhealthy := []*source{}, []*source{}
+	for _, src := range candidates {
+		if src.retention > 0 && rng.Start.Before(now.Add(-src.retention)) {
+			continue
+		}
+		if src.healthy() {
+			healthy = append(healthy, src)
+		} else {
+			unhealthy = append(unhealthy, src)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// runQuery runs the range query `q` over `rng`, failing over to the next source when a source fails.
+// Queries rejected as invalid by a source are not sent to the others
+func (col *Collector) runQuery(q query, rng v1.Range) (model.Matrix, error) {
+	names := []string{}
+	if q.sources != "" {
+		names = strings.Split(q.sources, ",")
+	}
+	now := col.timeNow()
+	sources := col.querySources(names, rng, now)
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("No Prometheus source keeps data from %s", rng.Start.Format(time.RFC3339))
+	}
+	var err error
+	for i, src := range sources {
+		var matrix model.Matrix
+		if matrix, err = col.getMatrix(src.api, q.expr, rng); err == nil {
+			src.succeeded(now)
+			return matrix, nil
+		}
+		if badQuery(err) {
+			return nil, err
+		}
+		src.failed(err, now)
+		if i < len(sources)-1 {
+			log.Warnf("Prometheus source %s failed, failing over to %s: %s", src.name, sources[i+1].name, err.Error())
+		}
+	}
+	return nil, err
+}
+
+// badQuery tells if `err` is the rejection of an invalid query by Prometheus
+func badQuery(err error) bool {
+	apiErr, ok := err.(*v1.Error)
+	return ok && apiErr.Type == v1.ErrBadData
+}
+
+// healthy tells if the last query sent to the source succeeded
+func (src *source) healthy() bool {
+	src.mutex.Lock()
+	defer src.mutex.Unlock()
+	return src.health.Healthy
+}
+
+// succeeded records a successful query to the source at time `now`
+func (src *source) succeeded(now time.Time) {
+	src.mutex.Lock()
+	defer src.mutex.Unlock()
+	if !src.health.Healthy {
+		log.Infof("Prometheus source %s is back to healthy", src.name)
+	}
+	src.health.Healthy = true
+	src.health.Failures = 0
+	src.health.LastSuccess = now
+}
+
+// failed records a failed query to the source at time `now`
+func (src *source) failed(err error, now time.Time) {
+	src.mutex.Lock()
+	defer src.mutex.Unlock()
+	src.health.Healthy = false
+	src.health.Failures++
+	src.health.TotalFailures++
+	src.health.LastError = err.Error()
+	src.health.LastFailure = now
+}
@@ -0,0 +1,170 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SourcesTestSuite struct {
+	suite.Suite
+	confEvent govel.EventConfiguration
+}
+
+func (s *SourcesTestSuite) SetupSuite() {
+	s.confEvent = govel.EventConfiguration{VNFName: "VNFName", NfNamingCode: "hsxp"}
+}
+
+func TestSources(t *testing.T) {
+	suite.Run(t, new(SourcesTestSuite))
+}
+
+func (s *SourcesTestSuite) TestNewSources() {
+	cfg := config.PrometheusConfig{PrometheusConnection: config.PrometheusConnection{Address: "http://127.0.0.1:9090"}}
+	sources, err := newSources(&cfg)
+	s.NoError(err)
+	s.Len(sources, 1)
+	s.Equal(defaultSource, sources[0].name)
+
+	cfg.Sources = []config.PrometheusSource{
+		{Name: "local", Retention: 2 * time.Hour, PrometheusConnection: config.PrometheusConnection{Address: "http://127.0.0.1:9090"}},
+		{Name: "thanos", PrometheusConnection: config.PrometheusConnection{Address: "http://thanos:9090"}},
+	}
+	sources, err = newSources(&cfg)
+	s.NoError(err)
+	s.Len(sources, 2)
+	s.Equal("local", sources[0].name)
+	s.Equal(2*time.Hour, sources[0].retention)
+	s.Equal("thanos", sources[1].name)
+
+	for _, invalid := range [][]config.PrometheusSource{
+		{{Name: "", PrometheusConnection: config.PrometheusConnection{Address: "http://127.0.0.1:9090"}}},
+		{{Name: "a,b", PrometheusConnection: config.PrometheusConnection{Address: "http://127.0.0.1:9090"}}},
+		{{Name: "local", PrometheusConnection: config.PrometheusConnection{Address: "127.0.0.1:9090"}}},
+		{
+			{Name: "local", PrometheusConnection: config.PrometheusConnection{Address: "http://127.0.0.1:9090"}},
+			{Name: "local", PrometheusConnection: config.PrometheusConnection{Address: "http://thanos:9090"}},
+		},
+	} {
+		cfg.Sources = invalid
+		_, err = newSources(&cfg)
+		s.Error(err)
+	}
+}
+
+func (s *SourcesTestSuite) TestUnknownSource() {
+	col, err := NewCollector(&config.MeasurementConfiguration{
+		Prometheus: config.PrometheusConfig{
+			Sources: []config.PrometheusSource{{Name: "local", PrometheusConnection: config.PrometheusConnection{Address: "http://127.0.0.1:9090"}}},
+			Rules: config.MetricRules{Metrics: []config.MetricRule{
+				{Expr: "sessions", Target: "ConcurrentSessions", VMIDLabel: "{{.labels.VNFC}}", Sources: []string{"thanos"}},
+			}},
+		}},
+		&s.confEvent,
+		map[string]string{},
+	)
+	s.Error(err)
+	s.Nil(col)
+}
+
+func (s *SourcesTestSuite) TestFailover() {
+	local, thanos := APIMock{}, APIMock{}
+	now := time.Unix(100000, 0)
+	collector := Collector{
+		state: &inMemState{},
+		sources: []*source{
+			newSource("local", "http://local:9090", &local, 2*time.Hour),
+			newSource("thanos", "http://thanos:9090", &thanos, 0),
+		},
+		rules: config.MetricRules{
+			DefaultValues: &config.MetricRule{VMIDLabel: "{{.labels.VNFC}}"},
+			Metrics: []config.MetricRule{
+				{Expr: "sessions", Target: "ConcurrentSessions"},
+				{Expr: "entities", Target: "ConfiguredEntities", Sources: []string{"thanos"}},
+			},
+		},
+		evtCfg:      &s.confEvent,
+		namingCodes: map[string]string{},
+		now:         func() time.Time { return now },
+	}
+	matrix := model.Matrix{&model.SampleStream{
+		Metric: model.Metric{"VNFC": "ope-1"},
+		Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(now.Unix()), Value: model.SampleValue(12)}},
+	}}
+
+	// Rules query the first source, unless they select others
+	local.On("QueryRange", mock.Anything, "sessions", mock.Anything).Once().Return(matrix, nil)
+	thanos.On("QueryRange", mock.Anything, "entities", mock.Anything).Once().Return(matrix, nil)
+	meas, err := collector.CollectMetrics(now.Add(-time.Minute), now, time.Minute)
+	s.NoError(err)
+	s.Len(meas, 1)
+	s.EqualValues(12, *meas[0].ConcurrentSessions)
+	s.EqualValues(12, *meas[0].ConfiguredEntities)
+	local.AssertExpectations(s.T())
+	thanos.AssertExpectations(s.T())
+
+	// A failed source is failed over, and tried last while unhealthy
+	local.On("QueryRange", mock.Anything, "sessions", mock.Anything).Once().Return(model.Matrix{}, errors.New("unavailable"))
+	thanos.On("QueryRange", mock.Anything, mock.Anything, mock.Anything).Times(4).Return(matrix, nil)
+	meas, err = collector.CollectMetrics(now.Add(-time.Minute), now, time.Minute)
+	s.NoError(err)
+	s.EqualValues(12, *meas[0].ConcurrentSessions)
+	health := collector.SourcesHealth()
+	s.Equal(SourceHealth{
+		Name:          "local",
+		Address:       "http://local:9090",
+		Failures:      1,
+		TotalFailures: 1,
+		LastError:     "unavailable",
+		LastFailure:   now,
+		LastSuccess:   now,
+	}, health[0])
+	s.True(health[1].Healthy)
+	meas, err = collector.CollectMetrics(now.Add(-time.Minute), now, time.Minute)
+	s.NoError(err)
+	s.EqualValues(12, *meas[0].ConcurrentSessions)
+	local.AssertNumberOfCalls(s.T(), "QueryRange", 2)
+
+	// Sources not keeping data from the start of the window are skipped
+	thanos.On("QueryRange", mock.Anything, mock.Anything, mock.Anything).Times(2).Return(matrix, nil)
+	_, err = collector.CollectMetrics(now.Add(-3*time.Hour), now, time.Minute)
+	s.NoError(err)
+	local.AssertNumberOfCalls(s.T(), "QueryRange", 2)
+	thanos.AssertExpectations(s.T())
+
+	// Invalid queries are not failed over, and don't change health
+	local.On("QueryRange", mock.Anything, "sessions", mock.Anything).Once().Return(model.Matrix{}, &v1.Error{Type: v1.ErrBadData, Msg: "parse error"})
+	collector.sources[0].succeeded(now)
+	_, err = collector.runQuery(query{sources: "local,thanos", expr: "sessions"}, v1.Range{Start: now.Add(-time.Minute), End: now, Step: time.Minute})
+	s.Error(err)
+	s.True(collector.SourcesHealth()[0].Healthy)
+	thanos.AssertNumberOfCalls(s.T(), "QueryRange", 7)
+
+	// No source keeps data old enough
+	_, err = collector.runQuery(query{sources: "local", expr: "sessions"}, v1.Range{Start: now.Add(-3 * time.Hour), End: now, Step: time.Minute})
+	s.Error(err)
+}
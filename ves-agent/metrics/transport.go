@@ -0,0 +1,192 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+)
+
+// newPrometheusTransport creates the HTTP transport of Prometheus requests, with the dial timeout `timeout`
+// and keep-alive `keepAlive`, and the TLS parameters, authentication and custom headers of `cfg`
+func newPrometheusTransport(cfg *config.PrometheusConnection, timeout, keepAlive time.Duration) (http.RoundTripper, error) {
+	tlsConfig, err := newPrometheusTLSConfig(&cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	var transport http.RoundTripper = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: keepAlive,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}
+	auth := &authTransport{
+		next:         transport,
+		user:         cfg.User,
+		password:     cfg.Password,
+		passwordFile: cfg.PasswordFile,
+		token:        cfg.BearerToken,
+		tokenFile:    cfg.BearerTokenFile,
+		headers:      cfg.Headers,
+	}
+	if err := auth.validate(); err != nil {
+		return nil, err
+	}
+	if auth.user == "" && !auth.bearer() && len(auth.headers) == 0 {
+		return transport, nil
+	}
+	return auth, nil
+}
+
+// newPrometheusTLSConfig creates the TLS configuration of Prometheus connections. It's nil
+// if no TLS parameter is set. The client certificate is loaded at each connection, so that it can rotate
+func newPrometheusTLSConfig(cfg *config.PrometheusTLSConfig) (*tls.Config, error) {
+	if *cfg == (config.PrometheusTLSConfig{}) {
+		return nil, nil
+	}
+	if (cfg.Cert == "") != (cfg.Key == "") {
+		return nil, errors.New("Both Cert and Key are required for Prometheus TLS")
+	}
+	/* #nosec */
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if cfg.CA != "" {
+		caBytes, err := ioutil.ReadFile(cfg.CA)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caBytes) {
+			return nil, errors.New("Cannot load Prometheus CA. PEM not valid")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if cfg.Cert != "" {
+		// Fail early on invalid certificate
+		if _, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key); err != nil {
+			return nil, err
+		}
+		certFile, keyFile := cfg.Cert, cfg.Key
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, err
+			}
+			return &cert, nil
+		}
+	}
+	return tlsConfig, nil
+}
+
+// authTransport is an http.RoundTripper adding authentication and custom headers to requests.
+// Secrets are read from their files at each request, so that they can rotate
+type authTransport struct {
+	next         http.RoundTripper // Transport sending the requests
+	user         string            // Basic authentication username. Empty for none
+	password     string            // Basic authentication password
+	passwordFile string            // Path to the file holding the password. Empty to use `password`
+	token        string            // Bearer token. Empty for none
+	tokenFile    string            // Path to the file holding the bearer token. Empty to use `token`
+	headers      map[string]string // Custom headers
+}
+
+// bearer tells if a bearer token is configured
+func (t *authTransport) bearer() bool {
+	return t.token != "" || t.tokenFile != ""
+}
+
+// validate checks the authentication parameters, and that secret files can be read
+func (t *authTransport) validate() error {
+	if t.password != "" && t.passwordFile != "" {
+		return errors.New("Prometheus Password and PasswordFile are exclusive")
+	}
+	if t.token != "" && t.tokenFile != "" {
+		return errors.New("Prometheus BearerToken and BearerTokenFile are exclusive")
+	}
+	if t.user == "" && (t.password != "" || t.passwordFile != "") {
+		return errors.New("Missing User for Prometheus basic authentication")
+	}
+	if t.user != "" && t.bearer() {
+		return errors.New("Prometheus basic and bearer authentications are exclusive")
+	}
+	if _, err := readSecret(t.password, t.passwordFile); err != nil {
+		return err
+	}
+	_, err := readSecret(t.token, t.tokenFile)
+	return err
+}
+
+// RoundTrip sends a copy of `req` with the authentication and custom headers
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Round trippers must not modify the request
+	r := req.WithContext(req.Context())
+	r.Header = make(http.Header, len(req.Header)+len(t.headers)+1)
+	for name, values := range req.Header {
+		r.Header[name] = values
+	}
+	for name, value := range t.headers {
+		r.Header.Set(name, value)
+	}
+	var err error
+	switch {
+	case t.user != "":
+		var password string
+		if password, err = readSecret(t.password, t.passwordFile); err == nil {
+			r.SetBasicAuth(t.user, password)
+		}
+	case t.bearer():
+		var token string
+		if token, err = readSecret(t.token, t.tokenFile); err == nil {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.next.RoundTrip(r)
+}
+
+// readSecret returns the content of `file` without trailing line breaks,
+// or `value` if `file` is empty
+func readSecret(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	/* #nosec */
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read secret file: %s", err.Error())
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
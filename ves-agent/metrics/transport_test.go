@@ -0,0 +1,229 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package metrics
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TransportTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestTransport(t *testing.T) {
+	suite.Run(t, new(TransportTestSuite))
+}
+
+func (s *TransportTestSuite) SetupSuite() {
+	var err error
+	s.dir, err = ioutil.TempDir("", "ves-agent-metrics")
+	s.Require().NoError(err)
+
+	ca, caKey := s.generateCertificate("ca", nil, nil, true)
+	s.generateCertificate("server", ca, caKey, false)
+	s.generateCertificate("client", ca, caKey, false)
+	s.generateCertificate("other", ca, caKey, false)
+	s.Require().NoError(ioutil.WriteFile(s.path("invalid.pem"), []byte("not a PEM"), 0600))
+}
+
+func (s *TransportTestSuite) TearDownSuite() {
+	os.RemoveAll(s.dir)
+}
+
+func (s *TransportTestSuite) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// generateCertificate creates a certificate and its key in files `<name>.pem` and `<name>-key.pem`,
+// signed by `parent`. The certificate is self-signed if `parent` is nil
+func (s *TransportTestSuite) generateCertificate(name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = &template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, parent, &key.PublicKey, parentKey)
+	s.Require().NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	s.Require().NoError(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	s.Require().NoError(err)
+	s.Require().NoError(ioutil.WriteFile(s.path(name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	s.Require().NoError(ioutil.WriteFile(s.path(name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, key
+}
+
+// get sends a GET request to `url` with `transport`, and returns the request received by the server
+func (s *TransportTestSuite) get(transport http.RoundTripper, url string, received <-chan *http.Request) *http.Request {
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	return <-received
+}
+
+func (s *TransportTestSuite) TestHeaders() {
+	received := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		received <- req
+	}))
+	defer server.Close()
+
+	// No authentication
+	transport, err := newPrometheusTransport(&config.PrometheusConnection{}, 0, 0)
+	s.Require().NoError(err)
+	s.IsType(&http.Transport{}, transport)
+	req := s.get(transport, server.URL, received)
+	s.Empty(req.Header.Get("Authorization"))
+
+	// Custom headers and basic authentication
+	transport, err = newPrometheusTransport(&config.PrometheusConnection{
+		User:     "user",
+		Password: "pass",
+		Headers:  map[string]string{"x-scope-orgid": "tenant1"},
+	}, 0, 0)
+	s.Require().NoError(err)
+	req = s.get(transport, server.URL, received)
+	s.Equal("tenant1", req.Header.Get("X-Scope-OrgID"))
+	user, password, ok := req.BasicAuth()
+	s.True(ok)
+	s.Equal("user", user)
+	s.Equal("pass", password)
+
+	// Secrets are read again from files at each request
+	s.Require().NoError(ioutil.WriteFile(s.path("password"), []byte("secret1\n"), 0600))
+	transport, err = newPrometheusTransport(&config.PrometheusConnection{User: "user", PasswordFile: s.path("password")}, 0, 0)
+	s.Require().NoError(err)
+	req = s.get(transport, server.URL, received)
+	_, password, _ = req.BasicAuth()
+	s.Equal("secret1", password)
+	s.Require().NoError(ioutil.WriteFile(s.path("password"), []byte("secret2"), 0600))
+	req = s.get(transport, server.URL, received)
+	_, password, _ = req.BasicAuth()
+	s.Equal("secret2", password)
+
+	s.Require().NoError(ioutil.WriteFile(s.path("token"), []byte("token1\n"), 0600))
+	transport, err = newPrometheusTransport(&config.PrometheusConnection{BearerTokenFile: s.path("token")}, 0, 0)
+	s.Require().NoError(err)
+	req = s.get(transport, server.URL, received)
+	s.Equal("Bearer token1", req.Header.Get("Authorization"))
+	s.Require().NoError(ioutil.WriteFile(s.path("token"), []byte("token2\n"), 0600))
+	req = s.get(transport, server.URL, received)
+	s.Equal("Bearer token2", req.Header.Get("Authorization"))
+
+	// Requests fail when the secret can't be read
+	s.Require().NoError(os.Remove(s.path("token")))
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	s.Error(err)
+}
+
+func (s *TransportTestSuite) TestInvalidConfig() {
+	for _, cfg := range []config.PrometheusConnection{
+		{Password: "pass"},
+		{User: "user", Password: "pass", PasswordFile: s.path("password")},
+		{User: "user", PasswordFile: s.path("missing")},
+		{User: "user", Password: "pass", BearerToken: "token"},
+		{BearerToken: "token", BearerTokenFile: s.path("token")},
+		{BearerTokenFile: s.path("missing")},
+		{TLS: config.PrometheusTLSConfig{Cert: s.path("client.pem")}},
+		{TLS: config.PrometheusTLSConfig{Cert: s.path("client.pem"), Key: s.path("other-key.pem")}},
+		{TLS: config.PrometheusTLSConfig{CA: s.path("missing.pem")}},
+		{TLS: config.PrometheusTLSConfig{CA: s.path("invalid.pem")}},
+	} {
+		_, err := newPrometheusTransport(&cfg, 0, 0)
+		s.Error(err, "%+v", cfg)
+	}
+}
+
+func (s *TransportTestSuite) TestTLS() {
+	cert, err := tls.LoadX509KeyPair(s.path("server.pem"), s.path("server-key.pem"))
+	s.Require().NoError(err)
+	caBytes, err := ioutil.ReadFile(s.path("ca.pem"))
+	s.Require().NoError(err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(caBytes)
+	received := make(chan *http.Request, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		received <- req
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	// Server certificate must be verified
+	transport, err := newPrometheusTransport(&config.PrometheusConnection{
+		TLS: config.PrometheusTLSConfig{Cert: s.path("client.pem"), Key: s.path("client-key.pem")},
+	}, 0, 0)
+	s.Require().NoError(err)
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	s.Error(err)
+
+	// Client certificate is required
+	transport, err = newPrometheusTransport(&config.PrometheusConnection{
+		TLS: config.PrometheusTLSConfig{CA: s.path("ca.pem")},
+	}, 0, 0)
+	s.Require().NoError(err)
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	s.Error(err)
+
+	transport, err = newPrometheusTransport(&config.PrometheusConnection{
+		TLS:         config.PrometheusTLSConfig{CA: s.path("ca.pem"), Cert: s.path("client.pem"), Key: s.path("client-key.pem")},
+		BearerToken: "token",
+	}, 0, 0)
+	s.Require().NoError(err)
+	req := s.get(transport, server.URL, received)
+	s.Equal("Bearer token", req.Header.Get("Authorization"))
+	s.Require().Len(req.TLS.PeerCertificates, 1)
+	s.Equal("client", req.TLS.PeerCertificates[0].Subject.CommonName)
+}
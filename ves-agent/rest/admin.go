@@ -0,0 +1,326 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// AdminPathPrefix is the path prefix of all the administration API routes
+const AdminPathPrefix = "/admin"
+
+// Errors returned by administration commands
+var (
+	// ErrNotFound is returned when the target of a command does not exist
+	ErrNotFound = errors.New("Not found")
+	// ErrNotLeader is returned when a command is received while not being the cluster's leader
+	ErrNotLeader = errors.New("Not the leader")
+	// ErrConflict is returned when a command cannot be applied to the current state of its target
+	ErrConflict = errors.New("Conflict with the target's state")
+)
+
+// AdminAction is the kind of action requested through the administration API
+type AdminAction int
+
+// Possible values for AdminAction
+const (
+	AdminSchedulerStatus AdminAction = iota
+	AdminSchedulerTrigger
+	AdminSchedulerSetInterval
+	AdminAlertStatus
+	AdminFaultStatus
+	AdminFaultClear
+	AdminFaultResend
+	AdminFaultHistory
+	AdminRuleStatus
+	AdminSourceStatus
+)
+
+// MessageAdmin contains
+// - an administration command received by the server, to be executed by the agent
+// - a channel to get the result of the command
+type MessageAdmin struct {
+	Action   AdminAction
+	Target   string        // Name or ID of the targeted object. Empty means all of them
+	Interval time.Duration // New interval for AdminSchedulerSetInterval. 0 resets to default interval
+	Filter   HistoryFilter // Filter of AdminFaultHistory
+	Response chan AdminResult
+}
+
+// AdminResult is the result of an administration command
+type AdminResult struct {
+	Data interface{} // Data to reply, if any
+	Err  error       // Error, if the command failed
+}
+
+// TimeWindow is a time range
+type TimeWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// SchedulerStatus is the status of a scheduler as reported by the administration API
+type SchedulerStatus struct {
+	Name            string      `json:"name"`
+	Interval        string      `json:"interval"`
+	DefaultInterval string      `json:"defaultInterval"`
+	NextRun         time.Time   `json:"nextRun"`
+	LastAck         *TimeWindow `json:"lastAck,omitempty"`
+	Backfilling     bool        `json:"backfilling,omitempty"`
+}
+
+// QueuedAlertStatus is the processing status of a received alert as reported by the administration API
+type QueuedAlertStatus struct {
+	ID          int64             `json:"id"`
+	Status      string            `json:"status"`
+	AlertStatus string            `json:"alertStatus"`
+	Labels      map[string]string `json:"labels"`
+	Received    time.Time         `json:"received"`
+	Attempts    int               `json:"attempts"`
+	LastAttempt *time.Time        `json:"lastAttempt,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// FaultStatus is an active fault as reported by the administration API
+type FaultStatus struct {
+	ID          int32             `json:"id"`
+	Name        string            `json:"name"`
+	EventID     string            `json:"eventId"`
+	Sequence    int64             `json:"sequence"`
+	StartEpoch  int64             `json:"startEpoch"`
+	SourceName  string            `json:"sourceName"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	LastSeen    *time.Time        `json:"lastSeen,omitempty"`
+	LastSent    *time.Time        `json:"lastSent,omitempty"`
+	LastEvent   json.RawMessage   `json:"lastEvent,omitempty"`
+}
+
+// HistoryFilter selects fault history records. Empty fields match any record
+type HistoryFilter struct {
+	SourceName string
+	AlertName  string
+	From, To   time.Time
+}
+
+// FaultRecordStatus is a fault history record as reported by the administration API
+type FaultRecordStatus struct {
+	ID               int64     `json:"id"`
+	Time             time.Time `json:"time"`
+	Action           string    `json:"action"`
+	EventID          string    `json:"eventId"`
+	AlertName        string    `json:"alertName"`
+	SourceName       string    `json:"sourceName"`
+	Severity         string    `json:"severity"`
+	PreviousSeverity string    `json:"previousSeverity,omitempty"`
+	Sequence         int64     `json:"sequence"`
+	Outcome          string    `json:"outcome"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// RuleStatus is the collection status of a metric rule as reported by the administration API
+type RuleStatus struct {
+	Index            int        `json:"index"`
+	Expr             string     `json:"expr"`
+	Target           string     `json:"target"`
+	Failures         int        `json:"failures"`
+	TotalFailures    int        `json:"totalFailures"`
+	LastError        string     `json:"lastError,omitempty"`
+	LastFailure      *time.Time `json:"lastFailure,omitempty"`
+	QuarantinedUntil *time.Time `json:"quarantinedUntil,omitempty"`
+}
+
+// SourceStatus is the health of a Prometheus source as reported by the administration API
+type SourceStatus struct {
+	Name          string     `json:"name"`
+	Address       string     `json:"address"`
+	Healthy       bool       `json:"healthy"`
+	Failures      int        `json:"failures"`
+	TotalFailures int        `json:"totalFailures"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastFailure   *time.Time `json:"lastFailure,omitempty"`
+	LastSuccess   *time.Time `json:"lastSuccess,omitempty"`
+}
+
+// intervalRequest is the body of a scheduler interval change request
+type intervalRequest struct {
+	Interval string `json:"interval"`
+}
+
+// badRequestError is an error caused by an invalid client request
+type badRequestError struct {
+	msg string
+}
+
+func (err *badRequestError) Error() string {
+	return err.msg
+}
+
+// parseTimeParam returns the RFC 3339 timestamp of query parameter `name`, or zero time if absent
+func parseTimeParam(req *http.Request, name string) (time.Time, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, &badRequestError{msg: fmt.Sprintf("Invalid %s time: %s", name, value)}
+	}
+	return t, nil
+}
+
+// parseHistoryFilter reads the fault history filter from the query parameters `source`, `alertname`, `from` and `to` of `req`
+func parseHistoryFilter(req *http.Request) (HistoryFilter, error) {
+	var err error
+	filter := HistoryFilter{SourceName: req.URL.Query().Get("source"), AlertName: req.URL.Query().Get("alertname")}
+	if filter.From, err = parseTimeParam(req, "from"); err != nil {
+		return filter, err
+	}
+	filter.To, err = parseTimeParam(req, "to")
+	return filter, err
+}
+
+// errorStatus returns the HTTP status code to reply for the given error
+func errorStatus(err error) int {
+	switch err.(type) {
+	case *badRequestError:
+		return http.StatusBadRequest
+	}
+	switch err {
+	case ErrNotFound:
+		return http.StatusNotFound
+	case ErrConflict:
+		return http.StatusConflict
+	case ErrNotLeader, ErrQueueFull:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// adminWrapper takes a function `f` which returns some data or an error, and transform it
+// into an `http.Handler` which replies the data encoded in JSON, or the HTTP error matching
+// the returned error
+func adminWrapper(f func(req *http.Request) (interface{}, error)) http.Handler {
+	hdl := func(resp http.ResponseWriter, req *http.Request) {
+		data, err := f(req)
+		if err != nil {
+			http.Error(resp, err.Error(), errorStatus(err))
+			return
+		}
+		if data == nil {
+			resp.WriteHeader(http.StatusNoContent)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(resp)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			log.Errorf("HTTP Handler - Cannot write response: %s", err.Error())
+		}
+	}
+	return http.HandlerFunc(hdl)
+}
+
+// sendAdminCommand sends the command to the agent, and waits for its result
+func sendAdminCommand(adminCh chan MessageAdmin, cmd MessageAdmin) (interface{}, error) {
+	cmd.Response = make(chan AdminResult, 1)
+	// Non blocking write, to avoid a dead lock situation
+	select {
+	case adminCh <- cmd:
+	default:
+		err := fmt.Errorf("Admin command %d could not be sent to a channel", cmd.Action)
+		log.Warn(err.Error())
+		return nil, err
+	}
+	res := <-cmd.Response
+	return res.Data, res.Err
+}
+
+// AdminRoutes returns the routes of the administration API. Received commands are sent
+// to `adminCh` for being executed. Routes are not authenticated, this must be done
+// by wrapping their handlers
+func AdminRoutes(adminCh chan MessageAdmin) []Route {
+	schedulerStatus := func(req *http.Request) (interface{}, error) {
+		return sendAdminCommand(adminCh, MessageAdmin{Action: AdminSchedulerStatus, Target: mux.Vars(req)["name"]})
+	}
+	alertStatus := func(req *http.Request) (interface{}, error) {
+		return sendAdminCommand(adminCh, MessageAdmin{Action: AdminAlertStatus, Target: mux.Vars(req)["id"]})
+	}
+	faultStatus := func(req *http.Request) (interface{}, error) {
+		return sendAdminCommand(adminCh, MessageAdmin{Action: AdminFaultStatus, Target: mux.Vars(req)["id"]})
+	}
+	return []Route{
+		{Name: "AdminSchedulers", Method: http.MethodGet, Pattern: AdminPathPrefix + "/schedulers", HandlerFunc: adminWrapper(schedulerStatus)},
+		{Name: "AdminScheduler", Method: http.MethodGet, Pattern: AdminPathPrefix + "/schedulers/{name}", HandlerFunc: adminWrapper(schedulerStatus)},
+		{Name: "AdminSchedulerTrigger", Method: http.MethodPost, Pattern: AdminPathPrefix + "/schedulers/{name}/trigger",
+			HandlerFunc: adminWrapper(func(req *http.Request) (interface{}, error) {
+				return sendAdminCommand(adminCh, MessageAdmin{Action: AdminSchedulerTrigger, Target: mux.Vars(req)["name"]})
+			})},
+		{Name: "AdminSchedulerSetInterval", Method: http.MethodPut, Pattern: AdminPathPrefix + "/schedulers/{name}/interval",
+			HandlerFunc: adminWrapper(func(req *http.Request) (interface{}, error) {
+				body := intervalRequest{}
+				if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+					return nil, &badRequestError{msg: err.Error()}
+				}
+				interval, err := time.ParseDuration(body.Interval)
+				if err != nil || interval <= 0 {
+					return nil, &badRequestError{msg: fmt.Sprintf("Invalid interval: %s", body.Interval)}
+				}
+				return sendAdminCommand(adminCh, MessageAdmin{Action: AdminSchedulerSetInterval, Target: mux.Vars(req)["name"], Interval: interval})
+			})},
+		{Name: "AdminSchedulerResetInterval", Method: http.MethodDelete, Pattern: AdminPathPrefix + "/schedulers/{name}/interval",
+			HandlerFunc: adminWrapper(func(req *http.Request) (interface{}, error) {
+				return sendAdminCommand(adminCh, MessageAdmin{Action: AdminSchedulerSetInterval, Target: mux.Vars(req)["name"]})
+			})},
+		{Name: "AdminAlerts", Method: http.MethodGet, Pattern: AdminPathPrefix + "/alerts", HandlerFunc: adminWrapper(alertStatus)},
+		{Name: "AdminAlert", Method: http.MethodGet, Pattern: AdminPathPrefix + "/alerts/{id:[0-9]+}", HandlerFunc: adminWrapper(alertStatus)},
+		{Name: "AdminFaultHistory", Method: http.MethodGet, Pattern: AdminPathPrefix + "/faults/history",
+			HandlerFunc: adminWrapper(func(req *http.Request) (interface{}, error) {
+				filter, err := parseHistoryFilter(req)
+				if err != nil {
+					return nil, err
+				}
+				return sendAdminCommand(adminCh, MessageAdmin{Action: AdminFaultHistory, Filter: filter})
+			})},
+		{Name: "AdminFaults", Method: http.MethodGet, Pattern: AdminPathPrefix + "/faults", HandlerFunc: adminWrapper(faultStatus)},
+		{Name: "AdminFault", Method: http.MethodGet, Pattern: AdminPathPrefix + "/faults/{id}", HandlerFunc: adminWrapper(faultStatus)},
+		{Name: "AdminFaultClear", Method: http.MethodPost, Pattern: AdminPathPrefix + "/faults/{id}/clear",
+			HandlerFunc: adminWrapper(func(req *http.Request) (interface{}, error) {
+				return sendAdminCommand(adminCh, MessageAdmin{Action: AdminFaultClear, Target: mux.Vars(req)["id"]})
+			})},
+		{Name: "AdminFaultResend", Method: http.MethodPost, Pattern: AdminPathPrefix + "/faults/{id}/resend",
+			HandlerFunc: adminWrapper(func(req *http.Request) (interface{}, error) {
+				return sendAdminCommand(adminCh, MessageAdmin{Action: AdminFaultResend, Target: mux.Vars(req)["id"]})
+			})},
+		{Name: "AdminRules", Method: http.MethodGet, Pattern: AdminPathPrefix + "/rules",
+			HandlerFunc: adminWrapper(func(req *http.Request) (interface{}, error) {
+				return sendAdminCommand(adminCh, MessageAdmin{Action: AdminRuleStatus})
+			})},
+		{Name: "AdminSources", Method: http.MethodGet, Pattern: AdminPathPrefix + "/sources",
+			HandlerFunc: adminWrapper(func(req *http.Request) (interface{}, error) {
+				return sendAdminCommand(adminCh, MessageAdmin{Action: AdminSourceStatus})
+			})},
+	}
+}
@@ -0,0 +1,384 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type leadershipMock struct {
+	leader bool
+	api    string
+}
+
+func (l *leadershipMock) IsLeader() bool {
+	return l.leader
+}
+
+func (l *leadershipMock) LeaderAPI() (string, error) {
+	if l.api == "" {
+		return "", errors.New("No known cluster leader")
+	}
+	return l.api, nil
+}
+
+type AdminTestSuite struct {
+	suite.Suite
+	adminCh chan MessageAdmin
+	handler http.Handler
+}
+
+func TestAdmin(t *testing.T) {
+	suite.Run(t, new(AdminTestSuite))
+}
+
+func (suite *AdminTestSuite) SetupTest() {
+	suite.adminCh = make(chan MessageAdmin, 1)
+	suite.handler = NewServer(AdminRoutes(suite.adminCh))
+}
+
+// reply answers the next admin command with `res`, and returns the command
+func (suite *AdminTestSuite) reply(res AdminResult) <-chan MessageAdmin {
+	cmdCh := make(chan MessageAdmin, 1)
+	go func() {
+		cmd := <-suite.adminCh
+		cmd.Response <- res
+		close(cmd.Response)
+		cmdCh <- cmd
+	}()
+	return cmdCh
+}
+
+func (suite *AdminTestSuite) TestSchedulersStatus() {
+	next := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	cmdCh := suite.reply(AdminResult{Data: []SchedulerStatus{{Name: "measurements", Interval: "5m0s", DefaultInterval: "5m0s", NextRun: next}}})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/schedulers", nil))
+
+	cmd := <-cmdCh
+	suite.Equal(AdminSchedulerStatus, cmd.Action)
+	suite.Equal("", cmd.Target)
+	suite.Equal(200, resp.Code)
+	suite.Equal("application/json", resp.Header().Get("Content-Type"))
+	status := []SchedulerStatus{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&status))
+	suite.Len(status, 1)
+	suite.Equal("measurements", status[0].Name)
+	suite.Equal(next, status[0].NextRun)
+	suite.Nil(status[0].LastAck)
+}
+
+func (suite *AdminTestSuite) TestSchedulerNotFound() {
+	cmdCh := suite.reply(AdminResult{Err: ErrNotFound})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/schedulers/foo", nil))
+
+	cmd := <-cmdCh
+	suite.Equal(AdminSchedulerStatus, cmd.Action)
+	suite.Equal("foo", cmd.Target)
+	suite.Equal(404, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestTrigger() {
+	cmdCh := suite.reply(AdminResult{})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil))
+
+	cmd := <-cmdCh
+	suite.Equal(AdminSchedulerTrigger, cmd.Action)
+	suite.Equal("heartbeats", cmd.Target)
+	suite.Equal(204, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestTriggerNotLeader() {
+	cmdCh := suite.reply(AdminResult{Err: ErrNotLeader})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil))
+
+	<-cmdCh
+	suite.Equal(503, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestSetInterval() {
+	cmdCh := suite.reply(AdminResult{})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("PUT", "/admin/schedulers/measurements/interval", strings.NewReader(`{"interval": "30s"}`)))
+
+	cmd := <-cmdCh
+	suite.Equal(AdminSchedulerSetInterval, cmd.Action)
+	suite.Equal("measurements", cmd.Target)
+	suite.Equal(30*time.Second, cmd.Interval)
+	suite.Equal(204, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestSetInvalidInterval() {
+	for _, body := range []string{`{"interval": "foo"}`, `{"interval": "-5s"}`, `{}`, `not json`} {
+		resp := httptest.NewRecorder()
+		suite.handler.ServeHTTP(resp, httptest.NewRequest("PUT", "/admin/schedulers/measurements/interval", strings.NewReader(body)))
+		suite.Equal(400, resp.Code, body)
+	}
+	select {
+	case <-suite.adminCh:
+		suite.Fail("Channel should be empty")
+	default:
+	}
+}
+
+func (suite *AdminTestSuite) TestResetInterval() {
+	cmdCh := suite.reply(AdminResult{})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("DELETE", "/admin/schedulers/measurements/interval", nil))
+
+	cmd := <-cmdCh
+	suite.Equal(AdminSchedulerSetInterval, cmd.Action)
+	suite.Equal("measurements", cmd.Target)
+	suite.EqualValues(0, cmd.Interval)
+	suite.Equal(204, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestAlertsStatus() {
+	cmdCh := suite.reply(AdminResult{Data: []QueuedAlertStatus{{ID: 12, Status: "failed", Error: "Cannot convert Fault to VES event"}}})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/alerts", nil))
+
+	cmd := <-cmdCh
+	suite.Equal(AdminAlertStatus, cmd.Action)
+	suite.Equal("", cmd.Target)
+	suite.Equal(200, resp.Code)
+	status := []QueuedAlertStatus{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&status))
+	suite.Len(status, 1)
+	suite.Equal(int64(12), status[0].ID)
+	suite.Nil(status[0].LastAttempt)
+
+	cmdCh = suite.reply(AdminResult{Err: ErrNotFound})
+	resp = httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/alerts/42", nil))
+	cmd = <-cmdCh
+	suite.Equal(AdminAlertStatus, cmd.Action)
+	suite.Equal("42", cmd.Target)
+	suite.Equal(404, resp.Code)
+
+	// Invalid IDs do not match any route
+	resp = httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/alerts/foo", nil))
+	suite.Equal(404, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestFaultsStatus() {
+	event := json.RawMessage(`{"commonEventHeader":{"eventId":"fault0000000003"}}`)
+	cmdCh := suite.reply(AdminResult{Data: []FaultStatus{{ID: 3, Name: "MyFault", EventID: "fault0000000003", LastEvent: event}}})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/faults", nil))
+
+	cmd := <-cmdCh
+	suite.Equal(AdminFaultStatus, cmd.Action)
+	suite.Equal("", cmd.Target)
+	suite.Equal(200, resp.Code)
+	status := []FaultStatus{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&status))
+	suite.Len(status, 1)
+	suite.Equal("fault0000000003", status[0].EventID)
+	suite.JSONEq(string(event), string(status[0].LastEvent))
+	suite.Nil(status[0].LastSent)
+
+	// Faults can be targeted by index or by eventId
+	for _, target := range []string{"3", "fault0000000003"} {
+		cmdCh = suite.reply(AdminResult{Err: ErrNotFound})
+		resp = httptest.NewRecorder()
+		suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/faults/"+target, nil))
+		cmd = <-cmdCh
+		suite.Equal(AdminFaultStatus, cmd.Action)
+		suite.Equal(target, cmd.Target)
+		suite.Equal(404, resp.Code)
+	}
+
+	cmdCh = suite.reply(AdminResult{Err: ErrNotFound})
+	resp = httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/faults/foo", nil))
+	cmd = <-cmdCh
+	suite.Equal("foo", cmd.Target)
+	suite.Equal(404, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestFaultClear() {
+	cmdCh := suite.reply(AdminResult{})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("POST", "/admin/faults/3/clear", nil))
+	cmd := <-cmdCh
+	suite.Equal(AdminFaultClear, cmd.Action)
+	suite.Equal("3", cmd.Target)
+	suite.Equal(204, resp.Code)
+
+	// No event known for the fault
+	cmdCh = suite.reply(AdminResult{Err: ErrConflict})
+	resp = httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("POST", "/admin/faults/3/clear", nil))
+	<-cmdCh
+	suite.Equal(409, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestFaultResend() {
+	cmdCh := suite.reply(AdminResult{Data: FaultStatus{ID: 3, Name: "MyFault", Sequence: 3}})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("POST", "/admin/faults/fault0000000003/resend", nil))
+	cmd := <-cmdCh
+	suite.Equal(AdminFaultResend, cmd.Action)
+	suite.Equal("fault0000000003", cmd.Target)
+	suite.Equal(200, resp.Code)
+	status := FaultStatus{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&status))
+	suite.EqualValues(3, status.Sequence)
+}
+
+func (suite *AdminTestSuite) TestFaultHistory() {
+	at := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	cmdCh := suite.reply(AdminResult{Data: []FaultRecordStatus{{ID: 1, Time: at, Action: "raise", EventID: "fault0000000003", Outcome: "sent"}}})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/faults/history?source=ope-1&alertname=NodeFailure&from=2019-01-01T00:00:00Z", nil))
+
+	cmd := <-cmdCh
+	suite.Equal(AdminFaultHistory, cmd.Action)
+	suite.Equal(HistoryFilter{SourceName: "ope-1", AlertName: "NodeFailure", From: at}, cmd.Filter)
+	suite.Equal(200, resp.Code)
+	history := []FaultRecordStatus{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&history))
+	suite.Len(history, 1)
+	suite.Equal("raise", history[0].Action)
+
+	resp = httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/faults/history?to=yesterday", nil))
+	suite.Equal(400, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestRuleStatus() {
+	cmdCh := suite.reply(AdminResult{Data: []RuleStatus{{Index: 0, Expr: "up", Target: "ConcurrentSessions", Failures: 2, LastError: "bad_data"}}})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/rules", nil))
+	cmd := <-cmdCh
+	suite.Equal(AdminRuleStatus, cmd.Action)
+	suite.Equal(200, resp.Code)
+	rules := []map[string]interface{}{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&rules))
+	suite.Equal([]map[string]interface{}{{
+		"index": 0.0, "expr": "up", "target": "ConcurrentSessions", "failures": 2.0, "totalFailures": 0.0, "lastError": "bad_data",
+	}}, rules)
+}
+
+func (suite *AdminTestSuite) TestSourceStatus() {
+	cmdCh := suite.reply(AdminResult{Data: []SourceStatus{{Name: "thanos", Address: "http://thanos:9090", Failures: 1, TotalFailures: 3, LastError: "timeout"}}})
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/sources", nil))
+	cmd := <-cmdCh
+	suite.Equal(AdminSourceStatus, cmd.Action)
+	suite.Equal(200, resp.Code)
+	sources := []map[string]interface{}{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&sources))
+	suite.Equal([]map[string]interface{}{{
+		"name": "thanos", "address": "http://thanos:9090", "healthy": false, "failures": 1.0, "totalFailures": 3.0, "lastError": "timeout",
+	}}, sources)
+}
+
+func (suite *AdminTestSuite) TestChannelFull() {
+	suite.adminCh <- MessageAdmin{}
+	resp := httptest.NewRecorder()
+	suite.handler.ServeHTTP(resp, httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil))
+	suite.Equal(500, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestBasicAuth() {
+	hdl := BasicAuth("admin", "secret", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusNoContent)
+	}))
+
+	resp := httptest.NewRecorder()
+	hdl.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/schedulers", nil))
+	suite.Equal(401, resp.Code)
+	suite.NotEmpty(resp.Header().Get("WWW-Authenticate"))
+
+	resp = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/schedulers", nil)
+	req.SetBasicAuth("admin", "wrong")
+	hdl.ServeHTTP(resp, req)
+	suite.Equal(401, resp.Code)
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/admin/schedulers", nil)
+	req.SetBasicAuth("admin", "secret")
+	hdl.ServeHTTP(resp, req)
+	suite.Equal(204, resp.Code)
+}
+
+func (suite *AdminTestSuite) TestForwardToLeader() {
+	local := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusNoContent)
+	})
+	var forwarded *http.Request
+	leader := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		forwarded = req
+		resp.WriteHeader(http.StatusAccepted)
+	}))
+	defer leader.Close()
+	leaderURL, _ := url.Parse(leader.URL)
+
+	// Leader serves requests locally
+	cluster := &leadershipMock{leader: true}
+	resp := httptest.NewRecorder()
+	ForwardToLeader(cluster, nil, local).ServeHTTP(resp, httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil))
+	suite.Equal(204, resp.Code)
+	suite.Nil(forwarded)
+
+	// Follower without known leader
+	cluster = &leadershipMock{leader: false}
+	resp = httptest.NewRecorder()
+	ForwardToLeader(cluster, nil, local).ServeHTTP(resp, httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil))
+	suite.Equal(503, resp.Code)
+	suite.Nil(forwarded)
+
+	// Follower forwards to leader
+	cluster.api = leaderURL.Host
+	resp = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil)
+	req.SetBasicAuth("admin", "secret")
+	ForwardToLeader(cluster, nil, local).ServeHTTP(resp, req)
+	suite.Equal(202, resp.Code)
+	suite.NotNil(forwarded)
+	suite.Equal("/admin/schedulers/heartbeats/trigger", forwarded.URL.Path)
+	suite.NotEmpty(forwarded.Header.Get(ForwardedHeader))
+	user, password, ok := forwarded.BasicAuth()
+	suite.True(ok)
+	suite.Equal("admin", user)
+	suite.Equal("secret", password)
+
+	// Already forwarded requests are not forwarded again
+	forwarded = nil
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil)
+	req.Header.Set(ForwardedHeader, "true")
+	ForwardToLeader(cluster, nil, local).ServeHTTP(resp, req)
+	suite.Equal(503, resp.Code)
+	suite.Nil(forwarded)
+}
@@ -17,42 +17,70 @@
 package rest
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/convert"
 
 	"github.com/prometheus/alertmanager/template"
 	log "github.com/sirupsen/logrus"
 )
 
-// MessageFault contains
-// - the fault received by the server, to be sent to the collector
-// - a channel to get the error (if any) after posting the fault.
-type MessageFault struct {
-	Alert    template.Alert
-	Response chan error
+// ErrQueueFull is returned when too many received alerts are waiting to be processed
+var ErrQueueFull = convert.ErrQueueFull
+
+// AlertQueue stores the received alerts until they are processed
+type AlertQueue interface {
+	// EnqueueAlerts appends the alerts received from `origin` at epoch time `received` to the queue, and returns their IDs.
+	// If `maxPending` is positive, alerts are rejected with ErrQueueFull when the queue would hold more alerts
+	// waiting to be processed
+	EnqueueAlerts(alerts []template.Alert, origin convert.AlertOrigin, received int64, maxPending int) ([]int64, error)
 }
 
-// decodeJSON function used to extract Alerts from http datas
-func decodeJSON(resp http.ResponseWriter, req *http.Request) template.Alerts {
-	//fmt.Println("decode json request")
+// OriginHeader is the header giving the origin of alerts forwarded to the cluster's leader,
+// when they were not received from Alertmanager. It's ignored on requests which aren't forwarded
+const OriginHeader = "X-Ves-Agent-Origin"
 
-	data := template.Data{}
+// AlertReceipt is the reply to an accepted alerts notification
+type AlertReceipt struct {
+	IDs []int64 `json:"ids"` // IDs of the queued alerts, in notification's order
+}
 
+// decodeJSON function used to extract Alerts from http datas
+func decodeJSON(req *http.Request) (template.Alerts, error) {
+	data := template.Data{}
 	decoder := json.NewDecoder(req.Body)
 	if err := decoder.Decode(&data); err != nil {
-		log.Errorf("Bad request from %s: %s\n", req.RemoteAddr, err.Error())
-		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return nil, err
 	}
-	alertsmsg := data.Alerts
+	return data.Alerts, nil
+}
 
-	//fmt.Printf("data received: %+v\n", alertsmsg)
-	return alertsmsg
+// EnqueueAlerts appends `alerts` received from `origin` to `queue`, and returns their IDs. If `maxPending` is positive,
+// alerts are rejected with ErrQueueFull when the queue would hold more alerts waiting to be processed
+func EnqueueAlerts(queue AlertQueue, maxPending int, alerts []template.Alert, origin convert.AlertOrigin) ([]int64, error) {
+	ids, err := queue.EnqueueAlerts(alerts, origin, time.Now().Unix(), maxPending)
+	if err == ErrQueueFull {
+		log.Warnf("Rejecting %d alerts: %s", len(alerts), err.Error())
+		return nil, err
+	}
+	if err != nil {
+		log.Errorf("Cannot queue alerts: %s", err.Error())
+		return nil, err
+	}
+	return ids, nil
 }
 
-// AlertReceiver is an handler to manage  http POST alert
-func AlertReceiver(alertCh chan MessageFault) http.Handler {
+// AlertReceiver is an handler to manage http POST alert. Received alerts are appended to `queue`,
+// and `notify` is called for them to be processed asynchronously. If `maxPending` is positive,
+// notifications are rejected when the queue would hold more alerts waiting to be processed
+func AlertReceiver(queue AlertQueue, maxPending int, notify func()) http.Handler {
 	hd1 := func(resp http.ResponseWriter, req *http.Request) error {
 		contentType := req.Header.Get("Content-Type")
 		if contentType != "application/json" {
@@ -60,26 +88,95 @@ func AlertReceiver(alertCh chan MessageFault) http.Handler {
 			//resp.WriteHeader(http.StatusInternalServerError)
 			return errors.New("content-type %s not managed")
 		}
-		alertsmsg := decodeJSON(resp, req)
-		for i := range alertsmsg {
-			errorCh := make(chan error)
-			message := MessageFault{Alert: alertsmsg[i], Response: errorCh}
-			// Non blocking write, to avoid a dead lock situation
-			select {
-			case alertCh <- message:
-				//wait for PostEvent result
-				err := <-errorCh
-				if err != nil {
-					log.Errorf("Cannot process alert: %s", err.Error())
-					return err
-				}
-			default:
-				err := fmt.Errorf("Alert %s could not be sent to a channel", alertsmsg[i].Labels["alertname"])
-				log.Warn(err.Error())
+		origin := convert.OriginAlertmanager
+		// The origin is given only by alerts forwarded from another cluster member
+		if req.Header.Get(ForwardedHeader) != "" {
+			origin = convert.AlertOrigin(req.Header.Get(OriginHeader))
+			if !origin.Valid() {
+				log.Errorf("Bad request from %s: unknown alert origin %s\n", req.RemoteAddr, origin)
+				http.Error(resp, "Unknown alert origin", http.StatusBadRequest)
+				return nil
+			}
+		}
+		alertsmsg, err := decodeJSON(req)
+		if err != nil {
+			log.Errorf("Bad request from %s: %s\n", req.RemoteAddr, err.Error())
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		receipt := AlertReceipt{IDs: []int64{}}
+		if len(alertsmsg) > 0 {
+			if receipt.IDs, err = EnqueueAlerts(queue, maxPending, alertsmsg, origin); err != nil {
 				return err
 			}
+			notify()
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(resp).Encode(receipt); err != nil {
+			log.Errorf("HTTP Handler - Cannot write response: %s", err.Error())
 		}
 		return nil
 	}
 	return errorWrapper(hd1)
 }
+
+// AlertForwarder posts alerts to the alert webhook of the cluster's leader
+type AlertForwarder struct {
+	cluster                     Leadership
+	client                      *http.Client
+	scheme, path                string
+	user, password, bearerToken string
+}
+
+// NewAlertForwarder creates an AlertForwarder posting alerts to the webhook at `path`, authenticated with
+// the basic authentication credentials `user` and `password`, or with the bearer `token`, if not empty.
+// If `tlsConfig` is not nil, alerts are posted with HTTPS, as for requests forwarded by ForwardToLeader
+func NewAlertForwarder(cluster Leadership, tlsConfig *tls.Config, path, user, password, token string) *AlertForwarder {
+	scheme, transport := leaderTransport(tlsConfig)
+	return &AlertForwarder{
+		cluster:     cluster,
+		client:      &http.Client{Transport: transport, Timeout: 10 * time.Second},
+		scheme:      scheme,
+		path:        path,
+		user:        user,
+		password:    password,
+		bearerToken: token,
+	}
+}
+
+// Forward posts `alerts` received from `origin` to the leader
+func (fwd *AlertForwarder) Forward(alerts []template.Alert, origin convert.AlertOrigin) error {
+	addr, err := fwd.cluster.LeaderAPI()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(template.Data{Alerts: alerts})
+	if err != nil {
+		return err
+	}
+	u := url.URL{Scheme: fwd.scheme, Host: addr, Path: fwd.path}
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ForwardedHeader, "true")
+	if origin != convert.OriginAlertmanager {
+		req.Header.Set(OriginHeader, string(origin))
+	}
+	if fwd.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+fwd.bearerToken)
+	} else if fwd.user != "" {
+		req.SetBasicAuth(fwd.user, fwd.password)
+	}
+	log.Debugf("Forwarding %d alerts to leader at %s", len(alerts), addr)
+	resp, err := fwd.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Leader replied with status %s", resp.Status)
+	}
+	return nil
+}
@@ -17,12 +17,15 @@
 package rest
 
 import (
-	"github.com/nokia/onap-vespa/govel"
+	"errors"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+
 	"github.com/nokia/onap-vespa/ves-agent/convert"
 
+	"github.com/prometheus/alertmanager/template"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -127,133 +130,155 @@ var postinvaliddata = []byte(`
 }
 `)
 
+// queueMock is an in memory AlertQueue
+type queueMock struct {
+	alerts   []template.Alert
+	origins  []convert.AlertOrigin
+	notified int
+	err      error
+}
+
+func (queue *queueMock) EnqueueAlerts(alerts []template.Alert, origin convert.AlertOrigin, received int64, maxPending int) ([]int64, error) {
+	if queue.err != nil {
+		return nil, queue.err
+	}
+	if maxPending > 0 && len(queue.alerts)+len(alerts) > maxPending {
+		return nil, ErrQueueFull
+	}
+	ids := make([]int64, 0, len(alerts))
+	for _, alert := range alerts {
+		queue.alerts = append(queue.alerts, alert)
+		queue.origins = append(queue.origins, origin)
+		ids = append(ids, int64(len(queue.alerts)))
+	}
+	return ids, nil
+}
+
+func (queue *queueMock) notify() {
+	queue.notified++
+}
+
 type HandlerTestSuite struct {
 	suite.Suite
-	confEvent govel.EventConfiguration
-	fm        *convert.FaultManager
-	faultID   int32
+	queue *queueMock
 }
 
 func TestHandler(t *testing.T) {
 	suite.Run(t, new(HandlerTestSuite))
 }
 
-func (suite *HandlerTestSuite) SetupSuite() {
-	suite.confEvent = govel.EventConfiguration{
-		MaxSize:      200,
-		NfNamingCode: "hspx",
-	}
-	suite.fm = convert.NewFaultManager(&suite.confEvent)
-	suite.faultID = 0
+func (suite *HandlerTestSuite) SetupTest() {
+	suite.queue = &queueMock{}
 }
 
-func (suite *HandlerTestSuite) TestHandlerData1Ok() {
+func (suite *HandlerTestSuite) post(data []byte, contentType string, maxPending int) *httptest.ResponseRecorder {
 	resp := httptest.NewRecorder()
-	req := httptest.NewRequest("POST", "/alerts", strings.NewReader(string(postdata1)))
-	req.Header.Set("content-Type", "application/json")
-
-	alertCh := make(chan MessageFault)
-
-	alertRoute.HandlerFunc = AlertReceiver(alertCh)
+	req := httptest.NewRequest("POST", "/alerts", strings.NewReader(string(data)))
+	if contentType != "" {
+		req.Header.Set("content-Type", contentType)
+	}
+	alertRoute.HandlerFunc = AlertReceiver(suite.queue, maxPending, suite.queue.notify)
 	// create an unstarted new server to receive http POST from prometheus
 	alertHandler := NewServer([]Route{alertRoute})
-	go alertHandler.ServeHTTP(resp, req)
-	messageFault := <-alertCh
-	//suite.Equal("Fault_hspx_AlertNodeFailure", messageFault.Fault.EventName)
-	suite.Equal("AlertNodeFailure1", messageFault.Alert.Labels["alertname"])
-	suite.Equal(200, resp.Code, "Bad HTTP response status code")
-
-	close(messageFault.Response)
+	alertHandler.ServeHTTP(resp, req)
+	return resp
 }
 
-func (suite *HandlerTestSuite) TestHandlerData2Ok() {
-	resp := httptest.NewRecorder()
-	req := httptest.NewRequest("POST", "/alerts", strings.NewReader(string(postdata2)))
-	req.Header.Set("content-Type", "application/json")
-
-	alertCh := make(chan MessageFault)
-
-	alertRoute.HandlerFunc = AlertReceiver(alertCh)
-	alertHandler := NewServer([]Route{alertRoute})
-	go alertHandler.ServeHTTP(resp, req)
-	messageFault := <-alertCh
-	//suite.Equal("Fault_hspx_AlertNodeFailure", messageFault.Fault.EventName)
-	suite.Equal("AlertNodeFailure21", messageFault.Alert.Labels["alertname"])
-	suite.Equal(200, resp.Code, "Bad HTTP response status code")
-	close(messageFault.Response)
-	messageFault = <-alertCh
-	//suite.Equal("Fault_hspx_AlertNodeFailure", messageFault.Fault.EventName)
-	suite.Equal("AlertNodeFailure22", messageFault.Alert.Labels["alertname"])
+func (suite *HandlerTestSuite) TestHandlerData1Ok() {
+	resp := suite.post(postdata1, "application/json", 0)
 	suite.Equal(200, resp.Code, "Bad HTTP response status code")
-	close(messageFault.Response)
-
-	// Also check that blocked channel will receive nothing and won't cause deadlock
-	select {
-	case <-alertCh:
-		suite.Fail("Channel should be empty")
-	default:
-	}
+	suite.Require().Len(suite.queue.alerts, 1)
+	suite.Equal("AlertNodeFailure1", suite.queue.alerts[0].Labels["alertname"])
+	suite.Equal(convert.OriginAlertmanager, suite.queue.origins[0])
+	suite.Equal(1, suite.queue.notified)
+	suite.JSONEq(`{"ids": [1]}`, resp.Body.String())
 }
 
-func (suite *HandlerTestSuite) TestHandlerData1InvalidContent() {
+func (suite *HandlerTestSuite) TestHandlerInvalidOrigin() {
 	resp := httptest.NewRecorder()
 	req := httptest.NewRequest("POST", "/alerts", strings.NewReader(string(postdata1)))
-	//req.Header.Set("content-Type", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ForwardedHeader, "true")
+	req.Header.Set(OriginHeader, "unknown")
+	AlertReceiver(suite.queue, 0, suite.queue.notify).ServeHTTP(resp, req)
+	suite.Equal(400, resp.Code, "Bad HTTP response status code")
+	suite.Empty(suite.queue.alerts)
+	suite.Zero(suite.queue.notified)
+}
 
-	alertCh := make(chan MessageFault)
+func (suite *HandlerTestSuite) TestHandlerOriginNotForwarded() {
+	// Alertmanager notifications can't claim another origin
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/alerts", strings.NewReader(string(postdata1)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(OriginHeader, string(convert.OriginSNMP))
+	AlertReceiver(suite.queue, 0, suite.queue.notify).ServeHTTP(resp, req)
+	suite.Equal(200, resp.Code, "Bad HTTP response status code")
+	suite.Require().Len(suite.queue.origins, 1)
+	suite.Equal(convert.OriginAlertmanager, suite.queue.origins[0])
+}
 
-	alertRoute.HandlerFunc = AlertReceiver(alertCh)
-	alertHandler := NewServer([]Route{alertRoute})
-	alertHandler.ServeHTTP(resp, req)
+func (suite *HandlerTestSuite) TestHandlerData2Ok() {
+	resp := suite.post(postdata2, "application/json", 0)
+	suite.Equal(200, resp.Code, "Bad HTTP response status code")
+	suite.Require().Len(suite.queue.alerts, 2)
+	suite.Equal("AlertNodeFailure21", suite.queue.alerts[0].Labels["alertname"])
+	suite.Equal("AlertNodeFailure22", suite.queue.alerts[1].Labels["alertname"])
+	suite.Equal(1, suite.queue.notified)
+	suite.JSONEq(`{"ids": [1, 2]}`, resp.Body.String())
+}
 
+func (suite *HandlerTestSuite) TestHandlerData1InvalidContent() {
+	resp := suite.post(postdata1, "", 0)
 	suite.Equal(500, resp.Code, "Bad HTTP response status code")
-	//check invalide fault is not sent to channel
-	select {
-	case <-alertCh:
-		suite.Fail("Channel should be empty")
-	default:
-	}
+	//check invalide fault is not queued
+	suite.Empty(suite.queue.alerts)
+	suite.Zero(suite.queue.notified)
 }
 
 func (suite *HandlerTestSuite) TestHandlerData1InvalidData() {
-	resp := httptest.NewRecorder()
-	req := httptest.NewRequest("POST", "/alerts", strings.NewReader(string(postinvaliddata)))
-	req.Header.Set("content-Type", "application/json")
-
-	alertCh := make(chan MessageFault)
-
-	alertRoute.HandlerFunc = AlertReceiver(alertCh)
-	alertHandler := NewServer([]Route{alertRoute})
-	alertHandler.ServeHTTP(resp, req)
-
+	resp := suite.post(postinvaliddata, "application/json", 0)
 	suite.Equal(400, resp.Code, "Bad HTTP response status code")
-	//check invalide fault is not sent to channel
-	select {
-	case <-alertCh:
-		suite.Fail("Channel should be empty")
-	default:
-	}
+	//check invalide fault is not queued
+	suite.Empty(suite.queue.alerts)
+	suite.Zero(suite.queue.notified)
 }
 
-func (suite *HandlerTestSuite) TestHandlerDataFaultAlredyExist() {
-	resp := httptest.NewRecorder()
-	req1 := httptest.NewRequest("POST", "/alerts", strings.NewReader(string(postdata1)))
-	req1.Header.Set("content-Type", "application/json")
-
-	alertCh := make(chan MessageFault)
+func (suite *HandlerTestSuite) TestHandlerQueueFull() {
+	suite.Equal(200, suite.post(postdata1, "application/json", 2).Code)
+	// Whole notification is rejected if it does not fit
+	suite.Equal(503, suite.post(postdata2, "application/json", 2).Code)
+	suite.Len(suite.queue.alerts, 1)
+	suite.Equal(200, suite.post(postdata1, "application/json", 2).Code)
+	suite.Len(suite.queue.alerts, 2)
+	suite.Equal(2, suite.queue.notified)
+}
 
-	alertRoute.HandlerFunc = AlertReceiver(alertCh)
-	alertHandler := NewServer([]Route{alertRoute})
-	go alertHandler.ServeHTTP(resp, req1)
-	messageFault := <-alertCh
-	close(messageFault.Response)
+func (suite *HandlerTestSuite) TestHandlerQueueError() {
+	suite.queue.err = errors.New("node is not the leader")
+	resp := suite.post(postdata1, "application/json", 0)
+	suite.Equal(500, resp.Code, "Bad HTTP response status code")
+	suite.Equal("node is not the leader", resp.Body.String())
+	suite.Zero(suite.queue.notified)
+}
 
-	suite.Equal(200, resp.Code, "Bad HTTP response status code")
-	select {
-	case messageFault := <-alertCh:
-		//suite.Equal("Fault_hspx_AlertNodeFailure", messageFault.Fault.EventName)
-		suite.Equal("AlertNodeFailure1", messageFault.Alert.Labels["alertname"])
-		suite.Equal(200, resp.Code, "Bad HTTP response status code")
-	default:
-	}
+func (suite *HandlerTestSuite) TestAlertForwarder() {
+	leader := httptest.NewServer(Auth("", "", "token", AlertReceiver(suite.queue, 0, suite.queue.notify)))
+	defer leader.Close()
+	leaderURL, _ := url.Parse(leader.URL)
+	alerts := []template.Alert{{Status: "firing", Labels: template.KV{"alertname": "LinkDown"}}}
+
+	cluster := &leadershipMock{}
+	suite.Error(NewAlertForwarder(cluster, nil, "/alerts", "", "", "token").Forward(alerts, convert.OriginSNMP))
+
+	cluster.api = leaderURL.Host
+	suite.NoError(NewAlertForwarder(cluster, nil, "/alerts", "", "", "token").Forward(alerts, convert.OriginSNMP))
+	suite.Require().Len(suite.queue.alerts, 1)
+	suite.Equal("LinkDown", suite.queue.alerts[0].Labels["alertname"])
+	suite.Equal(convert.OriginSNMP, suite.queue.origins[0])
+	suite.Equal(1, suite.queue.notified)
+
+	// Rejected by the leader
+	suite.Error(NewAlertForwarder(cluster, nil, "/alerts", "", "", "bad").Forward(alerts, convert.OriginSNMP))
+	suite.Len(suite.queue.alerts, 1)
 }
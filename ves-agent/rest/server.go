@@ -17,8 +17,17 @@
 package rest
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
@@ -32,12 +41,21 @@ type Route struct {
 	HandlerFunc http.Handler
 }
 
-// StartServer is used to rest server initialization and start up
-func StartServer(binAddr string, handler http.Handler) {
+// StartServer is used to rest server initialization and start up.
+// If `tlsConfig` is not nil, the server is started with HTTPS
+func StartServer(binAddr string, handler http.Handler, tlsConfig *tls.Config) {
 
 	if handler != nil {
 		log.Debug("router correctly initialized for ", binAddr)
-		if err := http.ListenAndServe(binAddr, handler); err != nil {
+		var err error
+		if tlsConfig != nil {
+			server := &http.Server{Addr: binAddr, Handler: handler, TLSConfig: tlsConfig}
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Warn("Insecure REST server using HTTP")
+			err = http.ListenAndServe(binAddr, handler)
+		}
+		if err != nil {
 			if err != http.ErrServerClosed {
 				log.Fatal("Cannot start server: ", err.Error())
 			}
@@ -61,12 +79,70 @@ func NewServer(routes []Route) http.Handler {
 	return router
 }
 
+// NewTLSConfig creates the TLS configuration of a server, loading the server
+// certificate and key from `certFile` and `keyFile`. If `clientCAFile` is not empty,
+// clients must present a certificate signed by one of the CAs it contains.
+// The leader's certificate is verified against the CAs of `peerCAFile` when requests are forwarded
+// to it. If empty, the client CAs are used, or else the system CAs and the server's own certificate chain
+func NewTLSConfig(certFile, keyFile, clientCAFile, peerCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		clientCAs, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot load client CA: %s", err.Error())
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	// RootCAs is not used by the server itself, only when forwarding requests to the leader
+	switch {
+	case peerCAFile != "":
+		if tlsConfig.RootCAs, err = loadCertPool(peerCAFile); err != nil {
+			return nil, fmt.Errorf("Cannot load peer CA: %s", err.Error())
+		}
+	case tlsConfig.ClientCAs != nil:
+		tlsConfig.RootCAs = tlsConfig.ClientCAs
+	default:
+		if tlsConfig.RootCAs, err = x509.SystemCertPool(); err != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+		}
+		for _, der := range cert.Certificate {
+			c, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs.AddCert(c)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// loadCertPool reads a pool of certificates from PEM file `file`
+func loadCertPool(file string) (*x509.CertPool, error) {
+	caBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBytes) {
+		return nil, errors.New("PEM not valid")
+	}
+	return pool, nil
+}
+
 // errorWrapper takes a function `f` which returns an error, and transform it
-// into an `http.Handler` which replies an HTTP error if `f` returns an error
+// into an `http.Handler` which replies the HTTP error matching the error returned by `f`, if any
 func errorWrapper(f func(resp http.ResponseWriter, req *http.Request) error) http.Handler {
 	hdl := func(resp http.ResponseWriter, req *http.Request) {
 		if err := f(resp, req); err != nil {
-			resp.WriteHeader(http.StatusInternalServerError)
+			resp.WriteHeader(errorStatus(err))
 			if _, err = io.WriteString(resp, err.Error()); err != nil {
 				log.Errorf("%s", err.Error())
 			}
@@ -74,3 +150,105 @@ func errorWrapper(f func(resp http.ResponseWriter, req *http.Request) error) htt
 	}
 	return http.HandlerFunc(hdl)
 }
+
+// ForwardedHeader is the header set on requests forwarded to the cluster's leader
+const ForwardedHeader = "X-Ves-Agent-Forwarded"
+
+// Leadership gives information about the cluster's leader
+type Leadership interface {
+	// IsLeader returns true if the current node is the cluster's leader
+	IsLeader() bool
+	// LeaderAPI returns the REST API address of the cluster's leader
+	LeaderAPI() (string, error)
+}
+
+// BasicAuth wraps `handler` into an `http.Handler` which
+// rejects requests without the expected basic authentication credentials
+func BasicAuth(user, password string, handler http.Handler) http.Handler {
+	return Auth(user, password, "", handler)
+}
+
+// Auth wraps `handler` into an `http.Handler` which rejects requests
+// authenticated neither with the basic authentication credentials `user` and `password`,
+// nor with the bearer `token`. Empty credentials are never accepted
+func Auth(user, password, token string, handler http.Handler) http.Handler {
+	hdl := func(resp http.ResponseWriter, req *http.Request) {
+		if !checkBasicAuth(req, user, password) && !checkBearerAuth(req, token) {
+			if user != "" {
+				resp.Header().Add("WWW-Authenticate", `Basic realm="ves-agent"`)
+			}
+			if token != "" {
+				resp.Header().Add("WWW-Authenticate", `Bearer realm="ves-agent"`)
+			}
+			http.Error(resp, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(resp, req)
+	}
+	return http.HandlerFunc(hdl)
+}
+
+func checkBasicAuth(req *http.Request, user, password string) bool {
+	if user == "" || password == "" {
+		return false
+	}
+	u, p, ok := req.BasicAuth()
+	return ok && subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+}
+
+func checkBearerAuth(req *http.Request, token string) bool {
+	const prefix = "Bearer "
+	if token == "" {
+		return false
+	}
+	auth := req.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) == 1
+}
+
+// ForwardToLeader wraps `handler` into an `http.Handler` which serves requests
+// locally when the current node is the cluster's leader, and proxies them
+// to the leader otherwise. Requests are forwarded only once.
+// If `tlsConfig` is not nil, requests are forwarded with HTTPS, presenting the same certificate
+// than the local server, and verifying the leader's one against the peer CAs
+func ForwardToLeader(cluster Leadership, tlsConfig *tls.Config, handler http.Handler) http.Handler {
+	scheme, transport := leaderTransport(tlsConfig)
+	hdl := func(resp http.ResponseWriter, req *http.Request) {
+		if cluster.IsLeader() {
+			handler.ServeHTTP(resp, req)
+			return
+		}
+		if req.Header.Get(ForwardedHeader) != "" {
+			http.Error(resp, ErrNotLeader.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		addr, err := cluster.LeaderAPI()
+		if err != nil {
+			log.Warnf("Cannot forward request to leader: %s", err.Error())
+			http.Error(resp, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		log.Debugf("Forwarding %s %s to leader at %s", req.Method, req.URL.Path, addr)
+		req.Header.Set(ForwardedHeader, "true")
+		proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: scheme, Host: addr})
+		proxy.Transport = transport
+		proxy.ServeHTTP(resp, req)
+	}
+	return http.HandlerFunc(hdl)
+}
+
+// leaderTransport returns the URL scheme and the transport of requests to the cluster's leader.
+// If `tlsConfig` is not nil, requests use HTTPS, presenting the same certificate
+// than the local server, and verifying the leader's one against the peer CAs
+func leaderTransport(tlsConfig *tls.Config) (string, http.RoundTripper) {
+	if tlsConfig == nil {
+		return "http", nil
+	}
+	return "https", &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{Certificates: tlsConfig.Certificates, RootCAs: tlsConfig.RootCAs},
+	}
+}
@@ -0,0 +1,258 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (suite *ServerTestSuite) SetupSuite() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "ves-agent-rest")
+	suite.Require().NoError(err)
+
+	ca, caKey := suite.generateCertificate("ca", nil, nil, true)
+	suite.generateCertificate("server", ca, caKey, false)
+	suite.generateCertificate("client", ca, caKey, false)
+	suite.Require().NoError(ioutil.WriteFile(suite.path("invalid.pem"), []byte("not a PEM"), 0600))
+}
+
+func (suite *ServerTestSuite) TearDownSuite() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *ServerTestSuite) path(name string) string {
+	return filepath.Join(suite.dir, name)
+}
+
+// generateCertificate creates a certificate and its key in files `<name>.pem` and `<name>-key.pem`,
+// signed by `parent`. The certificate is self-signed if `parent` is nil
+func (suite *ServerTestSuite) generateCertificate(name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.Require().NoError(err)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = &template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, parent, &key.PublicKey, parentKey)
+	suite.Require().NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	suite.Require().NoError(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	suite.Require().NoError(err)
+	suite.Require().NoError(ioutil.WriteFile(suite.path(name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	suite.Require().NoError(ioutil.WriteFile(suite.path(name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, key
+}
+
+// client creates an HTTPS client trusting the test CA, and presenting the certificate `name` if not empty
+func (suite *ServerTestSuite) client(name string) *http.Client {
+	caBytes, err := ioutil.ReadFile(suite.path("ca.pem"))
+	suite.Require().NoError(err)
+	rootCAs := x509.NewCertPool()
+	rootCAs.AppendCertsFromPEM(caBytes)
+	tlsConfig := &tls.Config{RootCAs: rootCAs}
+	if name != "" {
+		cert, err := tls.LoadX509KeyPair(suite.path(name+".pem"), suite.path(name+"-key.pem"))
+		suite.Require().NoError(err)
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+}
+
+func noContent(resp http.ResponseWriter, req *http.Request) {
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+func (suite *ServerTestSuite) TestAuth() {
+	check := func(hdl http.Handler, code int, setup func(req *http.Request)) {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/alerts", nil)
+		if setup != nil {
+			setup(req)
+		}
+		hdl.ServeHTTP(resp, req)
+		suite.Equal(code, resp.Code)
+	}
+	basic := func(user, password string) func(req *http.Request) {
+		return func(req *http.Request) { req.SetBasicAuth(user, password) }
+	}
+	bearer := func(token string) func(req *http.Request) {
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	// Basic authentication only
+	hdl := Auth("user", "pass", "", http.HandlerFunc(noContent))
+	check(hdl, 401, nil)
+	check(hdl, 401, basic("user", "wrong"))
+	check(hdl, 401, bearer(""))
+	check(hdl, 204, basic("user", "pass"))
+
+	// Bearer token only
+	hdl = Auth("", "", "mytoken", http.HandlerFunc(noContent))
+	check(hdl, 401, nil)
+	check(hdl, 401, basic("", ""))
+	check(hdl, 401, bearer("othertoken"))
+	check(hdl, 401, func(req *http.Request) { req.Header.Set("Authorization", "mytoken") })
+	check(hdl, 204, bearer("mytoken"))
+	check(hdl, 204, func(req *http.Request) { req.Header.Set("Authorization", "bearer mytoken") })
+
+	// Both
+	hdl = Auth("user", "pass", "mytoken", http.HandlerFunc(noContent))
+	check(hdl, 401, nil)
+	check(hdl, 204, basic("user", "pass"))
+	check(hdl, 204, bearer("mytoken"))
+	resp := httptest.NewRecorder()
+	hdl.ServeHTTP(resp, httptest.NewRequest("POST", "/alerts", nil))
+	suite.Len(resp.Header()["Www-Authenticate"], 2)
+}
+
+func (suite *ServerTestSuite) TestNewTLSConfigErrors() {
+	_, err := NewTLSConfig(suite.path("missing.pem"), suite.path("server-key.pem"), "", "")
+	suite.Error(err)
+	_, err = NewTLSConfig(suite.path("server.pem"), suite.path("client-key.pem"), "", "")
+	suite.Error(err)
+	_, err = NewTLSConfig(suite.path("server.pem"), suite.path("server-key.pem"), suite.path("missing.pem"), "")
+	suite.Error(err)
+	_, err = NewTLSConfig(suite.path("server.pem"), suite.path("server-key.pem"), suite.path("invalid.pem"), "")
+	suite.Error(err)
+	_, err = NewTLSConfig(suite.path("server.pem"), suite.path("server-key.pem"), "", suite.path("invalid.pem"))
+	suite.Error(err)
+}
+
+func (suite *ServerTestSuite) TestTLSServer() {
+	tlsConfig, err := NewTLSConfig(suite.path("server.pem"), suite.path("server-key.pem"), "", "")
+	suite.Require().NoError(err)
+	suite.Equal(tls.NoClientCert, tlsConfig.ClientAuth)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(noContent))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	resp, err := suite.client("").Post(server.URL+"/alerts", "application/json", nil)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Equal(204, resp.StatusCode)
+}
+
+func (suite *ServerTestSuite) TestTLSServerClientCert() {
+	tlsConfig, err := NewTLSConfig(suite.path("server.pem"), suite.path("server-key.pem"), suite.path("ca.pem"), "")
+	suite.Require().NoError(err)
+	suite.Equal(tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(noContent))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	// Without client certificate
+	_, err = suite.client("").Post(server.URL+"/alerts", "application/json", nil)
+	suite.Error(err)
+
+	// With client certificate
+	resp, err := suite.client("client").Post(server.URL+"/alerts", "application/json", nil)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Equal(204, resp.StatusCode)
+}
+
+func (suite *ServerTestSuite) TestForwardToLeaderTLS() {
+	tlsConfig, err := NewTLSConfig(suite.path("server.pem"), suite.path("server-key.pem"), suite.path("ca.pem"), "")
+	suite.Require().NoError(err)
+	leader := httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusAccepted)
+	}))
+	leader.TLS = tlsConfig
+	leader.StartTLS()
+	defer leader.Close()
+	leaderURL, _ := url.Parse(leader.URL)
+
+	cluster := &leadershipMock{leader: false, api: leaderURL.Host}
+	resp := httptest.NewRecorder()
+	ForwardToLeader(cluster, tlsConfig, http.HandlerFunc(noContent)).ServeHTTP(resp, httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil))
+	suite.Equal(202, resp.Code)
+}
+
+func (suite *ServerTestSuite) TestForwardToLeaderTLSWithoutClientCA() {
+	forward := func(leaderConfig, tlsConfig *tls.Config) int {
+		leader := httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			resp.WriteHeader(http.StatusAccepted)
+		}))
+		leader.TLS = leaderConfig
+		leader.StartTLS()
+		defer leader.Close()
+		leaderURL, _ := url.Parse(leader.URL)
+
+		cluster := &leadershipMock{leader: false, api: leaderURL.Host}
+		resp := httptest.NewRecorder()
+		ForwardToLeader(cluster, tlsConfig, http.HandlerFunc(noContent)).ServeHTTP(resp, httptest.NewRequest("POST", "/admin/schedulers/heartbeats/trigger", nil))
+		return resp.Code
+	}
+	// Cluster members sharing the same certificate trust it
+	tlsConfig, err := NewTLSConfig(suite.path("server.pem"), suite.path("server-key.pem"), "", "")
+	suite.Require().NoError(err)
+	suite.Equal(202, forward(tlsConfig, tlsConfig))
+
+	// Otherwise the leader's certificate is verified against the peer CAs
+	leaderConfig, err := NewTLSConfig(suite.path("client.pem"), suite.path("client-key.pem"), "", "")
+	suite.Require().NoError(err)
+	suite.Equal(502, forward(leaderConfig, tlsConfig))
+	tlsConfig, err = NewTLSConfig(suite.path("server.pem"), suite.path("server-key.pem"), "", suite.path("ca.pem"))
+	suite.Require().NoError(err)
+	suite.Equal(202, forward(leaderConfig, tlsConfig))
+}
@@ -0,0 +1,85 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/agent"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// isRulesTest tells if the command line runs the `rules test` subcommand
+func isRulesTest(args []string) bool {
+	return len(args) > 2 && args[1] == "rules" && args[2] == "test"
+}
+
+// parseTime parses the RFC 3339 time `value`, or returns `def` if it's empty
+func parseTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// testRules runs the `rules test` subcommand: it evaluates the metric rules on a time window, and
+// prints the report of the rules and the measurement events they build, without sending them.
+// It returns the exit status: 1 if a rule failed or if events are not valid
+func testRules() int {
+	flags := pflag.NewFlagSet("rules test", pflag.ExitOnError)
+	rule := flags.Int("rule", -1, "Index of the tested metric rule. All rules are tested if negative")
+	from := flags.String("from", "", "Start of the tested window (RFC 3339). Defaults to one measurement interval before its end")
+	to := flags.String("to", "", "End of the tested window (RFC 3339). Defaults to now")
+
+	var conf config.VESAgentConfiguration
+	if err := config.InitConfWithFlags(&conf, flags); err != nil {
+		log.Fatal("Cannot read config file: ", err.Error())
+	}
+	initLogging(conf.Debug)
+
+	end, err := parseTime(*to, time.Now())
+	if err != nil {
+		log.Fatal("Invalid end of window: ", err.Error())
+	}
+	start, err := parseTime(*from, end.Add(-conf.Measurement.DefaultInterval))
+	if err != nil {
+		log.Fatal("Invalid start of window: ", err.Error())
+	}
+	if start.After(end) {
+		log.Fatalf("Window start %s is after its end %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	report, err := agent.DryRunRules(&conf, *rule, start, end)
+	if err != nil {
+		log.Fatal("Cannot test metric rules: ", err.Error())
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Fatal("Cannot serialize report: ", err.Error())
+	}
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
@@ -40,11 +40,17 @@ type State interface {
 	UpdateInterval(name string, interval time.Duration) error
 	// UpdateScheduler set both interval and next execution time for the scheduler
 	UpdateScheduler(name string, interval time.Duration, next time.Time) error
+	// LastAck returns the boundaries of the last acknowledged execution window
+	LastAck(name string) (from, to time.Time)
+	// Acknowledge records the acknowledged execution window and sets the time of the next execution
+	Acknowledge(name string, from, to, next time.Time) error
 }
 
 type inMemState struct {
 	next     time.Time
 	interval time.Duration
+	ackFrom  time.Time
+	ackTo    time.Time
 }
 
 func (state *inMemState) NextRun(_ string) time.Time {
@@ -66,6 +72,15 @@ func (state *inMemState) UpdateScheduler(_ string, interval time.Duration, next
 	state.next = next
 	return nil
 }
+func (state *inMemState) LastAck(_ string) (time.Time, time.Time) {
+	return state.ackFrom, state.ackTo
+}
+func (state *inMemState) Acknowledge(_ string, from, to, next time.Time) error {
+	state.ackFrom = from
+	state.ackTo = to
+	state.next = next
+	return nil
+}
 
 // Job is an interface to a schedulable task
 type Job interface {
@@ -81,6 +96,15 @@ func (job JobFunc) Run(from, to time.Time, interval time.Duration) (interface{},
 	return job(from, to, interval)
 }
 
+// Backfill configures how a scheduler catches up with a long window, e.g. after an outage.
+// The window is truncated to the most recent `MaxAge`, then run in chunks of at most `Chunk`
+// which are acknowledged separately. Catch-up runs are delayed by `Delay` from each other
+type Backfill struct {
+	Chunk  time.Duration // Maximum window duration of a run. 0 to disable backfill in chunks
+	MaxAge time.Duration // Maximum age of a window's start time. 0 for no limit
+	Delay  time.Duration // Delay between 2 catch-up runs
+}
+
 // Scheduler schedules a job on a periodic interval. It provides on each Job run
 // some timing information about the interval being invoked.
 // After a run, the scheduler must receive Akcnowledge to commit the interval
@@ -91,7 +115,11 @@ type Scheduler struct {
 	name            string        // The scheduler name
 	defaultInterval time.Duration // Default interval between each run
 	job             Job           // Job to periodically execute
+	lastFrom        time.Time     // Start time of last successful, unacknowledged run
 	lastTime        *time.Time    // Last time of successful, unacknowleged run
+	lastChunk       bool          // Whether last successful, unacknowledged run was a catch-up chunk
+	backfill        Backfill      // Catch-up configuration
+	catchUp         time.Time     // Time of the next catch-up run. Zero when not catching up
 	state           State         // Scheduler internal state
 }
 
@@ -111,11 +139,26 @@ func NewScheduler(name string, job Job, defaultInterval time.Duration) *Schedule
 	return NewSchedulerWithState(name, job, defaultInterval, &inMemState{})
 }
 
+// SetBackfill sets the catch-up configuration of the scheduler
+func (sched *Scheduler) SetBackfill(backfill Backfill) {
+	sched.backfill = backfill
+}
+
+// Backfilling returns true if the scheduler is catching up with a window longer than a backfill chunk
+func (sched *Scheduler) Backfilling() bool {
+	return !sched.catchUp.IsZero()
+}
+
 // Name returns the name of this scheduler
 func (sched *Scheduler) Name() string {
 	return sched.name
 }
 
+// DefaultInterval returns the interval used when none has been set
+func (sched *Scheduler) DefaultInterval() time.Duration {
+	return sched.defaultInterval
+}
+
 // GetInterval returns the scheduled interval set,
 // or the default one
 func (sched *Scheduler) GetInterval() time.Duration {
@@ -177,9 +220,13 @@ func (sched *Scheduler) Ready() bool {
 }
 
 // WaitChan returns a timer.Timer set to
-// wait for the next run
+// wait for the next run. When catching up, it waits
+// for the backfill delay
 func (sched *Scheduler) WaitChan() *time.Timer {
 	if sched.Ready() {
+		if sched.Backfilling() && time.Now().Before(sched.catchUp) {
+			return time.NewTimer(time.Until(sched.catchUp))
+		}
 		return time.NewTimer(0)
 	}
 	sleepTime := time.Until(sched.NextRun())
@@ -206,16 +253,40 @@ func (sched *Scheduler) Ack() error {
 	}
 	next := sched.lastTime.Truncate(sched.GetInterval()).Add(sched.GetInterval())
 	log.Debugf("Scheduler %s Ack: Updating next run time from %s to %s", sched.name, sched.NextRun().String(), next.String())
-	if err := sched.state.UpdateNextRun(sched.name, next); err != nil {
+	if err := sched.state.Acknowledge(sched.name, sched.lastFrom, *sched.lastTime, next); err != nil {
 		return err
 	}
 	sched.lastTime = nil
+	if sched.lastChunk {
+		sched.catchUp = time.Now().Add(sched.backfill.Delay)
+		log.Infof("Scheduler %s: Catching up from %s", sched.name, sched.NextRun())
+		return nil
+	}
+	sched.catchUp = time.Time{}
 	log.Infof("Scheduler %s: Next run in %s", sched.name, time.Until(sched.NextRun()))
 	return nil
 }
 
+// window returns the window of the next run, ending at `now`. It's shortened
+// according to the backfill configuration. `chunk` is true if it doesn't end at `now`
+func (sched *Scheduler) window(now time.Time) (from, to time.Time, chunk bool) {
+	from = sched.NextRun()
+	if sched.backfill.Chunk <= 0 {
+		return from, now, false
+	}
+	if max := sched.backfill.MaxAge; max > 0 && now.Sub(from) > max {
+		from = now.Add(-max).Truncate(sched.GetInterval())
+		log.Warnf("Scheduler %s: Skipping window from %s to %s, older than %s", sched.name, sched.NextRun(), from, max)
+	}
+	if to = from.Add(sched.backfill.Chunk); to.Before(now) {
+		return from, to, true
+	}
+	return from, now, false
+}
+
 // Step will execute the next round,
-// starting from the last Acknowledged run
+// starting from the last Acknowledged run.
+// If backfill is enabled, a long window is shortened to its first chunk
 //
 // It won't wait until it's time to be run. If it's not the time, it will return an error.
 // For a blocking alternative, see StepWait()
@@ -224,15 +295,42 @@ func (sched *Scheduler) Step() (interface{}, error) {
 	if !sched.Ready() {
 		return nil, ErrNotReady
 	}
-	now := time.Now()
-	res, err := sched.job.Run(sched.NextRun(), now, sched.GetInterval())
+	from, to, chunk := sched.window(time.Now())
+	if chunk {
+		log.Infof("Scheduler %s: Catching up window from %s to %s", sched.name, from, to)
+	}
+	res, err := sched.job.Run(from, to, sched.GetInterval())
 	if err != nil {
 		return nil, err
 	}
-	sched.lastTime = &now // Set last successful query time
+	sched.lastFrom = from
+	sched.lastTime = &to // Set last successful query time
+	sched.lastChunk = chunk
 	return res, nil
 }
 
+// StepNow executes a round immediately, without waiting for the proper time.
+//
+// If the scheduler is ready, it behaves exactly like Step(). Otherwise, or while a catch-up
+// run is still delayed, the job is run for the last interval only, and acknowledging it
+// won't change the next run time
+func (sched *Scheduler) StepNow() (interface{}, error) {
+	now := time.Now()
+	if sched.Ready() && !(sched.Backfilling() && now.Before(sched.catchUp)) {
+		return sched.Step()
+	}
+	sched.lastTime = nil // Reset last successful query time
+	interval := sched.GetInterval()
+	log.Infof("Scheduler %s: forced run from %s to %s", sched.name, now.Add(-interval).String(), now.String())
+	return sched.job.Run(now.Add(-interval), now, interval)
+}
+
+// LastAck returns the boundaries of the last acknowledged run window.
+// Both values are zero if no run has been acknowledged yet
+func (sched *Scheduler) LastAck() (from, to time.Time) {
+	return sched.state.LastAck(sched.name)
+}
+
 // StepWait execute the next round, waiting for the proper time if it's necessary
 func (sched *Scheduler) StepWait() (interface{}, error) {
 	sched.lastTime = nil // Reset last successful query time
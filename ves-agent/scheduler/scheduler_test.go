@@ -131,6 +131,42 @@ func (s *SchedulerTestSuite) TestStepNotReady() {
 	s.Equal(ErrNotReady, err)
 }
 
+func (s *SchedulerTestSuite) TestStepNow() {
+	defaultInterval := 5 * time.Second
+	var lastFrom, lastTo time.Time
+	job := JobFunc(func(from, to time.Time, interval time.Duration) (interface{}, error) {
+		lastFrom = from
+		lastTo = to
+		return nil, nil
+	})
+
+	sched := NewScheduler("test", job, defaultInterval)
+	nextRun := time.Now().Add(1 * time.Minute)
+	s.NoError(sched.state.UpdateNextRun("test", nextRun))
+	_, err := sched.StepNow()
+	s.NoError(err)
+	s.Equal(defaultInterval, lastTo.Sub(lastFrom))
+	s.True(lastTo.Before(nextRun))
+	// Forced run doesn't change the next run time, nor the last acknowledged window
+	s.NoError(sched.Ack())
+	s.Equal(nextRun, sched.NextRun())
+	from, to := sched.LastAck()
+	s.True(from.IsZero())
+	s.True(to.IsZero())
+
+	// When ready, StepNow behaves like Step
+	nextRun = time.Now().Add(-1 * time.Minute)
+	s.NoError(sched.state.UpdateNextRun("test", nextRun))
+	_, err = sched.StepNow()
+	s.NoError(err)
+	s.Equal(nextRun, lastFrom)
+	s.NoError(sched.Ack())
+	from, to = sched.LastAck()
+	s.Equal(nextRun, from)
+	s.Equal(lastTo, to)
+	s.Equal(lastTo.Truncate(defaultInterval).Add(defaultInterval), sched.NextRun())
+}
+
 func (s *SchedulerTestSuite) TestWaitTimeout() {
 	defaultInterval := 5 * time.Second
 	job := JobFunc(func(from, to time.Time, interval time.Duration) (interface{}, error) {
@@ -145,3 +181,67 @@ func (s *SchedulerTestSuite) TestWaitTimeout() {
 	cancel()
 	s.Error(err)
 }
+
+func (s *SchedulerTestSuite) TestBackfill() {
+	defaultInterval := 1 * time.Minute
+	var lastFrom, lastTo time.Time
+	job := JobFunc(func(from, to time.Time, interval time.Duration) (interface{}, error) {
+		lastFrom = from
+		lastTo = to
+		return nil, nil
+	})
+
+	sched := NewScheduler("test", job, defaultInterval)
+	sched.SetBackfill(Backfill{Chunk: time.Hour, MaxAge: 3 * time.Hour, Delay: time.Minute})
+	// Windows older than the max age are skipped
+	now := time.Now()
+	s.NoError(sched.state.UpdateNextRun("test", now.Add(-5*time.Hour).Truncate(defaultInterval)))
+	_, err := sched.Step()
+	s.NoError(err)
+	start := lastFrom
+	s.True(start.After(now.Add(-3*time.Hour - defaultInterval)))
+	s.Equal(start.Add(time.Hour), lastTo)
+	// Until acknowledged, the same chunk is run again
+	s.False(sched.Backfilling())
+	_, err = sched.Step()
+	s.NoError(err)
+	s.Equal(start, lastFrom)
+	// Each chunk is acknowledged separately
+	s.NoError(sched.Ack())
+	from, to := sched.LastAck()
+	s.Equal(start, from)
+	s.Equal(start.Add(time.Hour), to)
+	s.Equal(start.Add(time.Hour+defaultInterval), sched.NextRun())
+	s.True(sched.Backfilling())
+	s.True(sched.Ready())
+	// Catch-up runs are delayed
+	timer := sched.WaitChan()
+	select {
+	case <-timer.C:
+		s.Fail("Catch-up run must be delayed")
+	case <-time.After(100 * time.Millisecond):
+	}
+	timer.Stop()
+
+	_, err = sched.Step()
+	s.NoError(err)
+	s.Equal(start.Add(time.Hour+defaultInterval), lastFrom)
+	s.Equal(lastFrom.Add(time.Hour), lastTo)
+	s.NoError(sched.Ack())
+	s.True(sched.Backfilling())
+	// A forced run doesn't bypass the catch-up delay
+	_, err = sched.StepNow()
+	s.NoError(err)
+	s.Equal(defaultInterval, lastTo.Sub(lastFrom))
+	s.NoError(sched.Ack())
+	s.Equal(start.Add(2*time.Hour+2*defaultInterval), sched.NextRun())
+	s.True(sched.Backfilling())
+	sched.catchUp = time.Now()
+	// Last chunk ends at current time
+	_, err = sched.Step()
+	s.NoError(err)
+	s.True(lastTo.Sub(lastFrom) < time.Hour)
+	s.NoError(sched.Ack())
+	s.False(sched.Backfilling())
+	s.False(sched.Ready())
+}
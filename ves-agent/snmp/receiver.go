@@ -0,0 +1,112 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package snmp
+
+import (
+	"crypto/subtle"
+	"net"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxDatagramSize is the largest SNMP message received over UDP
+const maxDatagramSize = 65535
+
+// Receiver listens for SNMPv2c traps over UDP, and maps them into alerts
+type Receiver struct {
+	conn      *net.UDPConn
+	community string
+	mapper    *Mapper
+}
+
+// NewReceiver validates the trap rules from `conf`, and binds the UDP socket to `conf.Bind`
+func NewReceiver(conf *config.SNMPConfiguration) (*Receiver, error) {
+	mapper, err := NewMapper(conf.Traps)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := net.ResolveUDPAddr("udp", conf.Bind)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Receiver{conn: conn, community: conf.Community, mapper: mapper}, nil
+}
+
+// Addr returns the address the receiver listens on
+func (recv *Receiver) Addr() net.Addr {
+	return recv.conn.LocalAddr()
+}
+
+// Close stops the receiver
+func (recv *Receiver) Close() error {
+	return recv.conn.Close()
+}
+
+// Serve receives traps until the receiver is closed. The alert mapped from each trap
+// is passed to `handle`. Traps which cannot be decoded or mapped are logged and dropped
+func (recv *Receiver) Serve(handle func(alerts []template.Alert) error) error {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := recv.conn.ReadFromUDP(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				log.Warnf("Cannot receive SNMP trap: %s", err.Error())
+				continue
+			}
+			return err
+		}
+		alert, ok := recv.receive(buf[:n], addr.IP.String())
+		if !ok {
+			continue
+		}
+		if err := handle([]template.Alert{alert}); err != nil {
+			log.Errorf("Cannot handle SNMP trap %s from %s: %s", alert.Labels["alertname"], addr.String(), err.Error())
+		}
+	}
+}
+
+// receive decodes and maps the trap `data` sent by `source`
+func (recv *Receiver) receive(data []byte, source string) (template.Alert, bool) {
+	trap, err := DecodeTrap(data)
+	if err != nil {
+		log.Warnf("Dropping SNMP message from %s: %s", source, err.Error())
+		return template.Alert{}, false
+	}
+	if recv.community != "" && subtle.ConstantTimeCompare([]byte(trap.Community), []byte(recv.community)) != 1 {
+		log.Warnf("Dropping SNMP trap %s from %s: bad community", trap.OID, source)
+		return template.Alert{}, false
+	}
+	alert, ok, err := recv.mapper.Map(trap, source, time.Now())
+	if err != nil {
+		log.Errorf("Dropping SNMP trap %s from %s: %s", trap.OID, source, err.Error())
+		return template.Alert{}, false
+	}
+	if !ok {
+		log.Debugf("Dropping SNMP trap %s from %s: no matching rule", trap.OID, source)
+		return template.Alert{}, false
+	}
+	log.Debugf("Received SNMP trap %s from %s: %s %s", trap.OID, source, alert.Labels["alertname"], alert.Status)
+	return alert, true
+}
@@ -0,0 +1,112 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package snmp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReceiverTestSuite struct {
+	suite.Suite
+	recv   *Receiver
+	alerts chan template.Alert
+	done   chan error
+}
+
+func TestReceiver(t *testing.T) {
+	suite.Run(t, new(ReceiverTestSuite))
+}
+
+func (suite *ReceiverTestSuite) SetupTest() {
+	conf := config.SNMPConfiguration{
+		Bind:      "127.0.0.1:0",
+		Community: "secret",
+		Traps: []config.TrapRule{{
+			Name:     "nodeFailure",
+			RaiseOID: "1.3.6.1.4.1.9999.0.1",
+			ClearOID: "1.3.6.1.4.1.9999.0.2",
+			Varbinds: map[string]string{"node": "1.3.6.1.4.1.9999.1.1"},
+			Labels:   map[string]string{"alertname": "NodeFailure", "severity": "critical", "VNFC": "{{ .vars.node }}"},
+		}},
+	}
+	var err error
+	suite.recv, err = NewReceiver(&conf)
+	suite.Require().NoError(err)
+	suite.alerts = make(chan template.Alert, 10)
+	suite.done = make(chan error, 1)
+	go func() {
+		suite.done <- suite.recv.Serve(func(alerts []template.Alert) error {
+			for _, alert := range alerts {
+				suite.alerts <- alert
+			}
+			return nil
+		})
+	}()
+}
+
+func (suite *ReceiverTestSuite) TearDownTest() {
+	suite.NoError(suite.recv.Close())
+	select {
+	case err := <-suite.done:
+		suite.Error(err)
+	case <-time.After(5 * time.Second):
+		suite.Fail("Receiver not stopped")
+	}
+}
+
+// send sends the datagram `data` to the receiver
+func (suite *ReceiverTestSuite) send(data []byte) {
+	conn, err := net.Dial("udp", suite.recv.Addr().String())
+	suite.Require().NoError(err)
+	defer conn.Close()
+	_, err = conn.Write(data)
+	suite.Require().NoError(err)
+}
+
+func (suite *ReceiverTestSuite) receive() template.Alert {
+	select {
+	case alert := <-suite.alerts:
+		return alert
+	case <-time.After(5 * time.Second):
+		suite.FailNow("No alert received")
+	}
+	return template.Alert{}
+}
+
+func (suite *ReceiverTestSuite) TestReceive() {
+	suite.send(encodeTrap("secret", "1.3.6.1.4.1.9999.0.1", vb("1.3.6.1.4.1.9999.1.1.0", octets("node-1"))))
+	alert := suite.receive()
+	suite.Equal("firing", alert.Status)
+	suite.Equal("node-1", alert.Labels["VNFC"])
+
+	// Bad community, garbage and unknown traps are dropped
+	suite.send(encodeTrap("public", "1.3.6.1.4.1.9999.0.1", vb("1.3.6.1.4.1.9999.1.1.0", octets("node-2"))))
+	suite.send([]byte("garbage"))
+	suite.send(encodeTrap("secret", "1.3.6.1.4.1.9999.0.3"))
+
+	suite.send(encodeTrap("secret", "1.3.6.1.4.1.9999.0.2", vb("1.3.6.1.4.1.9999.1.1.0", octets("node-1"))))
+	alert = suite.receive()
+	suite.Equal("resolved", alert.Status)
+	suite.Equal("node-1", alert.Labels["VNFC"])
+}
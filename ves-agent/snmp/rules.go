@@ -0,0 +1,163 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package snmp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/Masterminds/sprig"
+	amtemplate "github.com/prometheus/alertmanager/template"
+)
+
+// trapRule is a trap mapping rule, with its parsed templates
+type trapRule struct {
+	config.TrapRule
+	raiseOID, clearOID string
+	varbinds           map[string]string // OIDs of named varbinds, without leading dot
+	labels             map[string]*template.Template
+	annotations        map[string]*template.Template
+}
+
+// normalizeOID removes the leading dot of `oid`, if any
+func normalizeOID(oid string) string {
+	return strings.TrimPrefix(strings.TrimSpace(oid), ".")
+}
+
+// parseTemplates parses the template expressions of `exprs`
+func parseTemplates(exprs map[string]string) (map[string]*template.Template, error) {
+	tmpls := make(map[string]*template.Template, len(exprs))
+	for name, expr := range exprs {
+		tmpl, err := template.New(name).Funcs(sprig.TxtFuncMap()).Option("missingkey=zero").Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("bad template %s for %s (%s)", expr, name, err.Error())
+		}
+		tmpls[name] = tmpl
+	}
+	return tmpls, nil
+}
+
+// newTrapRule validates and parses a trap mapping rule
+func newTrapRule(rule config.TrapRule) (*trapRule, error) {
+	r := &trapRule{TrapRule: rule, raiseOID: normalizeOID(rule.RaiseOID), clearOID: normalizeOID(rule.ClearOID)}
+	if r.raiseOID == "" {
+		return nil, fmt.Errorf("Trap rule %s: raiseOid is required", rule.Name)
+	}
+	if _, ok := rule.Labels["alertname"]; !ok {
+		return nil, fmt.Errorf("Trap rule %s: alertname label is required", rule.Name)
+	}
+	r.varbinds = make(map[string]string, len(rule.Varbinds))
+	for name, oid := range rule.Varbinds {
+		r.varbinds[name] = normalizeOID(oid)
+	}
+	var err error
+	if r.labels, err = parseTemplates(rule.Labels); err != nil {
+		return nil, fmt.Errorf("Trap rule %s: %s", rule.Name, err.Error())
+	}
+	if r.annotations, err = parseTemplates(rule.Annotations); err != nil {
+		return nil, fmt.Errorf("Trap rule %s: %s", rule.Name, err.Error())
+	}
+	return r, nil
+}
+
+// vars returns the values of the rule's named varbinds found in `trap`
+func (rule *trapRule) vars(trap *Trap) map[string]string {
+	vars := make(map[string]string, len(rule.varbinds))
+	for name, oid := range rule.varbinds {
+		for _, vb := range trap.Varbinds {
+			if vb.OID == oid || strings.HasPrefix(vb.OID, oid+".") {
+				vars[name] = vb.Value
+				break
+			}
+		}
+	}
+	return vars
+}
+
+// Mapper maps SNMP traps into alerts according to trap rules
+type Mapper struct {
+	rules []*trapRule
+}
+
+// NewMapper validates the trap rules `rules`, and parses their templates
+func NewMapper(rules []config.TrapRule) (*Mapper, error) {
+	mapper := &Mapper{}
+	for _, r := range rules {
+		rule, err := newTrapRule(r)
+		if err != nil {
+			return nil, err
+		}
+		mapper.rules = append(mapper.rules, rule)
+	}
+	return mapper, nil
+}
+
+// Map returns the alert built from `trap`, sent by `source` and received at `now`.
+// Traps raising a fault give firing alerts, and the ones clearing it resolved alerts.
+// False is returned if no rule matches the trap
+func (mapper *Mapper) Map(trap *Trap, source string, now time.Time) (amtemplate.Alert, bool, error) {
+	for _, rule := range mapper.rules {
+		var alert amtemplate.Alert
+		switch trap.OID {
+		case rule.raiseOID:
+			alert = amtemplate.Alert{Status: "firing", StartsAt: now}
+		case rule.clearOID:
+			alert = amtemplate.Alert{Status: "resolved", StartsAt: now, EndsAt: now}
+		default:
+			continue
+		}
+		varbinds := make(map[string]string, len(trap.Varbinds))
+		for _, vb := range trap.Varbinds {
+			varbinds[vb.OID] = vb.Value
+		}
+		data := map[string]interface{}{
+			"oid":       trap.OID,
+			"source":    source,
+			"community": trap.Community,
+			"uptime":    trap.Uptime,
+			"varbinds":  varbinds,
+			"vars":      rule.vars(trap),
+		}
+		var err error
+		if alert.Labels, err = eval(rule.labels, data); err != nil {
+			return alert, true, fmt.Errorf("Trap rule %s: %s", rule.Name, err.Error())
+		}
+		if alert.Annotations, err = eval(rule.annotations, data); err != nil {
+			return alert, true, fmt.Errorf("Trap rule %s: %s", rule.Name, err.Error())
+		}
+		return alert, true, nil
+	}
+	return amtemplate.Alert{}, false, nil
+}
+
+// eval executes the templates `tmpls` with `data`
+func eval(tmpls map[string]*template.Template, data interface{}) (amtemplate.KV, error) {
+	values := make(amtemplate.KV, len(tmpls))
+	for name, tmpl := range tmpls {
+		buf := bytes.Buffer{}
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("Cannot execute template for %s (%s)", name, err.Error())
+		}
+		values[name] = buf.String()
+	}
+	return values, nil
+}
@@ -0,0 +1,115 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package snmp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RulesTestSuite struct {
+	suite.Suite
+	rules []config.TrapRule
+}
+
+func TestRules(t *testing.T) {
+	suite.Run(t, new(RulesTestSuite))
+}
+
+func (suite *RulesTestSuite) SetupTest() {
+	suite.rules = []config.TrapRule{
+		{
+			Name:     "linkDown",
+			RaiseOID: ".1.3.6.1.6.3.1.1.5.3",
+			ClearOID: ".1.3.6.1.6.3.1.1.5.4",
+			Varbinds: map[string]string{"ifIndex": "1.3.6.1.2.1.2.2.1.1", "ifDescr": ".1.3.6.1.2.1.2.2.1.2"},
+			Labels: map[string]string{
+				"alertname":   "LinkDown",
+				"severity":    "major",
+				"system_name": "{{ .source }}",
+				"id":          "{{ .vars.ifIndex }}",
+			},
+			Annotations: map[string]string{
+				"service":     "Network",
+				"description": "Interface {{ .vars.ifDescr }} is down",
+			},
+		},
+	}
+}
+
+func (suite *RulesTestSuite) trap(trapOID string) *Trap {
+	return &Trap{Community: "public", OID: trapOID, Varbinds: []Varbind{
+		{OID: "1.3.6.1.2.1.2.2.1.1.3", Value: "3"},
+		{OID: "1.3.6.1.2.1.2.2.1.2.3", Value: "eth0"},
+	}}
+}
+
+func (suite *RulesTestSuite) TestMapRaiseClear() {
+	mapper, err := NewMapper(suite.rules)
+	suite.Require().NoError(err)
+	now := time.Now()
+
+	alert, ok, err := mapper.Map(suite.trap("1.3.6.1.6.3.1.1.5.3"), "10.0.0.1", now)
+	suite.NoError(err)
+	suite.Require().True(ok)
+	suite.Equal("firing", alert.Status)
+	suite.Equal(now, alert.StartsAt)
+	suite.True(alert.EndsAt.IsZero())
+	suite.Equal(map[string]string{"alertname": "LinkDown", "severity": "major", "system_name": "10.0.0.1", "id": "3"}, map[string]string(alert.Labels))
+	suite.Equal("Interface eth0 is down", alert.Annotations["description"])
+
+	clear, ok, err := mapper.Map(suite.trap("1.3.6.1.6.3.1.1.5.4"), "10.0.0.1", now)
+	suite.NoError(err)
+	suite.Require().True(ok)
+	suite.Equal("resolved", clear.Status)
+	suite.Equal(now, clear.EndsAt)
+	suite.Equal(alert.Labels, clear.Labels)
+
+	_, ok, err = mapper.Map(suite.trap("1.3.6.1.6.3.1.1.5.1"), "10.0.0.1", now)
+	suite.NoError(err)
+	suite.False(ok)
+}
+
+func (suite *RulesTestSuite) TestMapVarbinds() {
+	suite.rules[0].Labels["id"] = `{{ index .varbinds "1.3.6.1.2.1.2.2.1.1.3" }}-{{ .vars.missing }}-{{ .oid }}`
+	mapper, err := NewMapper(suite.rules)
+	suite.Require().NoError(err)
+	alert, ok, err := mapper.Map(suite.trap("1.3.6.1.6.3.1.1.5.3"), "10.0.0.1", time.Now())
+	suite.NoError(err)
+	suite.True(ok)
+	suite.Equal("3--1.3.6.1.6.3.1.1.5.3", alert.Labels["id"])
+
+	suite.rules[0].Labels["id"] = `{{ fail "no index" }}`
+	mapper, err = NewMapper(suite.rules)
+	suite.Require().NoError(err)
+	_, ok, err = mapper.Map(suite.trap("1.3.6.1.6.3.1.1.5.3"), "10.0.0.1", time.Now())
+	suite.Error(err)
+	suite.True(ok)
+}
+
+func (suite *RulesTestSuite) TestBadRules() {
+	_, err := NewMapper([]config.TrapRule{{Name: "noOid", Labels: map[string]string{"alertname": "A"}}})
+	suite.Error(err)
+	_, err = NewMapper([]config.TrapRule{{Name: "noAlertname", RaiseOID: "1.2.3"}})
+	suite.Error(err)
+	_, err = NewMapper([]config.TrapRule{{Name: "badTemplate", RaiseOID: "1.2.3", Labels: map[string]string{"alertname": "{{ .oid"}}})
+	suite.Error(err)
+}
@@ -0,0 +1,203 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package snmp
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Well known OIDs of the first varbinds of SNMPv2 traps
+const (
+	SysUpTimeOID   = "1.3.6.1.2.1.1.3.0"
+	SnmpTrapOIDOID = "1.3.6.1.6.3.1.1.4.1.0"
+)
+
+// SNMP versions and PDU types
+const (
+	versionV2c  = 1
+	trapPDUType = 7 // SNMPv2-Trap-PDU
+)
+
+// Tags of the SNMP application types
+const (
+	tagIPAddress  = 0
+	tagCounter32  = 1
+	tagGauge32    = 2
+	tagTimeTicks  = 3
+	tagOpaque     = 4
+	tagCounter64  = 6
+	tagUInteger32 = 7
+)
+
+// ErrNotTrap is returned when decoding an SNMP message which is not an SNMPv2c trap
+var ErrNotTrap = errors.New("Not an SNMPv2c trap")
+
+// Varbind is a variable binding of a trap, with its value formatted as a string
+type Varbind struct {
+	OID   string
+	Value string
+}
+
+// Trap is a decoded SNMPv2c trap
+type Trap struct {
+	Community string
+	RequestID int
+	Uptime    uint64    // Value of sysUpTime.0, in hundredths of seconds
+	OID       string    // Value of snmpTrapOID.0, identifying the trap
+	Varbinds  []Varbind // Variable bindings following sysUpTime.0 and snmpTrapOID.0
+}
+
+// message is the SNMP message, with the PDU left encoded
+type message struct {
+	Version   int
+	Community []byte
+	PDU       asn1.RawValue
+}
+
+// trapPDU is the content of an SNMPv2-Trap-PDU
+type trapPDU struct {
+	RequestID   int
+	ErrorStatus int
+	ErrorIndex  int
+	Varbinds    []varbind
+}
+
+type varbind struct {
+	Name  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// DecodeTrap decodes the SNMPv2c trap from the datagram `data`
+func DecodeTrap(data []byte) (*Trap, error) {
+	msg := message{}
+	rest, err := asn1.Unmarshal(data, &msg)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed SNMP message: %s", err.Error())
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("Malformed SNMP message: trailing data")
+	}
+	if msg.Version != versionV2c || msg.PDU.Class != asn1.ClassContextSpecific || msg.PDU.Tag != trapPDUType {
+		return nil, ErrNotTrap
+	}
+	pdu := trapPDU{}
+	if _, err = asn1.UnmarshalWithParams(msg.PDU.FullBytes, &pdu, fmt.Sprintf("tag:%d", trapPDUType)); err != nil {
+		return nil, fmt.Errorf("Malformed SNMP trap: %s", err.Error())
+	}
+	trap := &Trap{Community: string(msg.Community), RequestID: pdu.RequestID}
+	for _, vb := range pdu.Varbinds {
+		oid := vb.Name.String()
+		switch oid {
+		case SysUpTimeOID:
+			if vb.Value.Class != asn1.ClassApplication || vb.Value.Tag != tagTimeTicks {
+				return nil, errors.New("Malformed SNMP trap: sysUpTime.0 is not a TimeTicks")
+			}
+			trap.Uptime = decodeUnsigned(vb.Value.Bytes)
+		case SnmpTrapOIDOID:
+			trapOID := asn1.ObjectIdentifier{}
+			if _, err = asn1.Unmarshal(vb.Value.FullBytes, &trapOID); err != nil {
+				return nil, fmt.Errorf("Malformed SNMP trap: bad snmpTrapOID.0 (%s)", err.Error())
+			}
+			trap.OID = trapOID.String()
+		default:
+			value, err := formatValue(vb.Value)
+			if err != nil {
+				return nil, fmt.Errorf("Malformed SNMP trap: bad value for %s (%s)", oid, err.Error())
+			}
+			trap.Varbinds = append(trap.Varbinds, Varbind{OID: oid, Value: value})
+		}
+	}
+	if trap.OID == "" {
+		return nil, errors.New("Malformed SNMP trap: no snmpTrapOID.0")
+	}
+	return trap, nil
+}
+
+// formatValue formats the varbind value `value` as a string
+func formatValue(value asn1.RawValue) (string, error) {
+	switch value.Class {
+	case asn1.ClassUniversal:
+		switch value.Tag {
+		case asn1.TagInteger:
+			i := new(big.Int)
+			if _, err := asn1.Unmarshal(value.FullBytes, &i); err != nil {
+				return "", err
+			}
+			return i.String(), nil
+		case asn1.TagOctetString:
+			return formatOctets(value.Bytes), nil
+		case asn1.TagOID:
+			oid := asn1.ObjectIdentifier{}
+			if _, err := asn1.Unmarshal(value.FullBytes, &oid); err != nil {
+				return "", err
+			}
+			return oid.String(), nil
+		case 5: // NULL
+			return "", nil
+		}
+	case asn1.ClassApplication:
+		switch value.Tag {
+		case tagIPAddress:
+			if len(value.Bytes) != net.IPv4len {
+				return "", fmt.Errorf("IpAddress of %d bytes", len(value.Bytes))
+			}
+			return net.IP(value.Bytes).String(), nil
+		case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64, tagUInteger32:
+			if len(value.Bytes) > 9 {
+				return "", fmt.Errorf("Unsigned integer of %d bytes", len(value.Bytes))
+			}
+			return strconv.FormatUint(decodeUnsigned(value.Bytes), 10), nil
+		case tagOpaque:
+			return hex.EncodeToString(value.Bytes), nil
+		}
+	case asn1.ClassContextSpecific:
+		// noSuchObject, noSuchInstance and endOfMibView exceptions
+		if value.Tag <= 2 {
+			return "", nil
+		}
+	}
+	return "", fmt.Errorf("Unsupported type (class %d, tag %d)", value.Class, value.Tag)
+}
+
+// formatOctets returns octet strings as text when they are printable, or as colon separated hexadecimal bytes
+func formatOctets(b []byte) string {
+	if utf8.Valid(b) && strings.IndexFunc(string(b), func(r rune) bool { return r < ' ' && r != '\t' && r != '\n' && r != '\r' }) < 0 {
+		return string(b)
+	}
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = hex.EncodeToString([]byte{c})
+	}
+	return strings.Join(parts, ":")
+}
+
+// decodeUnsigned decodes a big endian unsigned integer
+func decodeUnsigned(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
@@ -0,0 +1,168 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package snmp
+
+import (
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// tlv encodes a BER element of class `class` and tag `tag`
+func tlv(class, tag int, constructed bool, content ...[]byte) []byte {
+	b := []byte{}
+	for _, c := range content {
+		b = append(b, c...)
+	}
+	id := byte(class<<6 | tag)
+	if constructed {
+		id |= 0x20
+	}
+	out := []byte{id}
+	if len(b) < 0x80 {
+		out = append(out, byte(len(b)))
+	} else if len(b) < 0x100 {
+		out = append(out, 0x81, byte(len(b)))
+	} else {
+		out = append(out, 0x82, byte(len(b)>>8), byte(len(b)))
+	}
+	return append(out, b...)
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// oid encodes the dotted OID `s`
+func oid(s string) []byte {
+	id := asn1.ObjectIdentifier{}
+	for _, part := range splitOID(s) {
+		id = append(id, part)
+	}
+	return mustMarshal(id)
+}
+
+func splitOID(s string) []int {
+	parts := []int{}
+	n := 0
+	for _, c := range s + "." {
+		if c == '.' {
+			parts = append(parts, n)
+			n = 0
+		} else {
+			n = n*10 + int(c-'0')
+		}
+	}
+	return parts
+}
+
+func octets(s string) []byte {
+	return mustMarshal([]byte(s))
+}
+
+func integer(i int) []byte {
+	return mustMarshal(i)
+}
+
+func timeTicks(t uint32) []byte {
+	return tlv(asn1.ClassApplication, tagTimeTicks, false, []byte{byte(t >> 24), byte(t >> 16), byte(t >> 8), byte(t)})
+}
+
+// vb encodes a varbind
+func vb(name string, value []byte) []byte {
+	return tlv(asn1.ClassUniversal, asn1.TagSequence, true, oid(name), value)
+}
+
+// encodeTrap encodes an SNMPv2c trap, with sysUpTime.0 and snmpTrapOID.0 followed by `varbinds`
+func encodeTrap(community, trapOID string, varbinds ...[]byte) []byte {
+	all := append([][]byte{vb(SysUpTimeOID, timeTicks(4200)), vb(SnmpTrapOIDOID, oid(trapOID))}, varbinds...)
+	pdu := tlv(asn1.ClassContextSpecific, trapPDUType, true,
+		integer(1234), integer(0), integer(0), tlv(asn1.ClassUniversal, asn1.TagSequence, true, all...))
+	return tlv(asn1.ClassUniversal, asn1.TagSequence, true, integer(versionV2c), octets(community), pdu)
+}
+
+type TrapTestSuite struct {
+	suite.Suite
+}
+
+func TestTrap(t *testing.T) {
+	suite.Run(t, new(TrapTestSuite))
+}
+
+func (suite *TrapTestSuite) TestDecodeTrap() {
+	data := encodeTrap("public", "1.3.6.1.4.1.9999.0.1",
+		vb("1.3.6.1.4.1.9999.1.1.0", octets("node-1")),
+		vb("1.3.6.1.4.1.9999.1.2.3", integer(-5)),
+		vb("1.3.6.1.4.1.9999.1.3.0", tlv(asn1.ClassApplication, tagIPAddress, false, []byte{10, 0, 0, 1})),
+		vb("1.3.6.1.4.1.9999.1.4.0", tlv(asn1.ClassApplication, tagCounter64, false, []byte{0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})),
+		vb("1.3.6.1.4.1.9999.1.5.0", oid("1.3.6.1.2.1.2.2")),
+		vb("1.3.6.1.4.1.9999.1.6.0", octets("\x00\x1a\xff")),
+		vb("1.3.6.1.4.1.9999.1.7.0", tlv(asn1.ClassUniversal, 5, false)),
+	)
+	trap, err := DecodeTrap(data)
+	suite.Require().NoError(err)
+	suite.Equal("public", trap.Community)
+	suite.Equal(1234, trap.RequestID)
+	suite.EqualValues(4200, trap.Uptime)
+	suite.Equal("1.3.6.1.4.1.9999.0.1", trap.OID)
+	suite.Equal([]Varbind{
+		{OID: "1.3.6.1.4.1.9999.1.1.0", Value: "node-1"},
+		{OID: "1.3.6.1.4.1.9999.1.2.3", Value: "-5"},
+		{OID: "1.3.6.1.4.1.9999.1.3.0", Value: "10.0.0.1"},
+		{OID: "1.3.6.1.4.1.9999.1.4.0", Value: "18446744073709551615"},
+		{OID: "1.3.6.1.4.1.9999.1.5.0", Value: "1.3.6.1.2.1.2.2"},
+		{OID: "1.3.6.1.4.1.9999.1.6.0", Value: "00:1a:ff"},
+		{OID: "1.3.6.1.4.1.9999.1.7.0", Value: ""},
+	}, trap.Varbinds)
+}
+
+func (suite *TrapTestSuite) TestDecodeErrors() {
+	_, err := DecodeTrap([]byte("not an SNMP message"))
+	suite.Error(err)
+
+	// SNMPv1
+	data := encodeTrap("public", "1.3.6.1.4.1.9999.0.1")
+	v1 := append([]byte{}, data...)
+	v1[4] = 0
+	_, err = DecodeTrap(v1)
+	suite.Equal(ErrNotTrap, err)
+
+	// GetRequest PDU
+	get := append([]byte{}, data...)
+	get[2+3+len(octets("public"))] = 0xa0
+	_, err = DecodeTrap(get)
+	suite.Equal(ErrNotTrap, err)
+
+	// Trailing data
+	_, err = DecodeTrap(append(data, 0))
+	suite.Error(err)
+
+	// No snmpTrapOID.0
+	pdu := tlv(asn1.ClassContextSpecific, trapPDUType, true, integer(1), integer(0), integer(0),
+		tlv(asn1.ClassUniversal, asn1.TagSequence, true, vb(SysUpTimeOID, timeTicks(1))))
+	_, err = DecodeTrap(tlv(asn1.ClassUniversal, asn1.TagSequence, true, integer(versionV2c), octets("public"), pdu))
+	suite.Error(err)
+
+	// Unsupported value type
+	_, err = DecodeTrap(encodeTrap("public", "1.3.6.1.4.1.9999.0.1", vb("1.3.6.1.4.1.9999.1.1.0", tlv(asn1.ClassPrivate, 1, false))))
+	suite.Error(err)
+}
@@ -0,0 +1,179 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package syslog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+)
+
+// facilities are the syslog facility names, indexed by code
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"ntp", "security", "console", "solaris-cron", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// severities are the VES syslog severities, indexed by code
+var severities = []govel.SyslogSeverity{
+	govel.SyslogEmergency, govel.SyslogAlert, govel.SyslogCritical, govel.SyslogError,
+	govel.SyslogWarning, govel.SyslogNotice, govel.SyslogInfo, govel.SyslogDebug,
+}
+
+// severityAliases are the usual abbreviations of severity names
+var severityAliases = map[string]int{"emerg": 0, "panic": 0, "crit": 2, "err": 3, "warn": 4}
+
+// parseCode returns the code of `name` in `names`, or the code itself if `name` is numeric
+func parseCode(name string, names []string) (int, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if code, err := strconv.Atoi(name); err == nil {
+		return code, code >= 0 && code < len(names)
+	}
+	for code, n := range names {
+		if strings.ToLower(n) == name {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// parseSeverity returns the code of the severity `name`
+func parseSeverity(name string) (int, bool) {
+	if code, ok := severityAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return code, true
+	}
+	names := make([]string, len(severities))
+	for i, sev := range severities {
+		names[i] = string(sev)
+	}
+	return parseCode(name, names)
+}
+
+// filter is a compiled syslog filter
+type filter struct {
+	facilities map[int]bool // Nil if all facilities match
+	severity   int          // Least severe matching severity code
+	regex      *regexp.Regexp
+}
+
+// newFilter validates and compiles a syslog filter
+func newFilter(conf config.SyslogFilter) (*filter, error) {
+	f := &filter{severity: len(severities) - 1}
+	if len(conf.Facilities) > 0 {
+		f.facilities = make(map[int]bool, len(conf.Facilities))
+		for _, name := range conf.Facilities {
+			code, ok := parseCode(name, facilities)
+			if !ok {
+				return nil, fmt.Errorf("Syslog filter: unknown facility %q", name)
+			}
+			f.facilities[code] = true
+		}
+	}
+	if conf.Severity != "" {
+		code, ok := parseSeverity(conf.Severity)
+		if !ok {
+			return nil, fmt.Errorf("Syslog filter: unknown severity %q", conf.Severity)
+		}
+		f.severity = code
+	}
+	if conf.Regex != "" {
+		re, err := regexp.Compile(conf.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("Syslog filter: bad regex %s: %s", conf.Regex, err.Error())
+		}
+		f.regex = re
+	}
+	return f, nil
+}
+
+// matches returns true if `msg` satisfies the filter
+func (f *filter) matches(msg *Message) bool {
+	if f.facilities != nil && !f.facilities[msg.Facility] {
+		return false
+	}
+	if msg.Severity > f.severity {
+		return false
+	}
+	return f.regex == nil || f.regex.MatchString(msg.Text)
+}
+
+// bucket is the token bucket of a source
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter limits the rate of messages of each source with token buckets
+type limiter struct {
+	rate, burst float64
+	buckets     map[string]*bucket
+	lastSweep   time.Time
+	lock        sync.Mutex
+}
+
+// newLimiter creates a limiter allowing `rate` messages per second from each source,
+// with bursts of up to `burst` messages. It returns nil if `rate` is not positive
+func newLimiter(conf config.SyslogRateLimit) *limiter {
+	if conf.Rate <= 0 {
+		return nil
+	}
+	burst := float64(conf.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{rate: conf.Rate, burst: burst, buckets: make(map[string]*bucket)}
+}
+
+// allow returns true if a message from `source` received at `now` is within the rate limit
+func (l *limiter) allow(source string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if now.Sub(l.lastSweep) > time.Minute {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[source]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[source] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep forgets the sources whose bucket is full again
+func (l *limiter) sweep(now time.Time) {
+	for source, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, source)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,90 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FilterTestSuite struct {
+	suite.Suite
+}
+
+func TestFilter(t *testing.T) {
+	suite.Run(t, new(FilterTestSuite))
+}
+
+func (suite *FilterTestSuite) TestFilter() {
+	f, err := newFilter(config.SyslogFilter{Facilities: []string{"auth", "Local0", "10"}, Severity: "warn", Regex: "fail(ed|ure)"})
+	suite.Require().NoError(err)
+	suite.True(f.matches(&Message{Facility: 4, Severity: 2, Text: "login failed"}))
+	suite.True(f.matches(&Message{Facility: 16, Severity: 4, Text: "disk failure"}))
+	suite.True(f.matches(&Message{Facility: 10, Severity: 0, Text: "authentication failed"}))
+	suite.False(f.matches(&Message{Facility: 1, Severity: 2, Text: "login failed"}))
+	suite.False(f.matches(&Message{Facility: 4, Severity: 5, Text: "login failed"}))
+	suite.False(f.matches(&Message{Facility: 4, Severity: 2, Text: "login succeeded"}))
+
+	f, err = newFilter(config.SyslogFilter{})
+	suite.Require().NoError(err)
+	suite.True(f.matches(&Message{Facility: 23, Severity: 7}))
+
+	f, err = newFilter(config.SyslogFilter{Severity: "3"})
+	suite.Require().NoError(err)
+	suite.True(f.matches(&Message{Severity: 3}))
+	suite.False(f.matches(&Message{Severity: 4}))
+}
+
+func (suite *FilterTestSuite) TestBadFilters() {
+	for _, conf := range []config.SyslogFilter{
+		{Facilities: []string{"unknown"}},
+		{Facilities: []string{"24"}},
+		{Severity: "fatal"},
+		{Severity: "8"},
+		{Regex: "(unterminated"},
+	} {
+		_, err := newFilter(conf)
+		suite.Error(err, "%v", conf)
+	}
+}
+
+func (suite *FilterTestSuite) TestLimiter() {
+	suite.Nil(newLimiter(config.SyslogRateLimit{}))
+	l := newLimiter(config.SyslogRateLimit{Rate: 2, Burst: 3})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		suite.True(l.allow("10.0.0.1", now))
+	}
+	suite.False(l.allow("10.0.0.1", now))
+	// Sources have their own bucket
+	suite.True(l.allow("10.0.0.2", now))
+
+	// Tokens are refilled at the configured rate, up to the burst
+	suite.True(l.allow("10.0.0.1", now.Add(500*time.Millisecond)))
+	suite.False(l.allow("10.0.0.1", now.Add(500*time.Millisecond)))
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		suite.True(l.allow("10.0.0.1", later))
+	}
+	suite.False(l.allow("10.0.0.1", later))
+	// Full buckets are forgotten
+	suite.NotContains(l.buckets, "10.0.0.2")
+}
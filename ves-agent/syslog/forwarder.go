@@ -0,0 +1,132 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+)
+
+// ForwarderState handles the forwarder internal state
+type ForwarderState interface {
+	// NextSyslogIndexes reserves `count` event indexes, and returns the first one
+	NextSyslogIndexes(count int) (int64, error)
+}
+
+type inMemState struct {
+	index int64
+}
+
+func (mem *inMemState) NextSyslogIndexes(count int) (int64, error) {
+	i := mem.index
+	mem.index += int64(count)
+	return i, nil
+}
+
+// Forwarder is an utility to create syslog events from syslog messages
+type Forwarder struct {
+	nfNamingCode string
+	sourceType   govel.SourceType
+	state        ForwarderState    // Forwarder internal state
+	namingCodes  map[string]string // Cache for VnfcNamingCode from VnfcName
+}
+
+// NewForwarderWithState creates a new syslog Forwarder from provided configuration
+// and provided state handler
+func NewForwarderWithState(conf *config.SyslogConfiguration, eventConf *govel.EventConfiguration, namingCodes map[string]string, state ForwarderState) *Forwarder {
+	sourceType := govel.SourceType(conf.SourceType)
+	if sourceType == "" {
+		sourceType = govel.SourceVirtualMachine
+	}
+	return &Forwarder{nfNamingCode: eventConf.NfNamingCode, sourceType: sourceType, state: state, namingCodes: namingCodes}
+}
+
+// NewForwarder creates a new syslog Forwarder from provided configuration
+// that use an in memory state
+func NewForwarder(conf *config.SyslogConfiguration, eventConf *govel.EventConfiguration, namingCodes map[string]string) *Forwarder {
+	return NewForwarderWithState(conf, eventConf, namingCodes, &inMemState{})
+}
+
+// Events creates the syslog events of `msgs`
+func (fwd *Forwarder) Events(msgs []*Message) (govel.Batch, error) {
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	idx, err := fwd.state.NextSyslogIndexes(len(msgs))
+	if err != nil {
+		return nil, err
+	}
+	batch := make(govel.Batch, len(msgs))
+	for i, msg := range msgs {
+		batch[i] = fwd.event(msg, idx+int64(i))
+	}
+	return batch, nil
+}
+
+// event creates the syslog event of `msg` with index `idx`
+func (fwd *Forwarder) event(msg *Message, idx int64) *govel.EventSyslog {
+	sourceName := msg.Hostname
+	if sourceName == "" {
+		sourceName = msg.Source
+	}
+	tag := msg.MsgID
+	if tag == "" {
+		tag = "NILVALUE"
+	}
+	id := fmt.Sprintf("syslog%.10d", idx)
+	evt := govel.NewSyslog("syslog_"+fwd.nfNamingCode, id, msg.Text, tag, fwd.sourceType, sourceName)
+	evt.NfNamingCode = fwd.nfNamingCode
+	evt.NfcNamingCode = fwd.namingCodes[sourceName]
+	switch {
+	case msg.Severity <= 2:
+		evt.Priority = govel.PriorityHigh
+	case msg.Severity == 3:
+		evt.Priority = govel.PriorityMedium
+	}
+	when := msg.Timestamp
+	if when.IsZero() {
+		when = msg.Received
+	}
+	if !when.IsZero() {
+		evt.StartEpochMicrosec = when.UnixNano() / 1000
+		evt.LastEpochMicrosec = evt.StartEpochMicrosec
+	}
+
+	evt.EventSourceHost = msg.Hostname
+	evt.SyslogFacility = msg.Facility
+	evt.SyslogPri = msg.Priority()
+	evt.SyslogSev = severities[msg.Severity]
+	evt.SyslogProc = msg.AppName
+	if pid, err := strconv.ParseInt(msg.ProcID, 10, 64); err == nil {
+		evt.SyslogProcID = pid
+	}
+	evt.SyslogVer = msg.Version
+	if msg.StructuredData != "" {
+		evt.SyslogSData = msg.StructuredData
+		// SD-ID of the first structured data element
+		sdID := strings.TrimPrefix(msg.StructuredData, "[")
+		if end := strings.IndexAny(sdID, " ]"); end >= 0 {
+			sdID = sdID[:end]
+		}
+		evt.SyslogSdID = sdID
+	}
+	return evt
+}
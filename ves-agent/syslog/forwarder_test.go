@@ -0,0 +1,93 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/govel"
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ForwarderTestSuite struct {
+	suite.Suite
+}
+
+func TestForwarder(t *testing.T) {
+	suite.Run(t, new(ForwarderTestSuite))
+}
+
+func (suite *ForwarderTestSuite) TestEvents() {
+	fwd := NewForwarder(&config.SyslogConfiguration{}, &govel.EventConfiguration{NfNamingCode: "hspx"}, map[string]string{"ope-1": "oam"})
+	ts := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	msg5424, err := Parse([]byte(`<34>1 2019-01-01T12:00:00Z ope-1 su 1234 LOGIN [origin@32473 ip="10.0.0.1"] 'su root' failed`))
+	suite.Require().NoError(err)
+	msg3164, err := Parse([]byte("<14>app: started"))
+	suite.Require().NoError(err)
+	msg3164.Source = "10.0.0.2"
+	msg3164.Received = ts.Add(time.Minute)
+
+	batch, err := fwd.Events([]*Message{msg5424, msg3164})
+	suite.Require().NoError(err)
+	suite.Require().Len(batch, 2)
+
+	evt := batch[0].(*govel.EventSyslog)
+	suite.Equal(govel.DomainSyslog, evt.Domain)
+	suite.Equal("syslog0000000000", evt.EventID)
+	suite.Equal("syslog_hspx", evt.EventName)
+	suite.Equal("ope-1", evt.SourceName)
+	suite.Equal("hspx", evt.NfNamingCode)
+	suite.Equal("oam", evt.NfcNamingCode)
+	suite.Equal(govel.PriorityHigh, evt.Priority)
+	suite.Equal(ts.UnixNano()/1000, evt.StartEpochMicrosec)
+	suite.Equal(govel.SourceVirtualMachine, evt.EventSourceType)
+	suite.Equal("ope-1", evt.EventSourceHost)
+	suite.Equal(4, evt.SyslogFacility)
+	suite.Equal(34, evt.SyslogPri)
+	suite.Equal(govel.SyslogCritical, evt.SyslogSev)
+	suite.Equal("su", evt.SyslogProc)
+	suite.EqualValues(1234, evt.SyslogProcID)
+	suite.Equal("LOGIN", evt.SyslogTag)
+	suite.Equal(1, evt.SyslogVer)
+	suite.Equal(`[origin@32473 ip="10.0.0.1"]`, evt.SyslogSData)
+	suite.Equal("origin@32473", evt.SyslogSdID)
+	suite.Equal("'su root' failed", evt.SyslogMsg)
+
+	evt = batch[1].(*govel.EventSyslog)
+	suite.Equal("syslog0000000001", evt.EventID)
+	suite.Equal("10.0.0.2", evt.SourceName)
+	suite.Empty(evt.EventSourceHost)
+	suite.Equal(govel.PriorityNormal, evt.Priority)
+	suite.Equal(msg3164.Received.UnixNano()/1000, evt.StartEpochMicrosec)
+	suite.Equal(govel.SyslogInfo, evt.SyslogSev)
+	suite.Equal("NILVALUE", evt.SyslogTag)
+	suite.Zero(evt.SyslogVer)
+
+	batch, err = fwd.Events([]*Message{msg3164})
+	suite.NoError(err)
+	suite.Equal("syslog0000000002", batch[0].Header().EventID)
+}
+
+func (suite *ForwarderTestSuite) TestSourceType() {
+	fwd := NewForwarder(&config.SyslogConfiguration{SourceType: "host"}, &govel.EventConfiguration{}, nil)
+	batch, err := fwd.Events([]*Message{{Source: "10.0.0.1"}})
+	suite.Require().NoError(err)
+	suite.Equal(govel.SourceHost, batch[0].(*govel.EventSyslog).EventSourceType)
+}
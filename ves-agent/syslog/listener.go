@@ -0,0 +1,301 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxMessageSize is the largest syslog message accepted
+const maxMessageSize = 65535
+
+// maxLengthDigits is the number of digits of maxMessageSize, the longest octet count accepted
+const maxLengthDigits = 5
+
+// Listener receives syslog messages over UDP and TCP, and selects the ones to forward
+type Listener struct {
+	udp     *net.UDPConn // Nil if UDP is disabled
+	tcp     net.Listener // Nil if TCP is disabled
+	filters []*filter
+	limiter *limiter // Nil if messages are not rate limited
+	// Maximum number of concurrent TCP connections, and idle duration after which they are closed. 0 means no limit
+	maxConns    int
+	idleTimeout time.Duration
+	conns       map[net.Conn]struct{}
+	lock        sync.Mutex
+	closed      bool
+}
+
+// NewListener validates the filters from `conf`, and binds the UDP and TCP sockets
+func NewListener(conf *config.SyslogConfiguration) (*Listener, error) {
+	lst := &Listener{
+		limiter:     newLimiter(conf.RateLimit),
+		maxConns:    conf.MaxConnections,
+		idleTimeout: conf.IdleTimeout,
+		conns:       make(map[net.Conn]struct{}),
+	}
+	for _, fc := range conf.Filters {
+		f, err := newFilter(fc)
+		if err != nil {
+			return nil, err
+		}
+		lst.filters = append(lst.filters, f)
+	}
+	if conf.UDP != "" {
+		addr, err := net.ResolveUDPAddr("udp", conf.UDP)
+		if err != nil {
+			return nil, err
+		}
+		if lst.udp, err = net.ListenUDP("udp", addr); err != nil {
+			return nil, err
+		}
+	}
+	if conf.TCP != "" {
+		var err error
+		if lst.tcp, err = net.Listen("tcp", conf.TCP); err != nil {
+			if lst.udp != nil {
+				lst.udp.Close()
+			}
+			return nil, err
+		}
+	}
+	return lst, nil
+}
+
+// UDPAddr returns the UDP address the listener listens on, or nil if UDP is disabled
+func (lst *Listener) UDPAddr() net.Addr {
+	if lst.udp == nil {
+		return nil
+	}
+	return lst.udp.LocalAddr()
+}
+
+// TCPAddr returns the TCP address the listener listens on, or nil if TCP is disabled
+func (lst *Listener) TCPAddr() net.Addr {
+	if lst.tcp == nil {
+		return nil
+	}
+	return lst.tcp.Addr()
+}
+
+// Close stops the listener, and closes the TCP connections
+func (lst *Listener) Close() error {
+	lst.lock.Lock()
+	defer lst.lock.Unlock()
+	lst.closed = true
+	var err error
+	if lst.udp != nil {
+		err = lst.udp.Close()
+	}
+	if lst.tcp != nil {
+		if e := lst.tcp.Close(); e != nil {
+			err = e
+		}
+	}
+	for conn := range lst.conns {
+		conn.Close()
+	}
+	return err
+}
+
+// Serve receives messages until the listener is closed. Each message satisfying one
+// of the filters and within the rate limit of its source is passed to `handle`.
+// `handle` may be called concurrently for messages received over different connections
+func (lst *Listener) Serve(handle func(msg *Message)) error {
+	errs := make(chan error, 2)
+	n := 0
+	if lst.udp != nil {
+		n++
+		go func() { errs <- lst.serveUDP(handle) }()
+	}
+	if lst.tcp != nil {
+		n++
+		go func() { errs <- lst.serveTCP(handle) }()
+	}
+	var err error
+	for ; n > 0; n-- {
+		if e := <-errs; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (lst *Listener) serveUDP(handle func(msg *Message)) error {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := lst.udp.ReadFromUDP(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				log.Warnf("Cannot receive syslog message: %s", err.Error())
+				continue
+			}
+			return err
+		}
+		lst.receive(buf[:n], addr.IP.String(), handle)
+	}
+}
+
+func (lst *Listener) serveTCP(handle func(msg *Message)) error {
+	for {
+		conn, err := lst.tcp.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				log.Warnf("Cannot accept syslog connection: %s", err.Error())
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		lst.lock.Lock()
+		if lst.closed {
+			lst.lock.Unlock()
+			conn.Close()
+			return errors.New("Listener closed")
+		}
+		if lst.maxConns > 0 && len(lst.conns) >= lst.maxConns {
+			lst.lock.Unlock()
+			log.Warnf("Closing syslog connection from %s: too many connections", conn.RemoteAddr().String())
+			conn.Close()
+			continue
+		}
+		lst.conns[conn] = struct{}{}
+		lst.lock.Unlock()
+		go lst.serveConn(conn, handle)
+	}
+}
+
+// serveConn receives the messages of a TCP connection, framed
+// either with octet counting or with trailing new lines (RFC 6587).
+// The connection is closed if no message is received for the idle timeout
+func (lst *Listener) serveConn(conn net.Conn, handle func(msg *Message)) {
+	defer func() {
+		lst.lock.Lock()
+		delete(lst.conns, conn)
+		lst.lock.Unlock()
+		conn.Close()
+	}()
+	source := conn.RemoteAddr().String()
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		source = addr.IP.String()
+	}
+	reader := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		if lst.idleTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(lst.idleTimeout)); err != nil {
+				log.Warnf("Closing syslog connection from %s: %s", source, err.Error())
+				return
+			}
+		}
+		frame, err := readFrame(reader)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				log.Debugf("Closing idle syslog connection from %s", source)
+			} else if err != io.EOF {
+				log.Warnf("Closing syslog connection from %s: %s", source, err.Error())
+			}
+			return
+		}
+		if len(frame) > 0 {
+			lst.receive(frame, source, handle)
+		}
+	}
+}
+
+// readFrame reads the next message of a TCP stream
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] < '0' || first[0] > '9' {
+		// Non transparent framing
+		frame, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, errors.New("Syslog message too long")
+		}
+		if err == io.EOF && len(frame) > 0 {
+			err = nil
+		}
+		return frame, err
+	}
+	// Octet counting. The length is read digit by digit, not to buffer an unbounded length
+	n := 0
+	for digits := 0; ; digits++ {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == ' ' && digits > 0 {
+			break
+		}
+		if c < '0' || c > '9' || digits == maxLengthDigits {
+			return nil, errors.New("Invalid syslog message length")
+		}
+		n = n*10 + int(c-'0')
+	}
+	if n > maxMessageSize {
+		return nil, fmt.Errorf("Invalid syslog message length %d", n)
+	}
+	frame := make([]byte, n)
+	if _, err = io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// receive parses the message `data` sent by `source`, and hands it to `handle` if it is selected
+func (lst *Listener) receive(data []byte, source string, handle func(msg *Message)) {
+	msg, err := Parse(data)
+	if err != nil {
+		log.Debugf("Dropping syslog message from %s: %s", source, err.Error())
+		return
+	}
+	msg.Source = source
+	msg.Received = time.Now()
+	if !lst.selected(msg) {
+		return
+	}
+	if lst.limiter != nil && !lst.limiter.allow(source, msg.Received) {
+		log.Debugf("Dropping syslog message from %s: rate limit exceeded", source)
+		return
+	}
+	handle(msg)
+}
+
+// selected returns true if `msg` satisfies one of the filters, or if there is no filter
+func (lst *Listener) selected(msg *Message) bool {
+	if len(lst.filters) == 0 {
+		return true
+	}
+	for _, f := range lst.filters {
+		if f.matches(msg) {
+			return true
+		}
+	}
+	return false
+}
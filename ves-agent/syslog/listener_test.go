@@ -0,0 +1,208 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ListenerTestSuite struct {
+	suite.Suite
+	lst  *Listener
+	msgs chan *Message
+	done chan error
+}
+
+func TestListener(t *testing.T) {
+	suite.Run(t, new(ListenerTestSuite))
+}
+
+func (suite *ListenerTestSuite) SetupTest() {
+	conf := config.SyslogConfiguration{
+		UDP:       "127.0.0.1:0",
+		TCP:       "127.0.0.1:0",
+		Filters:   []config.SyslogFilter{{Severity: "warning"}, {Regex: "^keep"}},
+		RateLimit: config.SyslogRateLimit{Rate: 0.001, Burst: 4},
+	}
+	var err error
+	suite.lst, err = NewListener(&conf)
+	suite.Require().NoError(err)
+	suite.msgs = make(chan *Message, 10)
+	suite.done = make(chan error, 1)
+	go func() {
+		suite.done <- suite.lst.Serve(func(msg *Message) { suite.msgs <- msg })
+	}()
+}
+
+func (suite *ListenerTestSuite) TearDownTest() {
+	suite.NoError(suite.lst.Close())
+	select {
+	case err := <-suite.done:
+		suite.Error(err)
+	case <-time.After(5 * time.Second):
+		suite.Fail("Listener not stopped")
+	}
+}
+
+func (suite *ListenerTestSuite) receive() *Message {
+	select {
+	case msg := <-suite.msgs:
+		return msg
+	case <-time.After(5 * time.Second):
+		suite.FailNow("No message received")
+	}
+	return nil
+}
+
+func (suite *ListenerTestSuite) TestUDP() {
+	conn, err := net.Dial("udp", suite.lst.UDPAddr().String())
+	suite.Require().NoError(err)
+	defer conn.Close()
+	for _, data := range []string{
+		"<12>1 - host app - - - warning",
+		"<14>1 - host app - - - info, filtered out",
+		"garbage",
+		"<14>1 - host app - - - keep this info",
+	} {
+		_, err = conn.Write([]byte(data))
+		suite.Require().NoError(err)
+	}
+	msg := suite.receive()
+	suite.Equal("warning", msg.Text)
+	suite.Equal("127.0.0.1", msg.Source)
+	suite.False(msg.Received.IsZero())
+	suite.Equal("keep this info", suite.receive().Text)
+}
+
+func (suite *ListenerTestSuite) TestTCP() {
+	conn, err := net.Dial("tcp", suite.lst.TCPAddr().String())
+	suite.Require().NoError(err)
+	defer conn.Close()
+	octet := "<11>1 - host app - - - octet\ncounted"
+	_, err = fmt.Fprintf(conn, "%d %s<11>Oct 11 22:14:15 host app: new line\n<11>app: last", len(octet), octet)
+	suite.Require().NoError(err)
+	suite.Equal("octet\ncounted", suite.receive().Text)
+	suite.Equal("new line", suite.receive().Text)
+	conn.Close()
+	suite.Equal("last", suite.receive().Text)
+}
+
+// serve starts a TCP listener configured with `maxConns` and `idleTimeout`, and returns it with its stop function
+func (suite *ListenerTestSuite) serve(maxConns int, idleTimeout time.Duration) (*Listener, func()) {
+	lst, err := NewListener(&config.SyslogConfiguration{TCP: "127.0.0.1:0", MaxConnections: maxConns, IdleTimeout: idleTimeout})
+	suite.Require().NoError(err)
+	done := make(chan error, 1)
+	go func() {
+		done <- lst.Serve(func(msg *Message) { suite.msgs <- msg })
+	}()
+	return lst, func() {
+		suite.NoError(lst.Close())
+		<-done
+	}
+}
+
+// closedByPeer returns true if `conn` is closed by the listener within 5 seconds
+func (suite *ListenerTestSuite) closedByPeer(conn net.Conn) bool {
+	suite.Require().NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	_, err := conn.Read(make([]byte, 1))
+	return err == io.EOF
+}
+
+func (suite *ListenerTestSuite) TestTCPIdleTimeout() {
+	lst, stop := suite.serve(0, 200*time.Millisecond)
+	defer stop()
+	conn, err := net.Dial("tcp", lst.TCPAddr().String())
+	suite.Require().NoError(err)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, "<11>app: before timeout\n")
+	suite.Require().NoError(err)
+	suite.Equal("before timeout", suite.receive().Text)
+	suite.True(suite.closedByPeer(conn))
+}
+
+func (suite *ListenerTestSuite) TestTCPMaxConnections() {
+	lst, stop := suite.serve(1, 0)
+	defer stop()
+	conn, err := net.Dial("tcp", lst.TCPAddr().String())
+	suite.Require().NoError(err)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, "<11>app: first\n")
+	suite.Require().NoError(err)
+	suite.Equal("first", suite.receive().Text)
+
+	// Second connection is rejected while the first one is open
+	other, err := net.Dial("tcp", lst.TCPAddr().String())
+	suite.Require().NoError(err)
+	defer other.Close()
+	suite.True(suite.closedByPeer(other))
+
+	conn.Close()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		lst.lock.Lock()
+		n := len(lst.conns)
+		lst.lock.Unlock()
+		if n == 0 {
+			break
+		}
+		suite.Require().True(time.Since(start) < 5*time.Second, "Connection not released")
+	}
+	other, err = net.Dial("tcp", lst.TCPAddr().String())
+	suite.Require().NoError(err)
+	defer other.Close()
+	_, err = fmt.Fprint(other, "<11>app: second\n")
+	suite.Require().NoError(err)
+	suite.Equal("second", suite.receive().Text)
+}
+
+func (suite *ListenerTestSuite) TestReadFrameLength() {
+	// Length is read up to its 5 digits limit, not until the next space
+	for _, data := range []string{"123456 message", "12a message", "65536 message", "1" + strings.Repeat("0", maxMessageSize)} {
+		_, err := readFrame(bufio.NewReader(strings.NewReader(data)))
+		suite.Error(err, data)
+	}
+	frame, err := readFrame(bufio.NewReader(strings.NewReader("5 hello")))
+	suite.NoError(err)
+	suite.Equal("hello", string(frame))
+}
+
+func (suite *ListenerTestSuite) TestRateLimit() {
+	conn, err := net.Dial("udp", suite.lst.UDPAddr().String())
+	suite.Require().NoError(err)
+	defer conn.Close()
+	for i := 0; i < 6; i++ {
+		_, err = fmt.Fprintf(conn, "<11>1 - host app - - - message %d", i)
+		suite.Require().NoError(err)
+	}
+	for i := 0; i < 4; i++ {
+		suite.Equal(fmt.Sprintf("message %d", i), suite.receive().Text)
+	}
+	select {
+	case msg := <-suite.msgs:
+		suite.Fail("Unexpected message", msg.Text)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,200 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nilValue is the RFC 5424 value of absent fields
+const nilValue = "-"
+
+// Message is a syslog message, either RFC 5424 or RFC 3164 formatted
+type Message struct {
+	Facility       int
+	Severity       int
+	Version        int       // 1 for RFC 5424 messages, 0 for RFC 3164 ones
+	Timestamp      time.Time // Zero if absent
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData string // Raw structured data elements, empty if absent
+	Text           string
+	Source         string    // IP address of the sender
+	Received       time.Time // Time at which the message was received
+}
+
+// Priority returns the PRI value of the message
+func (msg *Message) Priority() int {
+	return msg.Facility*8 + msg.Severity
+}
+
+// Parse parses the syslog message `data`, either RFC 5424 or RFC 3164 formatted.
+// Fields absent from RFC 3164 messages are left empty
+func Parse(data []byte) (*Message, error) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+	pri, rest, err := parsePriority(data)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Facility: pri / 8, Severity: pri % 8}
+	if len(rest) > 1 && rest[0] == '1' && rest[1] == ' ' {
+		return msg, msg.parse5424(string(rest[2:]))
+	}
+	msg.parse3164(string(rest))
+	return msg, nil
+}
+
+// parsePriority parses the "<PRI>" header of a message
+func parsePriority(data []byte) (int, []byte, error) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, nil, errors.New("Missing syslog priority")
+	}
+	end := bytes.IndexByte(data, '>')
+	if end < 2 || end > 4 {
+		return 0, nil, errors.New("Malformed syslog priority")
+	}
+	pri, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || pri > 191 {
+		return 0, nil, fmt.Errorf("Invalid syslog priority %q", data[1:end])
+	}
+	return pri, data[end+1:], nil
+}
+
+// nextField returns the first space separated field of `s`, and the remaining
+func nextField(s string) (string, string) {
+	i := strings.IndexByte(s, ' ')
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+// nilable returns `s`, or an empty string if it is the nil value
+func nilable(s string) string {
+	if s == nilValue {
+		return ""
+	}
+	return s
+}
+
+// parse5424 parses the part of a RFC 5424 message following its version
+func (msg *Message) parse5424(s string) error {
+	msg.Version = 1
+	var ts string
+	ts, s = nextField(s)
+	if ts != nilValue {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return fmt.Errorf("Invalid syslog timestamp %q", ts)
+		}
+		msg.Timestamp = t
+	}
+	var field string
+	field, s = nextField(s)
+	msg.Hostname = nilable(field)
+	field, s = nextField(s)
+	msg.AppName = nilable(field)
+	field, s = nextField(s)
+	msg.ProcID = nilable(field)
+	field, s = nextField(s)
+	msg.MsgID = nilable(field)
+	if s == "" {
+		return errors.New("Missing syslog structured data")
+	}
+	if strings.HasPrefix(s, nilValue) {
+		s = s[len(nilValue):]
+	} else {
+		end, err := structuredDataEnd(s)
+		if err != nil {
+			return err
+		}
+		msg.StructuredData, s = s[:end], s[end:]
+	}
+	if s != "" && s[0] != ' ' {
+		return errors.New("Malformed syslog structured data")
+	}
+	msg.Text = strings.TrimPrefix(strings.TrimPrefix(s, " "), "\ufeff")
+	return nil
+}
+
+// structuredDataEnd returns the length of the structured data elements at the beginning of `s`
+func structuredDataEnd(s string) (int, error) {
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		quoted := false
+		for i++; i < len(s); i++ {
+			c := s[i]
+			if quoted && c == '\\' {
+				i++
+			} else if c == '"' {
+				quoted = !quoted
+			} else if c == ']' && !quoted {
+				break
+			}
+		}
+		if i >= len(s) {
+			return 0, errors.New("Unterminated syslog structured data element")
+		}
+		i++
+	}
+	if i == 0 {
+		return 0, errors.New("Malformed syslog structured data")
+	}
+	return i, nil
+}
+
+// parse3164 parses the part of a RFC 3164 message following its priority.
+// Messages without a valid timestamp are handled as if they only had a content
+func (msg *Message) parse3164(s string) {
+	const stamp = "Jan _2 15:04:05"
+	if len(s) > len(stamp) && s[len(stamp)] == ' ' {
+		if t, err := time.ParseInLocation(stamp, s[:len(stamp)], time.Local); err == nil {
+			now := time.Now()
+			// RFC 3164 timestamps have no year. Use the one making the date the closest to now
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.Sub(now) > 30*24*time.Hour {
+				t = t.AddDate(-1, 0, 0)
+			}
+			msg.Timestamp = t
+			msg.Hostname, s = nextField(s[len(stamp)+1:])
+		}
+	}
+	// The tag is made of alphanumeric characters, followed by an optional process ID in brackets
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' || r == '/')
+	})
+	if end > 0 && end <= 48 && (s[end] == ':' || s[end] == '[') {
+		msg.AppName = s[:end]
+		s = s[end:]
+		if s[0] == '[' {
+			if pidEnd := strings.IndexByte(s, ']'); pidEnd > 0 {
+				msg.ProcID = s[1:pidEnd]
+				s = s[pidEnd+1:]
+			}
+		}
+		s = strings.TrimPrefix(s, ":")
+		s = strings.TrimPrefix(s, " ")
+	}
+	msg.Text = s
+}
@@ -0,0 +1,113 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MessageTestSuite struct {
+	suite.Suite
+}
+
+func TestMessage(t *testing.T) {
+	suite.Run(t, new(MessageTestSuite))
+}
+
+func (suite *MessageTestSuite) TestParse5424() {
+	msg, err := Parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="App]lication"][examplePriority@32473 class="high"] An application event log entry...` + "\n"))
+	suite.Require().NoError(err)
+	suite.Equal(20, msg.Facility)
+	suite.Equal(5, msg.Severity)
+	suite.Equal(165, msg.Priority())
+	suite.Equal(1, msg.Version)
+	suite.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC), msg.Timestamp.UTC())
+	suite.Equal("mymachine.example.com", msg.Hostname)
+	suite.Equal("evntslog", msg.AppName)
+	suite.Equal("1234", msg.ProcID)
+	suite.Equal("ID47", msg.MsgID)
+	suite.Equal(`[exampleSDID@32473 iut="3" eventSource="App]lication"][examplePriority@32473 class="high"]`, msg.StructuredData)
+	suite.Equal("An application event log entry...", msg.Text)
+
+	msg, err = Parse([]byte("<34>1 - - su - - - \ufeff'su root' failed for lonvick on /dev/pts/8"))
+	suite.Require().NoError(err)
+	suite.Equal(4, msg.Facility)
+	suite.Equal(2, msg.Severity)
+	suite.True(msg.Timestamp.IsZero())
+	suite.Empty(msg.Hostname)
+	suite.Equal("su", msg.AppName)
+	suite.Empty(msg.ProcID)
+	suite.Empty(msg.StructuredData)
+	suite.Equal("'su root' failed for lonvick on /dev/pts/8", msg.Text)
+
+	msg, err = Parse([]byte("<13>1 2019-01-01T00:00:00+01:00 host app - - [meta x=\"1\"]"))
+	suite.Require().NoError(err)
+	suite.Equal(`[meta x="1"]`, msg.StructuredData)
+	suite.Empty(msg.Text)
+}
+
+func (suite *MessageTestSuite) TestParse3164() {
+	msg, err := Parse([]byte("<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8"))
+	suite.Require().NoError(err)
+	suite.Equal(4, msg.Facility)
+	suite.Equal(2, msg.Severity)
+	suite.Equal(0, msg.Version)
+	suite.Equal(time.October, msg.Timestamp.Month())
+	suite.Equal(11, msg.Timestamp.Day())
+	suite.Equal(22, msg.Timestamp.Hour())
+	suite.False(msg.Timestamp.After(time.Now().AddDate(0, 1, 0)))
+	suite.Equal("mymachine", msg.Hostname)
+	suite.Equal("su", msg.AppName)
+	suite.Equal("123", msg.ProcID)
+	suite.Equal("'su root' failed for lonvick on /dev/pts/8", msg.Text)
+
+	msg, err = Parse([]byte("<13>Feb  5 17:32:18 10.0.0.99 Use the BFG!"))
+	suite.Require().NoError(err)
+	suite.Equal(5, msg.Timestamp.Day())
+	suite.Equal("10.0.0.99", msg.Hostname)
+	suite.Empty(msg.AppName)
+	suite.Equal("Use the BFG!", msg.Text)
+
+	// No header
+	msg, err = Parse([]byte("<13>kernel: Out of memory"))
+	suite.Require().NoError(err)
+	suite.True(msg.Timestamp.IsZero())
+	suite.Empty(msg.Hostname)
+	suite.Equal("kernel", msg.AppName)
+	suite.Equal("Out of memory", msg.Text)
+}
+
+func (suite *MessageTestSuite) TestParseErrors() {
+	for _, data := range []string{
+		"",
+		"no priority",
+		"<>1 - - - - - -",
+		"<abc>1 - - - - - -",
+		"<192>1 - - - - - -",
+		"<13>1 yesterday host app - - -",
+		"<13>1 - host app - -",
+		"<13>1 - host app - - [unterminated",
+		"<13>1 - host app - - nosd",
+		"<13>1 - host app - - [sd]text",
+	} {
+		_, err := Parse([]byte(data))
+		suite.Error(err, data)
+	}
+}
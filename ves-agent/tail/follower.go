@@ -0,0 +1,164 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package tail
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// maxLineSize is the size beyond which a line without line break is split
+const maxLineSize = 64 * 1024
+
+// follower reads the lines appended to a file, following it when it's rotated.
+// Renamed files are detected by the file at the path changing, and truncated ones
+// by their size going under the read offset
+type follower struct {
+	path    string
+	file    *os.File    // Nil if the file does not exist yet
+	info    os.FileInfo // Info of the opened file
+	offset  int64       // Offset of the next read in the opened file
+	partial []byte      // Beginning of the line being read
+	buf     []byte
+}
+
+// newFollower creates a follower of the file at `path`. Lines already in the file
+// are skipped. If the file does not exist, it's read from the start once created
+func newFollower(path string) (*follower, error) {
+	f := &follower{path: path, buf: make([]byte, 32*1024)}
+	file, info, err := open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+		return nil, err
+	}
+	f.file, f.info, f.offset = file, info, info.Size()
+	if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at `path` for reading
+func open(path string) (*os.File, os.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// poll returns the lines appended to the file since the last poll, without their line break.
+// When the file has been rotated, the remaining lines of the previous file are returned first
+func (f *follower) poll() ([]string, error) {
+	lines := []string{}
+	if f.file != nil {
+		var err error
+		if lines, err = f.read(lines); err != nil {
+			return lines, err
+		}
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Renamed, and not created again yet. Writers may still append to the previous file
+			return lines, nil
+		}
+		return lines, err
+	}
+	switch {
+	case f.file == nil || !os.SameFile(info, f.info):
+		// Created or renamed: the new file is read from the start
+		if f.file != nil {
+			lines = f.flush(lines)
+			f.file.Close()
+		}
+		file, info, err := open(f.path)
+		if err != nil {
+			f.file = nil
+			if os.IsNotExist(err) {
+				return lines, nil
+			}
+			return lines, err
+		}
+		f.file, f.info, f.offset = file, info, 0
+	case info.Size() < f.offset:
+		// Truncated
+		lines = f.flush(lines)
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return lines, err
+		}
+		f.offset = 0
+	default:
+		return lines, nil
+	}
+	return f.read(lines)
+}
+
+// read appends the complete lines read from the opened file to `lines`
+func (f *follower) read(lines []string) ([]string, error) {
+	for {
+		n, err := f.file.Read(f.buf)
+		f.offset += int64(n)
+		data := f.buf[:n]
+		for len(data) > 0 {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				f.partial = append(f.partial, data...)
+				if len(f.partial) >= maxLineSize {
+					lines = f.flush(lines)
+				}
+				break
+			}
+			f.partial = append(f.partial, data[:i]...)
+			lines = f.flush(lines)
+			data = data[i+1:]
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
+
+// flush appends the line being read, if any, to `lines`
+func (f *follower) flush(lines []string) []string {
+	line := bytes.TrimSuffix(f.partial, []byte{'\r'})
+	f.partial = f.partial[:0]
+	if len(line) == 0 {
+		return lines
+	}
+	return append(lines, string(line))
+}
+
+// close closes the opened file
+func (f *follower) close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
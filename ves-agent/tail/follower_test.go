@@ -0,0 +1,138 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package tail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FollowerTestSuite struct {
+	suite.Suite
+	dir  string
+	path string
+}
+
+func TestFollower(t *testing.T) {
+	suite.Run(t, new(FollowerTestSuite))
+}
+
+func (suite *FollowerTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "tail")
+	suite.Require().NoError(err)
+	suite.path = filepath.Join(suite.dir, "app.log")
+}
+
+func (suite *FollowerTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+// write appends `data` to the followed file
+func (suite *FollowerTestSuite) write(data string) {
+	file, err := os.OpenFile(suite.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	suite.Require().NoError(err)
+	defer file.Close()
+	_, err = file.WriteString(data)
+	suite.Require().NoError(err)
+}
+
+// poll polls `f`, expecting no error
+func (suite *FollowerTestSuite) poll(f *follower) []string {
+	lines, err := f.poll()
+	suite.Require().NoError(err)
+	return lines
+}
+
+func (suite *FollowerTestSuite) TestAppend() {
+	suite.write("old line\n")
+	f, err := newFollower(suite.path)
+	suite.Require().NoError(err)
+	defer f.close()
+	suite.Empty(suite.poll(f))
+
+	suite.write("line 1\r\nline 2\n\nline")
+	suite.Equal([]string{"line 1", "line 2"}, suite.poll(f))
+	suite.write(" 3\n")
+	suite.Equal([]string{"line 3"}, suite.poll(f))
+	suite.Empty(suite.poll(f))
+}
+
+func (suite *FollowerTestSuite) TestLongLine() {
+	f, err := newFollower(suite.path)
+	suite.Require().NoError(err)
+	defer f.close()
+	suite.write(strings.Repeat("a", maxLineSize+10) + "\n")
+	lines := suite.poll(f)
+	suite.Require().Len(lines, 2)
+	suite.Len(lines[0], maxLineSize)
+	suite.Equal(strings.Repeat("a", 10), lines[1])
+}
+
+func (suite *FollowerTestSuite) TestCreated() {
+	f, err := newFollower(suite.path)
+	suite.Require().NoError(err)
+	defer f.close()
+	suite.Empty(suite.poll(f))
+
+	// Files created after the follower are read from the start
+	suite.write("line 1\n")
+	suite.Equal([]string{"line 1"}, suite.poll(f))
+}
+
+func (suite *FollowerTestSuite) TestRenamed() {
+	suite.write("old line\n")
+	f, err := newFollower(suite.path)
+	suite.Require().NoError(err)
+	defer f.close()
+
+	suite.write("line 1\nline 2")
+	suite.Require().NoError(os.Rename(suite.path, suite.path+".1"))
+	suite.Equal([]string{"line 1"}, suite.poll(f))
+
+	// The last line of the previous file is complete once the new one is created
+	suite.write("line 3\n")
+	suite.Equal([]string{"line 2", "line 3"}, suite.poll(f))
+
+	// Lines written to the previous file before the new one is created are not lost
+	suite.Require().NoError(os.Rename(suite.path, suite.path+".2"))
+	file, err := os.OpenFile(suite.path+".2", os.O_WRONLY|os.O_APPEND, 0644)
+	suite.Require().NoError(err)
+	_, err = file.WriteString("line 4\n")
+	suite.Require().NoError(err)
+	file.Close()
+	suite.write("line 5\n")
+	suite.Equal([]string{"line 4", "line 5"}, suite.poll(f))
+}
+
+func (suite *FollowerTestSuite) TestTruncated() {
+	suite.write("old line\n")
+	f, err := newFollower(suite.path)
+	suite.Require().NoError(err)
+	defer f.close()
+
+	suite.write("line 1\n")
+	suite.Equal([]string{"line 1"}, suite.poll(f))
+	suite.Require().NoError(os.Truncate(suite.path, 0))
+	suite.write("new\n")
+	suite.Equal([]string{"new"}, suite.poll(f))
+}
@@ -0,0 +1,249 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package tail
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+	"github.com/nokia/onap-vespa/ves-agent/convert"
+
+	"github.com/Masterminds/sprig"
+	amtemplate "github.com/prometheus/alertmanager/template"
+)
+
+// logRule is a log mapping rule, with its compiled expressions and parsed templates
+type logRule struct {
+	config.LogRule
+	raise, clear         *regexp.Regexp
+	sourceName           *template.Template
+	specificProblem      *template.Template
+	clearSpecificProblem *template.Template
+	labels               map[string]*template.Template
+	annotations          map[string]*template.Template
+}
+
+// parseTemplate parses the template expression `expr` of `name`. Nil is returned for empty expressions
+func parseTemplate(name, expr string) (*template.Template, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(sprig.TxtFuncMap()).Option("missingkey=zero").Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("bad template %s for %s (%s)", expr, name, err.Error())
+	}
+	return tmpl, nil
+}
+
+// parseTemplates parses the template expressions of `exprs`
+func parseTemplates(exprs map[string]string) (map[string]*template.Template, error) {
+	tmpls := make(map[string]*template.Template, len(exprs))
+	for name, expr := range exprs {
+		tmpl, err := parseTemplate(name, expr)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl != nil {
+			tmpls[name] = tmpl
+		}
+	}
+	return tmpls, nil
+}
+
+// newLogRule validates and parses a log mapping rule
+func newLogRule(rule config.LogRule) (*logRule, error) {
+	if rule.Raise == "" {
+		return nil, fmt.Errorf("Log rule %s: raise expression is required", rule.Name)
+	}
+	if _, ok := rule.Labels["alertname"]; !ok {
+		return nil, fmt.Errorf("Log rule %s: alertname label is required", rule.Name)
+	}
+	if rule.Timeout < 0 {
+		return nil, fmt.Errorf("Log rule %s: invalid negative timeout %s", rule.Name, rule.Timeout)
+	}
+	r := &logRule{LogRule: rule}
+	var err error
+	if r.raise, err = regexp.Compile(rule.Raise); err != nil {
+		return nil, fmt.Errorf("Log rule %s: bad raise expression %s (%s)", rule.Name, rule.Raise, err.Error())
+	}
+	if rule.Clear != "" {
+		if r.clear, err = regexp.Compile(rule.Clear); err != nil {
+			return nil, fmt.Errorf("Log rule %s: bad clear expression %s (%s)", rule.Name, rule.Clear, err.Error())
+		}
+	}
+	if r.sourceName, err = parseTemplate("sourceName", rule.SourceName); err != nil {
+		return nil, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+	}
+	if r.specificProblem, err = parseTemplate("specificProblem", rule.SpecificProblem); err != nil {
+		return nil, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+	}
+	clearProblem := rule.ClearSpecificProblem
+	if clearProblem == "" {
+		clearProblem = rule.SpecificProblem
+	}
+	if r.clearSpecificProblem, err = parseTemplate("clearSpecificProblem", clearProblem); err != nil {
+		return nil, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+	}
+	if r.labels, err = parseTemplates(rule.Labels); err != nil {
+		return nil, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+	}
+	if r.annotations, err = parseTemplates(rule.Annotations); err != nil {
+		return nil, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+	}
+	return r, nil
+}
+
+// groups returns the values of the groups captured by `re` in `match`, by name and by index
+func groups(re *regexp.Regexp, match []string) map[string]string {
+	values := make(map[string]string, 2*len(match))
+	for i, name := range re.SubexpNames() {
+		values[fmt.Sprint(i)] = match[i]
+		if name != "" {
+			values[name] = match[i]
+		}
+	}
+	return values
+}
+
+// match returns the alert built from `line` of file `path`, if the line matches the rule
+func (rule *logRule) match(path, line, hostname string, now time.Time) (amtemplate.Alert, bool, error) {
+	var alert amtemplate.Alert
+	re, problem := rule.raise, rule.specificProblem
+	match := re.FindStringSubmatch(line)
+	if match != nil {
+		alert = amtemplate.Alert{Status: "firing", StartsAt: now}
+	} else if rule.clear != nil {
+		re, problem = rule.clear, rule.clearSpecificProblem
+		if match = re.FindStringSubmatch(line); match == nil {
+			return alert, false, nil
+		}
+		alert = amtemplate.Alert{Status: "resolved", StartsAt: now, EndsAt: now}
+	} else {
+		return alert, false, nil
+	}
+	data := map[string]interface{}{
+		"file":     path,
+		"line":     line,
+		"hostname": hostname,
+		"groups":   groups(re, match),
+	}
+	var err error
+	if alert.Labels, err = eval(rule.labels, data); err != nil {
+		return alert, true, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+	}
+	if alert.Annotations, err = eval(rule.annotations, data); err != nil {
+		return alert, true, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+	}
+	if rule.sourceName != nil {
+		if alert.Labels["system_name"], err = execute(rule.sourceName, data); err != nil {
+			return alert, true, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+		}
+	}
+	if problem != nil {
+		annotation := "description"
+		if alert.Status == "resolved" {
+			annotation = "clearDescription"
+		}
+		if alert.Annotations[annotation], err = execute(problem, data); err != nil {
+			return alert, true, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+		}
+	}
+	if _, ok := alert.Annotations["clearAlertName"]; !ok {
+		alert.Annotations["clearAlertName"] = alert.Labels["alertname"]
+	}
+	if alert.Status == "firing" {
+		if rule.clearSpecificProblem != nil {
+			// Kept with the fault, for it to be cleared on timeout
+			if alert.Annotations["clearDescription"], err = execute(rule.clearSpecificProblem, data); err != nil {
+				return alert, true, fmt.Errorf("Log rule %s: %s", rule.Name, err.Error())
+			}
+		}
+		if rule.Timeout > 0 {
+			alert.Annotations[convert.TTLAnnotation] = rule.Timeout.String()
+		}
+	}
+	return alert, true, nil
+}
+
+// Matcher maps the lines of a log file into alerts according to log rules
+type Matcher struct {
+	rules    []*logRule
+	hostname string
+}
+
+// NewMatcher validates the log rules `rules`, and parses their expressions.
+// `hostname` is available to templates
+func NewMatcher(rules []config.LogRule, hostname string) (*Matcher, error) {
+	matcher := &Matcher{hostname: hostname}
+	for _, r := range rules {
+		rule, err := newLogRule(r)
+		if err != nil {
+			return nil, err
+		}
+		matcher.rules = append(matcher.rules, rule)
+	}
+	return matcher, nil
+}
+
+// Match returns the alerts built from `line` of file `path`, read at `now`. All rules apply to each line:
+// lines matching the raise expression of a rule give firing alerts, and the ones matching its clear
+// expression resolved alerts. Rules whose templates fail to evaluate are skipped, and their errors returned
+func (matcher *Matcher) Match(path, line string, now time.Time) ([]amtemplate.Alert, error) {
+	alerts := []amtemplate.Alert{}
+	errs := []string{}
+	for _, rule := range matcher.rules {
+		alert, ok, err := rule.match(path, line, matcher.hostname, now)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	if len(errs) > 0 {
+		return alerts, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return alerts, nil
+}
+
+// execute executes the template `tmpl` with `data`
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("Cannot execute template for %s (%s)", tmpl.Name(), err.Error())
+	}
+	return buf.String(), nil
+}
+
+// eval executes the templates `tmpls` with `data`
+func eval(tmpls map[string]*template.Template, data interface{}) (amtemplate.KV, error) {
+	values := make(amtemplate.KV, len(tmpls))
+	for name, tmpl := range tmpls {
+		value, err := execute(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
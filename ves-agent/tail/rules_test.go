@@ -0,0 +1,139 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package tail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+	"github.com/nokia/onap-vespa/ves-agent/convert"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RulesTestSuite struct {
+	suite.Suite
+	rules []config.LogRule
+}
+
+func TestRules(t *testing.T) {
+	suite.Run(t, new(RulesTestSuite))
+}
+
+func (suite *RulesTestSuite) SetupTest() {
+	suite.rules = []config.LogRule{
+		{
+			Name:                 "disk",
+			Raise:                `I/O error on (?P<disk>\w+): (.*)`,
+			Clear:                `Disk (?P<disk>\w+) recovered`,
+			Timeout:              10 * time.Minute,
+			SourceName:           "{{ .hostname }}",
+			SpecificProblem:      "Disk {{ .groups.disk }} failure: {{ index .groups \"2\" }}",
+			ClearSpecificProblem: "Disk {{ .groups.disk }} recovered",
+			Labels: map[string]string{
+				"alertname": "DiskFailure",
+				"severity":  "major",
+				"id":        "{{ .groups.disk }}",
+			},
+			Annotations: map[string]string{"service": "Storage"},
+		},
+		{
+			Name:            "panic",
+			Raise:           `panic: (?P<reason>.*)`,
+			SpecificProblem: "{{ .groups.reason }} in {{ .file }}",
+			Labels:          map[string]string{"alertname": "Panic"},
+		},
+	}
+}
+
+func (suite *RulesTestSuite) TestMatch() {
+	matcher, err := NewMatcher(suite.rules, "node1")
+	suite.Require().NoError(err)
+	now := time.Now()
+
+	alerts, err := matcher.Match("/var/log/app.log", "2019-06-01 ERROR I/O error on sda: bad sector", now)
+	suite.Require().NoError(err)
+	suite.Require().Len(alerts, 1)
+	alert := alerts[0]
+	suite.Equal("firing", alert.Status)
+	suite.Equal(now, alert.StartsAt)
+	suite.Equal("DiskFailure", alert.Labels["alertname"])
+	suite.Equal("sda", alert.Labels["id"])
+	suite.Equal("node1", alert.Labels["system_name"])
+	suite.Equal("Storage", alert.Annotations["service"])
+	suite.Equal("Disk sda failure: bad sector", alert.Annotations["description"])
+	suite.Equal("DiskFailure", alert.Annotations["clearAlertName"])
+	suite.Equal("Disk sda recovered", alert.Annotations["clearDescription"])
+	suite.Equal("10m0s", alert.Annotations[convert.TTLAnnotation])
+
+	alerts, err = matcher.Match("/var/log/app.log", "2019-06-01 INFO Disk sda recovered", now)
+	suite.Require().NoError(err)
+	suite.Require().Len(alerts, 1)
+	alert = alerts[0]
+	suite.Equal("resolved", alert.Status)
+	suite.Equal(now, alert.EndsAt)
+	suite.Equal("sda", alert.Labels["id"])
+	suite.Equal("node1", alert.Labels["system_name"])
+	suite.Equal("Disk sda recovered", alert.Annotations["clearDescription"])
+	suite.NotContains(alert.Annotations, "description")
+	suite.NotContains(alert.Annotations, convert.TTLAnnotation)
+
+	// Rules without timeout nor clear expression
+	alerts, err = matcher.Match("/var/log/app.log", "panic: nil pointer", now)
+	suite.Require().NoError(err)
+	suite.Require().Len(alerts, 1)
+	suite.Equal("nil pointer in /var/log/app.log", alerts[0].Annotations["description"])
+	suite.Equal("nil pointer in /var/log/app.log", alerts[0].Annotations["clearDescription"])
+	suite.NotContains(alerts[0].Labels, "system_name")
+	suite.NotContains(alerts[0].Annotations, convert.TTLAnnotation)
+
+	// All rules apply
+	alerts, err = matcher.Match("/var/log/app.log", "panic: I/O error on sdb: timeout", now)
+	suite.Require().NoError(err)
+	suite.Len(alerts, 2)
+
+	alerts, err = matcher.Match("/var/log/app.log", "INFO all good", now)
+	suite.NoError(err)
+	suite.Empty(alerts)
+}
+
+func (suite *RulesTestSuite) TestTemplateError() {
+	suite.rules[0].Labels["id"] = "{{ index .groups 3 }}"
+	matcher, err := NewMatcher(suite.rules, "node1")
+	suite.Require().NoError(err)
+	alerts, err := matcher.Match("/var/log/app.log", "panic: I/O error on sdb: timeout", time.Now())
+	suite.Error(err)
+	suite.Require().Len(alerts, 1)
+	suite.Equal("Panic", alerts[0].Labels["alertname"])
+}
+
+func (suite *RulesTestSuite) TestInvalidRules() {
+	rules := []config.LogRule{
+		{Name: "noRaise", Labels: map[string]string{"alertname": "A"}},
+		{Name: "noAlertName", Raise: "error"},
+		{Name: "badRaise", Raise: "error(", Labels: map[string]string{"alertname": "A"}},
+		{Name: "badClear", Raise: "error", Clear: "[ok", Labels: map[string]string{"alertname": "A"}},
+		{Name: "badTimeout", Raise: "error", Timeout: -time.Second, Labels: map[string]string{"alertname": "A"}},
+		{Name: "badTemplate", Raise: "error", SourceName: "{{ .hostname", Labels: map[string]string{"alertname": "A"}},
+		{Name: "badLabel", Raise: "error", Labels: map[string]string{"alertname": "{{ end }}"}},
+	}
+	for _, rule := range rules {
+		_, err := NewMatcher([]config.LogRule{rule}, "node1")
+		suite.Error(err, rule.Name)
+	}
+}
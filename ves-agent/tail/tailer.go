@@ -0,0 +1,122 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package tail
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	log "github.com/sirupsen/logrus"
+)
+
+// tailedFile is a log file, with the rules applied to its lines
+type tailedFile struct {
+	*follower
+	matcher *Matcher
+}
+
+// Tailer tails log files, and maps their new lines into alerts
+type Tailer struct {
+	files    []tailedFile
+	interval time.Duration
+	done     chan struct{}
+	stop     sync.Once
+}
+
+// NewTailer validates the log rules from `conf`, and opens the log files
+func NewTailer(conf *config.LogConfiguration) (*Tailer, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	interval := conf.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	tailer := &Tailer{interval: interval, done: make(chan struct{})}
+	for _, file := range conf.Files {
+		matcher, err := NewMatcher(file.Rules, hostname)
+		if err != nil {
+			tailer.Close()
+			return nil, err
+		}
+		f, err := newFollower(file.Path)
+		if err != nil {
+			tailer.Close()
+			return nil, err
+		}
+		tailer.files = append(tailer.files, tailedFile{follower: f, matcher: matcher})
+	}
+	return tailer, nil
+}
+
+// Close stops the tailer
+func (tailer *Tailer) Close() error {
+	tailer.stop.Do(func() { close(tailer.done) })
+	return nil
+}
+
+// Serve polls the log files for new lines until the tailer is closed. The alerts mapped from
+// each line are passed to `handle`. Files are closed when the tailer stops
+func (tailer *Tailer) Serve(handle func(alerts []template.Alert) error) error {
+	defer func() {
+		for _, file := range tailer.files {
+			file.close()
+		}
+	}()
+	ticker := time.NewTicker(tailer.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tailer.done:
+			return nil
+		case <-ticker.C:
+			if alerts := tailer.poll(); len(alerts) > 0 {
+				if err := handle(alerts); err != nil {
+					log.Errorf("Cannot handle %d alerts from log files: %s", len(alerts), err.Error())
+				}
+			}
+		}
+	}
+}
+
+// poll reads the new lines of the log files, and returns the alerts mapped from them
+func (tailer *Tailer) poll() []template.Alert {
+	alerts := []template.Alert{}
+	now := time.Now()
+	for _, file := range tailer.files {
+		lines, err := file.poll()
+		if err != nil {
+			log.Warnf("Cannot read log file %s: %s", file.path, err.Error())
+		}
+		for _, line := range lines {
+			matched, err := file.matcher.Match(file.path, line, now)
+			if err != nil {
+				log.Errorf("Cannot map line of log file %s: %s", file.path, err.Error())
+			}
+			for _, alert := range matched {
+				log.Debugf("Log file %s: %s %s", file.path, alert.Labels["alertname"], alert.Status)
+			}
+			alerts = append(alerts, matched...)
+		}
+	}
+	return alerts
+}
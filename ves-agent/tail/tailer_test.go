@@ -0,0 +1,134 @@
+/*
+	Copyright 2019 Nokia
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package tail
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nokia/onap-vespa/ves-agent/config"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/suite"
+)
+
+type TailerTestSuite struct {
+	suite.Suite
+	dir    string
+	path   string
+	tailer *Tailer
+	alerts chan template.Alert
+	done   chan error
+}
+
+func TestTailer(t *testing.T) {
+	suite.Run(t, new(TailerTestSuite))
+}
+
+func (suite *TailerTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "tail")
+	suite.Require().NoError(err)
+	suite.path = filepath.Join(suite.dir, "app.log")
+	conf := config.LogConfiguration{
+		PollInterval: 10 * time.Millisecond,
+		Files: []config.LogFile{{
+			Path: suite.path,
+			Rules: []config.LogRule{{
+				Name:   "nodeFailure",
+				Raise:  `Node (?P<node>\w+) is down`,
+				Clear:  `Node (?P<node>\w+) is up`,
+				Labels: map[string]string{"alertname": "NodeFailure", "severity": "critical", "VNFC": "{{ .groups.node }}"},
+			}},
+		}},
+	}
+	suite.tailer, err = NewTailer(&conf)
+	suite.Require().NoError(err)
+	suite.alerts = make(chan template.Alert, 10)
+	suite.done = make(chan error, 1)
+	go func() {
+		suite.done <- suite.tailer.Serve(func(alerts []template.Alert) error {
+			for _, alert := range alerts {
+				suite.alerts <- alert
+			}
+			return errors.New("Ignored")
+		})
+	}()
+}
+
+func (suite *TailerTestSuite) TearDownTest() {
+	suite.NoError(suite.tailer.Close())
+	select {
+	case err := <-suite.done:
+		suite.NoError(err)
+	case <-time.After(5 * time.Second):
+		suite.Fail("Tailer not stopped")
+	}
+	os.RemoveAll(suite.dir)
+}
+
+// write appends `data` to the tailed file
+func (suite *TailerTestSuite) write(data string) {
+	file, err := os.OpenFile(suite.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	suite.Require().NoError(err)
+	defer file.Close()
+	_, err = file.WriteString(data)
+	suite.Require().NoError(err)
+}
+
+// receive waits for the next alert
+func (suite *TailerTestSuite) receive() template.Alert {
+	select {
+	case alert := <-suite.alerts:
+		return alert
+	case <-time.After(5 * time.Second):
+		suite.FailNow("No alert received")
+	}
+	return template.Alert{}
+}
+
+func (suite *TailerTestSuite) TestServe() {
+	suite.write("Node node1 is down\nNothing to see\n")
+	alert := suite.receive()
+	suite.Equal("firing", alert.Status)
+	suite.Equal("NodeFailure", alert.Labels["alertname"])
+	suite.Equal("node1", alert.Labels["VNFC"])
+
+	suite.Require().NoError(os.Rename(suite.path, suite.path+".1"))
+	suite.write("Node node1 is up\n")
+	alert = suite.receive()
+	suite.Equal("resolved", alert.Status)
+	suite.Equal("node1", alert.Labels["VNFC"])
+
+	select {
+	case alert := <-suite.alerts:
+		suite.Fail("Unexpected alert", alert.Labels)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (suite *TailerTestSuite) TestInvalidRule() {
+	_, err := NewTailer(&config.LogConfiguration{Files: []config.LogFile{{
+		Path:  suite.path,
+		Rules: []config.LogRule{{Name: "bad", Raise: "error"}},
+	}}})
+	suite.Error(err)
+}
@@ -59,6 +59,9 @@ func launchVES(ves govel.VESCollectorIf, conf *config.VESAgentConfiguration) {
 }
 
 func main() {
+	if isRulesTest(os.Args) {
+		os.Exit(testRules())
+	}
 
 	var conf config.VESAgentConfiguration
 	if err := config.InitConf(&conf); err != nil {